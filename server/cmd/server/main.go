@@ -11,19 +11,32 @@ import (
 	"time"
 
 	"github.com/fle/server/internal/config"
+	"github.com/fle/server/internal/jsonrpc"
+	"github.com/fle/server/internal/logger"
 	"github.com/fle/server/internal/server"
 )
 
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
+	// Load configuration; CONFIG_FILE names an env file that SIGHUP re-reads
+	configFile := os.Getenv("CONFIG_FILE")
+	cfg, err := config.LoadFile(configFile)
 	if err != nil {
 		log.Printf("Failed to load configuration: %v", err)
 		os.Exit(1)
 	}
 
-	// Set up structured logging
-	logger := setupLogger(cfg)
+	// Identify this instance in every log record and in server.status
+	if cfg.InstanceID == "" {
+		cfg.InstanceID = server.NewInstanceID()
+	}
+
+	// Set up structured logging; the level variable lets SIGHUP change the level
+	logLevel := new(slog.LevelVar)
+	logger, err := setupLogger(cfg, logLevel)
+	if err != nil {
+		log.Printf("Failed to set up logging: %v", err)
+		os.Exit(1)
+	}
 
 	logger.Info("FLE Server starting",
 		"address", cfg.Address(),
@@ -38,6 +51,18 @@ func main() {
 		logger.Error("Failed to create server", "error", err)
 		os.Exit(1)
 	}
+	srv.SetLogLevelVar(logLevel)
+
+	// Reload hot-reloadable configuration on SIGHUP
+	go func() {
+		hupChan := make(chan os.Signal, 1)
+		signal.Notify(hupChan, syscall.SIGHUP)
+
+		for range hupChan {
+			logger.Info("Received SIGHUP, reloading configuration")
+			reloadConfig(srv, logger, configFile)
+		}
+	}()
 
 	// Set up graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -84,20 +109,51 @@ func main() {
 	}
 }
 
+// reloadConfig re-reads the configuration from the env file at configFile and
+// applies its hot-reloadable settings. The process environment cannot change
+// after startup, so without a config file a reload only reapplies what is
+// already in effect. An invalid configuration is logged and the current one
+// kept.
+func reloadConfig(srv *server.Server, logger *slog.Logger, configFile string) {
+	if configFile == "" {
+		logger.Warn("No CONFIG_FILE set, reloading from the unchanged environment")
+	}
+	cfg, err := config.LoadFile(configFile)
+	if err != nil {
+		logger.Error("Failed to reload configuration, keeping current settings", "error", err)
+		return
+	}
+	srv.Reload(cfg)
+}
+
 // setupLogger creates and configures a structured logger based on the configuration.
-func setupLogger(cfg *config.Config) *slog.Logger {
+// Logs go to stderr unless LOG_OUTPUT selects a rotating log file. The logger's
+// level is read from level, which is set to the configured level, so it can be
+// changed later without rebuilding the logger. Every record carries the
+// configured instance ID, if any, as the instance_id attribute.
+func setupLogger(cfg *config.Config, level *slog.LevelVar) (*slog.Logger, error) {
+	level.Set(cfg.LogLevelSlog())
 	opts := &slog.HandlerOptions{
-		Level: cfg.LogLevelSlog(),
+		Level: level,
+	}
+
+	output, err := logger.NewOutput(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	var handler slog.Handler
-	if cfg.IsDevelopment() {
+	if !cfg.UseJSONLogs(!cfg.IsDevelopment()) {
 		// Use text handler for better readability in development
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		handler = slog.NewTextHandler(output, opts)
 	} else {
 		// Use JSON handler for production
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		handler = slog.NewJSONHandler(output, opts)
 	}
 
-	return slog.New(handler)
+	base := slog.New(logger.NewContextHandler(handler, jsonrpc.SessionCodeAttr))
+	if cfg.InstanceID != "" {
+		base = base.With("instance_id", cfg.InstanceID)
+	}
+	return base, nil
 }
@@ -1,20 +1,30 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/fle/server/internal/config"
 	"github.com/fle/server/internal/jsonrpc"
+	"github.com/fle/server/internal/logger"
 	"github.com/fle/server/internal/server"
+	"github.com/fle/server/internal/session"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -26,11 +36,20 @@ type testServer struct {
 	httpServer *httptest.Server
 	url        string
 	wsURL      string
+	logLevel   *slog.LevelVar
+	logger     *logger.Logger
 }
 
 func setupTestServer(t *testing.T) *testServer {
-	// Set test environment variables
-	os.Setenv("ENV", "test")
+	return setupTestServerWithStore(t, nil)
+}
+
+// setupTestServerWithStore is setupTestServer with sessions persisted to store.
+func setupTestServerWithStore(t *testing.T, store session.Store) *testServer {
+	// Set test environment variables; tests may select another ENV with t.Setenv
+	if _, ok := os.LookupEnv("ENV"); !ok {
+		os.Setenv("ENV", "test")
+	}
 	os.Setenv("LOG_LEVEL", "error") // Reduce log noise during tests
 	
 	// Load test configuration
@@ -42,8 +61,12 @@ func setupTestServer(t *testing.T) *testServer {
 	cfg.Port = 0 // Let httptest choose a free port
 
 	// Create server instance
-	srv, err := server.NewServer(cfg, setupLogger(cfg))
+	logLevel := new(slog.LevelVar)
+	baseLogger, err := setupLogger(cfg, logLevel)
+	require.NoError(t, err, "Failed to set up logger")
+	srv, err := server.NewServerWithStore(cfg, baseLogger, store)
 	require.NoError(t, err, "Failed to create server")
+	srv.SetLogLevelVar(logLevel)
 
 	// Create test HTTP server
 	httpServer := httptest.NewServer(srv.Handler())
@@ -57,6 +80,8 @@ func setupTestServer(t *testing.T) *testServer {
 		httpServer: httpServer,
 		url:        serverURL,
 		wsURL:      wsURL,
+		logLevel:   logLevel,
+		logger:     &logger.Logger{Logger: baseLogger},
 	}
 }
 
@@ -147,12 +172,22 @@ func TestSessionCreationAndRestoration(t *testing.T) {
 
 	sessionCode := welcome["session_code"].(string)
 	assert.NotEmpty(t, sessionCode, "Session code should not be empty")
+	reconnectToken := welcome["reconnect_token"].(string)
+	assert.NotEmpty(t, reconnectToken, "Reconnect token should not be empty")
 
 	// Close first connection
 	conn1.Close()
 
+	// The code alone does not restore a session a token was issued for
+	_, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws?session="+sessionCode, nil)
+	require.Error(t, err, "Restoring without the reconnect token should fail")
+	require.NotNil(t, resp)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, 1, ts.server.SessionManager().GetSessionCount(), "A refused restore should not create a session")
+
 	// Second connection - restore existing session
-	wsURLWithSession := fmt.Sprintf("%s/ws?session=%s", ts.wsURL, sessionCode)
+	wsURLWithSession := fmt.Sprintf("%s/ws?session=%s&reconnect_token=%s", ts.wsURL, sessionCode, reconnectToken)
 	conn2, _, err := websocket.DefaultDialer.Dial(wsURLWithSession, nil)
 	require.NoError(t, err, "Failed to reconnect with session code")
 	defer conn2.Close()
@@ -583,6 +618,45 @@ func TestMessageBroadcasting(t *testing.T) {
 	assert.GreaterOrEqual(t, totalSessions, float64(2), "Should have at least 2 active connections")
 }
 
+// TestGetSessionInfoTruncatesSessionCodes tests that getSessionInfo lists at
+// most SESSION_INFO_MAX_CODES session codes and reports the full count.
+func TestGetSessionInfoTruncatesSessionCodes(t *testing.T) {
+	t.Setenv("SESSION_INFO_MAX_CODES", "3")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	const sessions = 8
+	conns := make([]*websocket.Conn, 0, sessions)
+	for i := 0; i < sessions; i++ {
+		conn, _ := dialWebSocket(t, ts, "")
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	require.Eventually(t, func() bool {
+		return len(ts.server.Hub().GetSessionCodes()) == sessions
+	}, 2*time.Second, 10*time.Millisecond)
+
+	response := callJSONRPC(t, conns[0], 1, "getSessionInfo", nil)
+	require.Nil(t, response.Error, "getSessionInfo should not return error")
+
+	result, ok := response.Result.(map[string]interface{})
+	require.True(t, ok, "Result should be a map, got %T", response.Result)
+	assert.Len(t, result["activeSessions"], 3)
+	assert.Equal(t, float64(sessions), result["activeSessionCount"])
+	assert.Equal(t, true, result["truncated"])
+
+	// Lifting the cap lists every session
+	t.Setenv("SESSION_INFO_MAX_CODES", "0")
+	newCfg, err := config.Load()
+	require.NoError(t, err)
+	ts.server.Reload(newCfg)
+
+	response = callJSONRPC(t, conns[0], 2, "getSessionInfo", nil)
+	result = response.Result.(map[string]interface{})
+	assert.Len(t, result["activeSessions"], sessions)
+	assert.Equal(t, false, result["truncated"])
+}
+
 // TestGracefulShutdown tests server shutdown behavior
 func TestGracefulShutdown(t *testing.T) {
 	ts := setupTestServer(t)
@@ -640,3 +714,2270 @@ func TestGracefulShutdown(t *testing.T) {
 	}
 }
 
+// TestStopDrainsInFlightRequest tests that Stop refuses new connections,
+// notifies connected clients and answers a request already in flight before
+// closing the connection
+func TestStopDrainsInFlightRequest(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	err := ts.server.JSONRPCRouter().RegisterSimpleMethod("test.slow", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		close(started)
+		<-release
+		return "finished", nil
+	}, "Blocks until released")
+	require.NoError(t, err)
+
+	conn, _ := dialWebSocket(t, ts, "")
+	defer conn.Close()
+
+	request, err := json.Marshal(jsonrpc.Request{JSONRPCVersion: "2.0", ID: 1, Method: "test.slow"})
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, request))
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The handler should have started")
+	}
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- ts.server.Stop(ctx)
+	}()
+
+	// New connections are refused while the request is in flight
+	require.Eventually(t, func() bool {
+		refused, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", nil)
+		if refused != nil {
+			refused.Close()
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return err != nil && resp != nil && resp.StatusCode == http.StatusServiceUnavailable
+	}, 5*time.Second, 10*time.Millisecond, "Upgrades should be refused once Stop begins")
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop should wait for the in-flight request, returned %v", err)
+	default:
+	}
+	close(release)
+
+	// The shutdown notice, then the response, then the close frame
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err, "Failed to read shutdown notification")
+	var notice jsonrpc.Request
+	require.NoError(t, json.Unmarshal(message, &notice))
+	assert.Equal(t, "server.shutdown", notice.Method)
+
+	_, message, err = conn.ReadMessage()
+	require.NoError(t, err, "The in-flight request should be answered before the connection closes")
+	var response jsonrpc.Response
+	require.NoError(t, json.Unmarshal(message, &response))
+	assert.Equal(t, float64(1), response.ID)
+	assert.Equal(t, "finished", response.Result)
+
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "Expected a going away close, got %v", err)
+	require.NoError(t, <-stopped)
+}
+
+// dialWebSocket connects to the test server's WebSocket endpoint with the given
+// raw query string, reads the welcome message, and returns the connection and
+// the session code from the welcome message.
+func dialWebSocket(t *testing.T, ts *testServer, query string) (*websocket.Conn, string) {
+	t.Helper()
+	conn, code, _ := dialSession(t, ts, query)
+	return conn, code
+}
+
+// dialSession is dialWebSocket that also returns the reconnect token from the
+// welcome message, which restoring a newly created session requires.
+func dialSession(t *testing.T, ts *testServer, query string) (*websocket.Conn, string, string) {
+	t.Helper()
+	return dialSessionWithHeader(t, ts, query, nil)
+}
+
+// dialAdmin is dialSession presenting the test admin token as a bearer token.
+func dialAdmin(t *testing.T, ts *testServer, query string) (*websocket.Conn, string, string) {
+	t.Helper()
+	return dialSessionWithHeader(t, ts, query, bearerHeader("test-admin-token"))
+}
+
+// bearerHeader returns request headers presenting token as a bearer token.
+func bearerHeader(token string) http.Header {
+	return http.Header{"Authorization": []string{"Bearer " + token}}
+}
+
+// dialSessionWithHeader is dialSession sending the given upgrade request headers.
+func dialSessionWithHeader(t *testing.T, ts *testServer, query string, header http.Header) (*websocket.Conn, string, string) {
+	t.Helper()
+
+	wsURL := ts.wsURL + "/ws"
+	if query != "" {
+		wsURL += "?" + query
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	require.NoError(t, err, "Failed to connect to WebSocket")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err, "Failed to read welcome message")
+
+	var welcome map[string]interface{}
+	require.NoError(t, json.Unmarshal(message, &welcome), "Failed to unmarshal welcome message")
+	require.Equal(t, "welcome", welcome["type"], "First message should be the welcome message")
+
+	token, _ := welcome["reconnect_token"].(string)
+	return conn, welcome["session_code"].(string), token
+}
+
+// callJSONRPC sends a JSON-RPC request over the connection and returns the response.
+func callJSONRPC(t *testing.T, conn *websocket.Conn, id int, method string, params interface{}) jsonrpc.Response {
+	t.Helper()
+
+	request, err := jsonrpc.NewRequest(method, params, id)
+	require.NoError(t, err, "Failed to build request")
+
+	requestBytes, err := json.Marshal(request)
+	require.NoError(t, err, "Failed to marshal request")
+
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, requestBytes), "Failed to send request")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, responseBytes, err := conn.ReadMessage()
+	require.NoError(t, err, "Failed to read response")
+
+	var response jsonrpc.Response
+	require.NoError(t, json.Unmarshal(responseBytes, &response), "Failed to unmarshal response")
+	require.Equal(t, float64(id), response.ID, "Response should have matching ID")
+
+	return response
+}
+
+// TestRoomList tests that room.list reflects the rooms joined and left by the caller
+func TestRoomList(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, _ := dialWebSocket(t, ts, "")
+	defer conn.Close()
+
+	for i, room := range []string{"lobby", "game-1"} {
+		response := callJSONRPC(t, conn, i+1, "room.join", map[string]string{"room": room})
+		require.Nil(t, response.Error, "room.join should succeed")
+	}
+
+	response := callJSONRPC(t, conn, 3, "room.list", nil)
+	require.Nil(t, response.Error, "room.list should succeed")
+	result := response.Result.(map[string]interface{})
+	assert.ElementsMatch(t, []interface{}{"lobby", "game-1"}, result["rooms"], "Should list both joined rooms")
+
+	response = callJSONRPC(t, conn, 4, "room.leave", map[string]string{"room": "lobby"})
+	require.Nil(t, response.Error, "room.leave should succeed")
+
+	response = callJSONRPC(t, conn, 5, "room.list", nil)
+	require.Nil(t, response.Error, "room.list should succeed")
+	result = response.Result.(map[string]interface{})
+	assert.Equal(t, []interface{}{"game-1"}, result["rooms"], "Should only list the remaining room")
+
+	// Room names are validated
+	response = callJSONRPC(t, conn, 6, "room.join", map[string]string{"room": ""})
+	require.NotNil(t, response.Error, "Empty room name should be rejected")
+	assert.Equal(t, jsonrpc.InvalidParams, response.Error.Code)
+}
+
+// TestMaxConnectionsEnforced tests that connections beyond MAX_CONNECTIONS are
+// closed with a try-again-later frame while existing connections keep working
+func TestMaxConnectionsEnforced(t *testing.T) {
+	t.Setenv("MAX_CONNECTIONS", "1")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, _ := dialWebSocket(t, ts, "")
+	defer conn.Close()
+
+	rejected, _, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", nil)
+	require.NoError(t, err, "Upgrade should succeed before the hub rejects the client")
+	defer rejected.Close()
+
+	rejected.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = rejected.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr, "Connection over the limit should be closed")
+	assert.Equal(t, websocket.CloseTryAgainLater, closeErr.Code)
+
+	response := callJSONRPC(t, conn, 1, "echo", "still here")
+	assert.Nil(t, response.Error, "Existing connection should keep working")
+}
+
+// TestMaxSessionsEnforced tests that an upgrade needing a new session beyond
+// MAX_SESSIONS is rejected with 503 and that server.status reports the cap
+func TestMaxSessionsEnforced(t *testing.T) {
+	t.Setenv("MAX_SESSIONS", "2")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	first, _ := dialWebSocket(t, ts, "")
+	defer first.Close()
+	second, _ := dialWebSocket(t, ts, "")
+	defer second.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", nil)
+	require.Error(t, err, "Upgrade beyond the session cap should fail")
+	require.NotNil(t, resp)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	response := callJSONRPC(t, first, 1, "server.status", nil)
+	require.Nil(t, response.Error, "server.status should succeed")
+	sessions := response.Result.(map[string]interface{})["sessions"].(map[string]interface{})
+	assert.Equal(t, float64(2), sessions["current"])
+	assert.Equal(t, float64(2), sessions["max"])
+}
+
+// TestMaxSessionsPerPrincipalEnforced tests that an authenticated principal
+// cannot hold more than MAX_SESSIONS_PER_PRINCIPAL sessions while anonymous
+// clients are not capped
+func TestMaxSessionsPerPrincipalEnforced(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-admin-token")
+	t.Setenv("MAX_SESSIONS_PER_PRINCIPAL", "1")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	admin, adminCode, adminToken := dialAdmin(t, ts, "")
+	defer admin.Close()
+	assert.Equal(t, 1, ts.server.SessionManager().SessionCountByOwner("admin"))
+
+	_, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", bearerHeader("test-admin-token"))
+	require.Error(t, err, "Upgrade beyond the principal's session cap should fail")
+	require.NotNil(t, resp)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	// Restoring the principal's existing session needs no new one
+	restored, restoredCode, _ := dialAdmin(t, ts, "session="+adminCode+"&reconnect_token="+adminToken)
+	defer restored.Close()
+	assert.Equal(t, adminCode, restoredCode)
+
+	first, _ := dialWebSocket(t, ts, "")
+	defer first.Close()
+	second, _ := dialWebSocket(t, ts, "")
+	defer second.Close()
+}
+
+// TestReadyzReflectsOverload tests that /readyz fails at MAX_CONNECTIONS and
+// recovers once the load drops below the recovery threshold
+func TestReadyzReflectsOverload(t *testing.T) {
+	t.Setenv("MAX_CONNECTIONS", "2")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	readyStatus := func() int {
+		resp, err := http.Get(ts.url + "/readyz")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, readyStatus(), "Idle server should be ready")
+
+	first, _ := dialWebSocket(t, ts, "")
+	defer first.Close()
+	second, _ := dialWebSocket(t, ts, "")
+
+	assert.Equal(t, http.StatusServiceUnavailable, readyStatus(), "Server at MAX_CONNECTIONS should not be ready")
+
+	second.Close()
+	assert.Eventually(t, func() bool {
+		return readyStatus() == http.StatusOK
+	}, 5*time.Second, 50*time.Millisecond, "Readiness should recover after load drops")
+}
+
+// TestAdminGetSession tests admin.getSession lookups and that non-admins are denied
+func TestAdminGetSession(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-admin-token")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	user, userCode := dialWebSocket(t, ts, "")
+	defer user.Close()
+	admin, _, _ := dialAdmin(t, ts, "")
+	defer admin.Close()
+
+	// Authorized lookup of a connected session
+	response := callJSONRPC(t, admin, 1, "admin.getSession", map[string]interface{}{
+		"code":        userCode,
+		"includeData": true,
+	})
+	require.Nil(t, response.Error, "admin.getSession should succeed for admins")
+	result := response.Result.(map[string]interface{})
+	assert.Equal(t, userCode, result["code"])
+	assert.Equal(t, true, result["connected"])
+	assert.Contains(t, result, "data", "Admins requesting data should receive it")
+
+	// Unknown code
+	response = callJSONRPC(t, admin, 2, "admin.getSession", map[string]string{"code": "missing-session-1"})
+	require.NotNil(t, response.Error, "Unknown codes should fail")
+	assert.Equal(t, jsonrpc.ResourceNotFound, response.Error.Code)
+
+	// Non-admin callers are denied
+	response = callJSONRPC(t, user, 3, "admin.getSession", map[string]string{"code": userCode})
+	require.NotNil(t, response.Error, "Non-admins should be denied")
+	assert.Equal(t, jsonrpc.Unauthorized, response.Error.Code)
+
+	// Tokens in the query string are ignored, so they do not end up in logs
+	queried, _ := dialWebSocket(t, ts, "token=test-admin-token")
+	defer queried.Close()
+	response = callJSONRPC(t, queried, 4, "admin.getSession", map[string]string{"code": userCode})
+	require.NotNil(t, response.Error, "A query string token should not grant admin")
+	assert.Equal(t, jsonrpc.Unauthorized, response.Error.Code)
+
+	// Unrecognized tokens are rejected before the upgrade
+	_, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", bearerHeader("wrong"))
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+// TestAdminSessionKind tests that admin connections get admin sessions that
+// only admins can restore
+func TestAdminSessionKind(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-admin-token")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	admin, adminCode, adminToken := dialAdmin(t, ts, "")
+	admin.Close()
+	user, userCode := dialWebSocket(t, ts, "")
+	user.Close()
+
+	manager := ts.server.SessionManager()
+	assert.Equal(t, []string{adminCode}, manager.SessionsByKind("admin"))
+	assert.Equal(t, []string{userCode}, manager.SessionsByKind(session.DefaultSessionKind))
+
+	// Anonymous clients cannot take over an admin session
+	conn, code := dialWebSocket(t, ts, "session="+adminCode+"&reconnect_token="+adminToken)
+	conn.Close()
+	assert.NotEqual(t, adminCode, code)
+
+	// Admins can restore it
+	conn, code, _ = dialAdmin(t, ts, "session="+adminCode+"&reconnect_token="+adminToken)
+	conn.Close()
+	assert.Equal(t, adminCode, code)
+}
+
+// TestMaxSessionReconnects tests that a session can be restored up to
+// MAX_SESSION_RECONNECTS times and is replaced by a new session after that
+func TestMaxSessionReconnects(t *testing.T) {
+	t.Setenv("MAX_SESSION_RECONNECTS", "2")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, sessionCode, token := dialSession(t, ts, "")
+	conn.Close()
+
+	for i := 0; i < 2; i++ {
+		conn, code := dialWebSocket(t, ts, "session="+sessionCode+"&reconnect_token="+token)
+		conn.Close()
+		assert.Equal(t, sessionCode, code, "Reconnect %d should restore the session", i+1)
+	}
+
+	conn, code := dialWebSocket(t, ts, "session="+sessionCode+"&reconnect_token="+token)
+	defer conn.Close()
+	assert.NotEqual(t, sessionCode, code, "Reconnecting past the cap should force a new session")
+
+	_, err := ts.server.SessionManager().PeekSession(sessionCode)
+	assert.ErrorIs(t, err, session.ErrSessionNotFound, "The old session should be invalidated")
+}
+
+// TestSessionDisconnectPolicy tests that a session records when its last
+// connection closed, and that the notify policy tells the other clients
+func TestSessionDisconnectPolicy(t *testing.T) {
+	t.Setenv("SESSION_DISCONNECT_POLICY", "notify")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	observer, _ := dialWebSocket(t, ts, "")
+	defer observer.Close()
+	outsider, _ := dialWebSocket(t, ts, "")
+	defer outsider.Close()
+
+	conn, sessionCode, token := dialSession(t, ts, "")
+	response := callJSONRPC(t, conn, 1, "room.join", map[string]string{"room": "lobby"})
+	require.Nil(t, response.Error, "room.join should succeed")
+	response = callJSONRPC(t, observer, 1, "room.join", map[string]string{"room": "lobby"})
+	require.Nil(t, response.Error, "room.join should succeed")
+	conn.Close()
+
+	manager := ts.server.SessionManager()
+	require.Eventually(t, func() bool {
+		sess, err := manager.PeekSession(sessionCode)
+		return err == nil && !sess.DisconnectedAt.IsZero()
+	}, 2*time.Second, 10*time.Millisecond, "disconnected_at should be set when the last connection drops")
+
+	observer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := observer.ReadMessage()
+	require.NoError(t, err, "Should receive a session.disconnected notification")
+
+	var notification jsonrpc.Request
+	require.NoError(t, json.Unmarshal(message, &notification))
+	assert.Equal(t, "session.disconnected", notification.Method)
+	var params map[string]interface{}
+	require.NoError(t, json.Unmarshal(notification.Params, &params))
+	assert.Equal(t, sessionCode, params["session_code"])
+
+	// Clients that share no room with the session are not told
+	outsider.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err = outsider.ReadMessage()
+	assert.Error(t, err, "A client outside the session's rooms should not learn its code")
+
+	// Reconnecting clears the mark
+	conn, _ = dialWebSocket(t, ts, "session="+sessionCode+"&reconnect_token="+token)
+	defer conn.Close()
+	sess, err := manager.PeekSession(sessionCode)
+	require.NoError(t, err)
+	assert.True(t, sess.DisconnectedAt.IsZero(), "disconnected_at should be cleared on reconnect")
+}
+
+// TestSessionUpdatedNotification tests that connected clients are notified
+// with the changed keys when their session data changes
+func TestSessionUpdatedNotification(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, sessionCode := dialWebSocket(t, ts, "")
+	defer conn.Close()
+
+	err := ts.server.SessionManager().UpdateSessionData(sessionCode, map[string]interface{}{
+		"score": 42,
+		"level": 3,
+	})
+	require.NoError(t, err)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err, "Should receive a session.updated notification")
+
+	var notification jsonrpc.Request
+	require.NoError(t, json.Unmarshal(message, &notification))
+	assert.Equal(t, "session.updated", notification.Method)
+	assert.Nil(t, notification.ID, "session.updated should be a notification")
+
+	var params struct {
+		SessionCode string   `json:"session_code"`
+		Keys        []string `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(notification.Params, &params))
+	assert.Equal(t, sessionCode, params.SessionCode)
+	assert.Equal(t, []string{"level", "score"}, params.Keys)
+}
+
+// TestOriginCheckByEnvironment tests that a disallowed origin may connect in
+// development but is rejected in production, where the allowlist is enforced
+func TestOriginCheckByEnvironment(t *testing.T) {
+	header := http.Header{"Origin": []string{"https://evil.example.com"}}
+
+	t.Run("development", func(t *testing.T) {
+		t.Setenv("ENV", "development")
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		conn, _, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", header)
+		require.NoError(t, err, "Development should accept any origin")
+		conn.Close()
+	})
+
+	t.Run("production", func(t *testing.T) {
+		t.Setenv("ENV", "production")
+		t.Setenv("ALLOWED_ORIGINS", "https://app.example.com")
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		_, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", header)
+		require.Error(t, err, "Production should reject a disallowed origin")
+		require.NotNil(t, resp)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		assert.Equal(t, 0, ts.server.SessionManager().GetSessionCount(), "No session should be created")
+
+		allowed := http.Header{"Origin": []string{"https://app.example.com"}}
+		conn, _, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", allowed)
+		require.NoError(t, err, "Production should accept an allowlisted origin")
+		conn.Close()
+	})
+}
+
+// TestWebSocketPreflight tests how OPTIONS /ws is answered under each
+// WS_PREFLIGHT setting, and that a plain GET is still refused with 400
+func TestWebSocketPreflight(t *testing.T) {
+	preflight := func(t *testing.T, ts *testServer, origin string) *http.Response {
+		req, err := http.NewRequest(http.MethodOptions, ts.url+"/ws", nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	for _, env := range []string{"production", "development"} {
+		t.Run("reject in "+env, func(t *testing.T) {
+			// The development CORS middleware must not answer it first
+			t.Setenv("ENV", env)
+			ts := setupTestServer(t)
+			defer ts.Close()
+
+			resp := preflight(t, ts, "http://localhost:3000")
+			assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+			assert.Equal(t, "GET", resp.Header.Get("Allow"))
+		})
+	}
+
+	t.Run("allow", func(t *testing.T) {
+		t.Setenv("ENV", "production")
+		t.Setenv("WS_PREFLIGHT", "allow")
+		t.Setenv("ALLOWED_ORIGINS", "https://app.example.com")
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		resp := preflight(t, ts, "https://app.example.com")
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET", resp.Header.Get("Access-Control-Allow-Methods"))
+
+		// Disallowed origins get no CORS headers
+		resp = preflight(t, ts, "https://evil.example.com")
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("plain GET", func(t *testing.T) {
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		resp, err := http.Get(ts.url + "/ws")
+		require.NoError(t, err)
+		var body struct {
+			Error jsonrpc.Error `json:"error"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, jsonrpc.InvalidRequest, body.Error.Code)
+		assert.Equal(t, 0, ts.server.SessionManager().GetSessionCount())
+	})
+}
+
+// TestAdminListSessionsPagination tests that following nextCursor returns every
+// session exactly once and that out-of-range limits are rejected
+func TestAdminListSessionsPagination(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-admin-token")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	admin, _, _ := dialAdmin(t, ts, "")
+	defer admin.Close()
+
+	for i := 0; i < 6; i++ {
+		_, err := ts.server.SessionManager().CreateSession(context.Background(), nil)
+		require.NoError(t, err)
+	}
+	expected := ts.server.SessionManager().GetSessionCount()
+
+	seen := make(map[string]bool)
+	params := map[string]interface{}{"limit": 2}
+	for id := 1; ; id++ {
+		require.Less(t, id, 10, "Pagination should terminate")
+		response := callJSONRPC(t, admin, id, "admin.listSessions", params)
+		require.Nil(t, response.Error, "admin.listSessions should succeed")
+		page := response.Result.(map[string]interface{})
+		assert.Equal(t, float64(expected), page["total"])
+
+		items := page["items"].([]interface{})
+		assert.LessOrEqual(t, len(items), 2)
+		for _, item := range items {
+			code := item.(map[string]interface{})["code"].(string)
+			assert.False(t, seen[code], "Session %s returned twice", code)
+			seen[code] = true
+		}
+
+		cursor, _ := page["nextCursor"].(string)
+		if cursor == "" {
+			break
+		}
+		params["cursor"] = cursor
+	}
+	assert.Len(t, seen, expected)
+
+	response := callJSONRPC(t, admin, 20, "admin.listSessions", map[string]interface{}{"limit": jsonrpc.MaxPageLimit + 1})
+	require.NotNil(t, response.Error, "Limits above the maximum should be rejected")
+	assert.Equal(t, jsonrpc.InvalidParams, response.Error.Code)
+}
+
+// TestReconnectWithTitleCaseCode tests that a session code whose case was
+// changed (e.g. "Happy-Panda-42") restores the original session
+func TestReconnectWithTitleCaseCode(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	first, sessionCode, token := dialSession(t, ts, "")
+	first.Close()
+
+	parts := strings.Split(sessionCode, "-")
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	titleCode := strings.Join(parts, "-")
+	require.NotEqual(t, sessionCode, titleCode)
+
+	second, restoredCode := dialWebSocket(t, ts, "session="+titleCode+"&reconnect_token="+token)
+	defer second.Close()
+
+	assert.Equal(t, sessionCode, restoredCode, "Title-cased code should restore the same session")
+	assert.Equal(t, 1, ts.server.SessionManager().GetSessionCount(), "No new session should be created")
+}
+
+// postRPC sends body to POST /rpc with the given Content-Encoding.
+func postRPC(t *testing.T, ts *testServer, encoding string, body []byte) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, ts.url+"/rpc", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// gzipBytes compresses data with gzip.
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// TestRPCEndpointGzip tests gzip-encoded requests to POST /rpc, including
+// rejection of unsupported encodings and of bodies that decompress too large
+func TestRPCEndpointGzip(t *testing.T) {
+	t.Setenv("RPC_MAX_BODY_BYTES", "4096")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	ping := []byte(`{"jsonrpc":"2.0","method":"ping","id":1}`)
+
+	t.Run("gzipped ping", func(t *testing.T) {
+		resp := postRPC(t, ts, "gzip", gzipBytes(t, ping))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var response jsonrpc.Response
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		assert.Nil(t, response.Error)
+		assert.NotNil(t, response.Result)
+	})
+
+	t.Run("plain ping", func(t *testing.T) {
+		resp := postRPC(t, ts, "", ping)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("unsupported encoding", func(t *testing.T) {
+		resp := postRPC(t, ts, "br", ping)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	})
+
+	t.Run("decompression bomb", func(t *testing.T) {
+		// Pad a valid request with whitespace so it compresses far below
+		// the limit but expands well past it
+		padded := append(bytes.Repeat([]byte(" "), 1<<20), ping...)
+		compressed := gzipBytes(t, padded)
+		require.Less(t, len(compressed), 4096)
+
+		resp := postRPC(t, ts, "gzip", compressed)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+}
+
+// TestRPCEndpointGzipDisabled tests that RPC_ACCEPT_GZIP=false rejects gzip bodies
+func TestRPCEndpointGzipDisabled(t *testing.T) {
+	t.Setenv("RPC_ACCEPT_GZIP", "false")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp := postRPC(t, ts, "gzip", gzipBytes(t, []byte(`{"jsonrpc":"2.0","method":"ping","id":1}`)))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+// postRPCWithContentType sends body to POST /rpc with the given Content-Type,
+// or none if contentType is empty.
+func postRPCWithContentType(t *testing.T, ts *testServer, contentType string, body []byte) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, ts.url+"/rpc", bytes.NewReader(body))
+	require.NoError(t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// TestRPCEndpointContentType tests that POST /rpc only accepts JSON content
+// types in strict mode, the default, and any content type in lenient mode
+func TestRPCEndpointContentType(t *testing.T) {
+	ping := []byte(`{"jsonrpc":"2.0","method":"ping","id":1}`)
+
+	t.Run("strict", func(t *testing.T) {
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		for _, contentType := range []string{"application/json", "application/json; charset=utf-8", "application/json-rpc"} {
+			resp := postRPCWithContentType(t, ts, contentType, ping)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode, "%s should be accepted", contentType)
+		}
+
+		for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded", "multipart/form-data; boundary=x"} {
+			resp := postRPCWithContentType(t, ts, contentType, ping)
+			assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode, "%q should be rejected", contentType)
+
+			var response jsonrpc.Response
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+			resp.Body.Close()
+			require.NotNil(t, response.Error)
+			assert.Equal(t, jsonrpc.InvalidRequest, response.Error.Code)
+		}
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		t.Setenv("RPC_CONTENT_TYPE_MODE", "lenient")
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		for _, contentType := range []string{"", "text/plain", "application/json"} {
+			resp := postRPCWithContentType(t, ts, contentType, ping)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode, "%q should be accepted", contentType)
+		}
+	})
+}
+
+// TestResponseGzip tests that HTTP responses over GZIP_MIN_SIZE are gzipped
+// for clients accepting gzip, and that smaller ones and upgrades are not
+func TestResponseGzip(t *testing.T) {
+	t.Setenv("GZIP_MIN_SIZE", "512")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	// Setting Accept-Encoding explicitly turns off the client's transparent
+	// decompression, so the encoding can be checked
+	fetch := func(path string, body []byte) *http.Response {
+		method := http.MethodGet
+		if body != nil {
+			method = http.MethodPost
+		}
+		req, err := http.NewRequest(method, ts.url+path, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("large response", func(t *testing.T) {
+		message := strings.Repeat("x", 4096)
+		request := fmt.Sprintf(`{"jsonrpc":"2.0","method":"echo","params":{"message":%q},"id":1}`, message)
+		resp := fetch("/rpc", []byte(request))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		assert.Contains(t, resp.Header.Values("Vary"), "Accept-Encoding")
+
+		gz, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+		var response jsonrpc.Response
+		require.NoError(t, json.NewDecoder(gz).Decode(&response))
+		require.Nil(t, response.Error)
+		assert.Contains(t, fmt.Sprint(response.Result), message)
+	})
+
+	t.Run("small response", func(t *testing.T) {
+		resp := fetch("/health", nil)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+
+		var health map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&health))
+	})
+
+	t.Run("websocket upgrade", func(t *testing.T) {
+		header := http.Header{"Accept-Encoding": []string{"gzip"}}
+		conn, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", header)
+		require.NoError(t, err)
+		defer conn.Close()
+		assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	})
+}
+
+// TestRPCEndpointStreaming tests that partial results sent with
+// jsonrpc.SendPartial arrive as NDJSON lines before the final response
+func TestRPCEndpointStreaming(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	err := ts.server.JSONRPCRouter().RegisterSimpleMethod("test.count", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		for i := 1; i <= 3; i++ {
+			if err := jsonrpc.SendPartial(ctx, i); err != nil {
+				return nil, err
+			}
+		}
+		return "done", nil
+	}, "Streams three partial counts")
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, ts.url+"/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"test.count","id":7}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	var lines []map[string]interface{}
+	decoder := json.NewDecoder(resp.Body)
+	for decoder.More() {
+		var line map[string]interface{}
+		require.NoError(t, decoder.Decode(&line))
+		lines = append(lines, line)
+	}
+
+	require.Len(t, lines, 4, "Expected three partial lines and the final response")
+	for i, line := range lines[:3] {
+		assert.Equal(t, float64(i+1), line["partial"])
+		assert.Equal(t, float64(7), line["id"])
+	}
+	assert.Equal(t, "done", lines[3]["result"])
+	assert.Equal(t, float64(7), lines[3]["id"])
+
+	// Without the streaming Accept header the handler cannot stream
+	resp = postRPC(t, ts, "", []byte(`{"jsonrpc":"2.0","method":"test.count","id":8}`))
+	defer resp.Body.Close()
+	var response jsonrpc.Response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	require.NotNil(t, response.Error, "SendPartial should fail on a non-streaming request")
+}
+
+// TestAdminDisconnect tests closing a connected session with a reason and
+// disconnecting (and deleting) a session with no live connection
+func TestAdminDisconnect(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-admin-token")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	user, userCode := dialWebSocket(t, ts, "")
+	defer user.Close()
+	admin, _, _ := dialAdmin(t, ts, "")
+	defer admin.Close()
+
+	// Non-admin callers are denied
+	response := callJSONRPC(t, user, 1, "admin.disconnect", map[string]string{"code": userCode})
+	require.NotNil(t, response.Error, "Non-admins should be denied")
+	assert.Equal(t, jsonrpc.Unauthorized, response.Error.Code)
+
+	// Connected session: the client receives the close frame with the reason
+	response = callJSONRPC(t, admin, 2, "admin.disconnect", map[string]string{"code": userCode, "reason": "spamming"})
+	require.Nil(t, response.Error, "admin.disconnect should succeed for admins")
+	result := response.Result.(map[string]interface{})
+	assert.Equal(t, true, result["disconnected"])
+	assert.Equal(t, false, result["deleted"])
+
+	user.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := user.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+	assert.Equal(t, "spamming", closeErr.Text)
+
+	_, err = ts.server.SessionManager().PeekSession(userCode)
+	assert.NoError(t, err, "The session should survive without deleteSession")
+
+	// Session without a live connection, deleted on request
+	idle, err := ts.server.SessionManager().CreateSession(context.Background(), nil)
+	require.NoError(t, err)
+
+	response = callJSONRPC(t, admin, 3, "admin.disconnect", map[string]interface{}{"code": idle.Code, "deleteSession": true})
+	require.Nil(t, response.Error)
+	result = response.Result.(map[string]interface{})
+	assert.Equal(t, false, result["disconnected"])
+	assert.Equal(t, true, result["deleted"])
+
+	_, err = ts.server.SessionManager().PeekSession(idle.Code)
+	assert.Error(t, err, "The session should be deleted")
+}
+
+// TestEventSubscriptions tests that a connection subscribed to other event
+// types no longer receives session.updated notifications
+func TestEventSubscriptions(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	subscribed, subscribedCode := dialWebSocket(t, ts, "")
+	defer subscribed.Close()
+	other, otherCode := dialWebSocket(t, ts, "")
+	defer other.Close()
+
+	response := callJSONRPC(t, subscribed, 1, "events.subscribe", map[string]interface{}{"events": []string{"session.updated"}})
+	require.Nil(t, response.Error)
+	assert.Equal(t, []interface{}{"session.updated"}, response.Result.(map[string]interface{})["events"])
+
+	response = callJSONRPC(t, other, 1, "events.subscribe", map[string]interface{}{"events": []string{"presence"}})
+	require.Nil(t, response.Error)
+
+	response = callJSONRPC(t, other, 2, "events.subscribe", map[string]interface{}{"events": []string{}})
+	require.NotNil(t, response.Error, "An empty event list should be rejected")
+	assert.Equal(t, jsonrpc.InvalidParams, response.Error.Code)
+
+	manager := ts.server.SessionManager()
+	require.NoError(t, manager.SetSessionValue(subscribedCode, "score", 1))
+	require.NoError(t, manager.SetSessionValue(otherCode, "score", 1))
+
+	subscribed.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := subscribed.ReadMessage()
+	require.NoError(t, err, "Subscriber should receive session.updated")
+	var notification jsonrpc.Request
+	require.NoError(t, json.Unmarshal(message, &notification))
+	assert.Equal(t, "session.updated", notification.Method)
+
+	other.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err = other.ReadMessage()
+	assert.Error(t, err, "Connections not subscribed to session.updated should not receive it")
+}
+
+// TestConfigReload tests that a reload from a changed config file applies the log level while the listen address stays unchanged and the server keeps serving
+func TestConfigReload(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	require.Equal(t, slog.LevelError, ts.logLevel.Level())
+	address := ts.server.Address()
+
+	configFile := filepath.Join(t.TempDir(), "fle.env")
+	require.NoError(t, os.WriteFile(configFile, []byte("LOG_LEVEL=error\n"), 0o600))
+
+	reloadConfig(ts.server, ts.logger.Logger, configFile)
+	assert.Equal(t, slog.LevelError, ts.logLevel.Level(), "An unchanged config file should keep the log level")
+
+	// Only the file changes; the process environment stays as it was at startup
+	require.NoError(t, os.WriteFile(configFile, []byte("# reloaded\nLOG_LEVEL=debug\nPORT=9999\n"), 0o600))
+	newCfg, err := config.LoadFile(configFile)
+	require.NoError(t, err)
+	ignored := ts.server.Reload(newCfg)
+
+	assert.Equal(t, slog.LevelDebug, ts.logLevel.Level(), "Log level should be reloaded")
+	assert.Contains(t, ignored, "PORT", "Port changes should be ignored")
+	assert.Equal(t, address, ts.server.Address(), "Listen address should not change")
+
+	// An invalid file keeps the current settings
+	require.NoError(t, os.WriteFile(configFile, []byte("LOG_LEVEL=loud\n"), 0o600))
+	reloadConfig(ts.server, ts.logger.Logger, configFile)
+	assert.Equal(t, slog.LevelDebug, ts.logLevel.Level(), "An invalid config file should be ignored")
+
+	resp, err := http.Get(ts.url + "/health")
+	require.NoError(t, err, "Server should keep serving on the original port")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// TestRPCMethodsEndpoint tests GET /rpc/methods under the introspection modes
+func TestRPCMethodsEndpoint(t *testing.T) {
+	getMethods := func(t *testing.T, ts *testServer, token string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, ts.url+"/rpc/methods", nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("public", func(t *testing.T) {
+		t.Setenv("METHOD_INTROSPECTION", "public")
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		resp := getMethods(t, ts, "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var body struct {
+			Methods []jsonrpc.MethodDescription `json:"methods"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+		descriptions := make(map[string]string)
+		for _, method := range body.Methods {
+			descriptions[method.Name] = method.Description
+		}
+		assert.NotEmpty(t, descriptions["ping"])
+		assert.NotEmpty(t, descriptions["echo"])
+		assert.Contains(t, descriptions, "rpc.listMethods")
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		t.Setenv("ADMIN_TOKEN", "test-admin-token")
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		resp := getMethods(t, ts, "")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp = getMethods(t, ts, "test-admin-token")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("off", func(t *testing.T) {
+		t.Setenv("METHOD_INTROSPECTION", "off")
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		resp := getMethods(t, ts, "")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+// TestHTTPErrorsAreJSON tests that throttled and oversized requests receive
+// JSON error bodies shaped like JSON-RPC errors
+func TestHTTPErrorsAreJSON(t *testing.T) {
+	decodeError := func(t *testing.T, resp *http.Response) jsonrpc.Error {
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		var body struct {
+			Error *jsonrpc.Error `json:"error"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		require.NotNil(t, body.Error)
+		return *body.Error
+	}
+
+	t.Run("rate limited", func(t *testing.T) {
+		t.Setenv("RPC_RATE_LIMIT", "1")
+		t.Setenv("RPC_RATE_BURST", "1")
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		ping := []byte(`{"jsonrpc":"2.0","method":"ping","id":1}`)
+		resp := postRPC(t, ts, "", ping)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = postRPC(t, ts, "", ping)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+
+		rpcErr := decodeError(t, resp)
+		assert.Equal(t, jsonrpc.RateLimited, rpcErr.Code)
+		assert.Equal(t, "Too many requests", rpcErr.Message)
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		t.Setenv("RPC_MAX_BODY_BYTES", "64")
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		resp := postRPC(t, ts, "", []byte(`{"jsonrpc":"2.0","method":"echo","params":"`+strings.Repeat("x", 128)+`","id":1}`))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		rpcErr := decodeError(t, resp)
+		assert.Equal(t, jsonrpc.InvalidRequest, rpcErr.Code)
+		assert.Equal(t, "Request body too large", rpcErr.Message)
+	})
+}
+
+// TestPresenceCheck tests presence.check for connected and disconnected sessions
+func TestPresenceCheck(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	checker, _ := dialWebSocket(t, ts, "")
+	defer checker.Close()
+	other, otherCode := dialWebSocket(t, ts, "")
+
+	// Connected session
+	response := callJSONRPC(t, checker, 1, "presence.check", map[string]string{"code": otherCode})
+	require.Nil(t, response.Error)
+	result := response.Result.(map[string]interface{})
+	assert.Equal(t, true, result["connected"])
+	assert.NotContains(t, result, "data", "presence.check must not leak session data")
+
+	// Known but disconnected session
+	other.Close()
+	require.Eventually(t, func() bool {
+		return !ts.server.Hub().HasSession(otherCode)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	response = callJSONRPC(t, checker, 2, "presence.check", map[string]string{"code": otherCode})
+	require.Nil(t, response.Error)
+	result = response.Result.(map[string]interface{})
+	assert.Equal(t, false, result["connected"])
+	lastSeen, err := time.Parse(time.RFC3339, result["last_seen"].(string))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), lastSeen, time.Minute)
+
+	// Unknown session
+	response = callJSONRPC(t, checker, 3, "presence.check", map[string]string{"code": "missing-session-99"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, jsonrpc.ResourceNotFound, response.Error.Code)
+
+	// Checks are throttled per connection, so codes cannot be enumerated
+	for i := 0; i < 20; i++ {
+		response = callJSONRPC(t, checker, 4+i, "presence.check", map[string]string{"code": "missing-session-99"})
+		if response.Error.Code != jsonrpc.ResourceNotFound {
+			break
+		}
+	}
+	assert.Equal(t, jsonrpc.RateLimited, response.Error.Code, "repeated checks should be throttled")
+
+	// Other connections keep their own allowance
+	fresh, _ := dialWebSocket(t, ts, "")
+	defer fresh.Close()
+	response = callJSONRPC(t, fresh, 1, "presence.check", map[string]string{"code": otherCode})
+	assert.Nil(t, response.Error)
+}
+
+func TestConnectionStats(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, code := dialWebSocket(t, ts, "")
+	defer conn.Close()
+	other, _ := dialWebSocket(t, ts, "")
+	defer other.Close()
+
+	for i := 1; i <= 3; i++ {
+		response := callJSONRPC(t, conn, i, "ping", nil)
+		require.Nil(t, response.Error)
+	}
+	callJSONRPC(t, other, 1, "ping", nil)
+
+	response := callJSONRPC(t, conn, 4, "connection.stats", nil)
+	require.Nil(t, response.Error)
+	result := response.Result.(map[string]interface{})
+
+	// Three pings and the stats request itself, none of the other connection's
+	assert.Equal(t, float64(4), result["messages_received"])
+	// The welcome message and the three ping responses
+	assert.GreaterOrEqual(t, result["messages_sent"], float64(4))
+	assert.Equal(t, code, result["session_code"])
+	assert.GreaterOrEqual(t, result["duration_ms"], float64(0))
+	assert.Contains(t, result, "queued_messages")
+
+	connectedAt, err := time.Parse(time.RFC3339Nano, result["connected_at"].(string))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), connectedAt, time.Minute)
+}
+
+func TestSessionValidateCode(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-admin-token")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	checker, _, _ := dialAdmin(t, ts, "")
+	defer checker.Close()
+	anonymous, _ := dialWebSocket(t, ts, "")
+	defer anonymous.Close()
+	other, otherCode := dialWebSocket(t, ts, "")
+	defer other.Close()
+	require.NoError(t, ts.server.SessionManager().SetSessionValue(otherCode, "secret", "hidden"))
+	sessionsBefore := ts.server.SessionManager().GetSessionCount()
+
+	tests := []struct {
+		name       string
+		code       string
+		wellFormed bool
+		exists     interface{}
+	}{
+		{name: "well-formed existing code", code: otherCode, wellFormed: true, exists: true},
+		{name: "well-formed missing code", code: "missing-session-99", wellFormed: true, exists: false},
+		{name: "malformed code", code: "not a code", wellFormed: false},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callJSONRPC(t, checker, i+1, "session.validateCode", map[string]string{"code": tt.code})
+			require.Nil(t, response.Error)
+			result := response.Result.(map[string]interface{})
+			assert.Equal(t, tt.wellFormed, result["well_formed"])
+			assert.Equal(t, tt.exists, result["exists"])
+			assert.LessOrEqual(t, len(result), 2, "session.validateCode must not leak session data")
+
+			// Anonymous callers only learn whether the code is well-formed
+			response = callJSONRPC(t, anonymous, i+1, "session.validateCode", map[string]string{"code": tt.code})
+			require.Nil(t, response.Error)
+			result = response.Result.(map[string]interface{})
+			assert.Equal(t, map[string]interface{}{"well_formed": tt.wellFormed}, result)
+		})
+	}
+
+	// Validating never creates a session
+	assert.Equal(t, sessionsBefore, ts.server.SessionManager().GetSessionCount())
+
+	// Lookups are throttled per connection
+	var response jsonrpc.Response
+	for i := 0; i < 20; i++ {
+		response = callJSONRPC(t, checker, 10+i, "session.validateCode", map[string]string{"code": otherCode})
+		if response.Error != nil {
+			break
+		}
+	}
+	require.NotNil(t, response.Error, "repeated lookups should be throttled")
+	assert.Equal(t, jsonrpc.RateLimited, response.Error.Code)
+}
+
+// TestInstanceID tests that the configured instance ID appears in log
+// records, in server.status and in the X-Instance-ID response header
+func TestInstanceID(t *testing.T) {
+	t.Setenv("INSTANCE_ID", "test-instance-7")
+	t.Setenv("INSTANCE_ID_HEADER", "true")
+
+	t.Run("logs", func(t *testing.T) {
+		logPath := filepath.Join(t.TempDir(), "server.log")
+		t.Setenv("LOG_OUTPUT", "file")
+		t.Setenv("LOG_FILE_PATH", logPath)
+		t.Setenv("LOG_FORMAT", "json")
+		cfg, err := config.Load()
+		require.NoError(t, err)
+
+		logger, err := setupLogger(cfg, new(slog.LevelVar))
+		require.NoError(t, err)
+		logger.Error("probe")
+
+		contents, err := os.ReadFile(logPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(contents), `"instance_id":"test-instance-7"`)
+	})
+
+	t.Run("server.status", func(t *testing.T) {
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		conn, _ := dialWebSocket(t, ts, "")
+		defer conn.Close()
+
+		response := callJSONRPC(t, conn, 1, "server.status", nil)
+		require.Nil(t, response.Error)
+		assert.Equal(t, "test-instance-7", response.Result.(map[string]interface{})["instance_id"])
+
+		resp, err := http.Get(ts.url + "/health")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, "test-instance-7", resp.Header.Get("X-Instance-ID"))
+	})
+}
+
+// TestAuthExemptMethods tests that with REQUIRE_AUTH set anonymous
+// connections may only call the exempt methods
+func TestAuthExemptMethods(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-admin-token")
+	t.Setenv("REQUIRE_AUTH", "true")
+	t.Setenv("AUTH_EXEMPT_METHODS", "ping, server.status")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	anonymous, _ := dialWebSocket(t, ts, "")
+	defer anonymous.Close()
+
+	response := callJSONRPC(t, anonymous, 1, "ping", nil)
+	assert.Nil(t, response.Error, "ping should be callable without auth")
+	response = callJSONRPC(t, anonymous, 2, "server.status", nil)
+	assert.Nil(t, response.Error, "server.status should be callable without auth")
+
+	response = callJSONRPC(t, anonymous, 3, "echo", map[string]string{"hello": "world"})
+	require.NotNil(t, response.Error, "echo should require auth")
+	assert.Equal(t, jsonrpc.Unauthorized, response.Error.Code)
+
+	authenticated, _, _ := dialAdmin(t, ts, "")
+	defer authenticated.Close()
+
+	response = callJSONRPC(t, authenticated, 1, "echo", map[string]string{"hello": "world"})
+	assert.Nil(t, response.Error, "echo should succeed with a valid token")
+}
+
+// TestSessionRotateToken tests that after session.rotateToken only the new
+// reconnect token restores the session
+func TestSessionRotateToken(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", nil)
+	require.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	var welcome server.WelcomeMessage
+	require.NoError(t, json.Unmarshal(message, &welcome))
+	code := welcome.SessionCode
+	oldToken := welcome.ReconnectToken
+	require.NotEmpty(t, oldToken, "New sessions should receive a reconnect token")
+
+	response := callJSONRPC(t, conn, 1, "session.rotateToken", nil)
+	require.Nil(t, response.Error)
+	newToken := response.Result.(map[string]interface{})["reconnect_token"].(string)
+	require.NotEmpty(t, newToken)
+	assert.NotEqual(t, oldToken, newToken)
+
+	conn.Close()
+	require.Eventually(t, func() bool {
+		return !ts.server.Hub().HasSession(code)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The old token no longer restores the session
+	stale, staleCode := dialWebSocket(t, ts, "session="+code+"&reconnect_token="+oldToken)
+	stale.Close()
+	assert.NotEqual(t, code, staleCode, "The rotated-out token should not restore the session")
+
+	// The new token does
+	restored, restoredCode := dialWebSocket(t, ts, "session="+code+"&reconnect_token="+newToken)
+	defer restored.Close()
+	assert.Equal(t, code, restoredCode, "The new token should restore the session")
+}
+
+// TestMaxConcurrentHandlers tests that MAX_CONCURRENT_HANDLERS bounds handler
+// executions across connections, queueing the excess under the queue policy
+// and failing it with server busy under the reject policy
+func TestMaxConcurrentHandlers(t *testing.T) {
+	t.Setenv("MAX_CONCURRENT_HANDLERS", "2")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var current, peak int
+	err := ts.server.JSONRPCRouter().RegisterMethod("test.slow", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		mu.Lock()
+		current++
+		peak = max(peak, current)
+		mu.Unlock()
+
+		time.Sleep(100 * time.Millisecond)
+
+		mu.Lock()
+		current--
+		mu.Unlock()
+		return "done", nil
+	}, nil)
+	require.NoError(t, err)
+
+	const numConns = 6
+	conns := make([]*websocket.Conn, numConns)
+	for i := range conns {
+		conns[i], _ = dialWebSocket(t, ts, "")
+		defer conns[i].Close()
+	}
+
+	// Each connection handles its own requests in order, so send one request
+	// on every connection before reading any response
+	for i, conn := range conns {
+		request, err := jsonrpc.NewRequest("test.slow", nil, i+1)
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteJSON(request))
+	}
+	for i, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var response jsonrpc.Response
+		require.NoError(t, conn.ReadJSON(&response))
+		assert.Nil(t, response.Error, "queued request %d should succeed", i+1)
+	}
+
+	mu.Lock()
+	assert.Equal(t, 2, peak, "handlers should run two at a time")
+	mu.Unlock()
+
+	// Under the reject policy requests over the cap fail immediately
+	t.Setenv("CONCURRENCY_POLICY", "reject")
+	newCfg, err := config.Load()
+	require.NoError(t, err)
+	ts.server.Reload(newCfg)
+
+	for i, conn := range conns {
+		request, err := jsonrpc.NewRequest("test.slow", nil, i+1)
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteJSON(request))
+	}
+	busy := 0
+	for _, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var response jsonrpc.Response
+		require.NoError(t, conn.ReadJSON(&response))
+		if response.Error != nil {
+			assert.Equal(t, jsonrpc.ServerBusy, response.Error.Code)
+			busy++
+		}
+	}
+	assert.Equal(t, numConns-2, busy, "requests over the cap should be rejected")
+}
+
+// TestAdminCleanup tests that admin.cleanup removes expired sessions on
+// demand, reports the counts and is denied to non-admins
+func TestAdminCleanup(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-admin-token")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	// Install a session clock that can be moved forward before any
+	// session exists
+	sessions := ts.server.SessionManager()
+	var offset atomic.Int64
+	sessions.SetClock(func() time.Time { return time.Now().Add(time.Duration(offset.Load())) })
+
+	// Two sessions that outlive their connections
+	for i := 0; i < 2; i++ {
+		conn, _ := dialWebSocket(t, ts, "")
+		conn.Close()
+	}
+
+	// Move the clock past their expiry; sessions created from now on are
+	// fresh
+	offset.Store(int64(1000 * time.Hour))
+
+	user, _ := dialWebSocket(t, ts, "")
+	defer user.Close()
+	admin, _, _ := dialAdmin(t, ts, "")
+	defer admin.Close()
+
+	response := callJSONRPC(t, user, 1, "admin.cleanup", nil)
+	require.NotNil(t, response.Error, "Non-admins should be denied")
+	assert.Equal(t, jsonrpc.Unauthorized, response.Error.Code)
+	assert.Equal(t, 4, sessions.GetSessionCount(), "A denied cleanup should remove nothing")
+
+	response = callJSONRPC(t, admin, 2, "admin.cleanup", nil)
+	require.Nil(t, response.Error, "admin.cleanup should succeed for admins")
+	result := response.Result.(map[string]interface{})
+	assert.Equal(t, float64(2), result["removed"])
+	assert.Equal(t, float64(4), result["before"])
+	assert.Equal(t, float64(2), result["after"])
+	assert.Equal(t, 2, sessions.GetSessionCount())
+
+	// Nothing is left to remove
+	response = callJSONRPC(t, admin, 3, "admin.cleanup", nil)
+	require.Nil(t, response.Error)
+	assert.Equal(t, float64(0), response.Result.(map[string]interface{})["removed"])
+}
+
+// TestAdminMetrics tests that admin.metrics reports per-method call counts
+// and hub metrics, and is restricted to admins
+func TestAdminMetrics(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-admin-token")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	user, _ := dialWebSocket(t, ts, "")
+	defer user.Close()
+	admin, _, _ := dialAdmin(t, ts, "")
+	defer admin.Close()
+
+	callJSONRPC(t, user, 1, "ping", nil)
+	callJSONRPC(t, user, 2, "ping", nil)
+	callJSONRPC(t, user, 3, "echo", map[string]string{"hello": "world"})
+	response := callJSONRPC(t, user, 4, "no.such.method", nil)
+	require.NotNil(t, response.Error)
+
+	// Non-admins are denied
+	response = callJSONRPC(t, user, 5, "admin.metrics", nil)
+	require.NotNil(t, response.Error, "Non-admins should be denied")
+	assert.Equal(t, jsonrpc.Unauthorized, response.Error.Code)
+
+	response = callJSONRPC(t, admin, 1, "admin.metrics", nil)
+	require.Nil(t, response.Error, "admin.metrics should succeed for admins")
+
+	raw, err := json.Marshal(response.Result)
+	require.NoError(t, err)
+	var result server.AdminMetricsResult
+	require.NoError(t, json.Unmarshal(raw, &result))
+
+	assert.Equal(t, uint64(2), result.Router.Methods["ping"].Calls)
+	assert.Equal(t, uint64(1), result.Router.Methods["echo"].Calls)
+	assert.Equal(t, uint64(1), result.Router.Methods["admin.metrics"].Errors, "The denied call should count as an error")
+	assert.NotContains(t, result.Router.Methods, "no.such.method", "Unknown methods should only count in the totals")
+	assert.Equal(t, uint64(5), result.Router.Calls, "The in-flight call is counted once it completes")
+	assert.Equal(t, uint64(2), result.Router.Errors)
+
+	assert.Equal(t, 2, result.Hub.Connections)
+	assert.Equal(t, uint64(6), result.Hub.Received.Count)
+	assert.Positive(t, result.Hub.Received.MaxBytes)
+	assert.GreaterOrEqual(t, result.Hub.Sent.Count, uint64(5))
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes and reads.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestServerReadyLogLine tests that Start logs the ready line with the bound
+// address once the listener accepts connections
+func TestServerReadyLogLine(t *testing.T) {
+	t.Setenv("READY_LOG_MESSAGE", "Listener up")
+	t.Setenv("REQUIRE_AUTH", "true")
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Host = "127.0.0.1"
+	cfg.Port = 0
+
+	var logs syncBuffer
+	srv, err := server.NewServer(cfg, slog.New(slog.NewJSONHandler(&logs, nil)))
+	require.NoError(t, err)
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- srv.Start() }()
+
+	var ready map[string]interface{}
+	require.Eventually(t, func() bool {
+		for _, line := range strings.Split(logs.String(), "\n") {
+			var entry map[string]interface{}
+			if json.Unmarshal([]byte(line), &entry) == nil && entry["msg"] == "Listener up" {
+				ready = entry
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond, "Ready line should be logged")
+
+	address, _ := ready["address"].(string)
+	assert.NotEqual(t, "127.0.0.1:0", address, "Ready line should carry the bound port")
+	assert.Equal(t, cfg.Environment, ready["environment"])
+	assert.Equal(t, server.Version, ready["version"])
+	assert.Contains(t, ready["features"], "require_auth")
+
+	resp, err := http.Get("http://" + address + "/health")
+	require.NoError(t, err, "Server should accept connections once ready")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Stop(ctx))
+	require.NoError(t, <-errChan)
+}
+
+// TestSessionGetHidesReservedKeys tests that session.get and session.updated
+// only expose session data keys clients may see
+func TestSessionGetHidesReservedKeys(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, sessionCode := dialWebSocket(t, ts, "")
+	defer conn.Close()
+
+	err := ts.server.SessionManager().UpdateSessionData(sessionCode, map[string]interface{}{
+		"_internal_flag": true,
+		"theme":          "dark",
+	})
+	require.NoError(t, err)
+
+	// The change notification names only the visible key
+	var notification jsonrpc.Request
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	require.NoError(t, conn.ReadJSON(&notification))
+	require.Equal(t, "session.updated", notification.Method)
+	var params struct {
+		Keys []string `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(notification.Params, &params))
+	assert.Equal(t, []string{"theme"}, params.Keys)
+
+	response := callJSONRPC(t, conn, 1, "session.get", nil)
+	require.Nil(t, response.Error)
+	result := response.Result.(map[string]interface{})
+	assert.Equal(t, sessionCode, result["code"])
+	assert.Equal(t, map[string]interface{}{"theme": "dark"}, result["data"])
+
+	// The full data stays server-side
+	sess, err := ts.server.SessionManager().PeekSession(sessionCode)
+	require.NoError(t, err)
+	assert.Equal(t, true, sess.Data["_internal_flag"])
+
+	// An allowlist hides every other key
+	t.Setenv("SESSION_DATA_ALLOWED_KEYS", "language")
+	newCfg, err := config.Load()
+	require.NoError(t, err)
+	ts.server.Reload(newCfg)
+
+	response = callJSONRPC(t, conn, 2, "session.get", nil)
+	require.Nil(t, response.Error)
+	assert.Empty(t, response.Result.(map[string]interface{})["data"])
+}
+
+// TestRPCEndpointRequestTimeout tests that POST /rpc answers with a request
+// timeout error once RPC_REQUEST_TIMEOUT passes, even if the handler ignores
+// its context, and that the handler's context is canceled
+func TestRPCEndpointRequestTimeout(t *testing.T) {
+	t.Setenv("RPC_REQUEST_TIMEOUT", "1")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	canceled := make(chan error, 1)
+	release := make(chan struct{})
+	defer close(release)
+	err := ts.server.JSONRPCRouter().RegisterSimpleMethod("test.stuck", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		// Keep running past the deadline, like a handler that ignores its context
+		<-release
+		return "too late", nil
+	}, "Blocks until released")
+	require.NoError(t, err)
+
+	start := time.Now()
+	resp := postRPC(t, ts, "", []byte(`{"jsonrpc":"2.0","method":"test.stuck","id":1}`))
+	defer resp.Body.Close()
+	elapsed := time.Since(start)
+
+	assert.Less(t, elapsed, 3*time.Second, "The response should not wait for the handler")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var response jsonrpc.Response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, jsonrpc.RequestTimeout, response.Error.Code)
+	assert.Equal(t, float64(1), response.ID)
+
+	select {
+	case err := <-canceled:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("The handler's context should be canceled at the deadline")
+	}
+
+	// Notifications that time out still get no response
+	resp = postRPC(t, ts, "", []byte(`{"jsonrpc":"2.0","method":"test.stuck"}`))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+// TestAdminLogLevel tests reading and changing the log level over JSON-RPC
+func TestAdminLogLevel(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-admin-token")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	admin, _, _ := dialAdmin(t, ts, "")
+	defer admin.Close()
+
+	response := callJSONRPC(t, admin, 1, "admin.getLogLevel", nil)
+	require.Nil(t, response.Error)
+	assert.Equal(t, "error", response.Result.(map[string]interface{})["level"])
+	require.False(t, ts.logger.IsDebugEnabled())
+
+	response = callJSONRPC(t, admin, 2, "admin.setLogLevel", map[string]string{"level": "DEBUG"})
+	require.Nil(t, response.Error)
+	assert.Equal(t, map[string]interface{}{"level": "debug", "previous": "error"}, response.Result)
+	assert.True(t, ts.logger.IsDebugEnabled(), "Debug logging should be enabled")
+
+	response = callJSONRPC(t, admin, 3, "admin.getLogLevel", nil)
+	require.Nil(t, response.Error)
+	assert.Equal(t, "debug", response.Result.(map[string]interface{})["level"])
+
+	// Unknown levels are rejected and leave the level unchanged
+	response = callJSONRPC(t, admin, 4, "admin.setLogLevel", map[string]string{"level": "verbose"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, jsonrpc.InvalidParams, response.Error.Code)
+	assert.Equal(t, slog.LevelDebug, ts.logLevel.Level())
+
+	// Non-admins are denied
+	user, _ := dialWebSocket(t, ts, "")
+	defer user.Close()
+	response = callJSONRPC(t, user, 1, "admin.setLogLevel", map[string]string{"level": "error"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, jsonrpc.Unauthorized, response.Error.Code)
+	assert.True(t, ts.logger.IsDebugEnabled())
+}
+
+// TestWelcomeSkippedAfterImmediateDisconnect tests that a client hanging up
+// before its welcome message is sent does not trigger a send to a session
+// that no longer has a connection
+func TestWelcomeSkippedAfterImmediateDisconnect(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	var logs syncBuffer
+	srv, err := server.NewServer(cfg, slog.New(slog.NewJSONHandler(&logs, nil)))
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(srv.Handler())
+	defer httpServer.Close()
+
+	for i := 0; i < 5; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(httpServer.URL, "http", "ws", 1)+"/ws", nil)
+		require.NoError(t, err)
+		conn.Close()
+	}
+
+	require.Eventually(t, func() bool { return srv.Hub().GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+	time.Sleep(300 * time.Millisecond) // Past the welcome delay
+
+	assert.NotContains(t, logs.String(), "non-existent session",
+		"The welcome message should not be sent after the client disconnected")
+}
+
+// TestSecurityHeaders tests that HTTP responses carry the configured security
+// headers, with HSTS only over TLS and no headers on WebSocket upgrades
+func TestSecurityHeaders(t *testing.T) {
+	t.Setenv("FRAME_OPTIONS", "sameorigin")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.url + "/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+	assert.Equal(t, "SAMEORIGIN", resp.Header.Get("X-Frame-Options"))
+	assert.Equal(t, config.DefaultContentSecurityPolicy, resp.Header.Get("Content-Security-Policy"))
+	assert.Empty(t, resp.Header.Get("Strict-Transport-Security"), "HSTS should not be sent over plain HTTP")
+
+	tlsServer := httptest.NewTLSServer(ts.server.Handler())
+	defer tlsServer.Close()
+	resp, err = tlsServer.Client().Get(tlsServer.URL + "/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "max-age=31536000", resp.Header.Get("Strict-Transport-Security"))
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+
+	conn, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Empty(t, resp.Header.Get("X-Frame-Options"), "WebSocket upgrades should not carry security headers")
+
+	// Disabling the headers takes effect on reload
+	t.Setenv("SECURITY_HEADERS", "false")
+	newCfg, err := config.Load()
+	require.NoError(t, err)
+	ts.server.Reload(newCfg)
+	resp, err = http.Get(ts.url + "/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, resp.Header.Get("X-Content-Type-Options"))
+}
+
+// TestMaintenanceMode tests that maintenance mode refuses new WebSocket
+// upgrades while health checks and existing connections keep working
+func TestWebSocketRefusedDuringShutdown(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	// Shutdown has begun but the listener is still accepting requests
+	ts.server.Hub().Shutdown()
+
+	_, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	var body struct {
+		Error jsonrpc.Error `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	assert.Equal(t, jsonrpc.Maintenance, body.Error.Code)
+
+	// No session was created for the refused upgrade
+	assert.Equal(t, 0, ts.server.SessionManager().GetSessionCount())
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-admin-token")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	admin, _, _ := dialAdmin(t, ts, "")
+	defer admin.Close()
+	user, _ := dialWebSocket(t, ts, "")
+	defer user.Close()
+
+	response := callJSONRPC(t, user, 1, "admin.setMaintenanceMode", map[string]bool{"enabled": true})
+	require.NotNil(t, response.Error, "Non-admins should be denied")
+	assert.Equal(t, jsonrpc.Unauthorized, response.Error.Code)
+
+	response = callJSONRPC(t, admin, 2, "admin.setMaintenanceMode", map[string]bool{"enabled": true})
+	require.Nil(t, response.Error)
+	assert.Equal(t, true, response.Result.(map[string]interface{})["maintenance"])
+	assert.True(t, ts.server.InMaintenance())
+
+	// New upgrades are refused with the maintenance code
+	_, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	var body struct {
+		Error jsonrpc.Error `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	assert.Equal(t, jsonrpc.Maintenance, body.Error.Code)
+	assert.Equal(t, config.DefaultMaintenanceMessage, body.Error.Message)
+
+	// Health still responds, readiness reports maintenance
+	resp, err = http.Get(ts.url + "/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(ts.url + "/readyz")
+	require.NoError(t, err)
+	var readiness server.ReadinessResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&readiness))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "maintenance", readiness.Status)
+
+	// Existing connections keep working
+	response = callJSONRPC(t, user, 3, "ping", nil)
+	require.Nil(t, response.Error)
+
+	response = callJSONRPC(t, admin, 4, "admin.setMaintenanceMode", map[string]bool{"enabled": false})
+	require.Nil(t, response.Error)
+	conn, _ := dialWebSocket(t, ts, "")
+	conn.Close()
+
+	// MAINTENANCE_MODE applies on reload
+	t.Setenv("MAINTENANCE_MODE", "true")
+	newCfg, err := config.Load()
+	require.NoError(t, err)
+	ts.server.Reload(newCfg)
+	assert.True(t, ts.server.InMaintenance())
+}
+
+// TestRequestSequencing tests that REQUEST_SEQUENCING rejects a regressed
+// "_seq" on a WebSocket connection
+func TestRequestSequencing(t *testing.T) {
+	t.Setenv("REQUEST_SEQUENCING", "true")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, _ := dialWebSocket(t, ts, "")
+	defer conn.Close()
+
+	response := callJSONRPC(t, conn, 1, "echo", map[string]interface{}{"_seq": 1, "text": "hi"})
+	require.Nil(t, response.Error, "In-order requests should be accepted")
+	assert.Equal(t, map[string]interface{}{"text": "hi"}, response.Result, "_seq should not reach handlers")
+
+	response = callJSONRPC(t, conn, 2, "echo", map[string]interface{}{"_seq": 2})
+	require.Nil(t, response.Error)
+
+	response = callJSONRPC(t, conn, 3, "echo", map[string]interface{}{"_seq": 1})
+	require.NotNil(t, response.Error, "A regressed sequence should be rejected")
+	assert.Equal(t, jsonrpc.InvalidRequest, response.Error.Code)
+}
+
+// TestDebugValidationTags tests that debug.validationTags lists the custom
+// validation tags in development and is not registered elsewhere
+func TestDebugValidationTags(t *testing.T) {
+	t.Run("development", func(t *testing.T) {
+		t.Setenv("ENV", "development")
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		conn, _ := dialWebSocket(t, ts, "")
+		defer conn.Close()
+
+		response := callJSONRPC(t, conn, 1, "debug.validationTags", nil)
+		require.Nil(t, response.Error, "debug.validationTags should succeed in development")
+
+		result, ok := response.Result.(map[string]interface{})
+		require.True(t, ok, "Result should be a map, got %T", response.Result)
+		assert.Contains(t, result["tags"], "sessioncode")
+		assert.Contains(t, result["tags"], "jsonrpcversion")
+	})
+
+	t.Run("production", func(t *testing.T) {
+		t.Setenv("ENV", "production")
+		ts := setupTestServer(t)
+		defer ts.Close()
+
+		conn, _ := dialWebSocket(t, ts, "")
+		defer conn.Close()
+
+		response := callJSONRPC(t, conn, 1, "debug.validationTags", nil)
+		require.NotNil(t, response.Error)
+		assert.Equal(t, jsonrpc.MethodNotFound, response.Error.Code)
+	})
+}
+
+func TestEnabledMethodsManifest(t *testing.T) {
+	t.Setenv("ENABLED_METHODS", "ping, getSessionInfo")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, _ := dialWebSocket(t, ts, "")
+	defer conn.Close()
+
+	response := callJSONRPC(t, conn, 1, "ping", nil)
+	require.Nil(t, response.Error, "ping is in the manifest and should succeed")
+
+	response = callJSONRPC(t, conn, 2, "getSessionInfo", nil)
+	require.Nil(t, response.Error, "getSessionInfo is in the manifest and should succeed")
+
+	// Methods left out of the manifest are never registered
+	response = callJSONRPC(t, conn, 3, "echo", map[string]string{"message": "hi"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, jsonrpc.MethodNotFound, response.Error.Code)
+	assert.False(t, ts.server.JSONRPCRouter().HasMethod("echo"))
+	assert.False(t, ts.server.JSONRPCRouter().HasMethod("room.join"))
+}
+
+func TestDebugErrorCodes(t *testing.T) {
+	t.Setenv("ENV", "development")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, _ := dialWebSocket(t, ts, "")
+	defer conn.Close()
+
+	response := callJSONRPC(t, conn, 1, "debug.errorCodes", nil)
+	require.Nil(t, response.Error, "debug.errorCodes should succeed in development")
+
+	result, ok := response.Result.(map[string]interface{})
+	require.True(t, ok, "Result should be a map, got %T", response.Result)
+	codes, ok := result["codes"].([]interface{})
+	require.True(t, ok, "Codes should be a list, got %T", result["codes"])
+
+	messages := make(map[float64]interface{})
+	for _, code := range codes {
+		info := code.(map[string]interface{})
+		messages[info["code"].(float64)] = info["message"]
+	}
+	assert.Equal(t, "Method not found", messages[-32601])
+	assert.NotEmpty(t, result["ranges"])
+}
+
+// TestSessionRekey tests that session.rekey moves the caller's session and
+// connection to a new code, keeping its data, and retires the old code
+func TestSessionRekey(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, oldCode := dialWebSocket(t, ts, "")
+	defer conn.Close()
+
+	manager := ts.server.SessionManager()
+	require.NoError(t, manager.SetSessionValue(oldCode, "score", 7))
+
+	request, err := jsonrpc.NewRequest("session.rekey", nil, 1)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteJSON(request))
+
+	// Notifications, among them session.rekeyed, precede the response
+	var notified map[string]string
+	var response jsonrpc.Response
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for response.ID == nil {
+		_, frame, err := conn.ReadMessage()
+		require.NoError(t, err)
+
+		// Queued messages may be batched into one frame, one per line
+		for _, message := range bytes.Split(frame, []byte("\n")) {
+			var notification jsonrpc.Request
+			require.NoError(t, json.Unmarshal(message, &notification))
+			if notification.Method == "" {
+				require.NoError(t, json.Unmarshal(message, &response))
+			} else if notification.Method == "session.rekeyed" {
+				require.NoError(t, json.Unmarshal(notification.Params, &notified))
+			}
+		}
+	}
+	require.Nil(t, response.Error, "session.rekey should succeed")
+	newCode := response.Result.(map[string]interface{})["session_code"].(string)
+	assert.NotEqual(t, oldCode, newCode)
+	assert.Equal(t, newCode, notified["session_code"], "session.rekeyed should carry the new code")
+
+	// Data is preserved under the new code and the old code no longer resolves
+	rekeyed, err := manager.GetSession(newCode)
+	require.NoError(t, err)
+	assert.Equal(t, 7, rekeyed.Data["score"])
+	_, err = manager.GetSession(oldCode)
+	assert.ErrorIs(t, err, session.ErrSessionNotFound)
+
+	assert.True(t, ts.server.Hub().HasSession(newCode))
+	assert.False(t, ts.server.Hub().HasSession(oldCode))
+
+	// Later requests on the connection run as the new session
+	response = callJSONRPC(t, conn, 2, "session.get", nil)
+	require.Nil(t, response.Error, "session.get should succeed")
+	result := response.Result.(map[string]interface{})
+	assert.Equal(t, newCode, result["code"])
+	assert.Equal(t, map[string]interface{}{"score": float64(7)}, result["data"])
+}
+
+// TestPendingBufferFreedWithSession tests that messages are only buffered for
+// existing sessions and that deleting a session frees its buffer
+func TestPendingBufferFreedWithSession(t *testing.T) {
+	t.Setenv("PENDING_MESSAGE_LIMIT", "10")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	conn, code := dialWebSocket(t, ts, "")
+	conn.Close()
+	require.Eventually(t, func() bool {
+		return !ts.server.Hub().HasSession(code)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	ts.server.Hub().SendToSession(code, []byte(`{"n":1}`))
+	assert.Equal(t, 2, ts.server.Hub().PendingCount(code), "the welcome message is kept along with the buffered one")
+
+	ts.server.Hub().SendToSession("unknown-session-1", []byte(`{"n":1}`))
+	assert.Equal(t, 0, ts.server.Hub().PendingCount("unknown-session-1"), "unknown sessions should not be buffered for")
+
+	require.True(t, ts.server.SessionManager().DeleteSession(code))
+	assert.Equal(t, 0, ts.server.Hub().PendingCount(code), "deleting the session should free its buffer")
+}
+
+// TestReconnectLastSeq tests that a client connecting with ?lastSeq= gets
+// sequence-numbered messages, and that a reconnect with ?lastSeq= set to the
+// seq of the last message received replays only the messages sent after it
+func TestReconnectLastSeq(t *testing.T) {
+	t.Setenv("PENDING_MESSAGE_LIMIT", "10")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	// readNumbered reads messages until the welcome message or until want
+	// numbered messages arrived, returning their numbers and seqs and the
+	// welcome message
+	readNumbered := func(t *testing.T, conn *websocket.Conn, want int) (numbers []int, seqs []uint64, welcome map[string]interface{}) {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for welcome == nil && len(numbers) < want {
+			_, frame, err := conn.ReadMessage()
+			require.NoError(t, err)
+
+			// Queued messages may be batched into one frame, one per line
+			for _, message := range bytes.Split(frame, []byte("\n")) {
+				var envelope struct {
+					Seq     *uint64                `json:"seq"`
+					Message map[string]interface{} `json:"message"`
+				}
+				require.NoError(t, json.Unmarshal(message, &envelope))
+				require.NotNil(t, envelope.Seq, "every session message carries its sequence number")
+				if envelope.Message["type"] == "welcome" {
+					welcome = envelope.Message
+					continue
+				}
+				numbers = append(numbers, int(envelope.Message["n"].(float64)))
+				seqs = append(seqs, *envelope.Seq)
+			}
+		}
+		return numbers, seqs, welcome
+	}
+
+	// Without lastSeq messages are delivered as sent
+	plain, plainCode := dialWebSocket(t, ts, "")
+	ts.server.Hub().SendToSession(plainCode, []byte(`{"n":1}`))
+	plain.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := plain.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"n":1}`, string(message))
+	plain.Close()
+
+	// Messages 1-3 reach the first connection, 4 and 5 are buffered after it closed
+	conn, _, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws?lastSeq=0", nil)
+	require.NoError(t, err)
+	_, _, welcome := readNumbered(t, conn, 1)
+	require.NotNil(t, welcome)
+	code := welcome["session_code"].(string)
+	token := welcome["reconnect_token"].(string)
+	for i := 1; i <= 3; i++ {
+		ts.server.Hub().SendToSession(code, []byte(fmt.Sprintf(`{"n":%d}`, i)))
+	}
+	received, seqs, _ := readNumbered(t, conn, 3)
+	require.Equal(t, []int{1, 2, 3}, received)
+	conn.Close()
+	require.Eventually(t, func() bool {
+		return !ts.server.Hub().HasSession(code)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for i := 4; i <= 5; i++ {
+		ts.server.Hub().SendToSession(code, []byte(fmt.Sprintf(`{"n":%d}`, i)))
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws?session="+code+"&reconnect_token="+token+"&lastSeq=three", nil)
+		require.Error(t, err)
+		require.NotNil(t, resp)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("replays newer messages", func(t *testing.T) {
+		lastSeq := strconv.FormatUint(seqs[2], 10)
+		conn, _, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws?session="+code+"&reconnect_token="+token+"&lastSeq="+lastSeq, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		// Replayed messages are queued before the welcome message and
+		// continue the numbering of the first connection
+		replayed, replayedSeqs, _ := readNumbered(t, conn, 2)
+		assert.Equal(t, []int{4, 5}, replayed)
+		assert.Equal(t, []uint64{seqs[2] + 1, seqs[2] + 2}, replayedSeqs)
+	})
+}
+
+// flakyStore is a session.Store that keeps nothing and fails while down is set.
+type flakyStore struct {
+	down atomic.Bool
+}
+
+func (s *flakyStore) Load(ctx context.Context, code string) (*session.Session, error) {
+	if s.down.Load() {
+		return nil, errors.New("store unavailable")
+	}
+	return nil, session.ErrSessionNotFound
+}
+
+func (s *flakyStore) Save(ctx context.Context, sess *session.Session) error {
+	if s.down.Load() {
+		return errors.New("store unavailable")
+	}
+	return nil
+}
+
+func (s *flakyStore) Delete(ctx context.Context, code string) error {
+	if s.down.Load() {
+		return errors.New("store unavailable")
+	}
+	return nil
+}
+
+// TestSessionStoreDegraded tests that a failing session store leaves
+// sessions working from memory while /readyz reports degraded, and that
+// readiness recovers with the store
+func TestSessionStoreDegraded(t *testing.T) {
+	t.Setenv("SESSION_STORE_RETRY", "1")
+	store := &flakyStore{}
+	store.down.Store(true)
+	ts := setupTestServerWithStore(t, store)
+	defer ts.Close()
+
+	readyStatus := func() (int, string) {
+		resp, err := http.Get(ts.url + "/readyz")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var ready map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&ready))
+		return resp.StatusCode, ready["status"].(string)
+	}
+
+	conn, sessionCode := dialWebSocket(t, ts, "")
+	defer conn.Close()
+	require.NotEmpty(t, sessionCode, "Sessions should still be created while the store is down")
+
+	response := callJSONRPC(t, conn, 1, "ping", nil)
+	assert.Nil(t, response.Error, "Requests should still be served while the store is down")
+	_, err := ts.server.SessionManager().PeekSession(sessionCode)
+	assert.NoError(t, err, "The session should be served from memory")
+
+	code, status := readyStatus()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "degraded", status)
+
+	store.down.Store(false)
+	require.Eventually(t, func() bool {
+		_, status := readyStatus()
+		return status == "ready"
+	}, 5*time.Second, 100*time.Millisecond, "Readiness should recover once queued writes reach the store")
+}
+
+// TestMaxHandshakesPerIP tests that concurrent WebSocket upgrades from one
+// client IP, identified through a trusted proxy, beyond MAX_HANDSHAKES_PER_IP
+// are rejected with 429 while other clients are unaffected
+func TestMaxHandshakesPerIP(t *testing.T) {
+	t.Setenv("MAX_HANDSHAKES_PER_IP", "1")
+	t.Setenv("TRUSTED_PROXIES", "127.0.0.1")
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	dial := func(clientIP string) int {
+		header := http.Header{"X-Forwarded-For": []string{clientIP}}
+		conn, resp, err := websocket.DefaultDialer.Dial(ts.wsURL+"/ws", header)
+		if err == nil {
+			conn.Close()
+		}
+		if resp == nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	const attempts = 50
+	var (
+		wg       sync.WaitGroup
+		start    = make(chan struct{})
+		statuses = make(chan int, attempts)
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			statuses <- dial("203.0.113.7")
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	assert.Positive(t, counts[http.StatusSwitchingProtocols], "Some upgrades should succeed")
+	assert.Positive(t, counts[http.StatusTooManyRequests], "Concurrent upgrades over the limit should get 429")
+	assert.Equal(t, attempts, counts[http.StatusSwitchingProtocols]+counts[http.StatusTooManyRequests],
+		"Upgrades should either succeed or be rate limited, got %v", counts)
+
+	// Once the burst is over, the client and others can connect again
+	assert.Equal(t, http.StatusSwitchingProtocols, dial("203.0.113.7"))
+	assert.Equal(t, http.StatusSwitchingProtocols, dial("198.51.100.9"))
+}
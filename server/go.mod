@@ -3,7 +3,6 @@ module github.com/fle/server
 go 1.24.5
 
 require (
-	github.com/dustinkirkland/golang-petname v0.0.0-20240428194347-eebcea082ee0
 	github.com/go-playground/validator/v10 v10.27.0
 	github.com/gorilla/websocket v1.5.3
 	github.com/stretchr/testify v1.11.1
@@ -6,6 +6,7 @@ package config
 import (
 	"fmt"
 	"log/slog"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
@@ -16,13 +17,70 @@ const (
 	DefaultPort                     = 8080
 	DefaultHost                     = "0.0.0.0"
 	DefaultCORSOrigin               = "http://localhost:3000"
+	DefaultOriginCheck              = "auto" // strict in production, permissive elsewhere
+	DefaultSecurityHeaders          = true
+	DefaultMaintenanceMode          = false
+	DefaultMaintenanceMessage       = "Server is in maintenance mode, please retry later"
+	DefaultFrameOptions             = "DENY"
+	DefaultContentSecurityPolicy    = "default-src 'none'; frame-ancestors 'none'"
+	DefaultHSTSMaxAge               = 31536000 // seconds; one year
 	DefaultLogLevel                 = "info"
+	DefaultLogFormat                = "auto" // JSON or text by environment
+	DefaultLogOutput                = "stderr"
+	DefaultLogMaxSizeMB             = 100
+	DefaultLogMaxBackups            = 5
+	DefaultReadyLogMessage          = "Server ready"
 	DefaultEnvironment              = "development"
 	DefaultWebSocketReadBufferSize  = 1024
 	DefaultWebSocketWriteBufferSize = 1024
 	DefaultMaxConnections           = 1000
 	DefaultHeartbeatInterval        = 30   // seconds
 	DefaultSessionTimeout           = 3600 // 1 hour in seconds
+	DefaultSessionCleanupInterval   = 600  // 10 minutes in seconds
+	DefaultSessionCleanupJitter     = 0    // seconds; no jitter
+	DefaultSessionCleanupBatch      = 0    // sessions scanned per run; all
+	DefaultSessionStoreRetry        = 5    // seconds
+	DefaultMaxSessions              = 0    // unlimited
+	DefaultMaxSessionsPerPrincipal  = 0    // unlimited
+	DefaultMaxSessionDataKeys       = 0    // unlimited
+	DefaultMaxSessionDataBytes      = 0    // across all sessions; unlimited
+	DefaultMaxSessionReconnects     = 0    // unlimited
+	DefaultMaxHandshakesPerIP       = 0    // unlimited
+	DefaultPendingMessageLimit      = 0    // buffering disabled
+	DefaultPendingMessageMaxAge     = 0    // seconds; buffered messages never age out
+	DefaultPendingMessagePolicy     = "drop-oldest"
+	DefaultPrincipalSessionPolicy   = "reject"
+	DefaultSessionDisconnectPolicy  = "detach"
+	DefaultStrictSessionOrdering    = true
+	DefaultShutdownBroadcastPolicy  = "flush"
+	DefaultSessionReservedKeyPrefix = "_"
+	DefaultMethodIntrospection      = "admin"
+	DefaultAuthExemptMethods        = "ping"
+	DefaultMethodNameNormalization  = "strict"
+	DefaultJSONRPCTrailingData      = "strict"
+	DefaultJSONRPCAckResponse       = "true"
+	DefaultReconnectRetryAfter      = 5  // seconds
+	DefaultConnectionLogSampleRate  = 1  // log every connection event
+	DefaultWriteBatchLimit          = 64 // messages coalesced per frame
+	DefaultSlowClientTolerance      = 0  // never disconnect for a backlogged queue
+	DefaultRequestSequencing        = false
+	DefaultSessionInfoMaxCodes      = 100
+	DefaultWebSocketPreflight       = "reject"
+	DefaultBroadcastQueueSize       = 64
+	DefaultGzipMinSize              = 1024 // bytes
+	DefaultTrimTrailingNewline      = false
+	DefaultSessionStoreFallback     = true
+	DefaultNotificationDedupWindow  = 0 // milliseconds; deduplication disabled
+	DefaultRPCMaxBodyBytes          = 1 << 20
+	DefaultRPCRequestTimeout        = 30 // seconds
+	DefaultRPCAcceptGzip            = true
+	DefaultRPCContentTypeMode       = "strict"
+	DefaultRPCRateLimit             = 0 // requests per second per client IP; unlimited
+	DefaultRPCRateBurst             = 20
+	DefaultMaxConcurrentHandlers    = 0 // across all connections; unlimited
+	DefaultHandlerTimeout           = 0 // seconds; handlers run until they return
+	DefaultMaxBatchSize             = 0 // requests per JSON-RPC batch; unlimited
+	DefaultConcurrencyPolicy        = "queue"
 )
 
 // Config represents the complete configuration for the FLE server.
@@ -32,12 +90,78 @@ type Config struct {
 	Port int    `json:"port" env:"PORT"`
 	Host string `json:"host" env:"HOST"`
 
+	// InstanceID identifies this server instance in logs and server.status;
+	// empty means a random ID is generated at startup. InstanceIDHeader also
+	// echoes it in an X-Instance-ID header on every HTTP response
+	InstanceID       string `json:"instanceId" env:"INSTANCE_ID"`
+	InstanceIDHeader bool   `json:"instanceIdHeader" env:"INSTANCE_ID_HEADER"`
+
+	// MaintenanceMode refuses new WebSocket connections, and so new sessions,
+	// with MaintenanceMessage while existing connections keep working
+	MaintenanceMode    bool   `json:"maintenanceMode" env:"MAINTENANCE_MODE"`
+	MaintenanceMessage string `json:"maintenanceMessage" env:"MAINTENANCE_MESSAGE"`
+
 	// CORS configuration for frontend development
 	CORSOrigin string `json:"corsOrigin" env:"CORS_ORIGIN"`
 
+	// AllowedOrigins is a comma-separated list of origins allowed to open WebSocket
+	// connections; empty means only CORSOrigin. "*" allows any origin.
+	AllowedOrigins string `json:"allowedOrigins" env:"ALLOWED_ORIGINS"`
+
+	// OriginCheck controls WebSocket origin checking: strict enforces the allowlist,
+	// permissive accepts any origin, and auto is strict only in production
+	OriginCheck string `json:"originCheck" env:"ORIGIN_CHECK"`
+
+	// WebSocketPreflight decides how OPTIONS /ws is answered: reject responds
+	// 405, as WebSocket upgrades are never preflighted, and allow responds 204
+	// with CORS headers permitting only GET
+	WebSocketPreflight string `json:"webSocketPreflight" env:"WS_PREFLIGHT"`
+
+	// SecurityHeaders adds X-Content-Type-Options, X-Frame-Options,
+	// Content-Security-Policy and, over TLS, Strict-Transport-Security to
+	// HTTP responses other than WebSocket upgrades
+	SecurityHeaders bool `json:"securityHeaders" env:"SECURITY_HEADERS"`
+
+	// FrameOptions is the X-Frame-Options value: DENY or SAMEORIGIN
+	FrameOptions string `json:"frameOptions" env:"FRAME_OPTIONS"`
+
+	// ContentSecurityPolicy is the Content-Security-Policy value; empty omits the header
+	ContentSecurityPolicy string `json:"contentSecurityPolicy" env:"CONTENT_SECURITY_POLICY"`
+
+	// HSTSMaxAge is the Strict-Transport-Security max-age in seconds for TLS
+	// requests; zero omits the header
+	HSTSMaxAge int `json:"hstsMaxAge" env:"HSTS_MAX_AGE"`
+
+	// GzipMinSize is the size in bytes from which HTTP responses are gzipped
+	// for clients that accept it (0 disables compression); WebSocket upgrades
+	// are never compressed
+	GzipMinSize int `json:"gzipMinSize" env:"GZIP_MIN_SIZE"`
+
 	// Logging configuration
 	LogLevel string `json:"logLevel" env:"LOG_LEVEL"`
 
+	// LogFormat forces json or text log output; auto picks by environment
+	LogFormat string `json:"logFormat" env:"LOG_FORMAT"`
+
+	// LogOutput is stderr or file; file writes to LogFilePath, rotating it at
+	// LogMaxSizeMB megabytes and keeping LogMaxBackups rotated files
+	LogOutput     string `json:"logOutput" env:"LOG_OUTPUT"`
+	LogFilePath   string `json:"logFilePath" env:"LOG_FILE_PATH"`
+	LogMaxSizeMB  int    `json:"logMaxSizeMB" env:"LOG_MAX_SIZE_MB"`
+	LogMaxBackups int    `json:"logMaxBackups" env:"LOG_MAX_BACKUPS"`
+
+	// LogPayloads logs JSON-RPC request params at debug level, masking each
+	// method's RedactFields
+	LogPayloads bool `json:"logPayloads" env:"LOG_PAYLOADS"`
+
+	// LogUnknownNotifications logs notifications to unregistered methods at
+	// warn level; they are otherwise ignored silently, as the spec requires
+	LogUnknownNotifications bool `json:"logUnknownNotifications" env:"LOG_UNKNOWN_NOTIFICATIONS"`
+
+	// ReadyLogMessage is the message of the line logged once the listener is
+	// bound and accepting connections, for log-based readiness checks
+	ReadyLogMessage string `json:"readyLogMessage" env:"READY_LOG_MESSAGE"`
+
 	// Environment (development, production, test)
 	Environment string `json:"environment" env:"ENV"`
 
@@ -45,12 +169,209 @@ type Config struct {
 	WebSocketReadBufferSize  int `json:"wsReadBufferSize" env:"WS_READ_BUFFER_SIZE"`
 	WebSocketWriteBufferSize int `json:"wsWriteBufferSize" env:"WS_WRITE_BUFFER_SIZE"`
 
-	// Connection management
+	// Connection management; connections beyond MaxConnections are rejected by the hub
 	MaxConnections    int `json:"maxConnections" env:"MAX_CONNECTIONS"`
 	HeartbeatInterval int `json:"heartbeatInterval" env:"HEARTBEAT_INTERVAL"`
 
+	// MaxHandshakesPerIP caps the WebSocket handshakes in flight from one client
+	// IP (0 means unlimited); excess upgrades are rejected with HTTP 429
+	MaxHandshakesPerIP int `json:"maxHandshakesPerIP" env:"MAX_HANDSHAKES_PER_IP"`
+
+	// TrustedProxies is a comma-separated list of proxy IPs or CIDR ranges whose
+	// X-Forwarded-For header identifies the client IP for per-IP limits
+	TrustedProxies string `json:"trustedProxies" env:"TRUSTED_PROXIES"`
+
+	// ReconnectRetryAfter is the backoff in seconds suggested to clients in close
+	// frames sent on shutdown or overload
+	ReconnectRetryAfter int `json:"reconnectRetryAfter" env:"RECONNECT_RETRY_AFTER"`
+
+	// ConnectionLogSampleRate logs one in N connection lifecycle events (1 logs all)
+	ConnectionLogSampleRate int `json:"connectionLogSampleRate" env:"CONNECTION_LOG_SAMPLE_RATE"`
+
+	// WriteBatchLimit caps the queued messages a connection coalesces into one
+	// frame per write, so a flooded queue cannot delay pings
+	WriteBatchLimit int `json:"writeBatchLimit" env:"WRITE_BATCH_LIMIT"`
+
+	// SlowClientTolerance is how many message batches in a row a connection is
+	// written while its send queue is at least half full before it is
+	// disconnected as too slow; zero disables the check. A write timeout always
+	// disconnects
+	SlowClientTolerance int `json:"slowClientTolerance" env:"SLOW_CLIENT_TOLERANCE"`
+
+	// RequestSequencing rejects WebSocket requests whose optional "_seq" param does
+	// not increase on the connection, catching replayed or reordered requests
+	RequestSequencing bool `json:"requestSequencing" env:"REQUEST_SEQUENCING"`
+
+	// TrimTrailingNewline removes a single trailing newline from inbound
+	// WebSocket text messages before they are parsed
+	TrimTrailingNewline bool `json:"trimTrailingNewline" env:"TRIM_TRAILING_NEWLINE"`
+
+	// SessionInfoMaxCodes caps the session codes listed by getSessionInfo, which
+	// then reports the total and sets truncated (0 lists them all)
+	SessionInfoMaxCodes int `json:"sessionInfoMaxCodes" env:"SESSION_INFO_MAX_CODES"`
+
+	// NotificationDedupWindow suppresses notifications repeating the same method and
+	// params to a session within this many milliseconds (0 disables it)
+	NotificationDedupWindow int `json:"notificationDedupWindow" env:"NOTIFICATION_DEDUP_WINDOW_MS"`
+
+	// RPCMaxBodyBytes caps the size of a POST /rpc request body after any
+	// decompression, so a small gzipped body cannot expand without bound
+	RPCMaxBodyBytes int `json:"rpcMaxBodyBytes" env:"RPC_MAX_BODY_BYTES"`
+
+	// RPCRequestTimeout bounds how long a POST /rpc request may spend in
+	// routing, in seconds (0 disables it); see handleRPC
+	RPCRequestTimeout int `json:"rpcRequestTimeout" env:"RPC_REQUEST_TIMEOUT"`
+
+	// RPCAcceptGzip allows POST /rpc bodies sent with Content-Encoding: gzip
+	RPCAcceptGzip bool `json:"rpcAcceptGzip" env:"RPC_ACCEPT_GZIP"`
+
+	// RPCContentTypeMode controls the POST /rpc Content-Type check: strict
+	// rejects bodies not sent as application/json or application/json-rpc
+	// with HTTP 415; lenient accepts any content type
+	RPCContentTypeMode string `json:"rpcContentTypeMode" env:"RPC_CONTENT_TYPE_MODE"`
+
+	// RPCRateLimit caps POST /rpc requests per second from each client IP
+	// (0 disables it); RPCRateBurst is how many may arrive at once
+	RPCRateLimit int `json:"rpcRateLimit" env:"RPC_RATE_LIMIT"`
+	RPCRateBurst int `json:"rpcRateBurst" env:"RPC_RATE_BURST"`
+
+	// MethodNameNormalization controls JSON-RPC method name matching: strict,
+	// trim (ignore surrounding whitespace) or lowercase (also ignore case)
+	MethodNameNormalization string `json:"methodNameNormalization" env:"METHOD_NAME_NORMALIZATION"`
+
+	// JSONRPCTrailingData decides how bytes after a JSON-RPC request object are
+	// treated: strict (reject anything but whitespace) or lenient (ignore them)
+	JSONRPCTrailingData string `json:"jsonrpcTrailingData" env:"JSONRPC_TRAILING_DATA"`
+
+	// JSONRPCAckResponse is the result sent for handlers returning
+	// jsonrpc.Ack: true or empty_object
+	JSONRPCAckResponse string `json:"jsonrpcAckResponse" env:"JSONRPC_ACK_RESPONSE"`
+
+	// MaxConcurrentHandlers caps JSON-RPC handlers running at once across all
+	// connections and POST /rpc (0 means unlimited)
+	MaxConcurrentHandlers int `json:"maxConcurrentHandlers" env:"MAX_CONCURRENT_HANDLERS"`
+
+	// HandlerTimeout bounds how long a JSON-RPC handler may run, in seconds;
+	// handlers still running are answered with a RequestTimeout error (0
+	// means no limit)
+	HandlerTimeout int `json:"handlerTimeout" env:"JSONRPC_HANDLER_TIMEOUT"`
+
+	// MaxBatchSize caps the requests in a JSON-RPC batch; larger batches are
+	// rejected whole with an InvalidRequest error (0 means unlimited)
+	MaxBatchSize int `json:"maxBatchSize" env:"JSONRPC_MAX_BATCH_SIZE"`
+
+	// ConcurrencyPolicy decides what happens to requests over a concurrency
+	// limit: queue (wait for a free slot) or reject (fail with server busy)
+	ConcurrencyPolicy string `json:"concurrencyPolicy" env:"CONCURRENCY_POLICY"`
+
 	// Session configuration
 	SessionTimeout int `json:"sessionTimeout" env:"SESSION_TIMEOUT"`
+
+	// SessionCleanupInterval is how often expired sessions are removed, in
+	// seconds; SessionCleanupJitter randomly offsets each run by up to that
+	// many seconds either way so instances do not clean up in lockstep
+	SessionCleanupInterval int `json:"sessionCleanupInterval" env:"SESSION_CLEANUP_INTERVAL"`
+	SessionCleanupJitter   int `json:"sessionCleanupJitter" env:"SESSION_CLEANUP_JITTER"`
+
+	// SessionCleanupBatch caps how many sessions each cleanup run scans, with
+	// later runs resuming where it stopped, to bound how long cleanup holds
+	// the session lock; 0 scans every session each run
+	SessionCleanupBatch int `json:"sessionCleanupBatch" env:"SESSION_CLEANUP_BATCH"`
+
+	// SessionStoreFallback keeps serving sessions from memory while the session
+	// store backend fails, retrying failed writes every SessionStoreRetry
+	// seconds; /readyz then reports degraded. Only used with a session store.
+	SessionStoreFallback bool `json:"sessionStoreFallback" env:"SESSION_STORE_FALLBACK"`
+	SessionStoreRetry    int  `json:"sessionStoreRetry" env:"SESSION_STORE_RETRY"`
+
+	// MaxSessions caps the number of live sessions (0 means unlimited); upgrades
+	// that would create a session beyond the cap are rejected with HTTP 503
+	MaxSessions int `json:"maxSessions" env:"MAX_SESSIONS"`
+
+	// MaxSessionsPerPrincipal caps the live sessions each authenticated
+	// principal may hold (0 means unlimited); PrincipalSessionPolicy picks
+	// whether a new one beyond the cap is rejected with HTTP 429 or evicts the
+	// principal's oldest session: "reject" or "evict-oldest"
+	MaxSessionsPerPrincipal int    `json:"maxSessionsPerPrincipal" env:"MAX_SESSIONS_PER_PRINCIPAL"`
+	PrincipalSessionPolicy  string `json:"principalSessionPolicy" env:"PRINCIPAL_SESSION_POLICY"`
+
+	// MaxSessionDataKeys caps the number of keys stored in each session's data
+	// (0 means unlimited); updates adding keys beyond it are rejected
+	MaxSessionDataKeys int `json:"maxSessionDataKeys" env:"MAX_SESSION_DATA_KEYS"`
+
+	// MaxSessionDataBytes caps the approximate combined JSON size of all session
+	// data (0 means unlimited); least recently accessed sessions are evicted beyond it
+	MaxSessionDataBytes int `json:"maxSessionDataBytes" env:"MAX_SESSION_DATA_BYTES"`
+
+	// MaxSessionReconnects caps how many times a session can be restored (0 means
+	// unlimited); exceeding it invalidates the session and the client gets a new one
+	MaxSessionReconnects int `json:"maxSessionReconnects" env:"MAX_SESSION_RECONNECTS"`
+
+	// SessionDisconnectPolicy decides what happens when a session's last
+	// connection closes: none, detach (record disconnected_at on the session)
+	// or notify (also send a session.disconnected notification to the clients
+	// sharing a room with the session)
+	SessionDisconnectPolicy string `json:"sessionDisconnectPolicy" env:"SESSION_DISCONNECT_POLICY"`
+
+	// SessionDataAllowedKeys is a comma-separated allowlist of session Data keys
+	// returned to clients (empty allows all); keys starting with
+	// SessionReservedKeyPrefix are never returned. The full map stays server-side
+	SessionDataAllowedKeys   string `json:"sessionDataAllowedKeys" env:"SESSION_DATA_ALLOWED_KEYS"`
+	SessionReservedKeyPrefix string `json:"sessionReservedKeyPrefix" env:"SESSION_RESERVED_KEY_PREFIX"`
+
+	// PendingMessageLimit caps the messages buffered per session while no client is
+	// connected (0 disables buffering); PendingMessagePolicy picks what to drop when
+	// the buffer is full: drop-oldest or drop-newest
+	PendingMessageLimit  int    `json:"pendingMessageLimit" env:"PENDING_MESSAGE_LIMIT"`
+	PendingMessagePolicy string `json:"pendingMessagePolicy" env:"PENDING_MESSAGE_POLICY"`
+
+	// PendingMessageMaxAge discards buffered messages older than this many
+	// seconds before they are replayed on reconnect (0 keeps them until their
+	// buffer has been idle for an hour)
+	PendingMessageMaxAge int `json:"pendingMessageMaxAge" env:"PENDING_MESSAGE_MAX_AGE"`
+
+	// StrictSessionOrdering guarantees per-session FIFO delivery across reconnects:
+	// buffered messages are always queued before live ones on the new connection
+	StrictSessionOrdering bool `json:"strictSessionOrdering" env:"STRICT_SESSION_ORDERING"`
+
+	// ShutdownBroadcastPolicy decides whether broadcasts still queued at
+	// shutdown are delivered before clients are closed (flush) or dropped (discard)
+	ShutdownBroadcastPolicy string `json:"shutdownBroadcastPolicy" env:"SHUTDOWN_BROADCAST_POLICY"`
+
+	// BroadcastQueueSize is how many broadcasts may wait for the hub loop;
+	// beyond it broadcasts wait for room, or are refused when sent without
+	// blocking
+	BroadcastQueueSize int `json:"broadcastQueueSize" env:"BROADCAST_QUEUE_SIZE"`
+
+	// AdminToken grants the admin role to WebSocket connections presenting it as a
+	// bearer token in the Authorization header; empty disables admin access
+	AdminToken string `json:"-" env:"ADMIN_TOKEN"`
+
+	// RequireAuth rejects JSON-RPC calls from unauthenticated callers, except to
+	// the comma-separated AuthExemptMethods, which stay callable pre-auth
+	RequireAuth       bool   `json:"requireAuth" env:"REQUIRE_AUTH"`
+	AuthExemptMethods string `json:"authExemptMethods" env:"AUTH_EXEMPT_METHODS"`
+
+	// RequireReconnectToken refuses to restore a session unless the upgrade
+	// presents its current reconnect token, even if none was issued for it. A
+	// presented token is always checked, and a restore that leaves out the
+	// token issued for the session is refused whatever this is set to
+	RequireReconnectToken bool `json:"requireReconnectToken" env:"REQUIRE_RECONNECT_TOKEN"`
+
+	// MethodIntrospection controls who may list the registered JSON-RPC methods
+	// via GET /rpc/methods and rpc.listMethods: off, admin or public
+	MethodIntrospection string `json:"methodIntrospection" env:"METHOD_INTROSPECTION"`
+
+	// EnabledMethods is a comma-separated manifest of the built-in JSON-RPC
+	// methods to register; empty registers all of them
+	EnabledMethods string `json:"enabledMethods" env:"ENABLED_METHODS"`
+
+	// SessionCaseSensitive disables lowercasing of session codes when true
+	SessionCaseSensitive bool `json:"sessionCaseSensitive" env:"SESSION_CASE_SENSITIVE"`
+
+	// SessionCodePrefix namespaces session codes, e.g. "prod" for codes like
+	// "prod:happy-panda-42", so environments can share a session store
+	SessionCodePrefix string `json:"sessionCodePrefix" env:"SESSION_CODE_PREFIX"`
 }
 
 // defaultConfig returns the default configuration values.
@@ -60,13 +381,70 @@ func defaultConfig() *Config {
 		Port:                     DefaultPort,
 		Host:                     DefaultHost,
 		CORSOrigin:               DefaultCORSOrigin,
+		OriginCheck:              DefaultOriginCheck,
+		WebSocketPreflight:       DefaultWebSocketPreflight,
+		SecurityHeaders:          DefaultSecurityHeaders,
+		MaintenanceMode:          DefaultMaintenanceMode,
+		MaintenanceMessage:       DefaultMaintenanceMessage,
+		FrameOptions:             DefaultFrameOptions,
+		ContentSecurityPolicy:    DefaultContentSecurityPolicy,
+		HSTSMaxAge:               DefaultHSTSMaxAge,
+		GzipMinSize:              DefaultGzipMinSize,
 		LogLevel:                 DefaultLogLevel,
+		LogFormat:                DefaultLogFormat,
+		LogOutput:                DefaultLogOutput,
+		LogMaxSizeMB:             DefaultLogMaxSizeMB,
+		LogMaxBackups:            DefaultLogMaxBackups,
+		ReadyLogMessage:          DefaultReadyLogMessage,
 		Environment:              DefaultEnvironment,
 		WebSocketReadBufferSize:  DefaultWebSocketReadBufferSize,
 		WebSocketWriteBufferSize: DefaultWebSocketWriteBufferSize,
 		MaxConnections:           DefaultMaxConnections,
+		MaxHandshakesPerIP:       DefaultMaxHandshakesPerIP,
 		HeartbeatInterval:        DefaultHeartbeatInterval,
+		ReconnectRetryAfter:      DefaultReconnectRetryAfter,
+		ConnectionLogSampleRate:  DefaultConnectionLogSampleRate,
+		WriteBatchLimit:          DefaultWriteBatchLimit,
+		SlowClientTolerance:      DefaultSlowClientTolerance,
+		RequestSequencing:        DefaultRequestSequencing,
+		SessionInfoMaxCodes:      DefaultSessionInfoMaxCodes,
+		TrimTrailingNewline:      DefaultTrimTrailingNewline,
+		NotificationDedupWindow:  DefaultNotificationDedupWindow,
+		RPCMaxBodyBytes:          DefaultRPCMaxBodyBytes,
+		RPCRequestTimeout:        DefaultRPCRequestTimeout,
+		RPCRateLimit:             DefaultRPCRateLimit,
+		RPCRateBurst:             DefaultRPCRateBurst,
+		RPCAcceptGzip:            DefaultRPCAcceptGzip,
+		RPCContentTypeMode:       DefaultRPCContentTypeMode,
 		SessionTimeout:           DefaultSessionTimeout,
+		SessionCleanupInterval:   DefaultSessionCleanupInterval,
+		SessionCleanupJitter:     DefaultSessionCleanupJitter,
+		SessionCleanupBatch:      DefaultSessionCleanupBatch,
+		SessionStoreFallback:     DefaultSessionStoreFallback,
+		SessionStoreRetry:        DefaultSessionStoreRetry,
+		MaxSessions:              DefaultMaxSessions,
+		MaxSessionsPerPrincipal:  DefaultMaxSessionsPerPrincipal,
+		PrincipalSessionPolicy:   DefaultPrincipalSessionPolicy,
+		MaxSessionDataKeys:       DefaultMaxSessionDataKeys,
+		MaxSessionDataBytes:      DefaultMaxSessionDataBytes,
+		MaxSessionReconnects:     DefaultMaxSessionReconnects,
+		SessionDisconnectPolicy:  DefaultSessionDisconnectPolicy,
+		SessionReservedKeyPrefix: DefaultSessionReservedKeyPrefix,
+		PendingMessageLimit:      DefaultPendingMessageLimit,
+		PendingMessagePolicy:     DefaultPendingMessagePolicy,
+		PendingMessageMaxAge:     DefaultPendingMessageMaxAge,
+		StrictSessionOrdering:    DefaultStrictSessionOrdering,
+		ShutdownBroadcastPolicy:  DefaultShutdownBroadcastPolicy,
+		BroadcastQueueSize:       DefaultBroadcastQueueSize,
+		MethodIntrospection:      DefaultMethodIntrospection,
+		AuthExemptMethods:        DefaultAuthExemptMethods,
+		MethodNameNormalization:  DefaultMethodNameNormalization,
+		JSONRPCTrailingData:      DefaultJSONRPCTrailingData,
+		JSONRPCAckResponse:       DefaultJSONRPCAckResponse,
+		MaxConcurrentHandlers:    DefaultMaxConcurrentHandlers,
+		HandlerTimeout:           DefaultHandlerTimeout,
+		MaxBatchSize:             DefaultMaxBatchSize,
+		ConcurrencyPolicy:        DefaultConcurrencyPolicy,
 	}
 }
 
@@ -74,41 +452,289 @@ func defaultConfig() *Config {
 // Missing environment variables will use sensible defaults.
 // Returns an error if any required validation fails.
 func Load() (*Config, error) {
+	return load(os.LookupEnv)
+}
+
+// LoadFile reads configuration like Load, with the variables set in the env
+// file at path taking precedence over the environment. Unlike the process
+// environment, the file can change while the server runs, so it is the
+// source re-read on reload. An empty path is the same as Load.
+func LoadFile(path string) (*Config, error) {
+	if path == "" {
+		return Load()
+	}
+
+	values, err := readEnvFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return load(func(name string) (string, bool) {
+		if value, ok := values[name]; ok {
+			return value, true
+		}
+		return os.LookupEnv(name)
+	})
+}
+
+// load reads configuration through lookup, which reports a variable's value
+// and whether it is set.
+func load(lookup func(string) (string, bool)) (*Config, error) {
 	config := defaultConfig()
 
 	// Load environment variables with type conversion
-	if err := loadEnvInt("PORT", &config.Port); err != nil {
+	if err := loadEnvInt(lookup, "PORT", &config.Port); err != nil {
 		return nil, fmt.Errorf("invalid PORT: %w", err)
 	}
 
-	loadEnvString("HOST", &config.Host)
+	loadEnvString(lookup, "HOST", &config.Host)
+
+	loadEnvString(lookup, "INSTANCE_ID", &config.InstanceID)
+	if err := loadEnvBool(lookup, "INSTANCE_ID_HEADER", &config.InstanceIDHeader); err != nil {
+		return nil, fmt.Errorf("invalid INSTANCE_ID_HEADER: %w", err)
+	}
+
+	if err := loadEnvBool(lookup, "MAINTENANCE_MODE", &config.MaintenanceMode); err != nil {
+		return nil, fmt.Errorf("invalid MAINTENANCE_MODE: %w", err)
+	}
+	loadEnvString(lookup, "MAINTENANCE_MESSAGE", &config.MaintenanceMessage)
+
+	loadEnvString(lookup, "CORS_ORIGIN", &config.CORSOrigin)
+	loadEnvString(lookup, "ALLOWED_ORIGINS", &config.AllowedOrigins)
+	loadEnvString(lookup, "ORIGIN_CHECK", &config.OriginCheck)
 
-	loadEnvString("CORS_ORIGIN", &config.CORSOrigin)
+	loadEnvString(lookup, "WS_PREFLIGHT", &config.WebSocketPreflight)
 
-	loadEnvString("LOG_LEVEL", &config.LogLevel)
+	if err := loadEnvBool(lookup, "SECURITY_HEADERS", &config.SecurityHeaders); err != nil {
+		return nil, fmt.Errorf("invalid SECURITY_HEADERS: %w", err)
+	}
+	loadEnvString(lookup, "FRAME_OPTIONS", &config.FrameOptions)
+	// Unlike other strings, an empty CONTENT_SECURITY_POLICY is honored: it omits the header
+	if value, ok := lookup("CONTENT_SECURITY_POLICY"); ok {
+		config.ContentSecurityPolicy = value
+	}
+	if err := loadEnvInt(lookup, "HSTS_MAX_AGE", &config.HSTSMaxAge); err != nil {
+		return nil, fmt.Errorf("invalid HSTS_MAX_AGE: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "GZIP_MIN_SIZE", &config.GzipMinSize); err != nil {
+		return nil, fmt.Errorf("invalid GZIP_MIN_SIZE: %w", err)
+	}
 
-	loadEnvString("ENV", &config.Environment)
+	loadEnvString(lookup, "LOG_LEVEL", &config.LogLevel)
+	loadEnvString(lookup, "LOG_FORMAT", &config.LogFormat)
+	loadEnvString(lookup, "LOG_OUTPUT", &config.LogOutput)
+	loadEnvString(lookup, "LOG_FILE_PATH", &config.LogFilePath)
 
-	if err := loadEnvInt("WS_READ_BUFFER_SIZE", &config.WebSocketReadBufferSize); err != nil {
+	if err := loadEnvInt(lookup, "LOG_MAX_SIZE_MB", &config.LogMaxSizeMB); err != nil {
+		return nil, fmt.Errorf("invalid LOG_MAX_SIZE_MB: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "LOG_MAX_BACKUPS", &config.LogMaxBackups); err != nil {
+		return nil, fmt.Errorf("invalid LOG_MAX_BACKUPS: %w", err)
+	}
+
+	if err := loadEnvBool(lookup, "LOG_PAYLOADS", &config.LogPayloads); err != nil {
+		return nil, fmt.Errorf("invalid LOG_PAYLOADS: %w", err)
+	}
+
+	if err := loadEnvBool(lookup, "LOG_UNKNOWN_NOTIFICATIONS", &config.LogUnknownNotifications); err != nil {
+		return nil, fmt.Errorf("invalid LOG_UNKNOWN_NOTIFICATIONS: %w", err)
+	}
+
+	loadEnvString(lookup, "READY_LOG_MESSAGE", &config.ReadyLogMessage)
+
+	loadEnvString(lookup, "ENV", &config.Environment)
+
+	if err := loadEnvInt(lookup, "WS_READ_BUFFER_SIZE", &config.WebSocketReadBufferSize); err != nil {
 		return nil, fmt.Errorf("invalid WS_READ_BUFFER_SIZE: %w", err)
 	}
 
-	if err := loadEnvInt("WS_WRITE_BUFFER_SIZE", &config.WebSocketWriteBufferSize); err != nil {
+	if err := loadEnvInt(lookup, "WS_WRITE_BUFFER_SIZE", &config.WebSocketWriteBufferSize); err != nil {
 		return nil, fmt.Errorf("invalid WS_WRITE_BUFFER_SIZE: %w", err)
 	}
 
-	if err := loadEnvInt("MAX_CONNECTIONS", &config.MaxConnections); err != nil {
+	if err := loadEnvInt(lookup, "MAX_CONNECTIONS", &config.MaxConnections); err != nil {
 		return nil, fmt.Errorf("invalid MAX_CONNECTIONS: %w", err)
 	}
 
-	if err := loadEnvInt("HEARTBEAT_INTERVAL", &config.HeartbeatInterval); err != nil {
+	if err := loadEnvInt(lookup, "MAX_HANDSHAKES_PER_IP", &config.MaxHandshakesPerIP); err != nil {
+		return nil, fmt.Errorf("invalid MAX_HANDSHAKES_PER_IP: %w", err)
+	}
+
+	loadEnvString(lookup, "TRUSTED_PROXIES", &config.TrustedProxies)
+
+	if err := loadEnvInt(lookup, "HEARTBEAT_INTERVAL", &config.HeartbeatInterval); err != nil {
 		return nil, fmt.Errorf("invalid HEARTBEAT_INTERVAL: %w", err)
 	}
 
-	if err := loadEnvInt("SESSION_TIMEOUT", &config.SessionTimeout); err != nil {
+	if err := loadEnvInt(lookup, "RECONNECT_RETRY_AFTER", &config.ReconnectRetryAfter); err != nil {
+		return nil, fmt.Errorf("invalid RECONNECT_RETRY_AFTER: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "CONNECTION_LOG_SAMPLE_RATE", &config.ConnectionLogSampleRate); err != nil {
+		return nil, fmt.Errorf("invalid CONNECTION_LOG_SAMPLE_RATE: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "WRITE_BATCH_LIMIT", &config.WriteBatchLimit); err != nil {
+		return nil, fmt.Errorf("invalid WRITE_BATCH_LIMIT: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "SLOW_CLIENT_TOLERANCE", &config.SlowClientTolerance); err != nil {
+		return nil, fmt.Errorf("invalid SLOW_CLIENT_TOLERANCE: %w", err)
+	}
+
+	if err := loadEnvBool(lookup, "REQUEST_SEQUENCING", &config.RequestSequencing); err != nil {
+		return nil, fmt.Errorf("invalid REQUEST_SEQUENCING: %w", err)
+	}
+
+	if err := loadEnvBool(lookup, "TRIM_TRAILING_NEWLINE", &config.TrimTrailingNewline); err != nil {
+		return nil, fmt.Errorf("invalid TRIM_TRAILING_NEWLINE: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "SESSION_INFO_MAX_CODES", &config.SessionInfoMaxCodes); err != nil {
+		return nil, fmt.Errorf("invalid SESSION_INFO_MAX_CODES: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "NOTIFICATION_DEDUP_WINDOW_MS", &config.NotificationDedupWindow); err != nil {
+		return nil, fmt.Errorf("invalid NOTIFICATION_DEDUP_WINDOW_MS: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "RPC_MAX_BODY_BYTES", &config.RPCMaxBodyBytes); err != nil {
+		return nil, fmt.Errorf("invalid RPC_MAX_BODY_BYTES: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "RPC_REQUEST_TIMEOUT", &config.RPCRequestTimeout); err != nil {
+		return nil, fmt.Errorf("invalid RPC_REQUEST_TIMEOUT: %w", err)
+	}
+
+	if err := loadEnvBool(lookup, "RPC_ACCEPT_GZIP", &config.RPCAcceptGzip); err != nil {
+		return nil, fmt.Errorf("invalid RPC_ACCEPT_GZIP: %w", err)
+	}
+
+	loadEnvString(lookup, "RPC_CONTENT_TYPE_MODE", &config.RPCContentTypeMode)
+
+	if err := loadEnvInt(lookup, "RPC_RATE_LIMIT", &config.RPCRateLimit); err != nil {
+		return nil, fmt.Errorf("invalid RPC_RATE_LIMIT: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "RPC_RATE_BURST", &config.RPCRateBurst); err != nil {
+		return nil, fmt.Errorf("invalid RPC_RATE_BURST: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "SESSION_TIMEOUT", &config.SessionTimeout); err != nil {
 		return nil, fmt.Errorf("invalid SESSION_TIMEOUT: %w", err)
 	}
 
+	if err := loadEnvInt(lookup, "SESSION_CLEANUP_INTERVAL", &config.SessionCleanupInterval); err != nil {
+		return nil, fmt.Errorf("invalid SESSION_CLEANUP_INTERVAL: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "SESSION_CLEANUP_JITTER", &config.SessionCleanupJitter); err != nil {
+		return nil, fmt.Errorf("invalid SESSION_CLEANUP_JITTER: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "SESSION_CLEANUP_BATCH", &config.SessionCleanupBatch); err != nil {
+		return nil, fmt.Errorf("invalid SESSION_CLEANUP_BATCH: %w", err)
+	}
+
+	if err := loadEnvBool(lookup, "SESSION_STORE_FALLBACK", &config.SessionStoreFallback); err != nil {
+		return nil, fmt.Errorf("invalid SESSION_STORE_FALLBACK: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "SESSION_STORE_RETRY", &config.SessionStoreRetry); err != nil {
+		return nil, fmt.Errorf("invalid SESSION_STORE_RETRY: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "MAX_SESSIONS", &config.MaxSessions); err != nil {
+		return nil, fmt.Errorf("invalid MAX_SESSIONS: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "MAX_SESSIONS_PER_PRINCIPAL", &config.MaxSessionsPerPrincipal); err != nil {
+		return nil, fmt.Errorf("invalid MAX_SESSIONS_PER_PRINCIPAL: %w", err)
+	}
+
+	loadEnvString(lookup, "PRINCIPAL_SESSION_POLICY", &config.PrincipalSessionPolicy)
+
+	if err := loadEnvInt(lookup, "MAX_SESSION_DATA_KEYS", &config.MaxSessionDataKeys); err != nil {
+		return nil, fmt.Errorf("invalid MAX_SESSION_DATA_KEYS: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "MAX_SESSION_DATA_BYTES", &config.MaxSessionDataBytes); err != nil {
+		return nil, fmt.Errorf("invalid MAX_SESSION_DATA_BYTES: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "MAX_SESSION_RECONNECTS", &config.MaxSessionReconnects); err != nil {
+		return nil, fmt.Errorf("invalid MAX_SESSION_RECONNECTS: %w", err)
+	}
+
+	loadEnvString(lookup, "SESSION_DISCONNECT_POLICY", &config.SessionDisconnectPolicy)
+
+	loadEnvString(lookup, "SESSION_DATA_ALLOWED_KEYS", &config.SessionDataAllowedKeys)
+	loadEnvString(lookup, "SESSION_RESERVED_KEY_PREFIX", &config.SessionReservedKeyPrefix)
+
+	if err := loadEnvInt(lookup, "PENDING_MESSAGE_LIMIT", &config.PendingMessageLimit); err != nil {
+		return nil, fmt.Errorf("invalid PENDING_MESSAGE_LIMIT: %w", err)
+	}
+
+	loadEnvString(lookup, "PENDING_MESSAGE_POLICY", &config.PendingMessagePolicy)
+
+	if err := loadEnvInt(lookup, "PENDING_MESSAGE_MAX_AGE", &config.PendingMessageMaxAge); err != nil {
+		return nil, fmt.Errorf("invalid PENDING_MESSAGE_MAX_AGE: %w", err)
+	}
+
+	if err := loadEnvBool(lookup, "STRICT_SESSION_ORDERING", &config.StrictSessionOrdering); err != nil {
+		return nil, fmt.Errorf("invalid STRICT_SESSION_ORDERING: %w", err)
+	}
+
+	loadEnvString(lookup, "SHUTDOWN_BROADCAST_POLICY", &config.ShutdownBroadcastPolicy)
+
+	if err := loadEnvInt(lookup, "BROADCAST_QUEUE_SIZE", &config.BroadcastQueueSize); err != nil {
+		return nil, fmt.Errorf("invalid BROADCAST_QUEUE_SIZE: %w", err)
+	}
+
+	loadEnvString(lookup, "ADMIN_TOKEN", &config.AdminToken)
+
+	if err := loadEnvBool(lookup, "REQUIRE_AUTH", &config.RequireAuth); err != nil {
+		return nil, fmt.Errorf("invalid REQUIRE_AUTH: %w", err)
+	}
+
+	loadEnvString(lookup, "AUTH_EXEMPT_METHODS", &config.AuthExemptMethods)
+
+	if err := loadEnvBool(lookup, "REQUIRE_RECONNECT_TOKEN", &config.RequireReconnectToken); err != nil {
+		return nil, fmt.Errorf("invalid REQUIRE_RECONNECT_TOKEN: %w", err)
+	}
+
+	loadEnvString(lookup, "METHOD_INTROSPECTION", &config.MethodIntrospection)
+
+	loadEnvString(lookup, "ENABLED_METHODS", &config.EnabledMethods)
+
+	loadEnvString(lookup, "METHOD_NAME_NORMALIZATION", &config.MethodNameNormalization)
+
+	loadEnvString(lookup, "JSONRPC_TRAILING_DATA", &config.JSONRPCTrailingData)
+
+	loadEnvString(lookup, "JSONRPC_ACK_RESPONSE", &config.JSONRPCAckResponse)
+
+	if err := loadEnvInt(lookup, "MAX_CONCURRENT_HANDLERS", &config.MaxConcurrentHandlers); err != nil {
+		return nil, fmt.Errorf("invalid MAX_CONCURRENT_HANDLERS: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "JSONRPC_HANDLER_TIMEOUT", &config.HandlerTimeout); err != nil {
+		return nil, fmt.Errorf("invalid JSONRPC_HANDLER_TIMEOUT: %w", err)
+	}
+
+	if err := loadEnvInt(lookup, "JSONRPC_MAX_BATCH_SIZE", &config.MaxBatchSize); err != nil {
+		return nil, fmt.Errorf("invalid JSONRPC_MAX_BATCH_SIZE: %w", err)
+	}
+
+	loadEnvString(lookup, "CONCURRENCY_POLICY", &config.ConcurrencyPolicy)
+
+	if err := loadEnvBool(lookup, "SESSION_CASE_SENSITIVE", &config.SessionCaseSensitive); err != nil {
+		return nil, fmt.Errorf("invalid SESSION_CASE_SENSITIVE: %w", err)
+	}
+
+	loadEnvString(lookup, "SESSION_CODE_PREFIX", &config.SessionCodePrefix)
+
 	// Validate configuration
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
@@ -172,6 +798,39 @@ func (c *Config) validateLogLevel() error {
 		return fmt.Errorf("invalid log level %q, must be one of: debug, info, warn, error", c.LogLevel)
 	}
 
+	validLogFormats := map[string]bool{
+		"auto": true,
+		"json": true,
+		"text": true,
+	}
+	if !validLogFormats[strings.ToLower(c.LogFormat)] {
+		return fmt.Errorf("invalid log format %q, must be one of: auto, json, text", c.LogFormat)
+	}
+
+	validLogOutputs := map[string]bool{
+		"stderr": true,
+		"file":   true,
+	}
+	if !validLogOutputs[strings.ToLower(c.LogOutput)] {
+		return fmt.Errorf("invalid log output %q, must be one of: stderr, file", c.LogOutput)
+	}
+
+	if c.LogToFile() && c.LogFilePath == "" {
+		return fmt.Errorf("log file path cannot be empty when log output is file")
+	}
+
+	if c.LogMaxSizeMB <= 0 {
+		return fmt.Errorf("log max size must be positive, got %d", c.LogMaxSizeMB)
+	}
+
+	if c.LogMaxBackups < 0 {
+		return fmt.Errorf("log max backups must not be negative, got %d", c.LogMaxBackups)
+	}
+
+	if strings.TrimSpace(c.ReadyLogMessage) == "" {
+		return fmt.Errorf("ready log message must not be empty")
+	}
+
 	return nil
 }
 
@@ -212,17 +871,271 @@ func (c *Config) validateConnectionSettings() error {
 		return fmt.Errorf("max connections must be positive, got %d", c.MaxConnections)
 	}
 
+	if c.MaxHandshakesPerIP < 0 {
+		return fmt.Errorf("max handshakes per IP must not be negative, got %d", c.MaxHandshakesPerIP)
+	}
+
+	for _, proxy := range c.TrustedProxyList() {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				return fmt.Errorf("invalid trusted proxy %q, must be an IP address or CIDR range", proxy)
+			}
+		}
+	}
+
 	if c.HeartbeatInterval <= 0 {
 		return fmt.Errorf("heartbeat interval must be positive, got %d", c.HeartbeatInterval)
 	}
 
+	if c.ReconnectRetryAfter < 0 {
+		return fmt.Errorf("reconnect retry-after must not be negative, got %d", c.ReconnectRetryAfter)
+	}
+
+	if c.ConnectionLogSampleRate <= 0 {
+		return fmt.Errorf("connection log sample rate must be positive, got %d", c.ConnectionLogSampleRate)
+	}
+
+	if c.WriteBatchLimit <= 0 {
+		return fmt.Errorf("write batch limit must be positive, got %d", c.WriteBatchLimit)
+	}
+
+	if c.BroadcastQueueSize <= 0 {
+		return fmt.Errorf("broadcast queue size must be positive, got %d", c.BroadcastQueueSize)
+	}
+
+	if c.SlowClientTolerance < 0 {
+		return fmt.Errorf("slow client tolerance must not be negative, got %d", c.SlowClientTolerance)
+	}
+
+	if c.SessionInfoMaxCodes < 0 {
+		return fmt.Errorf("session info max codes must not be negative, got %d", c.SessionInfoMaxCodes)
+	}
+
+	if c.NotificationDedupWindow < 0 {
+		return fmt.Errorf("notification dedup window must not be negative, got %d", c.NotificationDedupWindow)
+	}
+
+	if c.RPCMaxBodyBytes <= 0 {
+		return fmt.Errorf("rpc max body bytes must be positive, got %d", c.RPCMaxBodyBytes)
+	}
+
+	if c.RPCRequestTimeout < 0 {
+		return fmt.Errorf("rpc request timeout must not be negative, got %d", c.RPCRequestTimeout)
+	}
+
+	if c.RPCRateLimit < 0 {
+		return fmt.Errorf("rpc rate limit must not be negative, got %d", c.RPCRateLimit)
+	}
+
+	if c.RPCRateBurst <= 0 {
+		return fmt.Errorf("rpc rate burst must be positive, got %d", c.RPCRateBurst)
+	}
+
 	if c.SessionTimeout <= 0 {
 		return fmt.Errorf("session timeout must be positive, got %d", c.SessionTimeout)
 	}
 
+	if c.SessionCleanupInterval <= 0 {
+		return fmt.Errorf("session cleanup interval must be positive, got %d", c.SessionCleanupInterval)
+	}
+
+	if c.SessionCleanupJitter < 0 || 2*c.SessionCleanupJitter > c.SessionCleanupInterval {
+		return fmt.Errorf("session cleanup jitter must be between 0 and half the cleanup interval (%d), got %d",
+			c.SessionCleanupInterval/2, c.SessionCleanupJitter)
+	}
+
+	if c.SessionCleanupBatch < 0 {
+		return fmt.Errorf("session cleanup batch must not be negative, got %d", c.SessionCleanupBatch)
+	}
+
+	if c.SessionStoreRetry <= 0 {
+		return fmt.Errorf("session store retry must be positive, got %d", c.SessionStoreRetry)
+	}
+
+	if c.MaxSessions < 0 {
+		return fmt.Errorf("max sessions must not be negative, got %d", c.MaxSessions)
+	}
+
+	if c.MaxSessionsPerPrincipal < 0 {
+		return fmt.Errorf("max sessions per principal must not be negative, got %d", c.MaxSessionsPerPrincipal)
+	}
+
+	if c.MaxSessionDataKeys < 0 {
+		return fmt.Errorf("max session data keys must not be negative, got %d", c.MaxSessionDataKeys)
+	}
+
+	if c.MaxSessionDataBytes < 0 {
+		return fmt.Errorf("max session data bytes must not be negative, got %d", c.MaxSessionDataBytes)
+	}
+
+	if c.MaxSessionReconnects < 0 {
+		return fmt.Errorf("max session reconnects must not be negative, got %d", c.MaxSessionReconnects)
+	}
+
+	validDisconnectPolicies := map[string]bool{
+		"none":   true,
+		"detach": true,
+		"notify": true,
+	}
+	if !validDisconnectPolicies[strings.ToLower(c.SessionDisconnectPolicy)] {
+		return fmt.Errorf("invalid session disconnect policy %q, must be one of: none, detach, notify", c.SessionDisconnectPolicy)
+	}
+
+	for _, r := range c.SessionCodePrefix {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("invalid session code prefix %q, must contain only letters, digits, '-' and '_'", c.SessionCodePrefix)
+		}
+	}
+
+	if c.PendingMessageLimit < 0 {
+		return fmt.Errorf("pending message limit must not be negative, got %d", c.PendingMessageLimit)
+	}
+
+	if c.PendingMessageMaxAge < 0 {
+		return fmt.Errorf("pending message max age must not be negative, got %d", c.PendingMessageMaxAge)
+	}
+
+	validNormalizations := map[string]bool{
+		"strict":    true,
+		"trim":      true,
+		"lowercase": true,
+	}
+	if !validNormalizations[strings.ToLower(c.MethodNameNormalization)] {
+		return fmt.Errorf("invalid method name normalization %q, must be one of: strict, trim, lowercase", c.MethodNameNormalization)
+	}
+
+	validTrailingData := map[string]bool{
+		"strict":  true,
+		"lenient": true,
+	}
+	if !validTrailingData[strings.ToLower(c.JSONRPCTrailingData)] {
+		return fmt.Errorf("invalid JSON-RPC trailing data policy %q, must be one of: strict, lenient", c.JSONRPCTrailingData)
+	}
+
+	validAckResponses := map[string]bool{
+		"true":         true,
+		"empty_object": true,
+	}
+	if !validAckResponses[strings.ToLower(c.JSONRPCAckResponse)] {
+		return fmt.Errorf("invalid JSON-RPC ack response %q, must be one of: true, empty_object", c.JSONRPCAckResponse)
+	}
+
+	if c.MaxConcurrentHandlers < 0 {
+		return fmt.Errorf("max concurrent handlers must not be negative, got %d", c.MaxConcurrentHandlers)
+	}
+
+	if c.HandlerTimeout < 0 {
+		return fmt.Errorf("handler timeout must not be negative, got %d", c.HandlerTimeout)
+	}
+
+	if c.MaxBatchSize < 0 {
+		return fmt.Errorf("max batch size must not be negative, got %d", c.MaxBatchSize)
+	}
+
+	validConcurrencyPolicies := map[string]bool{
+		"queue":  true,
+		"reject": true,
+	}
+	if !validConcurrencyPolicies[strings.ToLower(c.ConcurrencyPolicy)] {
+		return fmt.Errorf("invalid concurrency policy %q, must be one of: queue, reject", c.ConcurrencyPolicy)
+	}
+
+	validOriginChecks := map[string]bool{
+		"auto":       true,
+		"permissive": true,
+		"strict":     true,
+	}
+	if !validOriginChecks[strings.ToLower(c.OriginCheck)] {
+		return fmt.Errorf("invalid origin check %q, must be one of: auto, permissive, strict", c.OriginCheck)
+	}
+
+	validPreflights := map[string]bool{
+		"reject": true,
+		"allow":  true,
+	}
+	if !validPreflights[strings.ToLower(c.WebSocketPreflight)] {
+		return fmt.Errorf("invalid WebSocket preflight %q, must be one of: reject, allow", c.WebSocketPreflight)
+	}
+
+	validFrameOptions := map[string]bool{
+		"deny":       true,
+		"sameorigin": true,
+	}
+	if !validFrameOptions[strings.ToLower(c.FrameOptions)] {
+		return fmt.Errorf("invalid frame options %q, must be one of: DENY, SAMEORIGIN", c.FrameOptions)
+	}
+
+	if c.HSTSMaxAge < 0 {
+		return fmt.Errorf("HSTS max-age must not be negative, got %d", c.HSTSMaxAge)
+	}
+
+	if c.GzipMinSize < 0 {
+		return fmt.Errorf("gzip min size must not be negative, got %d", c.GzipMinSize)
+	}
+
+	validIntrospection := map[string]bool{
+		"off":    true,
+		"admin":  true,
+		"public": true,
+	}
+	if !validIntrospection[strings.ToLower(c.MethodIntrospection)] {
+		return fmt.Errorf("invalid method introspection %q, must be one of: off, admin, public", c.MethodIntrospection)
+	}
+
+	validContentTypeModes := map[string]bool{
+		"strict":  true,
+		"lenient": true,
+	}
+	if !validContentTypeModes[strings.ToLower(c.RPCContentTypeMode)] {
+		return fmt.Errorf("invalid RPC content type mode %q, must be one of: strict, lenient", c.RPCContentTypeMode)
+	}
+
+	validPendingPolicies := map[string]bool{
+		"drop-oldest": true,
+		"drop-newest": true,
+	}
+	if !validPendingPolicies[strings.ToLower(c.PendingMessagePolicy)] {
+		return fmt.Errorf("invalid pending message policy %q, must be one of: drop-oldest, drop-newest", c.PendingMessagePolicy)
+	}
+
+	validPrincipalSessionPolicies := map[string]bool{
+		"reject":       true,
+		"evict-oldest": true,
+	}
+	if !validPrincipalSessionPolicies[strings.ToLower(c.PrincipalSessionPolicy)] {
+		return fmt.Errorf("invalid principal session policy %q, must be one of: reject, evict-oldest", c.PrincipalSessionPolicy)
+	}
+
+	validShutdownPolicies := map[string]bool{
+		"flush":   true,
+		"discard": true,
+	}
+	if !validShutdownPolicies[strings.ToLower(c.ShutdownBroadcastPolicy)] {
+		return fmt.Errorf("invalid shutdown broadcast policy %q, must be one of: flush, discard", c.ShutdownBroadcastPolicy)
+	}
+
 	return nil
 }
 
+// UseJSONLogs reports whether logs should be written as JSON. A LogFormat of
+// json or text forces the choice; auto returns envDefault, the caller's
+// environment-based format.
+func (c *Config) UseJSONLogs(envDefault bool) bool {
+	switch strings.ToLower(c.LogFormat) {
+	case "json":
+		return true
+	case "text":
+		return false
+	default:
+		return envDefault
+	}
+}
+
+// LogToFile returns true if logs should be written to LogFilePath.
+func (c *Config) LogToFile() bool {
+	return strings.ToLower(c.LogOutput) == "file"
+}
+
 // IsDevelopment returns true if the current environment is development.
 func (c *Config) IsDevelopment() bool {
 	return strings.ToLower(c.Environment) == "development"
@@ -233,6 +1146,71 @@ func (c *Config) IsProduction() bool {
 	return strings.ToLower(c.Environment) == "production"
 }
 
+// StrictOriginCheck returns true if WebSocket upgrades must come from an
+// allowlisted origin. With OriginCheck "auto" this is the case in production only.
+func (c *Config) StrictOriginCheck() bool {
+	switch strings.ToLower(c.OriginCheck) {
+	case "strict":
+		return true
+	case "permissive":
+		return false
+	default:
+		return c.IsProduction()
+	}
+}
+
+// OriginAllowlist returns the origins allowed to open WebSocket connections,
+// falling back to CORSOrigin when AllowedOrigins is empty.
+func (c *Config) OriginAllowlist() []string {
+	var origins []string
+	for _, origin := range strings.Split(c.AllowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 && c.CORSOrigin != "" {
+		origins = append(origins, c.CORSOrigin)
+	}
+	return origins
+}
+
+// AuthExemptMethodList returns the methods callable without authentication
+// when RequireAuth is set, parsed from the comma-separated AuthExemptMethods.
+func (c *Config) AuthExemptMethodList() []string {
+	return splitList(c.AuthExemptMethods)
+}
+
+// EnabledMethodList returns the built-in methods to register, parsed from the
+// comma-separated EnabledMethods. An empty list registers every method.
+func (c *Config) EnabledMethodList() []string {
+	return splitList(c.EnabledMethods)
+}
+
+// TrustedProxyList returns the trusted proxy IPs and CIDR ranges, parsed from
+// the comma-separated TrustedProxies.
+func (c *Config) TrustedProxyList() []string {
+	return splitList(c.TrustedProxies)
+}
+
+// SessionDataAllowedKeyList returns the session Data keys returned to
+// clients, parsed from the comma-separated SessionDataAllowedKeys. An empty
+// list allows every key that is not reserved.
+func (c *Config) SessionDataAllowedKeyList() []string {
+	return splitList(c.SessionDataAllowedKeys)
+}
+
+// splitList splits a comma-separated setting, trimming whitespace and
+// dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // IsTest returns true if the current environment is test.
 func (c *Config) IsTest() bool {
 	return strings.ToLower(c.Environment) == "test"
@@ -259,10 +1237,41 @@ func (c *Config) Address() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
 
+// readEnvFile parses an env file of NAME=value lines. Blank lines and lines
+// starting with # are skipped, an optional "export " prefix is allowed, and a
+// value may be wrapped in single or double quotes.
+func readEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("line %d: expected NAME=value", i+1)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
 // loadEnvString loads a string environment variable into the target pointer.
 // If the environment variable is not set, the target value remains unchanged.
-func loadEnvString(envVar string, target *string) {
-	if value := os.Getenv(envVar); value != "" {
+func loadEnvString(lookup func(string) (string, bool), envVar string, target *string) {
+	if value, _ := lookup(envVar); value != "" {
 		*target = value
 	}
 }
@@ -270,8 +1279,8 @@ func loadEnvString(envVar string, target *string) {
 // loadEnvInt loads an integer environment variable into the target pointer.
 // If the environment variable is not set, the target value remains unchanged.
 // Returns an error if the environment variable is set but cannot be parsed as an integer.
-func loadEnvInt(envVar string, target *int) error {
-	value := os.Getenv(envVar)
+func loadEnvInt(lookup func(string) (string, bool), envVar string, target *int) error {
+	value, _ := lookup(envVar)
 	if value == "" {
 		return nil // Keep default value
 	}
@@ -284,3 +1293,21 @@ func loadEnvInt(envVar string, target *int) error {
 	*target = parsed
 	return nil
 }
+
+// loadEnvBool loads a boolean environment variable into the target pointer.
+// If the environment variable is not set, the target value remains unchanged.
+// Accepts the values understood by strconv.ParseBool (1, t, true, 0, f, false, ...).
+func loadEnvBool(lookup func(string) (string, bool), envVar string, target *bool) error {
+	value, _ := lookup(envVar)
+	if value == "" {
+		return nil // Keep default value
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("cannot parse %s as boolean: %w", envVar, err)
+	}
+
+	*target = parsed
+	return nil
+}
@@ -2,6 +2,7 @@ package config_test
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/fle/server/internal/config"
@@ -164,3 +165,278 @@ func TestHelperMethods(t *testing.T) {
 		t.Errorf("Expected address to be %s, got %s", expected, cfg.Address())
 	}
 }
+
+func TestSessionCaseSensitiveFromEnv(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.SessionCaseSensitive {
+		t.Error("Expected session codes to be case-insensitive by default")
+	}
+
+	if err := os.Setenv("SESSION_CASE_SENSITIVE", "true"); err != nil {
+		t.Fatalf("Failed to set SESSION_CASE_SENSITIVE: %v", err)
+	}
+	defer func() {
+		_ = os.Unsetenv("SESSION_CASE_SENSITIVE") // Errors are ignored in cleanup
+	}()
+
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !cfg.SessionCaseSensitive {
+		t.Error("Expected SESSION_CASE_SENSITIVE=true to enable case-sensitive codes")
+	}
+
+	if err := os.Setenv("SESSION_CASE_SENSITIVE", "maybe"); err != nil {
+		t.Fatalf("Failed to set SESSION_CASE_SENSITIVE: %v", err)
+	}
+	if _, err := config.Load(); err == nil {
+		t.Error("Expected invalid SESSION_CASE_SENSITIVE to fail loading")
+	}
+}
+
+func TestSessionCodePrefixConfig(t *testing.T) {
+	t.Setenv("SESSION_CODE_PREFIX", "eu-prod_1")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.SessionCodePrefix != "eu-prod_1" {
+		t.Errorf("Expected prefix eu-prod_1, got %q", cfg.SessionCodePrefix)
+	}
+
+	t.Setenv("SESSION_CODE_PREFIX", "prod:eu")
+	if _, err := config.Load(); err == nil {
+		t.Error("Expected a prefix containing ':' to fail loading")
+	}
+}
+
+func TestStrictOriginCheck(t *testing.T) {
+	tests := []struct {
+		environment string
+		originCheck string
+		strict      bool
+	}{
+		{"development", "auto", false},
+		{"test", "auto", false},
+		{"production", "auto", true},
+		{"production", "permissive", false},
+		{"development", "strict", true},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{Environment: tt.environment, OriginCheck: tt.originCheck}
+		if got := cfg.StrictOriginCheck(); got != tt.strict {
+			t.Errorf("StrictOriginCheck() with ENV=%s ORIGIN_CHECK=%s = %v, want %v",
+				tt.environment, tt.originCheck, got, tt.strict)
+		}
+	}
+}
+
+func TestOriginAllowlist(t *testing.T) {
+	cfg := &config.Config{CORSOrigin: "http://localhost:3000"}
+	if got := cfg.OriginAllowlist(); len(got) != 1 || got[0] != "http://localhost:3000" {
+		t.Errorf("Expected allowlist to fall back to CORS origin, got %v", got)
+	}
+
+	cfg.AllowedOrigins = "https://a.example.com, https://b.example.com,"
+	got := cfg.OriginAllowlist()
+	if len(got) != 2 || got[0] != "https://a.example.com" || got[1] != "https://b.example.com" {
+		t.Errorf("Expected two allowlisted origins, got %v", got)
+	}
+}
+
+func TestLogFormatFromEnv(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.LogFormat != "auto" || cfg.UseJSONLogs(false) || !cfg.UseJSONLogs(true) {
+		t.Errorf("Expected auto log format to follow the environment default, got %q", cfg.LogFormat)
+	}
+
+	if err := os.Setenv("LOG_FORMAT", "json"); err != nil {
+		t.Fatalf("Failed to set LOG_FORMAT: %v", err)
+	}
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !cfg.UseJSONLogs(false) {
+		t.Error("Expected LOG_FORMAT=json to force JSON logs")
+	}
+
+	if err := os.Setenv("LOG_FORMAT", "xml"); err != nil {
+		t.Fatalf("Failed to set LOG_FORMAT: %v", err)
+	}
+	if _, err := config.Load(); err == nil {
+		t.Error("Expected invalid LOG_FORMAT to fail loading")
+	}
+}
+
+func TestLogFileValidation(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	if err := os.Setenv("LOG_OUTPUT", "file"); err != nil {
+		t.Fatalf("Failed to set LOG_OUTPUT: %v", err)
+	}
+	if _, err := config.Load(); err == nil {
+		t.Error("Expected LOG_OUTPUT=file without LOG_FILE_PATH to fail loading")
+	}
+
+	if err := os.Setenv("LOG_FILE_PATH", "/tmp/fle.log"); err != nil {
+		t.Fatalf("Failed to set LOG_FILE_PATH: %v", err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !cfg.LogToFile() || cfg.LogMaxSizeMB != 100 || cfg.LogMaxBackups != 5 {
+		t.Errorf("Unexpected log file settings: %+v", cfg)
+	}
+
+	if err := os.Setenv("LOG_MAX_SIZE_MB", "0"); err != nil {
+		t.Fatalf("Failed to set LOG_MAX_SIZE_MB: %v", err)
+	}
+	if _, err := config.Load(); err == nil {
+		t.Error("Expected LOG_MAX_SIZE_MB=0 to fail loading")
+	}
+}
+
+func TestSessionCleanupFromEnv(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.SessionCleanupInterval != 600 || cfg.SessionCleanupJitter != 0 {
+		t.Errorf("Unexpected cleanup defaults: interval %d, jitter %d", cfg.SessionCleanupInterval, cfg.SessionCleanupJitter)
+	}
+
+	os.Setenv("SESSION_CLEANUP_INTERVAL", "120")
+	os.Setenv("SESSION_CLEANUP_JITTER", "30")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.SessionCleanupInterval != 120 || cfg.SessionCleanupJitter != 30 {
+		t.Errorf("Unexpected cleanup settings: interval %d, jitter %d", cfg.SessionCleanupInterval, cfg.SessionCleanupJitter)
+	}
+
+	os.Setenv("SESSION_CLEANUP_JITTER", "61")
+	if _, err := config.Load(); err == nil {
+		t.Error("Expected jitter above half the interval to fail loading")
+	}
+}
+
+func TestPrincipalSessionLimitFromEnv(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.MaxSessionsPerPrincipal != 0 || cfg.PrincipalSessionPolicy != "reject" {
+		t.Errorf("Unexpected defaults: max %d, policy %q", cfg.MaxSessionsPerPrincipal, cfg.PrincipalSessionPolicy)
+	}
+
+	os.Setenv("MAX_SESSIONS_PER_PRINCIPAL", "3")
+	os.Setenv("PRINCIPAL_SESSION_POLICY", "evict-oldest")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.MaxSessionsPerPrincipal != 3 || cfg.PrincipalSessionPolicy != "evict-oldest" {
+		t.Errorf("Unexpected settings: max %d, policy %q", cfg.MaxSessionsPerPrincipal, cfg.PrincipalSessionPolicy)
+	}
+
+	os.Setenv("PRINCIPAL_SESSION_POLICY", "evict-newest")
+	if _, err := config.Load(); err == nil {
+		t.Error("Expected an unknown principal session policy to fail loading")
+	}
+
+	os.Setenv("PRINCIPAL_SESSION_POLICY", "reject")
+	os.Setenv("MAX_SESSIONS_PER_PRINCIPAL", "-1")
+	if _, err := config.Load(); err == nil {
+		t.Error("Expected a negative per-principal cap to fail loading")
+	}
+}
+
+func TestRPCContentTypeModeFromEnv(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.RPCContentTypeMode != "strict" {
+		t.Errorf("Expected strict by default, got %q", cfg.RPCContentTypeMode)
+	}
+
+	os.Setenv("RPC_CONTENT_TYPE_MODE", "Lenient")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.RPCContentTypeMode != "Lenient" {
+		t.Errorf("Expected Lenient, got %q", cfg.RPCContentTypeMode)
+	}
+
+	os.Setenv("RPC_CONTENT_TYPE_MODE", "off")
+	if _, err := config.Load(); err == nil {
+		t.Error("Expected an unknown content type mode to fail loading")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	path := filepath.Join(t.TempDir(), "fle.env")
+	content := "# comment\n\nexport LOG_LEVEL=debug\nCORS_ORIGIN=\"https://app.example.com\"\nPORT = 9000\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	os.Setenv("PORT", "7000")
+	os.Setenv("HOST", "localhost")
+
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config file: %v", err)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("Expected log level from the file, got %s", cfg.LogLevel)
+	}
+	if cfg.CORSOrigin != "https://app.example.com" {
+		t.Errorf("Expected the quotes to be stripped, got %s", cfg.CORSOrigin)
+	}
+	if cfg.Port != 9000 {
+		t.Errorf("Expected the file to take precedence over the environment, got port %d", cfg.Port)
+	}
+	if cfg.Host != "localhost" {
+		t.Errorf("Expected settings missing from the file to come from the environment, got %s", cfg.Host)
+	}
+
+	if err := os.WriteFile(path, []byte("LOG_LEVEL\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if _, err := config.LoadFile(path); err == nil {
+		t.Error("Expected a malformed line to fail loading")
+	}
+	if _, err := config.LoadFile(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Error("Expected a missing config file to fail loading")
+	}
+}
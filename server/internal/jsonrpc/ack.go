@@ -0,0 +1,67 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AckResult is the type of Ack.
+type AckResult struct{}
+
+// Ack is returned by handlers that have nothing to report beyond success.
+// The router replaces it with the result shape chosen by the router's
+// AckStyle, so every such method answers clients the same way.
+var Ack = AckResult{}
+
+// AckStyle decides what result a handler returning Ack produces.
+type AckStyle int
+
+const (
+	// AckTrue serializes Ack as "result": true.
+	AckTrue AckStyle = iota
+
+	// AckEmptyObject serializes Ack as "result": {}.
+	AckEmptyObject
+)
+
+// String returns the configuration name of the style.
+func (s AckStyle) String() string {
+	switch s {
+	case AckEmptyObject:
+		return "empty_object"
+	default:
+		return "true"
+	}
+}
+
+// ParseAckStyle parses "true" or "empty_object" (case-insensitive).
+func ParseAckStyle(name string) (AckStyle, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "true":
+		return AckTrue, nil
+	case "empty_object":
+		return AckEmptyObject, nil
+	default:
+		return AckTrue, fmt.Errorf("unknown ack style %q", name)
+	}
+}
+
+// SetAckStyle sets the result produced for handlers returning Ack. The
+// default is AckTrue.
+func (r *Router) SetAckStyle(style AckStyle) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.ackStyle = style
+}
+
+// ackResult returns the result to send in place of Ack.
+func (r *Router) ackResult() interface{} {
+	r.mutex.RLock()
+	style := r.ackStyle
+	r.mutex.RUnlock()
+
+	if style == AckEmptyObject {
+		return map[string]interface{}{}
+	}
+	return true
+}
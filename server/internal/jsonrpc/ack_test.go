@@ -0,0 +1,66 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// TestRouteAck tests that a handler returning Ack produces the result shape
+// of the router's ack style.
+func TestRouteAck(t *testing.T) {
+	tests := []struct {
+		name  string
+		style AckStyle
+		want  string
+	}{
+		{"default", AckTrue, `true`},
+		{"empty object", AckEmptyObject, `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouter()
+			router.SetAckStyle(tt.style)
+			handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+				return Ack, nil
+			}
+			if err := router.RegisterSimpleMethod("item.delete", handler, "Delete an item"); err != nil {
+				t.Fatalf("RegisterSimpleMethod failed: %v", err)
+			}
+
+			responseJSON, err := router.RouteJSON(context.Background(), []byte(`{"jsonrpc":"2.0","method":"item.delete","id":1}`))
+			if err != nil {
+				t.Fatalf("RouteJSON failed: %v", err)
+			}
+
+			var response struct {
+				Result json.RawMessage `json:"result"`
+				Error  *Error          `json:"error"`
+			}
+			if err := json.Unmarshal(responseJSON, &response); err != nil {
+				t.Fatalf("Invalid response JSON: %v", err)
+			}
+			if response.Error != nil {
+				t.Fatalf("Expected success, got error %v", response.Error)
+			}
+			if string(response.Result) != tt.want {
+				t.Errorf("Expected result %s, got %s", tt.want, response.Result)
+			}
+		})
+	}
+}
+
+func TestParseAckStyle(t *testing.T) {
+	style, err := ParseAckStyle("Empty_Object")
+	if err != nil || style != AckEmptyObject {
+		t.Errorf("Expected empty_object style, got %v (%v)", style, err)
+	}
+	if style.String() != "empty_object" {
+		t.Errorf("Expected String() empty_object, got %q", style.String())
+	}
+
+	if _, err := ParseAckStyle("null"); err == nil {
+		t.Error("Expected an error for an unknown style")
+	}
+}
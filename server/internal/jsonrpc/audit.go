@@ -0,0 +1,42 @@
+package jsonrpc
+
+import "time"
+
+// AuditEntry records who called which method, when, and how it ended.
+// Params and results are deliberately not included.
+type AuditEntry struct {
+	// Method is the requested method name (it may not be registered)
+	Method string `json:"method"`
+
+	// SessionCode identifies the caller, if the transport provided one
+	SessionCode string `json:"session_code,omitempty"`
+
+	// Timestamp is when the router started handling the request
+	Timestamp time.Time `json:"timestamp"`
+
+	// Duration is how long the router took to produce the response
+	Duration time.Duration `json:"duration"`
+
+	// Success is true if the request completed without a JSON-RPC error
+	Success bool `json:"success"`
+
+	// ErrorCode is the JSON-RPC error code of a failed request, 0 on success
+	ErrorCode int `json:"error_code,omitempty"`
+
+	// Notification is true for requests without an ID; they are audited like
+	// other requests even though no response is sent
+	Notification bool `json:"notification,omitempty"`
+}
+
+// AuditSink receives one AuditEntry per request handled by a Router.
+// Record is called synchronously on the request path, so implementations
+// should be fast and must be safe for concurrent use.
+type AuditSink interface {
+	Record(entry AuditEntry)
+}
+
+// NoopAuditSink discards audit entries. It is the Router default.
+type NoopAuditSink struct{}
+
+// Record implements AuditSink.
+func (NoopAuditSink) Record(AuditEntry) {}
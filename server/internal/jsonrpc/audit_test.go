@@ -0,0 +1,92 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// recordingSink is an AuditSink that keeps every entry for inspection.
+type recordingSink struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+func (s *recordingSink) Record(entry AuditEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+}
+
+func (s *recordingSink) all() []AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AuditEntry(nil), s.entries...)
+}
+
+// TestRouterAuditSink tests that one audit entry is recorded per request, including failures.
+func TestRouterAuditSink(t *testing.T) {
+	router := NewRouter()
+	sink := &recordingSink{}
+	router.SetAuditSink(sink)
+
+	router.RegisterSimpleMethod("ok", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "fine", nil
+	}, "")
+	router.RegisterSimpleMethod("fail", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return nil, errors.New("boom")
+	}, "")
+
+	ctx := WithSessionCode(context.Background(), "happy-panda-42")
+	requests := []*Request{
+		{JSONRPCVersion: "2.0", Method: "ok", ID: 1},
+		{JSONRPCVersion: "2.0", Method: "fail", ID: 2},
+		{JSONRPCVersion: "2.0", Method: "missing", ID: 3},
+		{JSONRPCVersion: "1.0", Method: "ok", ID: 4},
+		{JSONRPCVersion: "2.0", Method: "ok"},
+	}
+	for _, request := range requests {
+		router.Route(ctx, request)
+	}
+
+	expected := []struct {
+		method       string
+		success      bool
+		errorCode    int
+		notification bool
+	}{
+		{"ok", true, 0, false},
+		{"fail", false, InternalError, false},
+		{"missing", false, MethodNotFound, false},
+		{"ok", false, InvalidRequest, false},
+		{"ok", true, 0, true},
+	}
+
+	entries := sink.all()
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d audit entries, got %d", len(expected), len(entries))
+	}
+
+	for i, want := range expected {
+		entry := entries[i]
+		if entry.Method != want.method || entry.Success != want.success ||
+			entry.ErrorCode != want.errorCode || entry.Notification != want.notification {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want, entry)
+		}
+		if entry.SessionCode != "happy-panda-42" {
+			t.Errorf("Entry %d: expected session code from context, got %q", i, entry.SessionCode)
+		}
+		if entry.Timestamp.IsZero() || entry.Duration < 0 {
+			t.Errorf("Entry %d: expected timestamp and duration, got %+v", i, entry)
+		}
+	}
+
+	// Resetting to nil restores the no-op sink
+	router.SetAuditSink(nil)
+	router.Route(ctx, requests[0])
+	if len(sink.all()) != len(expected) {
+		t.Error("Expected no entries after the sink was removed")
+	}
+}
@@ -0,0 +1,53 @@
+package jsonrpc
+
+import (
+	"context"
+	"strings"
+)
+
+// Principal identifies the authenticated caller of a request.
+type Principal struct {
+	// ID identifies the caller (e.g. a user or service name)
+	ID string `json:"id"`
+
+	// Roles lists the roles granted to the caller
+	Roles []string `json:"roles,omitempty"`
+}
+
+// HasRole reports whether the principal has been granted role.
+// It is safe to call on a nil principal.
+func (p *Principal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+	for _, granted := range p.Roles {
+		if granted == role {
+			return true
+		}
+	}
+	return false
+}
+
+// MethodAuthorizer decides whether the caller described by ctx may invoke a
+// method. It runs after the method is resolved and before params validation.
+// Returning a *Error sends that error to the caller; any other error is
+// reported as ErrUnauthorized.
+type MethodAuthorizer interface {
+	Authorize(ctx context.Context, method string) error
+}
+
+// RoleAuthorizer maps method name prefixes to the role required to call
+// matching methods (e.g. "admin." -> "admin"). Methods matching no prefix
+// are allowed for everyone.
+type RoleAuthorizer map[string]string
+
+// Authorize implements MethodAuthorizer using the principal in ctx.
+func (a RoleAuthorizer) Authorize(ctx context.Context, method string) error {
+	principal, _ := PrincipalFromContext(ctx)
+	for prefix, role := range a {
+		if strings.HasPrefix(method, prefix) && !principal.HasRole(role) {
+			return NewErrorWithData(Unauthorized, ErrUnauthorized.Message, "method requires role "+role)
+		}
+	}
+	return nil
+}
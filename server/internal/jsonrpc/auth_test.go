@@ -0,0 +1,133 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// TestRouterAuthorizer tests that the authorizer guards methods by role.
+func TestRouterAuthorizer(t *testing.T) {
+	router := NewRouter()
+	router.SetAuthorizer(RoleAuthorizer{"admin.": "admin"})
+
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "ok", nil
+	}
+	router.RegisterSimpleMethod("admin.stats", handler, "")
+	router.RegisterSimpleMethod("ping", handler, "")
+
+	anonymous := context.Background()
+	admin := WithPrincipal(context.Background(), &Principal{ID: "ops", Roles: []string{"admin"}})
+	user := WithPrincipal(context.Background(), &Principal{ID: "player", Roles: []string{"user"}})
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		method   string
+		wantCode int
+	}{
+		{"admin calls admin method", admin, "admin.stats", 0},
+		{"user denied admin method", user, "admin.stats", Unauthorized},
+		{"anonymous denied admin method", anonymous, "admin.stats", Unauthorized},
+		{"anonymous calls public method", anonymous, "ping", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := router.Route(tt.ctx, &Request{JSONRPCVersion: "2.0", Method: tt.method, ID: 1})
+			if tt.wantCode == 0 {
+				if response.Error != nil {
+					t.Fatalf("Unexpected error: %v", response.Error)
+				}
+				return
+			}
+			if response.Error == nil || response.Error.Code != tt.wantCode {
+				t.Errorf("Expected error code %d, got %+v", tt.wantCode, response.Error)
+			}
+		})
+	}
+}
+
+// TestRouterAuthRequired tests that with authentication required only
+// exempt methods can be called without a principal.
+func TestRouterAuthRequired(t *testing.T) {
+	router := NewRouter()
+	router.SetMethodNameNormalization(MethodNamesTrimLower)
+	router.SetAuthorizer(RoleAuthorizer{"admin.": "admin"})
+	router.SetAuthRequired(true, []string{" Ping "})
+
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "ok", nil
+	}
+	router.RegisterSimpleMethod("ping", handler, "")
+	router.RegisterSimpleMethod("echo", handler, "")
+	router.RegisterSimpleMethod("admin.stats", handler, "")
+
+	anonymous := context.Background()
+	user := WithPrincipal(context.Background(), &Principal{ID: "player", Roles: []string{"user"}})
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		method   string
+		wantCode int
+	}{
+		{"anonymous calls exempt method", anonymous, "ping", 0},
+		{"anonymous denied protected method", anonymous, "echo", Unauthorized},
+		{"user calls protected method", user, "echo", 0},
+		{"authorizer still applies", user, "admin.stats", Unauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := router.Route(tt.ctx, &Request{JSONRPCVersion: "2.0", Method: tt.method, ID: 1})
+			if tt.wantCode == 0 {
+				if response.Error != nil {
+					t.Fatalf("Unexpected error: %v", response.Error)
+				}
+				return
+			}
+			if response.Error == nil || response.Error.Code != tt.wantCode {
+				t.Errorf("Expected error code %d, got %+v", tt.wantCode, response.Error)
+			}
+		})
+	}
+}
+
+// TestRouterAuthorizerPlainError tests that non-JSON-RPC authorizer errors map to Unauthorized.
+func TestRouterAuthorizerPlainError(t *testing.T) {
+	router := NewRouter()
+	router.SetAuthorizer(authorizerFunc(func(ctx context.Context, method string) error {
+		return fmt.Errorf("denied")
+	}))
+	router.RegisterSimpleMethod("ping", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "pong", nil
+	}, "")
+
+	response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "ping", ID: 1})
+	if response.Error == nil || response.Error.Code != Unauthorized {
+		t.Errorf("Expected Unauthorized, got %+v", response.Error)
+	}
+}
+
+// TestHandlerJSONRPCErrorPassthrough tests that handlers can choose the error returned.
+func TestHandlerJSONRPCErrorPassthrough(t *testing.T) {
+	router := NewRouter()
+	router.RegisterSimpleMethod("lookup", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return nil, fmt.Errorf("lookup failed: %w", ErrResourceNotFound)
+	}, "")
+
+	response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "lookup", ID: 1})
+	if response.Error == nil || response.Error.Code != ResourceNotFound {
+		t.Errorf("Expected ResourceNotFound, got %+v", response.Error)
+	}
+}
+
+// authorizerFunc adapts a function to MethodAuthorizer.
+type authorizerFunc func(ctx context.Context, method string) error
+
+func (f authorizerFunc) Authorize(ctx context.Context, method string) error {
+	return f(ctx, method)
+}
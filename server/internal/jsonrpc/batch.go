@@ -0,0 +1,67 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// isBatch reports whether data holds a JSON array, i.e. a batch of requests.
+func isBatch(data []byte) bool {
+	// JSON whitespace is only space, tab, CR and LF
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// routeBatch routes a JSON-RPC 2.0 batch request. Each element is routed in
+// turn and the responses to its requests are returned as an array in the
+// order the requests appeared; notifications get none, so a batch of only
+// notifications returns nil. An element that is not a valid request gets an
+// InvalidRequest response in its place and does not affect the others. An
+// empty batch gets a single InvalidRequest response, as the specification
+// requires, and so does a batch over the limit set with SetMaxBatchSize,
+// without any of its requests being routed.
+func (r *Router) routeBatch(ctx context.Context, data []byte) ([]byte, error) {
+	var batch []json.RawMessage
+	if err := r.decodeRequest(data, &batch); err != nil {
+		return json.Marshal(NewErrorResponse(ErrParse, nil))
+	}
+	if len(batch) == 0 {
+		return json.Marshal(NewErrorResponse(ErrInvalidRequest, nil))
+	}
+
+	r.mutex.RLock()
+	maxBatchSize := r.maxBatchSize
+	r.mutex.RUnlock()
+	if maxBatchSize > 0 && len(batch) > maxBatchSize {
+		tooLarge := NewErrorWithData(InvalidRequest, ErrInvalidRequest.Message,
+			fmt.Sprintf("batch of %d requests exceeds the maximum of %d", len(batch), maxBatchSize))
+		return json.Marshal(NewErrorResponse(tooLarge, nil))
+	}
+
+	responses := make([]json.RawMessage, 0, len(batch))
+	for _, element := range batch {
+		var request Request
+		if err := json.Unmarshal(element, &request); err != nil {
+			invalid, _ := json.Marshal(NewErrorResponse(ErrInvalidRequest, nil))
+			responses = append(responses, invalid)
+			continue
+		}
+
+		response := r.Route(ctx, &request)
+		if response == nil {
+			continue
+		}
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			responseJSON, _ = json.Marshal(NewErrorResponse(ErrInternal, request.ID))
+		}
+		responses = append(responses, responseJSON)
+	}
+
+	if len(responses) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(responses)
+}
@@ -0,0 +1,170 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+)
+
+func newBatchTestRouter(t *testing.T, notified *atomic.Int32) *Router {
+	router := NewRouter()
+	echo := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return string(params), nil
+	}
+	notify := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		notified.Add(1)
+		return nil, nil
+	}
+	if err := router.RegisterSimpleMethod("test.echo", echo, "Echo params"); err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+	if err := router.RegisterSimpleMethod("test.notify", notify, "Count calls"); err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+	return router
+}
+
+func TestRouteJSONBatch(t *testing.T) {
+	var notified atomic.Int32
+	router := newBatchTestRouter(t, &notified)
+
+	requestJSON := []byte(` [
+		{"jsonrpc": "2.0", "method": "test.echo", "params": [1], "id": 1},
+		{"jsonrpc": "2.0", "method": "test.notify"},
+		{"jsonrpc": "2.0", "method": "test.missing", "id": 2},
+		42,
+		{"jsonrpc": "2.0", "method": "test.echo", "params": [2], "id": 3}
+	]`)
+
+	responseJSON, err := router.RouteJSON(context.Background(), requestJSON)
+	if err != nil {
+		t.Fatalf("RouteJSON failed: %v", err)
+	}
+
+	var responses []Response
+	if err := json.Unmarshal(responseJSON, &responses); err != nil {
+		t.Fatalf("Expected a response array, got %s: %v", responseJSON, err)
+	}
+	if len(responses) != 4 {
+		t.Fatalf("Expected 4 responses, got %d: %s", len(responses), responseJSON)
+	}
+
+	// Responses follow the order of the requests, failures included
+	if responses[0].IsError() || responses[0].ID != float64(1) || responses[0].Result != "[1]" {
+		t.Errorf("Unexpected first response: %+v", responses[0])
+	}
+	if !responses[1].IsError() || responses[1].Error.Code != MethodNotFound || responses[1].ID != float64(2) {
+		t.Errorf("Expected MethodNotFound for id 2, got %+v", responses[1])
+	}
+	if !responses[2].IsError() || responses[2].Error.Code != InvalidRequest || responses[2].ID != nil {
+		t.Errorf("Expected InvalidRequest with a null id for the non-object, got %+v", responses[2])
+	}
+	if responses[3].IsError() || responses[3].ID != float64(3) || responses[3].Result != "[2]" {
+		t.Errorf("Unexpected last response: %+v", responses[3])
+	}
+	if notified.Load() != 1 {
+		t.Errorf("Expected the notification to run once, ran %d times", notified.Load())
+	}
+}
+
+func TestRouteJSONBatchEmpty(t *testing.T) {
+	var notified atomic.Int32
+	router := newBatchTestRouter(t, &notified)
+
+	responseJSON, err := router.RouteJSON(context.Background(), []byte(`[]`))
+	if err != nil {
+		t.Fatalf("RouteJSON failed: %v", err)
+	}
+
+	// A single error response, not an array
+	var response Response
+	if err := json.Unmarshal(responseJSON, &response); err != nil {
+		t.Fatalf("Expected a single response object, got %s: %v", responseJSON, err)
+	}
+	if !response.IsError() || response.Error.Code != InvalidRequest {
+		t.Errorf("Expected InvalidRequest, got %+v", response)
+	}
+}
+
+func TestRouteJSONBatchOnlyNotifications(t *testing.T) {
+	var notified atomic.Int32
+	router := newBatchTestRouter(t, &notified)
+
+	requestJSON := []byte(`[{"jsonrpc": "2.0", "method": "test.notify"}, {"jsonrpc": "2.0", "method": "test.notify"}]`)
+	responseJSON, err := router.RouteJSON(context.Background(), requestJSON)
+	if err != nil {
+		t.Fatalf("RouteJSON failed: %v", err)
+	}
+	if responseJSON != nil {
+		t.Errorf("Expected no response for a batch of notifications, got %s", responseJSON)
+	}
+	if notified.Load() != 2 {
+		t.Errorf("Expected both notifications to run, ran %d", notified.Load())
+	}
+}
+
+func TestRouteJSONBatchParseError(t *testing.T) {
+	var notified atomic.Int32
+	router := newBatchTestRouter(t, &notified)
+
+	responseJSON, err := router.RouteJSON(context.Background(), []byte(`[{"jsonrpc": "2.0", "method": "test.echo", "id": 1},`))
+	if err != nil {
+		t.Fatalf("RouteJSON failed: %v", err)
+	}
+
+	var response Response
+	if err := json.Unmarshal(responseJSON, &response); err != nil {
+		t.Fatalf("Expected a single response object, got %s: %v", responseJSON, err)
+	}
+	if !response.IsError() || response.Error.Code != ParseError {
+		t.Errorf("Expected ParseError, got %+v", response)
+	}
+}
+
+func TestRouteJSONBatchMaxSize(t *testing.T) {
+	var notified atomic.Int32
+	router := newBatchTestRouter(t, &notified)
+	router.SetMaxBatchSize(2)
+
+	requestJSON := []byte(`[
+		{"jsonrpc": "2.0", "method": "test.notify"},
+		{"jsonrpc": "2.0", "method": "test.notify"},
+		{"jsonrpc": "2.0", "method": "test.echo", "id": 1}
+	]`)
+	responseJSON, err := router.RouteJSON(context.Background(), requestJSON)
+	if err != nil {
+		t.Fatalf("RouteJSON failed: %v", err)
+	}
+
+	// A single error response stating the limit, and nothing dispatched
+	var response Response
+	if err := json.Unmarshal(responseJSON, &response); err != nil {
+		t.Fatalf("Expected a single response object, got %s: %v", responseJSON, err)
+	}
+	if !response.IsError() || response.Error.Code != InvalidRequest || response.ID != nil {
+		t.Fatalf("Expected InvalidRequest with a null id, got %+v", response)
+	}
+	if response.Error.Data != "batch of 3 requests exceeds the maximum of 2" {
+		t.Errorf("Expected the data to state the limit, got %v", response.Error.Data)
+	}
+	if notified.Load() != 0 {
+		t.Errorf("Expected no handlers to run, ran %d", notified.Load())
+	}
+
+	// Batches within the limit are routed as usual
+	responseJSON, err = router.RouteJSON(context.Background(), []byte(`[{"jsonrpc": "2.0", "method": "test.notify"}, {"jsonrpc": "2.0", "method": "test.notify"}]`))
+	if err != nil || responseJSON != nil || notified.Load() != 2 {
+		t.Errorf("Expected a batch at the limit to run, got %s, %v, %d calls", responseJSON, err, notified.Load())
+	}
+
+	// Zero removes the limit
+	router.SetMaxBatchSize(0)
+	if responseJSON, err = router.RouteJSON(context.Background(), requestJSON); err != nil {
+		t.Fatalf("RouteJSON failed: %v", err)
+	}
+	var responses []Response
+	if err := json.Unmarshal(responseJSON, &responses); err != nil || len(responses) != 1 {
+		t.Errorf("Expected the batch to run without a limit, got %s", responseJSON)
+	}
+}
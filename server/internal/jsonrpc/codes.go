@@ -0,0 +1,117 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrorCodeInfo describes an error code in the catalog returned by
+// Router.ErrorCatalog.
+type ErrorCodeInfo struct {
+	// Code is the JSON-RPC error code
+	Code int `json:"code"`
+
+	// Name is the code's identifier, e.g. "MethodNotFound"
+	Name string `json:"name"`
+
+	// Message is the standard error message sent with the code
+	Message string `json:"message"`
+
+	// Custom marks codes registered with RegisterErrorCode
+	Custom bool `json:"custom,omitempty"`
+}
+
+// ErrorCodeRange describes a range of error codes reserved by the JSON-RPC
+// 2.0 specification.
+type ErrorCodeRange struct {
+	// Name identifies the range
+	Name string `json:"name"`
+
+	// Start is the lowest code in the range
+	Start int `json:"start"`
+
+	// End is the highest code in the range
+	End int `json:"end"`
+}
+
+// ErrorCatalog lists the error codes a router's methods may return.
+type ErrorCatalog struct {
+	// Codes lists the predefined and registered custom codes, ordered by code
+	Codes []ErrorCodeInfo `json:"codes"`
+
+	// Ranges lists the reserved code ranges
+	Ranges []ErrorCodeRange `json:"ranges"`
+}
+
+// predefinedErrorCodes lists the specification and implementation-defined
+// server error codes declared by this package.
+var predefinedErrorCodes = []ErrorCodeInfo{
+	{Code: ParseError, Name: "ParseError", Message: ErrParse.Message},
+	{Code: InvalidRequest, Name: "InvalidRequest", Message: ErrInvalidRequest.Message},
+	{Code: MethodNotFound, Name: "MethodNotFound", Message: ErrMethodNotFound.Message},
+	{Code: InvalidParams, Name: "InvalidParams", Message: ErrInvalidParams.Message},
+	{Code: InternalError, Name: "InternalError", Message: ErrInternal.Message},
+	{Code: RequestTimeout, Name: "RequestTimeout", Message: ErrRequestTimeout.Message},
+	{Code: ServerBusy, Name: "ServerBusy", Message: ErrServerBusy.Message},
+	{Code: RequestCancelled, Name: "RequestCancelled", Message: ErrRequestCancelled.Message},
+	{Code: Unauthorized, Name: "Unauthorized", Message: ErrUnauthorized.Message},
+	{Code: ResourceNotFound, Name: "ResourceNotFound", Message: ErrResourceNotFound.Message},
+	{Code: RateLimited, Name: "RateLimited", Message: "Rate limited"},
+	{Code: Maintenance, Name: "Maintenance", Message: "Maintenance"},
+}
+
+// errorCodeRanges lists the code ranges reserved by the specification.
+var errorCodeRanges = []ErrorCodeRange{
+	{Name: "reserved", Start: -32768, End: -32000},
+	{Name: "server", Start: ServerErrorStart, End: ServerErrorEnd},
+}
+
+// RegisterErrorCode adds an application-defined error code to the router's
+// catalog so client tooling can discover it. Codes must not clash with the
+// predefined or already registered ones, and must lie outside the reserved
+// range except for its implementation-defined server error part.
+func (r *Router) RegisterErrorCode(code int, name, message string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("error code name cannot be empty")
+	}
+	if IsReservedErrorCode(code) && !IsServerErrorCode(code) {
+		return fmt.Errorf("error code %d is reserved by the JSON-RPC specification", code)
+	}
+	for _, info := range predefinedErrorCodes {
+		if info.Code == code {
+			return fmt.Errorf("error code %d is already defined as %s", code, info.Name)
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, ok := r.errorCodes[code]; ok {
+		return fmt.Errorf("error code %d is already registered as %s", code, existing.Name)
+	}
+	if r.errorCodes == nil {
+		r.errorCodes = make(map[int]ErrorCodeInfo)
+	}
+	r.errorCodes[code] = ErrorCodeInfo{Code: code, Name: name, Message: message, Custom: true}
+	return nil
+}
+
+// ErrorCatalog returns the predefined error codes, the custom codes
+// registered with RegisterErrorCode and the reserved code ranges.
+func (r *Router) ErrorCatalog() ErrorCatalog {
+	r.mutex.RLock()
+	codes := make([]ErrorCodeInfo, 0, len(predefinedErrorCodes)+len(r.errorCodes))
+	codes = append(codes, predefinedErrorCodes...)
+	for _, info := range r.errorCodes {
+		codes = append(codes, info)
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(codes, func(i, j int) bool { return codes[i].Code < codes[j].Code })
+	return ErrorCatalog{
+		Codes:  codes,
+		Ranges: append([]ErrorCodeRange(nil), errorCodeRanges...),
+	}
+}
@@ -0,0 +1,47 @@
+package jsonrpc
+
+import "testing"
+
+func TestErrorCatalog(t *testing.T) {
+	router := NewRouter()
+	if err := router.RegisterErrorCode(-40001, "QuotaExceeded", "Quota exceeded"); err != nil {
+		t.Fatalf("RegisterErrorCode failed: %v", err)
+	}
+
+	catalog := router.ErrorCatalog()
+	byCode := make(map[int]ErrorCodeInfo)
+	for _, info := range catalog.Codes {
+		byCode[info.Code] = info
+	}
+
+	if info := byCode[MethodNotFound]; info.Name != "MethodNotFound" || info.Message != "Method not found" {
+		t.Errorf("Expected MethodNotFound to be cataloged, got %+v", info)
+	}
+	if info := byCode[-40001]; info.Name != "QuotaExceeded" || !info.Custom {
+		t.Errorf("Expected the registered custom code, got %+v", info)
+	}
+	for i := 1; i < len(catalog.Codes); i++ {
+		if catalog.Codes[i-1].Code >= catalog.Codes[i].Code {
+			t.Fatalf("Expected codes ordered by code, got %d before %d", catalog.Codes[i-1].Code, catalog.Codes[i].Code)
+		}
+	}
+	if len(catalog.Ranges) == 0 {
+		t.Error("Expected the reserved ranges to be listed")
+	}
+}
+
+func TestRegisterErrorCodeRejectsClashes(t *testing.T) {
+	router := NewRouter()
+	if err := router.RegisterErrorCode(MethodNotFound, "Missing", "Missing"); err == nil {
+		t.Error("Expected an error for a predefined code")
+	}
+	if err := router.RegisterErrorCode(-32500, "Reserved", "Reserved"); err == nil {
+		t.Error("Expected an error for a reserved code")
+	}
+	if err := router.RegisterErrorCode(-32050, "Custom", "Custom"); err != nil {
+		t.Errorf("Expected a server error range code to be accepted, got %v", err)
+	}
+	if err := router.RegisterErrorCode(-32050, "Again", "Again"); err == nil {
+		t.Error("Expected an error for a code registered twice")
+	}
+}
@@ -0,0 +1,52 @@
+package jsonrpc
+
+import (
+	"context"
+	"log/slog"
+)
+
+// contextKey is an unexported type for context keys defined in this package,
+// preventing collisions with keys defined in other packages.
+type contextKey int
+
+const (
+	// sessionCodeContextKey is the context key for the caller's session code
+	sessionCodeContextKey contextKey = iota
+
+	// principalContextKey is the context key for the caller's Principal
+	principalContextKey
+
+	// partialSenderContextKey is the context key for the transport's PartialSender
+	partialSenderContextKey
+)
+
+// WithSessionCode returns a copy of ctx carrying the session code of the
+// caller. Transports set it so the router can attribute requests (e.g. in
+// audit entries) without depending on the transport package.
+func WithSessionCode(ctx context.Context, sessionCode string) context.Context {
+	return context.WithValue(ctx, sessionCodeContextKey, sessionCode)
+}
+
+// SessionCodeFromContext returns the caller's session code, or "" if none was set.
+func SessionCodeFromContext(ctx context.Context) string {
+	sessionCode, _ := ctx.Value(sessionCodeContextKey).(string)
+	return sessionCode
+}
+
+// SessionCodeAttr returns the caller's session code as a "session_code" log
+// attribute, reporting false if none was set. It suits logger.ContextHandler.
+func SessionCodeAttr(ctx context.Context) (slog.Attr, bool) {
+	sessionCode := SessionCodeFromContext(ctx)
+	return slog.String("session_code", sessionCode), sessionCode != ""
+}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey).(*Principal)
+	return principal, ok && principal != nil
+}
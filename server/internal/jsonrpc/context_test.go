@@ -0,0 +1,20 @@
+package jsonrpc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSessionCodeAttr(t *testing.T) {
+	if _, ok := SessionCodeAttr(context.Background()); ok {
+		t.Error("Expected no attribute without a session code")
+	}
+
+	attr, ok := SessionCodeAttr(WithSessionCode(context.Background(), "happy-cat-42"))
+	if !ok {
+		t.Fatal("Expected an attribute for the session code")
+	}
+	if attr.Key != "session_code" || attr.Value.String() != "happy-cat-42" {
+		t.Errorf("Expected session_code=happy-cat-42, got %s", attr)
+	}
+}
@@ -0,0 +1,60 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// DeprecationNotice is sent in a response's "_meta" when the called method
+// is deprecated.
+type DeprecationNotice struct {
+	// Method is the deprecated method that was called
+	Method string `json:"method"`
+
+	// ReplacedBy names the method to call instead, if there is one
+	ReplacedBy string `json:"replacedBy,omitempty"`
+
+	// Message is a human-readable warning
+	Message string `json:"message"`
+}
+
+// SetDeprecationLogger enables warn-level logging of calls to methods
+// registered as Deprecated. A nil logger disables the logging, which is the
+// default; responses carry the deprecation notice either way.
+func (r *Router) SetDeprecationLogger(logger *slog.Logger) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.deprecationLogger = logger
+}
+
+// deprecationNotice returns the notice for a call to request.Method, logging
+// the call if a deprecation logger is set, or nil if the method is not
+// deprecated or not registered.
+func (r *Router) deprecationNotice(ctx context.Context, request *Request) *DeprecationNotice {
+	r.mutex.RLock()
+	methodInfo, exists := r.methods[request.Method]
+	logger := r.deprecationLogger
+	r.mutex.RUnlock()
+
+	if !exists || !methodInfo.Deprecated {
+		return nil
+	}
+
+	notice := &DeprecationNotice{
+		Method:     request.Method,
+		ReplacedBy: methodInfo.ReplacedBy,
+		Message:    fmt.Sprintf("method %q is deprecated", request.Method),
+	}
+	if methodInfo.ReplacedBy != "" {
+		notice.Message += fmt.Sprintf("; use %q instead", methodInfo.ReplacedBy)
+	}
+
+	if logger != nil {
+		logger.WarnContext(ctx, "Deprecated method called",
+			"method", request.Method,
+			"replacedBy", methodInfo.ReplacedBy,
+			"sessionCode", SessionCodeFromContext(ctx))
+	}
+	return notice
+}
@@ -0,0 +1,77 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// TestRouteDeprecatedMethod tests that a deprecated method still works while
+// its responses carry a deprecation notice, its calls are logged and
+// introspection reports it.
+func TestRouteDeprecatedMethod(t *testing.T) {
+	var buf bytes.Buffer
+	router := NewRouter()
+	router.SetDeprecationLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "ok", nil
+	}
+	if err := router.RegisterMethod("old.greet", handler, &MethodInfo{Deprecated: true, ReplacedBy: "greet"}); err != nil {
+		t.Fatalf("RegisterMethod failed: %v", err)
+	}
+	if err := router.RegisterSimpleMethod("greet", handler, "Greet"); err != nil {
+		t.Fatalf("RegisterSimpleMethod failed: %v", err)
+	}
+
+	responseJSON, err := router.RouteJSON(context.Background(), []byte(`{"jsonrpc":"2.0","method":"old.greet","id":1}`))
+	if err != nil {
+		t.Fatalf("RouteJSON failed: %v", err)
+	}
+	var response struct {
+		Result string `json:"result"`
+		Error  *Error `json:"error"`
+		Meta   struct {
+			DurationMs  *float64           `json:"durationMs"`
+			Deprecation *DeprecationNotice `json:"deprecation"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(responseJSON, &response); err != nil {
+		t.Fatalf("Failed to decode %s: %v", responseJSON, err)
+	}
+	if response.Error != nil || response.Result != "ok" {
+		t.Errorf("Expected the deprecated method to still work, got %s", responseJSON)
+	}
+	notice := response.Meta.Deprecation
+	if notice == nil || notice.Method != "old.greet" || notice.ReplacedBy != "greet" || !strings.Contains(notice.Message, "deprecated") {
+		t.Errorf("Expected a deprecation notice naming the replacement, got %s", responseJSON)
+	}
+	if response.Meta.DurationMs != nil {
+		t.Errorf("Expected no duration without a timing request, got %s", responseJSON)
+	}
+	if logged := buf.String(); !strings.Contains(logged, "Deprecated method called") || !strings.Contains(logged, "old.greet") {
+		t.Errorf("Expected the call to be logged, got %q", logged)
+	}
+
+	// Other methods get no _meta at all
+	responseJSON, err = router.RouteJSON(context.Background(), []byte(`{"jsonrpc":"2.0","method":"greet","id":2}`))
+	if err != nil {
+		t.Fatalf("RouteJSON failed: %v", err)
+	}
+	if strings.Contains(string(responseJSON), "_meta") {
+		t.Errorf("Expected no _meta for a current method, got %s", responseJSON)
+	}
+
+	for _, description := range router.DescribeMethods() {
+		deprecated := description.Name == "old.greet"
+		if description.Deprecated != deprecated {
+			t.Errorf("Expected %s deprecated=%v in introspection, got %+v", description.Name, deprecated, description)
+		}
+		if deprecated && description.ReplacedBy != "greet" {
+			t.Errorf("Expected introspection to name the replacement, got %+v", description)
+		}
+	}
+}
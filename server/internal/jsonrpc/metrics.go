@@ -0,0 +1,83 @@
+package jsonrpc
+
+import "sync"
+
+// UnknownMethodLabel is the RouterMetrics.Methods key that counts calls to
+// methods that are not registered.
+const UnknownMethodLabel = "unknown"
+
+// MethodMetrics counts the calls to a single method.
+type MethodMetrics struct {
+	// Calls counts requests and notifications routed to the method
+	Calls uint64 `json:"calls"`
+
+	// Errors counts the calls that ended with a JSON-RPC error
+	Errors uint64 `json:"errors"`
+}
+
+// RouterMetrics is a snapshot of the calls handled by a Router.
+type RouterMetrics struct {
+	// Calls and Errors count every routed request, including those for
+	// methods that are not registered
+	Calls  uint64 `json:"calls"`
+	Errors uint64 `json:"errors"`
+
+	// Methods holds the counts of each registered method that has been
+	// called, with calls to unregistered methods under UnknownMethodLabel
+	Methods map[string]MethodMetrics `json:"methods"`
+}
+
+// routerMetrics accumulates call counts. Per-method counts are only kept for
+// registered methods; calls to any other method share UnknownMethodLabel, so
+// clients cannot grow the map with made-up names.
+type routerMetrics struct {
+	mu      sync.Mutex
+	calls   uint64
+	errors  uint64
+	methods map[string]*MethodMetrics
+}
+
+// record counts one call, attributing it to method when registered is true
+// and to UnknownMethodLabel otherwise.
+func (m *routerMetrics) record(method string, registered, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls++
+	if failed {
+		m.errors++
+	}
+	if !registered {
+		method = UnknownMethodLabel
+	}
+
+	if m.methods == nil {
+		m.methods = make(map[string]*MethodMetrics)
+	}
+	counts, ok := m.methods[method]
+	if !ok {
+		counts = &MethodMetrics{}
+		m.methods[method] = counts
+	}
+	counts.Calls++
+	if failed {
+		counts.Errors++
+	}
+}
+
+// Metrics returns a snapshot of the calls the router has handled.
+// This method is thread-safe.
+func (r *Router) Metrics() RouterMetrics {
+	r.metrics.mu.Lock()
+	defer r.metrics.mu.Unlock()
+
+	snapshot := RouterMetrics{
+		Calls:   r.metrics.calls,
+		Errors:  r.metrics.errors,
+		Methods: make(map[string]MethodMetrics, len(r.metrics.methods)),
+	}
+	for method, counts := range r.metrics.methods {
+		snapshot.Methods[method] = *counts
+	}
+	return snapshot
+}
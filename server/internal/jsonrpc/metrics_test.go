@@ -0,0 +1,52 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// TestRouterMetrics tests that calls and errors are counted per registered
+// method, and that unknown methods share a single entry.
+func TestRouterMetrics(t *testing.T) {
+	router := NewRouter()
+	router.RegisterSimpleMethod("ok", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "fine", nil
+	}, "")
+	router.RegisterSimpleMethod("fail", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return nil, errors.New("boom")
+	}, "")
+
+	router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "ok", ID: 1})
+	router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "ok"})
+	router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "fail", ID: 2})
+	router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "missing", ID: 3})
+	router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "made.up", ID: 4})
+
+	metrics := router.Metrics()
+	if metrics.Calls != 5 || metrics.Errors != 3 {
+		t.Errorf("Expected 5 calls and 3 errors in total, got %d and %d", metrics.Calls, metrics.Errors)
+	}
+	if got := metrics.Methods["ok"]; got != (MethodMetrics{Calls: 2}) {
+		t.Errorf("Expected 2 successful calls to ok, got %+v", got)
+	}
+	if got := metrics.Methods["fail"]; got != (MethodMetrics{Calls: 1, Errors: 1}) {
+		t.Errorf("Expected 1 failed call to fail, got %+v", got)
+	}
+	if _, ok := metrics.Methods["missing"]; ok {
+		t.Error("Unknown methods should not get their own entry")
+	}
+	if got := metrics.Methods[UnknownMethodLabel]; got != (MethodMetrics{Calls: 2, Errors: 2}) {
+		t.Errorf("Expected 2 failed calls under %q, got %+v", UnknownMethodLabel, got)
+	}
+	if len(metrics.Methods) != 3 {
+		t.Errorf("Expected 3 method entries, got %v", metrics.Methods)
+	}
+
+	// Snapshots are copies
+	metrics.Methods["ok"] = MethodMetrics{}
+	if router.Metrics().Methods["ok"].Calls != 2 {
+		t.Error("Modifying a snapshot should not affect the router")
+	}
+}
@@ -0,0 +1,83 @@
+package jsonrpc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+)
+
+const (
+	// DefaultPageLimit is the page size used when PageParams.Limit is omitted.
+	DefaultPageLimit = 50
+
+	// MaxPageLimit is the largest page size a caller may request.
+	MaxPageLimit = 500
+)
+
+// PageParams are the standard pagination parameters for list-returning methods.
+// Embed it in a method's params struct to accept "cursor" and "limit".
+type PageParams struct {
+	// Cursor is the NextCursor of the previous page; empty requests the first page
+	Cursor string `json:"cursor,omitempty"`
+
+	// Limit is the maximum number of items to return (1 to MaxPageLimit);
+	// zero uses DefaultPageLimit
+	Limit int `json:"limit,omitempty" validate:"min=0,max=500"`
+}
+
+// Page is the standard result envelope for paginated methods.
+type Page[T any] struct {
+	// Items holds this page's items
+	Items []T `json:"items"`
+
+	// NextCursor is passed as the cursor to fetch the next page; empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+
+	// Total is the number of items across all pages
+	Total int `json:"total"`
+}
+
+// Paginate returns the page of items selected by params. Items are ordered by
+// the string returned by key, which must be unique per item. The cursor encodes
+// the key of the last item returned, so iteration neither repeats nor skips
+// items that exist throughout, even if others are added or removed between
+// pages. It returns an InvalidParams *Error for an out-of-range limit or a
+// malformed cursor.
+func Paginate[T any](items []T, key func(T) string, params PageParams) (Page[T], error) {
+	limit := params.Limit
+	if limit == 0 {
+		limit = DefaultPageLimit
+	}
+	if limit < 1 || limit > MaxPageLimit {
+		return Page[T]{}, NewErrorWithData(InvalidParams, ErrInvalidParams.Message,
+			fmt.Sprintf("limit must be between 1 and %d, got %d", MaxPageLimit, params.Limit))
+	}
+
+	var after string
+	if params.Cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(params.Cursor)
+		if err != nil || len(decoded) == 0 {
+			return Page[T]{}, NewErrorWithData(InvalidParams, ErrInvalidParams.Message, "invalid cursor")
+		}
+		after = string(decoded)
+	}
+
+	sorted := make([]T, len(items))
+	copy(sorted, items)
+	sort.Slice(sorted, func(i, j int) bool { return key(sorted[i]) < key(sorted[j]) })
+
+	start := 0
+	if params.Cursor != "" {
+		start = sort.Search(len(sorted), func(i int) bool { return key(sorted[i]) > after })
+	}
+	end := start + limit
+	if end > len(sorted) {
+		end = len(sorted)
+	}
+
+	page := Page[T]{Items: sorted[start:end], Total: len(sorted)}
+	if end < len(sorted) {
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(key(sorted[end-1])))
+	}
+	return page, nil
+}
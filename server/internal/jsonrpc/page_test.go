@@ -0,0 +1,92 @@
+package jsonrpc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func identity(s string) string { return s }
+
+// TestPaginateIteratesAllItems tests that following cursors visits every item exactly once.
+func TestPaginateIteratesAllItems(t *testing.T) {
+	items := make([]string, 0, 23)
+	for i := 22; i >= 0; i-- {
+		items = append(items, fmt.Sprintf("item-%02d", i))
+	}
+
+	seen := make(map[string]bool)
+	params := PageParams{Limit: 5}
+	pages := 0
+	for {
+		page, err := Paginate(items, identity, params)
+		if err != nil {
+			t.Fatalf("Paginate failed: %v", err)
+		}
+		pages++
+		if page.Total != len(items) {
+			t.Errorf("expected total %d, got %d", len(items), page.Total)
+		}
+		for _, item := range page.Items {
+			if seen[item] {
+				t.Errorf("item %s returned twice", item)
+			}
+			seen[item] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+
+	if len(seen) != len(items) {
+		t.Errorf("expected %d items, got %d", len(items), len(seen))
+	}
+	if pages != 5 {
+		t.Errorf("expected 5 pages, got %d", pages)
+	}
+}
+
+// TestPaginateCursorSurvivesRemoval tests that removing an already returned item does not shift later pages.
+func TestPaginateCursorSurvivesRemoval(t *testing.T) {
+	items := []string{"a", "b", "c", "d"}
+	first, err := Paginate(items, identity, PageParams{Limit: 2})
+	if err != nil {
+		t.Fatalf("Paginate failed: %v", err)
+	}
+
+	second, err := Paginate([]string{"b", "c", "d"}, identity, PageParams{Cursor: first.NextCursor, Limit: 2})
+	if err != nil {
+		t.Fatalf("Paginate failed: %v", err)
+	}
+	if len(second.Items) != 2 || second.Items[0] != "c" || second.Items[1] != "d" {
+		t.Errorf("expected [c d], got %v", second.Items)
+	}
+	if second.NextCursor != "" {
+		t.Errorf("expected last page, got cursor %q", second.NextCursor)
+	}
+}
+
+// TestPaginateValidatesParams tests limit bounds and cursor decoding.
+func TestPaginateValidatesParams(t *testing.T) {
+	page, err := Paginate([]string{"a"}, identity, PageParams{})
+	if err != nil {
+		t.Fatalf("default limit should be accepted: %v", err)
+	}
+	if len(page.Items) != 1 {
+		t.Errorf("expected 1 item, got %d", len(page.Items))
+	}
+
+	tests := []PageParams{
+		{Limit: -1},
+		{Limit: MaxPageLimit + 1},
+		{Cursor: "not base64!"},
+	}
+	for _, params := range tests {
+		_, err := Paginate([]string{"a"}, identity, params)
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) || rpcErr.Code != InvalidParams {
+			t.Errorf("params %+v: expected InvalidParams error, got %v", params, err)
+		}
+	}
+}
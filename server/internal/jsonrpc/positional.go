@@ -0,0 +1,85 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MethodOption adjusts how a method is registered; see
+// RegisterMethodWithValidation.
+type MethodOption func(*MethodInfo)
+
+// WithPositionalParams accepts params sent as an array as well as an object,
+// mapping array elements to the ParamsSchema struct's fields in declaration
+// order; see MethodInfo.PositionalParams.
+func WithPositionalParams() MethodOption {
+	return func(info *MethodInfo) {
+		info.PositionalParams = true
+	}
+}
+
+// positionalFieldNames returns the JSON names of the exported fields of the
+// struct type schema is, points to or holds, in declaration order. Fields
+// tagged json:"-" are skipped. JSON Schema documents name no fields in
+// order, so they cannot be used.
+func positionalFieldNames(schema interface{}) ([]string, error) {
+	if _, ok := schema.(*JSONSchema); ok {
+		return nil, errors.New("positional params require a struct params schema, not a JSON Schema document")
+	}
+	schemaType, ok := schema.(reflect.Type)
+	if !ok && schema != nil {
+		schemaType = reflect.TypeOf(schema)
+	}
+	if schemaType != nil && schemaType.Kind() == reflect.Pointer {
+		schemaType = schemaType.Elem()
+	}
+	if schemaType == nil || schemaType.Kind() != reflect.Struct {
+		return nil, errors.New("positional params require a struct params schema")
+	}
+
+	var names []string
+	for i := 0; i < schemaType.NumField(); i++ {
+		field := schemaType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// mapPositionalParams rewrites params sent as an array into an object keyed
+// by fields, pairing elements and names in order. Params that are not an
+// array are returned unchanged. An array whose length differs from fields is
+// an error.
+func mapPositionalParams(params json.RawMessage, fields []string) (json.RawMessage, error) {
+	trimmed := bytes.TrimLeft(params, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return params, nil
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(trimmed, &elements); err != nil {
+		return nil, fmt.Errorf("failed to parse params: %w", err)
+	}
+	if len(elements) != len(fields) {
+		return nil, fmt.Errorf("expected %d positional params, got %d", len(fields), len(elements))
+	}
+
+	named := make(map[string]json.RawMessage, len(fields))
+	for i, name := range fields {
+		named[name] = elements[i]
+	}
+	return json.Marshal(named)
+}
@@ -0,0 +1,81 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type subtractParams struct {
+	Minuend    int    `json:"minuend" validate:"required"`
+	Subtrahend int    `json:"subtrahend"`
+	internal   string // unexported fields take no position
+	Ignored    string `json:"-"`
+}
+
+func newPositionalTestRouter(t *testing.T) *Router {
+	router := NewRouter()
+	subtract := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p subtractParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return p.Minuend - p.Subtrahend, nil
+	}
+	err := router.RegisterMethodWithValidation("test.subtract", subtract, reflect.TypeOf(subtractParams{}), nil, "Subtract", WithPositionalParams())
+	if err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+	return router
+}
+
+// TestPositionalParams tests that array params are mapped to the schema's
+// fields in declaration order, alongside named params.
+func TestPositionalParams(t *testing.T) {
+	router := newPositionalTestRouter(t)
+
+	for _, params := range []string{`[42, 23]`, ` [42,23]`, `{"minuend": 42, "subtrahend": 23}`, `{"subtrahend": 23, "minuend": 42}`} {
+		response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.subtract", Params: []byte(params), ID: 1})
+		if response.IsError() || response.Result != 19 {
+			t.Errorf("Expected 19 for %s, got %+v", params, response)
+		}
+	}
+}
+
+// TestPositionalParamsInvalid tests that arrays of the wrong length and
+// mapped params failing validation are rejected with InvalidParams.
+func TestPositionalParamsInvalid(t *testing.T) {
+	router := newPositionalTestRouter(t)
+
+	for _, params := range []string{`[42]`, `[42, 23, 1]`, `[]`, `[0, 23]`, `["42", 23]`} {
+		response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.subtract", Params: []byte(params), ID: 1})
+		if !response.IsError() || response.Error.Code != InvalidParams {
+			t.Errorf("Expected InvalidParams for %s, got %+v", params, response)
+		}
+	}
+
+	response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.subtract", Params: []byte(`[1]`), ID: 2})
+	if response.Error == nil || response.Error.Data != "expected 2 positional params, got 1" {
+		t.Errorf("Expected the arity in the error data, got %+v", response.Error)
+	}
+}
+
+// TestPositionalParamsRequireStructSchema tests that positional params
+// cannot be enabled without a struct schema to map them to.
+func TestPositionalParamsRequireStructSchema(t *testing.T) {
+	router := NewRouter()
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return nil, nil
+	}
+
+	if err := router.RegisterMethodWithValidation("test.schemaless", handler, nil, nil, "No schema", WithPositionalParams()); err == nil {
+		t.Error("Expected registration without a schema to fail")
+	}
+	if err := router.RegisterMethodWithValidation("test.document", handler, `{"type": "object"}`, nil, "JSON Schema", WithPositionalParams()); err == nil {
+		t.Error("Expected registration with a JSON Schema document to fail")
+	}
+	if err := router.RegisterMethodWithValidation("test.pointer", handler, reflect.TypeOf(&subtractParams{}), nil, "Pointer schema", WithPositionalParams()); err != nil {
+		t.Errorf("Expected a pointer to a struct to be accepted: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+)
+
+// RedactedValue replaces redacted fields in logged payloads.
+const RedactedValue = "[REDACTED]"
+
+// RedactParams returns a copy of params with the fields at the given paths
+// replaced by RedactedValue. A path names a field by its dot-separated keys,
+// e.g. "password" or "credentials.token"; arrays along the path are
+// redacted element by element. Params that are not valid JSON are replaced
+// entirely, since their contents cannot be inspected.
+func RedactParams(params json.RawMessage, paths []string) json.RawMessage {
+	if len(paths) == 0 || len(params) == 0 {
+		return params
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(params, &value); err != nil {
+		return json.RawMessage(`"` + RedactedValue + `"`)
+	}
+	for _, path := range paths {
+		redactPath(value, strings.Split(path, "."))
+	}
+
+	redacted, err := json.Marshal(value)
+	if err != nil {
+		return json.RawMessage(`"` + RedactedValue + `"`)
+	}
+	return redacted
+}
+
+// redactPath masks the field reached by keys within value, in place.
+func redactPath(value interface{}, keys []string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		field, ok := v[keys[0]]
+		if !ok {
+			return
+		}
+		if len(keys) == 1 {
+			v[keys[0]] = RedactedValue
+			return
+		}
+		redactPath(field, keys[1:])
+	case []interface{}:
+		for _, element := range v {
+			redactPath(element, keys)
+		}
+	}
+}
+
+// SetPayloadLogger enables debug logging of each request's method and params.
+// Fields listed in a method's RedactFields are masked before logging. A nil
+// logger disables payload logging, which is the default.
+func (r *Router) SetPayloadLogger(logger *slog.Logger) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.payloadLogger = logger
+}
+
+// logPayload logs a request's params, redacted per its method's RedactFields,
+// if payload logging is enabled.
+func (r *Router) logPayload(ctx context.Context, request *Request) {
+	r.mutex.RLock()
+	logger := r.payloadLogger
+	methodInfo := r.methods[request.Method]
+	r.mutex.RUnlock()
+
+	if logger == nil || !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	params := request.Params
+	if methodInfo != nil {
+		params = RedactParams(params, methodInfo.RedactFields)
+	}
+	logger.DebugContext(ctx, "JSON-RPC request payload",
+		"method", request.Method,
+		"id", request.ID,
+		"params", string(params))
+}
@@ -0,0 +1,72 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// TestPayloadLoggingRedactsFields tests that a method's RedactFields are
+// masked in logged payloads while other fields are logged as sent.
+func TestPayloadLoggingRedactsFields(t *testing.T) {
+	var buf bytes.Buffer
+	router := NewRouter()
+	router.SetPayloadLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "ok", nil
+	}
+	if err := router.RegisterMethod("login", handler, &MethodInfo{RedactFields: []string{"password", "device.token"}}); err != nil {
+		t.Fatalf("RegisterMethod failed: %v", err)
+	}
+
+	request := &Request{
+		JSONRPCVersion: Version,
+		Method:         "login",
+		Params:         json.RawMessage(`{"user":"alice","password":"hunter2","device":{"token":"abc123","os":"ios"}}`),
+		ID:             1,
+	}
+	if response := router.Route(context.Background(), request); response.Error != nil {
+		t.Fatalf("Route failed: %v", response.Error)
+	}
+
+	logged := buf.String()
+	for _, secret := range []string{"hunter2", "abc123"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("Logged payload should not contain %q: %s", secret, logged)
+		}
+	}
+	for _, expected := range []string{"alice", "ios", RedactedValue} {
+		if !strings.Contains(logged, expected) {
+			t.Errorf("Logged payload should contain %q: %s", expected, logged)
+		}
+	}
+}
+
+// TestRedactParams tests masking of top-level, nested and array fields.
+func TestRedactParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+		paths  []string
+		want   string
+	}{
+		{"no paths", `{"password":"x"}`, nil, `{"password":"x"}`},
+		{"top level", `{"password":"x","a":1}`, []string{"password"}, `{"a":1,"password":"[REDACTED]"}`},
+		{"missing field", `{"a":1}`, []string{"password"}, `{"a":1}`},
+		{"array elements", `[{"password":"x"},{"password":"y"}]`, []string{"password"}, `[{"password":"[REDACTED]"},{"password":"[REDACTED]"}]`},
+		{"invalid JSON", `{"password":`, []string{"password"}, `"[REDACTED]"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(RedactParams(json.RawMessage(tt.params), tt.paths))
+			if got != tt.want {
+				t.Errorf("RedactParams() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,13 +2,23 @@
 package jsonrpc
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
+// DefaultConcurrencyQueueTimeout is how long a queued request waits for a free
+// slot of a method whose MaxConcurrency limit has been reached.
+const DefaultConcurrencyQueueTimeout = 5 * time.Second
+
 // HandlerFunc represents a JSON-RPC method handler function.
 // It receives a context, parsed params, and returns a result and error.
 // The params will be validated according to the registered schema before calling the handler.
@@ -20,7 +30,9 @@ type MethodInfo struct {
 	Handler HandlerFunc
 
 	// ParamsSchema defines the validation schema for method parameters.
-	// This can be a struct type, interface{}, or nil if no validation is needed.
+	// This can be a struct type, interface{}, a JSON Schema document (string,
+	// []byte or *JSONSchema), or nil if no validation is needed. JSON Schema
+	// documents are compiled to a *JSONSchema when the method is registered.
 	ParamsSchema interface{}
 
 	// ResultSchema defines the validation schema for method results.
@@ -33,8 +45,101 @@ type MethodInfo struct {
 	// ValidateParams indicates whether to validate incoming parameters
 	ValidateParams bool
 
+	// RequireParams rejects requests that omit params (or send null) with
+	// InvalidParams instead of calling the handler
+	RequireParams bool
+
+	// StrictParams rejects params carrying fields the ParamsSchema type does
+	// not declare with InvalidParams instead of ignoring them. Only applies
+	// to struct schemas, not JSON Schema documents
+	StrictParams bool
+
+	// PositionalParams also accepts params sent as an array, mapping its
+	// elements to the exported fields of the ParamsSchema struct in
+	// declaration order before validation; the handler receives them as an
+	// object. An array of the wrong length is rejected with InvalidParams.
+	// Requires a struct ParamsSchema
+	PositionalParams bool
+
 	// ValidateResult indicates whether to validate outgoing results
 	ValidateResult bool
+
+	// MaxConcurrency caps how many executions of this method may run at the same
+	// time across all callers of the router. Zero means unlimited.
+	MaxConcurrency int
+
+	// RedactFields lists dot-separated paths of params fields, e.g. "password",
+	// that are masked when request payloads are logged
+	RedactFields []string
+
+	// Deprecated marks a method that still works but is due to be removed;
+	// responses to it carry a DeprecationNotice in their "_meta"
+	Deprecated bool
+
+	// ReplacedBy names the method clients of a deprecated method should call
+	// instead, if any
+	ReplacedBy string
+
+	// semaphore bounds concurrent executions when MaxConcurrency is set
+	semaphore chan struct{}
+
+	// positionalFields names the fields array params map to, in order, when
+	// PositionalParams is set
+	positionalFields []string
+}
+
+// ConcurrencyPolicy determines what happens to a request for a method whose
+// MaxConcurrency limit has been reached.
+type ConcurrencyPolicy int
+
+const (
+	// ConcurrencyQueue makes the request wait for a free slot until the queue timeout
+	// elapses or its context is done.
+	ConcurrencyQueue ConcurrencyPolicy = iota
+
+	// ConcurrencyReject immediately fails the request with a ServerBusy error.
+	ConcurrencyReject
+)
+
+// ParseConcurrencyPolicy parses "queue" or "reject" (case-insensitive).
+func ParseConcurrencyPolicy(name string) (ConcurrencyPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "queue":
+		return ConcurrencyQueue, nil
+	case "reject":
+		return ConcurrencyReject, nil
+	default:
+		return ConcurrencyQueue, fmt.Errorf("unknown concurrency policy %q", name)
+	}
+}
+
+// MethodNameNormalization determines how method names are normalized before
+// registration and lookup.
+type MethodNameNormalization int
+
+const (
+	// MethodNamesStrict matches method names exactly.
+	MethodNamesStrict MethodNameNormalization = iota
+
+	// MethodNamesTrim ignores leading and trailing whitespace.
+	MethodNamesTrim
+
+	// MethodNamesTrimLower ignores surrounding whitespace and case.
+	MethodNamesTrimLower
+)
+
+// ParseMethodNameNormalization parses "strict", "trim" or "lowercase".
+func ParseMethodNameNormalization(name string) (MethodNameNormalization, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "strict":
+		return MethodNamesStrict, nil
+	case "trim":
+		return MethodNamesTrim, nil
+	case "lowercase":
+		return MethodNamesTrimLower, nil
+	default:
+		return MethodNamesStrict, fmt.Errorf("unknown method name normalization %q", name)
+	}
 }
 
 // Router provides JSON-RPC 2.0 method registration and request routing functionality.
@@ -46,6 +151,60 @@ type Router struct {
 	// validator provides validation functionality for requests and responses
 	validator *Validator
 
+	// concurrencyPolicy decides whether requests over a method's MaxConcurrency queue or fail
+	concurrencyPolicy ConcurrencyPolicy
+
+	// concurrencyQueueTimeout bounds how long queued requests wait for a slot
+	concurrencyQueueTimeout time.Duration
+
+	// defaultTimeout bounds how long each handler may run; zero means no limit
+	defaultTimeout time.Duration
+
+	// maxBatchSize caps the requests in a batch; zero means no limit
+	maxBatchSize int
+
+	// handlerSemaphore bounds concurrent handler executions across all methods;
+	// nil means unlimited
+	handlerSemaphore chan struct{}
+
+	// auditSink receives an AuditEntry for every routed request
+	auditSink AuditSink
+
+	// metrics counts routed requests; see Metrics
+	metrics routerMetrics
+
+	// authorizer decides whether callers may invoke methods; nil allows all
+	authorizer MethodAuthorizer
+
+	// authRequired rejects calls without a principal, except to authExempt methods
+	authRequired bool
+
+	// authExempt lists the (normalized) methods callable without authentication
+	authExempt map[string]bool
+
+	// methodNameNormalization is applied to method names on registration and lookup
+	methodNameNormalization MethodNameNormalization
+
+	// payloadLogger, if set, receives a debug record of each request's params
+	payloadLogger *slog.Logger
+
+	// unknownNotificationLogger, if set, receives a warning for each
+	// notification to an unregistered method
+	unknownNotificationLogger *slog.Logger
+
+	// deprecationLogger, if set, receives a warning for each call to a
+	// deprecated method
+	deprecationLogger *slog.Logger
+
+	// trailingDataPolicy decides whether RouteJSON rejects bytes after the request object
+	trailingDataPolicy TrailingDataPolicy
+
+	// ackStyle decides the result sent for handlers returning Ack
+	ackStyle AckStyle
+
+	// errorCodes holds the custom codes added with RegisterErrorCode
+	errorCodes map[int]ErrorCodeInfo
+
 	// mutex protects concurrent access to the methods map
 	mutex sync.RWMutex
 }
@@ -53,8 +212,10 @@ type Router struct {
 // NewRouter creates a new JSON-RPC router with validation support.
 func NewRouter() *Router {
 	return &Router{
-		methods:   make(map[string]*MethodInfo),
-		validator: NewValidator(),
+		methods:                 make(map[string]*MethodInfo),
+		validator:               NewValidator(),
+		concurrencyQueueTimeout: DefaultConcurrencyQueueTimeout,
+		auditSink:               NoopAuditSink{},
 	}
 }
 
@@ -78,6 +239,11 @@ func (r *Router) RegisterMethod(methodName string, handler HandlerFunc, info *Me
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	methodName = r.normalizeMethodName(methodName)
+	if methodName == "" {
+		return fmt.Errorf("method name cannot be empty")
+	}
+
 	// Check if method is already registered
 	if _, exists := r.methods[methodName]; exists {
 		return fmt.Errorf("method '%s' is already registered", methodName)
@@ -87,7 +253,23 @@ func (r *Router) RegisterMethod(methodName string, handler HandlerFunc, info *Me
 	if info == nil {
 		info = &MethodInfo{}
 	}
+	if info.MaxConcurrency < 0 {
+		return fmt.Errorf("max concurrency cannot be negative")
+	}
 	info.Handler = handler
+	if err := compileParamsSchema(info); err != nil {
+		return err
+	}
+	if info.PositionalParams {
+		fields, err := positionalFieldNames(info.ParamsSchema)
+		if err != nil {
+			return err
+		}
+		info.positionalFields = fields
+	}
+	if info.MaxConcurrency > 0 {
+		info.semaphore = make(chan struct{}, info.MaxConcurrency)
+	}
 
 	// Store the method
 	r.methods[methodName] = info
@@ -95,8 +277,30 @@ func (r *Router) RegisterMethod(methodName string, handler HandlerFunc, info *Me
 	return nil
 }
 
+// compileParamsSchema replaces a JSON Schema document given as a string or
+// []byte in info.ParamsSchema with its compiled form.
+func compileParamsSchema(info *MethodInfo) error {
+	var document []byte
+	switch schema := info.ParamsSchema.(type) {
+	case string:
+		document = []byte(schema)
+	case []byte:
+		document = schema
+	default:
+		return nil
+	}
+
+	compiled, err := CompileJSONSchema(document)
+	if err != nil {
+		return fmt.Errorf("invalid params schema: %w", err)
+	}
+	info.ParamsSchema = compiled
+	return nil
+}
+
 // RegisterMethodWithValidation is a convenience method for registering a method with validation schemas.
-func (r *Router) RegisterMethodWithValidation(methodName string, handler HandlerFunc, paramsSchema, resultSchema interface{}, description string) error {
+// Options such as WithPositionalParams adjust the registration.
+func (r *Router) RegisterMethodWithValidation(methodName string, handler HandlerFunc, paramsSchema, resultSchema interface{}, description string, opts ...MethodOption) error {
 	info := &MethodInfo{
 		ParamsSchema:   paramsSchema,
 		ResultSchema:   resultSchema,
@@ -104,6 +308,9 @@ func (r *Router) RegisterMethodWithValidation(methodName string, handler Handler
 		ValidateParams: paramsSchema != nil,
 		ValidateResult: resultSchema != nil,
 	}
+	for _, opt := range opts {
+		opt(info)
+	}
 
 	return r.RegisterMethod(methodName, handler, info)
 }
@@ -119,11 +326,132 @@ func (r *Router) RegisterSimpleMethod(methodName string, handler HandlerFunc, de
 	return r.RegisterMethod(methodName, handler, info)
 }
 
+// SetConcurrencyPolicy sets how requests are handled when a method's MaxConcurrency
+// limit is reached. The default is ConcurrencyQueue.
+func (r *Router) SetConcurrencyPolicy(policy ConcurrencyPolicy) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.concurrencyPolicy = policy
+}
+
+// SetMaxConcurrentHandlers caps how many handlers may run at the same time
+// across all methods and callers of the router, on top of each method's
+// MaxConcurrency. Requests over the cap queue or fail with a ServerBusy error
+// according to the concurrency policy. Zero or a negative value, the default,
+// means unlimited. Changing the cap does not affect handlers already running.
+func (r *Router) SetMaxConcurrentHandlers(limit int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if limit <= 0 {
+		r.handlerSemaphore = nil
+		return
+	}
+	if r.handlerSemaphore == nil || cap(r.handlerSemaphore) != limit {
+		r.handlerSemaphore = make(chan struct{}, limit)
+	}
+}
+
+// SetConcurrencyQueueTimeout sets how long a request queued under ConcurrencyQueue
+// waits for a free slot before failing with a ServerBusy error. Requests without a
+// deadline (such as those arriving over WebSocket) rely on this bound, so zero or
+// a negative value, which waits for the request context only, should be used with
+// care. The default is DefaultConcurrencyQueueTimeout.
+func (r *Router) SetConcurrencyQueueTimeout(timeout time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.concurrencyQueueTimeout = timeout
+}
+
+// SetDefaultTimeout bounds how long a handler may run. When set, each handler
+// receives a context that expires after d, and a handler that fails once that
+// deadline has passed is answered with a RequestTimeout error. Handlers that
+// finish in time are unaffected. Zero or a negative value, the default,
+// leaves handlers without a deadline of their own.
+func (r *Router) SetDefaultTimeout(d time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if d < 0 {
+		d = 0
+	}
+	r.defaultTimeout = d
+}
+
+// SetMaxBatchSize caps the number of entries RouteJSON accepts in a batch.
+// A larger batch is answered with a single InvalidRequest error whose data
+// states the limit, and none of its requests are dispatched. Zero or a
+// negative value, the default, allows batches of any size.
+func (r *Router) SetMaxBatchSize(n int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	r.maxBatchSize = n
+}
+
+// SetAuditSink sets the sink that receives an AuditEntry after each routed
+// request, including failed ones. A nil sink restores the no-op default.
+func (r *Router) SetAuditSink(sink AuditSink) {
+	if sink == nil {
+		sink = NoopAuditSink{}
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.auditSink = sink
+}
+
+// SetMethodNameNormalization sets how method names are normalized on
+// registration and lookup. The default, MethodNamesStrict, matches names
+// exactly. It should be set before methods are registered, since names
+// already registered are not renormalized.
+func (r *Router) SetMethodNameNormalization(mode MethodNameNormalization) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.methodNameNormalization = mode
+}
+
+// normalizeMethodName applies the configured normalization to a method name.
+// The caller must hold the mutex.
+func (r *Router) normalizeMethodName(methodName string) string {
+	switch r.methodNameNormalization {
+	case MethodNamesTrim:
+		return strings.TrimSpace(methodName)
+	case MethodNamesTrimLower:
+		return strings.ToLower(strings.TrimSpace(methodName))
+	default:
+		return methodName
+	}
+}
+
+// SetAuthorizer sets the authorizer consulted before every method call.
+// A nil authorizer, the default, allows all calls.
+func (r *Router) SetAuthorizer(authorizer MethodAuthorizer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.authorizer = authorizer
+}
+
+// SetAuthRequired sets whether calls require an authenticated principal in
+// their context. Methods listed in exempt, such as ping, may be called
+// without one so clients can negotiate before authenticating; they skip
+// the authorizer as well. Names are normalized like registered methods.
+func (r *Router) SetAuthRequired(required bool, exempt []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.authRequired = required
+	r.authExempt = make(map[string]bool, len(exempt))
+	for _, method := range exempt {
+		r.authExempt[r.normalizeMethodName(method)] = true
+	}
+}
+
 // UnregisterMethod removes a method from the router.
 func (r *Router) UnregisterMethod(methodName string) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	methodName = r.normalizeMethodName(methodName)
+
 	if _, exists := r.methods[methodName]; !exists {
 		return fmt.Errorf("method '%s' is not registered", methodName)
 	}
@@ -137,7 +465,7 @@ func (r *Router) HasMethod(methodName string) bool {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	_, exists := r.methods[methodName]
+	_, exists := r.methods[r.normalizeMethodName(methodName)]
 	return exists
 }
 
@@ -154,12 +482,60 @@ func (r *Router) GetMethods() []string {
 	return methods
 }
 
+// MethodDescription is the introspection data for one registered method.
+type MethodDescription struct {
+	// Name is the registered (normalized) method name
+	Name string `json:"name"`
+
+	// Description is the human-readable documentation given at registration
+	Description string `json:"description"`
+
+	// ValidatesParams is true if params are checked against a schema
+	ValidatesParams bool `json:"validatesParams"`
+
+	// ValidatesResult is true if results are checked against a schema
+	ValidatesResult bool `json:"validatesResult"`
+
+	// MaxConcurrency is the method's concurrency limit, 0 if unlimited
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+
+	// Deprecated is true if the method is due to be removed
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	// ReplacedBy names the method to call instead of a deprecated one
+	ReplacedBy string `json:"replacedBy,omitempty"`
+}
+
+// DescribeMethods returns the introspection data of every registered method,
+// sorted by name.
+func (r *Router) DescribeMethods() []MethodDescription {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	descriptions := make([]MethodDescription, 0, len(r.methods))
+	for name, info := range r.methods {
+		descriptions = append(descriptions, MethodDescription{
+			Name:            name,
+			Description:     info.Description,
+			ValidatesParams: info.ValidateParams && info.ParamsSchema != nil,
+			ValidatesResult: info.ValidateResult && info.ResultSchema != nil,
+			MaxConcurrency:  info.MaxConcurrency,
+			Deprecated:      info.Deprecated,
+			ReplacedBy:      info.ReplacedBy,
+		})
+	}
+	sort.Slice(descriptions, func(i, j int) bool {
+		return descriptions[i].Name < descriptions[j].Name
+	})
+	return descriptions
+}
+
 // GetMethodInfo returns the method information for a registered method.
 func (r *Router) GetMethodInfo(methodName string) (*MethodInfo, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	info, exists := r.methods[methodName]
+	info, exists := r.methods[r.normalizeMethodName(methodName)]
 	if !exists {
 		return nil, fmt.Errorf("method '%s' is not registered", methodName)
 	}
@@ -170,18 +546,48 @@ func (r *Router) GetMethodInfo(methodName string) (*MethodInfo, error) {
 // Route processes a JSON-RPC request and returns a response.
 // This method handles request validation, method dispatch, and response formatting.
 // It is thread-safe and can be called concurrently.
+// With method name normalization enabled, request.Method is normalized in place
+// so that authorization and auditing see the canonical name.
 func (r *Router) Route(ctx context.Context, request *Request) *Response {
+	start := time.Now()
+
+	r.mutex.RLock()
+	request.Method = r.normalizeMethodName(request.Method)
+	r.mutex.RUnlock()
+
 	// Validate the request structure
 	if err := r.validator.ValidateRequest(request); err != nil {
-		return NewErrorResponse(r.createValidationError(err), request.ID)
+		response := NewErrorResponse(r.createValidationError(err), request.ID)
+		r.audit(ctx, request, start, response.Error)
+		return response
 	}
 
+	r.logPayload(ctx, request)
+	deprecation := r.deprecationNotice(ctx, request)
+
 	// Handle notifications (requests without ID)
 	if request.IsNotification() {
-		r.routeNotification(ctx, request)
+		r.audit(ctx, request, start, r.routeNotification(ctx, request))
 		return nil // No response for notifications
 	}
 
+	response := r.routeRequest(ctx, request)
+	if request.Meta != nil && request.Meta.Timing {
+		durationMs := float64(time.Since(start).Microseconds()) / 1000
+		response.Meta = &ResponseMeta{DurationMs: &durationMs}
+	}
+	if deprecation != nil {
+		if response.Meta == nil {
+			response.Meta = &ResponseMeta{}
+		}
+		response.Meta.Deprecation = deprecation
+	}
+	r.audit(ctx, request, start, response.Error)
+	return response
+}
+
+// routeRequest dispatches a validated request that expects a response.
+func (r *Router) routeRequest(ctx context.Context, request *Request) *Response {
 	// Find the method handler
 	r.mutex.RLock()
 	methodInfo, exists := r.methods[request.Method]
@@ -191,21 +597,34 @@ func (r *Router) Route(ctx context.Context, request *Request) *Response {
 		return NewErrorResponse(ErrMethodNotFound, request.ID)
 	}
 
-	// Validate parameters if schema is provided
-	if methodInfo.ValidateParams && methodInfo.ParamsSchema != nil {
-		if err := r.validateParams(request.Params, methodInfo.ParamsSchema); err != nil {
-			return NewErrorResponse(r.createParamsError(err), request.ID)
-		}
+	if rpcErr := r.authorize(ctx, request.Method); rpcErr != nil {
+		return NewErrorResponse(rpcErr, request.ID)
+	}
+
+	// Check for required params and validate them if schema is provided
+	params, err := r.checkParams(methodInfo, request.Params)
+	if err != nil {
+		return NewErrorResponse(r.createParamsError(err), request.ID)
+	}
+
+	// Acquire a concurrency slot if the method is limited
+	release, rpcErr := r.acquireSlot(ctx, methodInfo)
+	if rpcErr != nil {
+		return NewErrorResponse(rpcErr, request.ID)
 	}
+	defer release()
 
 	// Call the method handler
-	result, err := r.callHandler(ctx, methodInfo.Handler, request.Params)
+	result, err := r.callHandler(ctx, methodInfo.Handler, params)
 	if err != nil {
 		return NewErrorResponse(r.createInternalError(err), request.ID)
 	}
 
-	// Validate result if schema is provided
-	if methodInfo.ValidateResult && methodInfo.ResultSchema != nil {
+	// Substitute the configured acknowledgement shape; otherwise validate
+	// the result if a schema is provided
+	if _, ok := result.(AckResult); ok {
+		result = r.ackResult()
+	} else if methodInfo.ValidateResult && methodInfo.ResultSchema != nil {
 		if err := r.validateResult(result, methodInfo.ResultSchema); err != nil {
 			return NewErrorResponse(r.createInternalError(fmt.Errorf("result validation failed: %w", err)), request.ID)
 		}
@@ -221,35 +640,214 @@ func (r *Router) Route(ctx context.Context, request *Request) *Response {
 }
 
 // routeNotification handles notification requests (requests without ID).
-func (r *Router) routeNotification(ctx context.Context, request *Request) {
+// Failures are never sent to the client; the returned error is only used for auditing.
+func (r *Router) routeNotification(ctx context.Context, request *Request) *Error {
 	// Find the method handler
 	r.mutex.RLock()
 	methodInfo, exists := r.methods[request.Method]
 	r.mutex.RUnlock()
 
 	if !exists {
-		// Silently ignore notifications for non-existent methods as per JSON-RPC spec
+		// Ignore notifications for non-existent methods as per JSON-RPC spec,
+		// logging them only when asked to
+		r.logUnknownNotification(ctx, request)
+		return ErrMethodNotFound
+	}
+
+	if rpcErr := r.authorize(ctx, request.Method); rpcErr != nil {
+		return rpcErr
+	}
+
+	// Check for required params and validate them if schema is provided
+	params, err := r.checkParams(methodInfo, request.Params)
+	if err != nil {
+		// Silently ignore invalid notifications as per JSON-RPC spec
+		return r.createParamsError(err)
+	}
+
+	// Acquire a concurrency slot if the method is limited
+	release, rpcErr := r.acquireSlot(ctx, methodInfo)
+	if rpcErr != nil {
+		// Silently drop notifications that cannot run
+		return rpcErr
+	}
+	defer release()
+
+	// Call the method handler (the result is discarded for notifications)
+	if _, err := r.callHandler(ctx, methodInfo.Handler, params); err != nil {
+		return r.createInternalError(err)
+	}
+	return nil
+}
+
+// SetUnknownNotificationLogger enables warn-level logging of notifications
+// to methods that are not registered. Such notifications never get a
+// response either way. A nil logger disables the logging, which is the default.
+func (r *Router) SetUnknownNotificationLogger(logger *slog.Logger) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.unknownNotificationLogger = logger
+}
+
+// logUnknownNotification logs a notification to an unregistered method if
+// an unknown notification logger is set. Params are not logged.
+func (r *Router) logUnknownNotification(ctx context.Context, request *Request) {
+	r.mutex.RLock()
+	logger := r.unknownNotificationLogger
+	r.mutex.RUnlock()
+
+	if logger == nil {
 		return
 	}
+	logger.WarnContext(ctx, "Ignoring notification to unknown method",
+		"method", request.Method,
+		"sessionCode", SessionCodeFromContext(ctx))
+}
 
-	// Validate parameters if schema is provided
-	if methodInfo.ValidateParams && methodInfo.ParamsSchema != nil {
-		if err := r.validateParams(request.Params, methodInfo.ParamsSchema); err != nil {
-			// Silently ignore invalid notifications as per JSON-RPC spec
-			return
+// authorize checks the authentication requirement and then consults the
+// authorizer, if any, for a method call. Auth-exempt methods are always allowed.
+func (r *Router) authorize(ctx context.Context, method string) *Error {
+	r.mutex.RLock()
+	authorizer := r.authorizer
+	authRequired := r.authRequired
+	exempt := r.authExempt[method]
+	r.mutex.RUnlock()
+
+	if exempt {
+		return nil
+	}
+	if authRequired {
+		if _, ok := PrincipalFromContext(ctx); !ok {
+			return NewErrorWithData(Unauthorized, ErrUnauthorized.Message, "authentication required")
+		}
+	}
+
+	if authorizer == nil {
+		return nil
+	}
+	err := authorizer.Authorize(ctx, method)
+	if err == nil {
+		return nil
+	}
+
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	return NewErrorWithData(Unauthorized, ErrUnauthorized.Message, err.Error())
+}
+
+// audit reports a routed request to the audit sink and counts it in the
+// router metrics.
+func (r *Router) audit(ctx context.Context, request *Request, start time.Time, rpcErr *Error) {
+	entry := AuditEntry{
+		Method:       request.Method,
+		SessionCode:  SessionCodeFromContext(ctx),
+		Timestamp:    start,
+		Duration:     time.Since(start),
+		Success:      rpcErr == nil,
+		Notification: request.IsNotification(),
+	}
+	if rpcErr != nil {
+		entry.ErrorCode = rpcErr.Code
+	}
+
+	r.mutex.RLock()
+	sink := r.auditSink
+	_, registered := r.methods[request.Method]
+	r.mutex.RUnlock()
+	sink.Record(entry)
+	r.metrics.record(request.Method, registered, rpcErr != nil)
+}
+
+// acquireSlot reserves an execution slot for a method, first under its
+// MaxConcurrency limit and then under the router-wide handler cap. Depending
+// on the concurrency policy it waits for free slots, bounded by a single
+// queue timeout and the context, or fails immediately with a ServerBusy
+// error. The returned release function must be called once the handler has
+// finished.
+func (r *Router) acquireSlot(ctx context.Context, methodInfo *MethodInfo) (func(), *Error) {
+	r.mutex.RLock()
+	policy := r.concurrencyPolicy
+	queueTimeout := r.concurrencyQueueTimeout
+	handlerSemaphore := r.handlerSemaphore
+	r.mutex.RUnlock()
+
+	if methodInfo.semaphore == nil && handlerSemaphore == nil {
+		return func() {}, nil
+	}
+
+	var timeout <-chan time.Time
+	if policy == ConcurrencyQueue && queueTimeout > 0 {
+		timer := time.NewTimer(queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	releaseMethod, rpcErr := acquireSemaphore(ctx, methodInfo.semaphore, policy, timeout, queueTimeout,
+		fmt.Sprintf("method concurrency limit of %d reached", methodInfo.MaxConcurrency))
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	releaseHandler, rpcErr := acquireSemaphore(ctx, handlerSemaphore, policy, timeout, queueTimeout,
+		fmt.Sprintf("server handler limit of %d reached", cap(handlerSemaphore)))
+	if rpcErr != nil {
+		releaseMethod()
+		return nil, rpcErr
+	}
+
+	return func() {
+		releaseHandler()
+		releaseMethod()
+	}, nil
+}
+
+// acquireSemaphore takes a slot of semaphore, if set, under the given policy.
+// Under ConcurrencyQueue it waits until timeout fires or ctx is done; under
+// ConcurrencyReject it fails with a ServerBusy error carrying busyDetail.
+func acquireSemaphore(ctx context.Context, semaphore chan struct{}, policy ConcurrencyPolicy,
+	timeout <-chan time.Time, queueTimeout time.Duration, busyDetail string) (func(), *Error) {
+	if semaphore == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-semaphore }
+
+	if policy == ConcurrencyReject {
+		select {
+		case semaphore <- struct{}{}:
+			return release, nil
+		default:
+			return nil, NewErrorWithData(ServerBusy, ErrServerBusy.Message, busyDetail)
 		}
 	}
 
-	// Call the method handler (ignore result and errors for notifications)
-	_, _ = r.callHandler(ctx, methodInfo.Handler, request.Params)
+	select {
+	case semaphore <- struct{}{}:
+		return release, nil
+	case <-timeout:
+		return nil, NewErrorWithData(ServerBusy, ErrServerBusy.Message,
+			fmt.Sprintf("gave up waiting for a free slot after %s", queueTimeout))
+	case <-ctx.Done():
+		return nil, NewErrorWithData(ServerBusy, ErrServerBusy.Message,
+			fmt.Sprintf("gave up waiting for a free slot: %v", ctx.Err()))
+	}
 }
 
 // RouteJSON is a convenience method that accepts JSON bytes and returns JSON response.
-// It handles JSON parsing and serialization automatically.
+// It handles JSON parsing and serialization automatically. Bytes after the
+// request object are handled according to the trailing data policy. An array
+// of requests is routed as a batch; see routeBatch.
 func (r *Router) RouteJSON(ctx context.Context, requestJSON []byte) ([]byte, error) {
+	// An array is a batch of requests
+	if isBatch(requestJSON) {
+		return r.routeBatch(ctx, requestJSON)
+	}
+
 	// Parse the request
 	var request Request
-	if err := json.Unmarshal(requestJSON, &request); err != nil {
+	if err := r.decodeRequest(requestJSON, &request); err != nil {
 		// Return parse error response
 		response := NewErrorResponse(ErrParse, nil)
 		return json.Marshal(response)
@@ -274,19 +872,52 @@ func (r *Router) RouteJSON(ctx context.Context, requestJSON []byte) ([]byte, err
 	return responseJSON, nil
 }
 
+// errParamsRequired reports a call omitting the params of a method
+// registered with RequireParams.
+var errParamsRequired = errors.New("params are required")
+
+// checkParams rejects omitted params when the method requires them, maps
+// array params onto named ones for methods with PositionalParams, and
+// validates params against the method's schema if it has one. It returns
+// the params to pass to the handler.
+func (r *Router) checkParams(methodInfo *MethodInfo, params json.RawMessage) (json.RawMessage, error) {
+	if methodInfo.RequireParams && (len(params) == 0 || string(params) == "null") {
+		return nil, errParamsRequired
+	}
+	if methodInfo.PositionalParams {
+		mapped, err := mapPositionalParams(params, methodInfo.positionalFields)
+		if err != nil {
+			return nil, err
+		}
+		params = mapped
+	}
+	if methodInfo.ValidateParams && methodInfo.ParamsSchema != nil {
+		if err := r.validateParams(params, methodInfo.ParamsSchema, methodInfo.StrictParams); err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
+
 // validateParams validates method parameters against the provided schema.
-func (r *Router) validateParams(params json.RawMessage, schema interface{}) error {
+// With strict set, fields the schema type does not declare are rejected.
+func (r *Router) validateParams(params json.RawMessage, schema interface{}, strict bool) error {
 	if params == nil {
 		return nil
 	}
 
+	// JSON Schema documents are validated against the raw params
+	if jsonSchema, ok := schema.(*JSONSchema); ok {
+		return jsonSchema.Validate(params)
+	}
+
 	// If schema is a reflect.Type, create an instance
 	if schemaType, ok := schema.(reflect.Type); ok {
 		// Create a new instance of the schema type
 		instance := reflect.New(schemaType).Interface()
 		
 		// Unmarshal params into the instance
-		if err := json.Unmarshal(params, instance); err != nil {
+		if err := decodeParams(params, instance, strict); err != nil {
 			return fmt.Errorf("failed to parse params: %w", err)
 		}
 
@@ -295,13 +926,33 @@ func (r *Router) validateParams(params json.RawMessage, schema interface{}) erro
 	}
 
 	// If schema is a concrete type, unmarshal and validate directly
-	if err := json.Unmarshal(params, schema); err != nil {
+	if err := decodeParams(params, schema, strict); err != nil {
 		return fmt.Errorf("failed to parse params: %w", err)
 	}
 
 	return r.validator.Validate(schema)
 }
 
+// decodeParams unmarshals params into target, failing on fields target does
+// not declare when strict is set. Like json.Unmarshal it rejects anything
+// after the params value and bounds nesting depth, so deeply nested params
+// cannot exhaust the stack.
+func decodeParams(params json.RawMessage, target interface{}, strict bool) error {
+	if !strict {
+		return json.Unmarshal(params, target)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected data after params")
+	}
+	return nil
+}
+
 // validateResult validates method results against the provided schema.
 func (r *Router) validateResult(result interface{}, schema interface{}) error {
 	if result == nil {
@@ -319,8 +970,24 @@ func (r *Router) validateResult(result interface{}, schema interface{}) error {
 	return r.validator.Validate(result)
 }
 
-// callHandler safely calls a method handler with error recovery.
+// callHandler safely calls a method handler with error recovery, under the
+// router's default timeout if one is set.
 func (r *Router) callHandler(ctx context.Context, handler HandlerFunc, params json.RawMessage) (result interface{}, err error) {
+	r.mutex.RLock()
+	timeout := r.defaultTimeout
+	r.mutex.RUnlock()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		defer func() {
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = NewErrorWithData(RequestTimeout, ErrRequestTimeout.Message,
+					fmt.Sprintf("handler did not finish within %s", timeout))
+			}
+		}()
+	}
+
 	// Recover from panics in handler code
 	defer func() {
 		if r := recover(); r != nil {
@@ -348,7 +1015,18 @@ func (r *Router) createParamsError(err error) *Error {
 }
 
 // createInternalError creates a JSON-RPC internal error.
+// Handlers can return a *Error (possibly wrapped) to choose the error sent to the caller.
+// Context deadline and cancellation errors map to RequestTimeout and RequestCancelled.
 func (r *Router) createInternalError(err error) *Error {
+	var rpcErr *Error
+	switch {
+	case errors.As(err, &rpcErr):
+		return rpcErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewErrorWithData(RequestTimeout, ErrRequestTimeout.Message, err.Error())
+	case errors.Is(err, context.Canceled):
+		return NewErrorWithData(RequestCancelled, ErrRequestCancelled.Message, err.Error())
+	}
 	return NewErrorWithData(InternalError, "Internal error", err.Error())
 }
 
@@ -361,6 +1039,12 @@ func (r *Router) Clear() {
 	r.methods = make(map[string]*MethodInfo)
 }
 
+// Validator returns the validator the router checks requests, responses
+// and method schemas with.
+func (r *Router) Validator() *Validator {
+	return r.validator
+}
+
 // MethodCount returns the number of registered methods.
 func (r *Router) MethodCount() int {
 	r.mutex.RLock()
@@ -1,9 +1,11 @@
 package jsonrpc
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"reflect"
 	"strings"
 	"sync"
@@ -655,6 +657,48 @@ func TestRouteNotificationForNonExistentMethod(t *testing.T) {
 	}
 }
 
+// TestRouteUnknownNotificationLogging tests that notifications to unknown
+// methods are logged at warn level only when an unknown notification logger
+// is set, and never produce a response.
+func TestRouteUnknownNotificationLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	router := NewRouter()
+	router.RegisterSimpleMethod("known", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "ok", nil
+	}, "")
+
+	route := func(method string) {
+		t.Helper()
+		if response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: method}); response != nil {
+			t.Errorf("Expected no response for notification to %q, got %+v", method, response)
+		}
+	}
+
+	route("non.existent")
+	if buf.Len() != 0 {
+		t.Errorf("Expected no log output while disabled, got %s", buf.String())
+	}
+
+	router.SetUnknownNotificationLogger(logger)
+	route("non.existent")
+	route("known")
+	logged := buf.String()
+	if !strings.Contains(logged, `"level":"WARN"`) || !strings.Contains(logged, `"method":"non.existent"`) {
+		t.Errorf("Expected a warning naming the unknown method, got %s", logged)
+	}
+	if strings.Contains(logged, `"method":"known"`) {
+		t.Errorf("Notifications to registered methods should not be logged: %s", logged)
+	}
+
+	buf.Reset()
+	router.SetUnknownNotificationLogger(nil)
+	route("non.existent")
+	if buf.Len() != 0 {
+		t.Errorf("Expected no log output after disabling, got %s", buf.String())
+	}
+}
+
 // TestRouteNotificationWithInvalidParams tests notifications with invalid parameters.
 func TestRouteNotificationWithInvalidParams(t *testing.T) {
 	router := NewRouter()
@@ -737,4 +781,625 @@ func TestRouteJSONNotification(t *testing.T) {
 	if !called {
 		t.Error("Handler should have been called for notification")
 	}
-}
\ No newline at end of file
+}
+
+// registerGatedMethod registers a method limited to maxConcurrency simultaneous
+// executions that blocks until release is closed, tracking peak concurrency.
+func registerGatedMethod(t *testing.T, router *Router, maxConcurrency int, release <-chan struct{}, peak *int32) {
+	t.Helper()
+
+	var current int32
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		n := atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+		for {
+			old := atomic.LoadInt32(peak)
+			if n <= old || atomic.CompareAndSwapInt32(peak, old, n) {
+				break
+			}
+		}
+		<-release
+		return "done", nil
+	}
+
+	err := router.RegisterMethod("test.gated", handler, &MethodInfo{
+		Description:    "Method with a concurrency gate",
+		MaxConcurrency: maxConcurrency,
+	})
+	if err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+}
+
+// TestRouteMaxConcurrencyQueue tests that queued calls respect the method's concurrency cap.
+func TestRouteMaxConcurrencyQueue(t *testing.T) {
+	router := NewRouter()
+	release := make(chan struct{})
+	var peak int32
+	registerGatedMethod(t, router, 2, release, &peak)
+
+	const numRequests = 6
+	var wg sync.WaitGroup
+	responses := make([]*Response, numRequests)
+
+	wg.Add(numRequests)
+	for i := 0; i < numRequests; i++ {
+		go func(index int) {
+			defer wg.Done()
+			responses[index] = router.Route(context.Background(), &Request{
+				JSONRPCVersion: "2.0",
+				Method:         "test.gated",
+				ID:             index + 1,
+			})
+		}(i)
+	}
+
+	// Give all requests a chance to reach the gate before letting them through
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt32(&peak); got != 2 {
+		t.Errorf("Expected 2 concurrent executions while blocked, got %d", got)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&peak); got > 2 {
+		t.Errorf("Concurrency cap exceeded: peak %d", got)
+	}
+	for i, response := range responses {
+		if response.IsError() {
+			t.Errorf("Request %d failed: %v", i, response.Error)
+		}
+	}
+}
+
+// TestRouteMaxConcurrencyReject tests that calls over the cap are rejected with ServerBusy.
+func TestRouteMaxConcurrencyReject(t *testing.T) {
+	router := NewRouter()
+	router.SetConcurrencyPolicy(ConcurrencyReject)
+	release := make(chan struct{})
+	var peak int32
+	registerGatedMethod(t, router, 2, release, &peak)
+
+	// Occupy both slots
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func(index int) {
+			defer wg.Done()
+			router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.gated", ID: index})
+		}(i)
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&peak) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.gated", ID: "over"})
+	if !response.IsError() {
+		t.Fatal("Expected request over the concurrency cap to be rejected")
+	}
+	if response.Error.Code != ServerBusy {
+		t.Errorf("Expected ServerBusy error, got %d", response.Error.Code)
+	}
+	if !IsServerErrorCode(response.Error.Code) {
+		t.Errorf("Expected a server-range error code, got %d", response.Error.Code)
+	}
+
+	close(release)
+	wg.Wait()
+
+	// Slots are released once handlers finish
+	response = router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.gated", ID: "after"})
+	if response.IsError() {
+		t.Errorf("Expected request to succeed after slots were released, got %v", response.Error)
+	}
+}
+
+// TestRouteMaxConcurrencyQueueContextCancelled tests that a queued call gives up when its context ends.
+func TestRouteMaxConcurrencyQueueContextCancelled(t *testing.T) {
+	router := NewRouter()
+	release := make(chan struct{})
+	defer close(release)
+	var peak int32
+	registerGatedMethod(t, router, 1, release, &peak)
+
+	go router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.gated", ID: 1})
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&peak) < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	response := router.Route(ctx, &Request{JSONRPCVersion: "2.0", Method: "test.gated", ID: 2})
+	if !response.IsError() || response.Error.Code != ServerBusy {
+		t.Fatalf("Expected ServerBusy after waiting for a slot, got %+v", response)
+	}
+}
+
+// TestRouteMaxConcurrencyQueueTimeout tests that a queued call without a deadline
+// gives up once the router's queue timeout elapses.
+func TestRouteMaxConcurrencyQueueTimeout(t *testing.T) {
+	router := NewRouter()
+	router.SetConcurrencyQueueTimeout(20 * time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	var peak int32
+	registerGatedMethod(t, router, 1, release, &peak)
+
+	go router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.gated", ID: 1})
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&peak) < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan *Response, 1)
+	go func() {
+		done <- router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.gated", ID: 2})
+	}()
+
+	select {
+	case response := <-done:
+		if !response.IsError() || response.Error.Code != ServerBusy {
+			t.Fatalf("Expected ServerBusy after the queue timeout, got %+v", response)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Queued request without a deadline should not wait forever")
+	}
+}
+
+// TestRouteMaxConcurrentHandlers tests that the router-wide handler cap bounds
+// concurrent executions of a method without a cap of its own and applies to
+// other methods as well.
+func TestRouteMaxConcurrentHandlers(t *testing.T) {
+	router := NewRouter()
+	router.SetMaxConcurrentHandlers(3)
+	release := make(chan struct{})
+	var peak int32
+	registerGatedMethod(t, router, 0, release, &peak)
+
+	const numRequests = 8
+	var wg sync.WaitGroup
+	responses := make([]*Response, numRequests)
+
+	wg.Add(numRequests)
+	for i := 0; i < numRequests; i++ {
+		go func(index int) {
+			defer wg.Done()
+			responses[index] = router.Route(context.Background(), &Request{
+				JSONRPCVersion: "2.0",
+				Method:         "test.gated",
+				ID:             index + 1,
+			})
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt32(&peak); got != 3 {
+		t.Errorf("Expected 3 concurrent executions while blocked, got %d", got)
+	}
+
+	// Every slot is taken, so a different method is rejected too
+	router.SetConcurrencyPolicy(ConcurrencyReject)
+	err := router.RegisterMethod("test.other", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "ok", nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+	response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.other", ID: "other"})
+	if !response.IsError() || response.Error.Code != ServerBusy {
+		t.Errorf("Expected ServerBusy for another method over the handler cap, got %+v", response)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&peak); got > 3 {
+		t.Errorf("Handler cap exceeded: peak %d", got)
+	}
+	for i, response := range responses {
+		if response.IsError() {
+			t.Errorf("Request %d failed: %v", i, response.Error)
+		}
+	}
+
+	response = router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.other", ID: "after"})
+	if response.IsError() {
+		t.Errorf("Expected request to succeed after slots were released, got %v", response.Error)
+	}
+}
+
+// TestRouteWithJSONSchemaParams tests params validation against a JSON Schema document.
+func TestRouteWithJSONSchemaParams(t *testing.T) {
+	router := NewRouter()
+
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "ok", nil
+	}
+
+	schema := `{
+		"type": "object",
+		"properties": {
+			"user": {
+				"type": "object",
+				"properties": {"name": {"type": "string", "minLength": 1}},
+				"required": ["name"]
+			}
+		},
+		"required": ["user"]
+	}`
+	if err := router.RegisterMethodWithValidation("user.create", handler, schema, nil, "Create a user"); err != nil {
+		t.Fatalf("Failed to register method with JSON schema: %v", err)
+	}
+
+	info, err := router.GetMethodInfo("user.create")
+	if err != nil {
+		t.Fatalf("GetMethodInfo failed: %v", err)
+	}
+	if _, ok := info.ParamsSchema.(*JSONSchema); !ok {
+		t.Errorf("Expected ParamsSchema to be compiled, got %T", info.ParamsSchema)
+	}
+
+	tests := []struct {
+		name      string
+		params    string
+		wantError bool
+		wantField string
+	}{
+		{"valid params", `{"user":{"name":"alice"}}`, false, ""},
+		{"missing top-level property", `{}`, true, "user"},
+		{"missing nested property", `{"user":{}}`, true, "user.name"},
+		{"wrong type", `{"user":{"name":42}}`, true, "user.name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := router.Route(context.Background(), &Request{
+				JSONRPCVersion: "2.0",
+				Method:         "user.create",
+				Params:         json.RawMessage(tt.params),
+				ID:             1,
+			})
+
+			if !tt.wantError {
+				if response.Error != nil {
+					t.Fatalf("Unexpected error: %v", response.Error)
+				}
+				return
+			}
+
+			if response.Error == nil {
+				t.Fatal("Expected a validation error")
+			}
+			if response.Error.Code != InvalidParams {
+				t.Errorf("Expected InvalidParams, got %d", response.Error.Code)
+			}
+			if data, _ := response.Error.Data.(string); !strings.Contains(data, "'"+tt.wantField+"'") {
+				t.Errorf("Expected error data to name field %q, got %v", tt.wantField, response.Error.Data)
+			}
+		})
+	}
+
+	// Byte-slice schemas are accepted and malformed schemas are rejected at registration
+	if err := router.RegisterMethodWithValidation("bytes.schema", handler, []byte(`{"type":"object"}`), nil, ""); err != nil {
+		t.Errorf("Failed to register method with []byte schema: %v", err)
+	}
+	if err := router.RegisterMethodWithValidation("bad.schema", handler, `{"type":"thing"}`, nil, ""); err == nil {
+		t.Error("Expected an error registering a malformed JSON schema")
+	}
+}
+
+// TestRouteRequireParams tests that methods registered with RequireParams
+// reject omitted params with InvalidParams.
+func TestRouteRequireParams(t *testing.T) {
+	router := NewRouter()
+
+	type greetParams struct {
+		Name string `json:"name" validate:"required"`
+	}
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "ok", nil
+	}
+	err := router.RegisterMethod("user.greet", handler, &MethodInfo{
+		ParamsSchema:   reflect.TypeOf(greetParams{}),
+		ValidateParams: true,
+		RequireParams:  true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		params    json.RawMessage
+		wantError bool
+	}{
+		{"omitted params", nil, true},
+		{"null params", json.RawMessage(`null`), true},
+		{"valid params", json.RawMessage(`{"name":"alice"}`), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := router.Route(context.Background(), &Request{
+				JSONRPCVersion: "2.0",
+				Method:         "user.greet",
+				Params:         tt.params,
+				ID:             1,
+			})
+
+			if !tt.wantError {
+				if response.Error != nil {
+					t.Fatalf("Unexpected error: %v", response.Error)
+				}
+				return
+			}
+
+			if response.Error == nil {
+				t.Fatal("Expected an error for omitted params")
+			}
+			if response.Error.Code != InvalidParams {
+				t.Errorf("Expected InvalidParams, got %d", response.Error.Code)
+			}
+		})
+	}
+}
+
+// TestRouteStrictParams tests that MethodInfo.StrictParams rejects params
+// fields the schema does not declare, which are otherwise ignored.
+func TestRouteStrictParams(t *testing.T) {
+	type greetParams struct {
+		Name string `json:"name" validate:"required"`
+	}
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "ok", nil
+	}
+
+	tests := []struct {
+		name      string
+		strict    bool
+		params    string
+		wantError bool
+	}{
+		{"strict with unexpected field", true, `{"name":"alice","admin":true}`, true},
+		{"strict with declared fields", true, `{"name":"alice"}`, false},
+		{"lenient with unexpected field", false, `{"name":"alice","admin":true}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouter()
+			err := router.RegisterMethod("user.greet", handler, &MethodInfo{
+				ParamsSchema:   reflect.TypeOf(greetParams{}),
+				ValidateParams: true,
+				StrictParams:   tt.strict,
+			})
+			if err != nil {
+				t.Fatalf("Failed to register method: %v", err)
+			}
+
+			response := router.Route(context.Background(), &Request{
+				JSONRPCVersion: "2.0",
+				Method:         "user.greet",
+				Params:         json.RawMessage(tt.params),
+				ID:             1,
+			})
+
+			if !tt.wantError {
+				if response.Error != nil {
+					t.Fatalf("Unexpected error: %v", response.Error)
+				}
+				return
+			}
+
+			if response.Error == nil {
+				t.Fatal("Expected an error for the unexpected field")
+			}
+			if response.Error.Code != InvalidParams {
+				t.Errorf("Expected InvalidParams, got %d", response.Error.Code)
+			}
+			if data, _ := response.Error.Data.(string); !strings.Contains(data, "admin") {
+				t.Errorf("Expected error data to name the field, got %v", response.Error.Data)
+			}
+		})
+	}
+}
+
+// TestRouteTimingMeta tests that requests opting in with "_meta":{"timing":true}
+// get the processing time in the response's _meta, and others an unchanged response.
+func TestRouteTimingMeta(t *testing.T) {
+	router := NewRouter()
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		time.Sleep(5 * time.Millisecond)
+		return "done", nil
+	}
+	if err := router.RegisterSimpleMethod("test.slow", handler, "Slow test method"); err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+
+	var timed struct {
+		Result string                     `json:"result"`
+		Meta   map[string]json.RawMessage `json:"_meta"`
+	}
+	responseJSON, err := router.RouteJSON(context.Background(), []byte(`{"jsonrpc":"2.0","method":"test.slow","id":1,"_meta":{"timing":true}}`))
+	if err != nil {
+		t.Fatalf("RouteJSON failed: %v", err)
+	}
+	if err := json.Unmarshal(responseJSON, &timed); err != nil {
+		t.Fatalf("Invalid response JSON: %v", err)
+	}
+	if timed.Result != "done" {
+		t.Errorf("Expected the standard result, got %q", timed.Result)
+	}
+	var durationMs float64
+	if err := json.Unmarshal(timed.Meta["durationMs"], &durationMs); err != nil {
+		t.Fatalf("Expected _meta.durationMs in %s: %v", responseJSON, err)
+	}
+	if durationMs < 5 || durationMs > 5000 {
+		t.Errorf("Expected a plausible duration of at least 5ms, got %v", durationMs)
+	}
+
+	// Without the flag the response is the plain JSON-RPC response
+	responseJSON, err = router.RouteJSON(context.Background(), []byte(`{"jsonrpc":"2.0","method":"test.slow","id":1}`))
+	if err != nil {
+		t.Fatalf("RouteJSON failed: %v", err)
+	}
+	if want := `{"jsonrpc":"2.0","result":"done","id":1}`; string(responseJSON) != want {
+		t.Errorf("Expected %s, got %s", want, responseJSON)
+	}
+}
+
+// TestMethodNameNormalization tests trimmed and case-insensitive method matching.
+func TestMethodNameNormalization(t *testing.T) {
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "pong", nil
+	}
+
+	tests := []struct {
+		name    string
+		mode    MethodNameNormalization
+		method  string
+		matches bool
+	}{
+		{"strict exact", MethodNamesStrict, "ping", true},
+		{"strict rejects whitespace", MethodNamesStrict, " ping ", false},
+		{"strict rejects case", MethodNamesStrict, "Ping", false},
+		{"trim matches whitespace", MethodNamesTrim, " ping ", true},
+		{"trim keeps case", MethodNamesTrim, " Ping ", false},
+		{"lowercase matches both", MethodNamesTrimLower, " Ping ", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouter()
+			router.SetMethodNameNormalization(tt.mode)
+			if err := router.RegisterSimpleMethod("ping", handler, ""); err != nil {
+				t.Fatalf("Failed to register method: %v", err)
+			}
+
+			response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: tt.method, ID: 1})
+			if tt.matches && response.Error != nil {
+				t.Errorf("Expected %q to match, got %v", tt.method, response.Error)
+			}
+			if !tt.matches && (response.Error == nil || response.Error.Code != MethodNotFound) {
+				t.Errorf("Expected MethodNotFound for %q, got %+v", tt.method, response.Error)
+			}
+			if router.HasMethod(tt.method) != tt.matches {
+				t.Errorf("HasMethod(%q) = %v, expected %v", tt.method, !tt.matches, tt.matches)
+			}
+		})
+	}
+
+	// Registration is normalized too, so differently written names collide
+	router := NewRouter()
+	router.SetMethodNameNormalization(MethodNamesTrimLower)
+	router.RegisterSimpleMethod(" Echo ", handler, "")
+	if err := router.RegisterSimpleMethod("echo", handler, ""); err == nil {
+		t.Error("Expected duplicate registration error for normalized names")
+	}
+	if err := router.RegisterSimpleMethod("   ", handler, ""); err == nil {
+		t.Error("Expected an error registering a blank method name")
+	}
+}
+
+// TestParseMethodNameNormalization tests parsing of configuration values.
+func TestParseMethodNameNormalization(t *testing.T) {
+	for name, want := range map[string]MethodNameNormalization{
+		"strict":    MethodNamesStrict,
+		"Trim":      MethodNamesTrim,
+		"lowercase": MethodNamesTrimLower,
+	} {
+		got, err := ParseMethodNameNormalization(name)
+		if err != nil || got != want {
+			t.Errorf("ParseMethodNameNormalization(%q) = %v, %v; expected %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseMethodNameNormalization("fuzzy"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+// TestRouteContextErrors tests that context errors from handlers get distinct codes.
+func TestRouteContextErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{"deadline exceeded", context.DeadlineExceeded, RequestTimeout},
+		{"cancelled", context.Canceled, RequestCancelled},
+		{"wrapped deadline", fmt.Errorf("query failed: %w", context.DeadlineExceeded), RequestTimeout},
+		{"other error", fmt.Errorf("boom"), InternalError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouter()
+			router.RegisterSimpleMethod("slow", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+				return nil, tt.err
+			}, "")
+
+			response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "slow", ID: 1})
+			if response.Error == nil || response.Error.Code != tt.wantCode {
+				t.Errorf("Expected error code %d, got %+v", tt.wantCode, response.Error)
+			}
+		})
+	}
+}
+
+// TestRouteDefaultTimeout tests that handlers outliving the default timeout
+// are answered with RequestTimeout while fast handlers are unaffected.
+func TestRouteDefaultTimeout(t *testing.T) {
+	router := NewRouter()
+	err := router.RegisterMethod("test.slow", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+	err = router.RegisterMethod("test.deadline", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		_, hasDeadline := ctx.Deadline()
+		return hasDeadline, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+
+	// Without a timeout handlers get the caller's context unchanged
+	response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.deadline", ID: 1})
+	if response.IsError() || response.Result != false {
+		t.Errorf("Expected no deadline without a default timeout, got %+v", response)
+	}
+
+	router.SetDefaultTimeout(20 * time.Millisecond)
+
+	response = router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.deadline", ID: 2})
+	if response.IsError() || response.Result != true {
+		t.Errorf("Expected a fast handler to succeed under a deadline, got %+v", response)
+	}
+
+	done := make(chan *Response, 1)
+	go func() {
+		done <- router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.slow", ID: 3})
+	}()
+	select {
+	case response := <-done:
+		if !response.IsError() || response.Error.Code != RequestTimeout {
+			t.Fatalf("Expected RequestTimeout, got %+v", response)
+		}
+		if response.Error.Message != ErrRequestTimeout.Message {
+			t.Errorf("Expected message %q, got %q", ErrRequestTimeout.Message, response.Error.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Slow handler was not bounded by the default timeout")
+	}
+
+	router.SetDefaultTimeout(0)
+	response = router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.deadline", ID: 4})
+	if response.IsError() || response.Result != false {
+		t.Errorf("Expected a zero timeout to remove the deadline, got %+v", response)
+	}
+}
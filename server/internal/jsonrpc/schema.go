@@ -0,0 +1,364 @@
+// Package jsonrpc provides JSON-RPC 2.0 routing and method dispatch functionality.
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// JSONSchema is a compiled JSON Schema document used to validate method params.
+// It implements a lightweight subset of the specification: type, properties,
+// required, additionalProperties, items, enum, const, minLength, maxLength,
+// pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, minItems and
+// maxItems. Other keywords (such as $schema, title or description) are ignored.
+type JSONSchema struct {
+	types                []string
+	properties           map[string]*JSONSchema
+	required             []string
+	additionalProperties *JSONSchema
+	noAdditional         bool
+	items                *JSONSchema
+	enum                 []interface{}
+	constValue           interface{}
+	hasConst             bool
+	minLength            *int
+	maxLength            *int
+	pattern              *regexp.Regexp
+	minimum              *float64
+	maximum              *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	minItems             *int
+	maxItems             *int
+}
+
+// rawSchema mirrors the supported keywords of a JSON Schema document.
+type rawSchema struct {
+	Type                 json.RawMessage            `json:"type"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Items                json.RawMessage            `json:"items"`
+	Enum                 []interface{}              `json:"enum"`
+	Const                json.RawMessage            `json:"const"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	Pattern              *string                    `json:"pattern"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+	ExclusiveMinimum     *float64                   `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64                   `json:"exclusiveMaximum"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+}
+
+// CompileJSONSchema parses a JSON Schema document. It returns an error if the
+// document is not valid JSON or uses a supported keyword incorrectly.
+func CompileJSONSchema(document []byte) (*JSONSchema, error) {
+	schema, err := compileSchema(document, "#")
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return schema, nil
+}
+
+// compileSchema compiles the schema at the given location in the document.
+func compileSchema(document []byte, location string) (*JSONSchema, error) {
+	// true and false are valid schemas accepting everything and nothing
+	switch string(bytes.TrimSpace(document)) {
+	case "true":
+		return &JSONSchema{}, nil
+	case "false":
+		return &JSONSchema{types: []string{}}, nil
+	}
+
+	var raw rawSchema
+	if err := json.Unmarshal(document, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", location, err)
+	}
+
+	schema := &JSONSchema{
+		required:         raw.Required,
+		enum:             raw.Enum,
+		minLength:        raw.MinLength,
+		maxLength:        raw.MaxLength,
+		minimum:          raw.Minimum,
+		maximum:          raw.Maximum,
+		exclusiveMinimum: raw.ExclusiveMinimum,
+		exclusiveMaximum: raw.ExclusiveMaximum,
+		minItems:         raw.MinItems,
+		maxItems:         raw.MaxItems,
+	}
+
+	if len(raw.Type) > 0 {
+		var single string
+		if err := json.Unmarshal(raw.Type, &single); err == nil {
+			schema.types = []string{single}
+		} else if err := json.Unmarshal(raw.Type, &schema.types); err != nil {
+			return nil, fmt.Errorf("%s/type: must be a string or an array of strings", location)
+		}
+		for _, t := range schema.types {
+			switch t {
+			case "object", "array", "string", "number", "integer", "boolean", "null":
+			default:
+				return nil, fmt.Errorf("%s/type: unknown type %q", location, t)
+			}
+		}
+	}
+
+	if len(raw.Properties) > 0 {
+		schema.properties = make(map[string]*JSONSchema, len(raw.Properties))
+		for name, doc := range raw.Properties {
+			property, err := compileSchema(doc, location+"/properties/"+name)
+			if err != nil {
+				return nil, err
+			}
+			schema.properties[name] = property
+		}
+	}
+
+	if len(raw.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(raw.AdditionalProperties, &allowed); err == nil {
+			schema.noAdditional = !allowed
+		} else {
+			additional, err := compileSchema(raw.AdditionalProperties, location+"/additionalProperties")
+			if err != nil {
+				return nil, err
+			}
+			schema.additionalProperties = additional
+		}
+	}
+
+	if len(raw.Items) > 0 {
+		items, err := compileSchema(raw.Items, location+"/items")
+		if err != nil {
+			return nil, err
+		}
+		schema.items = items
+	}
+
+	if len(raw.Const) > 0 {
+		if err := json.Unmarshal(raw.Const, &schema.constValue); err != nil {
+			return nil, fmt.Errorf("%s/const: %w", location, err)
+		}
+		schema.hasConst = true
+	}
+
+	if raw.Pattern != nil {
+		pattern, err := regexp.Compile(*raw.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s/pattern: %w", location, err)
+		}
+		schema.pattern = pattern
+	}
+
+	return schema, nil
+}
+
+// Validate checks a JSON document against the schema. It returns
+// ValidationErrors whose Field is the path of each offending value
+// (e.g. "user.tags[1]"), or nil if the document is valid.
+func (s *JSONSchema) Validate(document []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(document, &value); err != nil {
+		return fmt.Errorf("failed to parse params: %w", err)
+	}
+
+	var errs ValidationErrors
+	s.validate(value, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validate appends a ValidationError to errs for every violation found in value.
+func (s *JSONSchema) validate(value interface{}, path string, errs *ValidationErrors) {
+	fail := func(tag, param, message string) {
+		field := path
+		if field == "" {
+			field = "params"
+		}
+		*errs = append(*errs, ValidationError{
+			Field:   field,
+			Tag:     tag,
+			Value:   value,
+			Param:   param,
+			Message: fmt.Sprintf("field '%s' %s", field, message),
+		})
+	}
+
+	if s.types != nil && !s.matchesType(value) {
+		if len(s.types) == 0 {
+			fail("false", "", "is not allowed")
+		} else {
+			fail("type", fmt.Sprint(s.types), fmt.Sprintf("must be of type %s", joinTypes(s.types)))
+		}
+		return
+	}
+
+	if s.hasConst && !jsonEqual(value, s.constValue) {
+		fail("const", fmt.Sprint(s.constValue), fmt.Sprintf("must be %v", s.constValue))
+	}
+
+	if s.enum != nil {
+		found := false
+		for _, candidate := range s.enum {
+			if jsonEqual(value, candidate) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("enum", fmt.Sprint(s.enum), fmt.Sprintf("must be one of %v", s.enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		s.validateObject(v, path, errs, fail)
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("minItems", strconv.Itoa(*s.minItems), fmt.Sprintf("must contain at least %d items", *s.minItems))
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("maxItems", strconv.Itoa(*s.maxItems), fmt.Sprintf("must contain at most %d items", *s.maxItems))
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			fail("minLength", strconv.Itoa(*s.minLength), fmt.Sprintf("must be at least %d characters long", *s.minLength))
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail("maxLength", strconv.Itoa(*s.maxLength), fmt.Sprintf("must be at most %d characters long", *s.maxLength))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("pattern", s.pattern.String(), fmt.Sprintf("must match pattern %s", s.pattern))
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			fail("minimum", formatNumber(*s.minimum), fmt.Sprintf("must be at least %s", formatNumber(*s.minimum)))
+		}
+		if s.maximum != nil && v > *s.maximum {
+			fail("maximum", formatNumber(*s.maximum), fmt.Sprintf("must be at most %s", formatNumber(*s.maximum)))
+		}
+		if s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum {
+			fail("exclusiveMinimum", formatNumber(*s.exclusiveMinimum), fmt.Sprintf("must be greater than %s", formatNumber(*s.exclusiveMinimum)))
+		}
+		if s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum {
+			fail("exclusiveMaximum", formatNumber(*s.exclusiveMaximum), fmt.Sprintf("must be less than %s", formatNumber(*s.exclusiveMaximum)))
+		}
+	}
+}
+
+// validateObject checks required, properties and additionalProperties.
+// Properties are visited in sorted order so errors are reported deterministically.
+func (s *JSONSchema) validateObject(object map[string]interface{}, path string, errs *ValidationErrors, fail func(tag, param, message string)) {
+	for _, name := range s.required {
+		if _, ok := object[name]; !ok {
+			*errs = append(*errs, ValidationError{
+				Field:   joinPath(path, name),
+				Tag:     "required",
+				Message: fmt.Sprintf("field '%s' is required", joinPath(path, name)),
+			})
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if property, ok := s.properties[name]; ok {
+			property.validate(object[name], joinPath(path, name), errs)
+			continue
+		}
+		if s.noAdditional {
+			*errs = append(*errs, ValidationError{
+				Field:   joinPath(path, name),
+				Tag:     "additionalProperties",
+				Value:   object[name],
+				Message: fmt.Sprintf("field '%s' is not allowed", joinPath(path, name)),
+			})
+			continue
+		}
+		if s.additionalProperties != nil {
+			s.additionalProperties.validate(object[name], joinPath(path, name), errs)
+		}
+	}
+}
+
+// matchesType reports whether value is one of the schema's types.
+func (s *JSONSchema) matchesType(value interface{}) bool {
+	for _, t := range s.types {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case nil:
+			if t == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// joinPath appends a property name to a field path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// joinTypes formats a type list for error messages.
+func joinTypes(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	return fmt.Sprintf("one of %v", types)
+}
+
+// formatNumber formats a schema bound without a trailing ".0" for integers.
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// jsonEqual compares two decoded JSON values.
+func jsonEqual(a, b interface{}) bool {
+	left, errLeft := json.Marshal(a)
+	right, errRight := json.Marshal(b)
+	return errLeft == nil && errRight == nil && bytes.Equal(left, right)
+}
@@ -0,0 +1,82 @@
+package jsonrpc
+
+import (
+	"testing"
+)
+
+// TestJSONSchemaValidate tests the supported JSON Schema keywords.
+func TestJSONSchemaValidate(t *testing.T) {
+	schema, err := CompileJSONSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 2, "maxLength": 5, "pattern": "^[a-z]+$"},
+			"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+			"role": {"enum": ["admin", "user"]},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+			"nickname": {"type": ["string", "null"]}
+		},
+		"required": ["name"],
+		"additionalProperties": false
+	}`))
+	if err != nil {
+		t.Fatalf("CompileJSONSchema failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		document  string
+		wantField string
+		wantTag   string
+	}{
+		{"valid", `{"name":"bob","age":30,"role":"user","tags":["a"],"nickname":null}`, "", ""},
+		{"not an object", `[]`, "params", "type"},
+		{"missing required", `{"age":1}`, "name", "required"},
+		{"too short", `{"name":"b"}`, "name", "minLength"},
+		{"too long", `{"name":"bobbyb"}`, "name", "maxLength"},
+		{"pattern mismatch", `{"name":"Bob"}`, "name", "pattern"},
+		{"non-integer", `{"name":"bob","age":1.5}`, "age", "type"},
+		{"below minimum", `{"name":"bob","age":-1}`, "age", "minimum"},
+		{"exclusive maximum", `{"name":"bob","age":150}`, "age", "exclusiveMaximum"},
+		{"not in enum", `{"name":"bob","role":"root"}`, "role", "enum"},
+		{"bad array item", `{"name":"bob","tags":["a",1]}`, "tags[1]", "type"},
+		{"too many items", `{"name":"bob","tags":["a","b","c"]}`, "tags", "maxItems"},
+		{"additional property", `{"name":"bob","extra":true}`, "extra", "additionalProperties"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate([]byte(tt.document))
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			errs, ok := err.(ValidationErrors)
+			if !ok || len(errs) == 0 {
+				t.Fatalf("Expected ValidationErrors, got %v", err)
+			}
+			if errs[0].Field != tt.wantField || errs[0].Tag != tt.wantTag {
+				t.Errorf("Expected %s/%s, got %s/%s", tt.wantField, tt.wantTag, errs[0].Field, errs[0].Tag)
+			}
+		})
+	}
+}
+
+// TestCompileJSONSchemaErrors tests that malformed schemas are rejected.
+func TestCompileJSONSchemaErrors(t *testing.T) {
+	invalid := []string{
+		`not json`,
+		`{"type": "thing"}`,
+		`{"type": 5}`,
+		`{"pattern": "("}`,
+		`{"properties": {"a": {"type": "nope"}}}`,
+	}
+
+	for _, document := range invalid {
+		if _, err := CompileJSONSchema([]byte(document)); err == nil {
+			t.Errorf("Expected error compiling %s", document)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStreamingUnsupported is returned by SendPartial when the transport that
+// delivered the request cannot stream partial results. Handlers that stream
+// opportunistically may ignore it and return their full result as usual.
+var ErrStreamingUnsupported = errors.New("transport does not support streaming partial results")
+
+// PartialSender delivers one partial result to the caller ahead of the
+// final response.
+type PartialSender func(partial interface{}) error
+
+// WithPartialSender returns a copy of ctx through which handlers can stream
+// partial results with SendPartial. Transports that can deliver partials
+// before the final response set it.
+func WithPartialSender(ctx context.Context, send PartialSender) context.Context {
+	return context.WithValue(ctx, partialSenderContextKey, send)
+}
+
+// SendPartial streams a partial result to the caller of a long-running
+// method. It returns ErrStreamingUnsupported if the caller's transport
+// did not ask for streaming.
+func SendPartial(ctx context.Context, partial interface{}) error {
+	send, ok := ctx.Value(partialSenderContextKey).(PartialSender)
+	if !ok || send == nil {
+		return ErrStreamingUnsupported
+	}
+	return send(partial)
+}
@@ -0,0 +1,78 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TrailingDataPolicy decides how RouteJSON treats bytes that follow the
+// JSON-RPC request object.
+type TrailingDataPolicy int
+
+const (
+	// TrailingDataStrict accepts trailing whitespace but fails requests with
+	// any other trailing bytes with a ParseError.
+	TrailingDataStrict TrailingDataPolicy = iota
+
+	// TrailingDataLenient ignores everything after the first complete JSON value.
+	TrailingDataLenient
+)
+
+// errTrailingData reports non-whitespace bytes after the request object.
+var errTrailingData = errors.New("unexpected data after JSON-RPC request")
+
+// String returns the configuration name of the policy.
+func (p TrailingDataPolicy) String() string {
+	switch p {
+	case TrailingDataLenient:
+		return "lenient"
+	default:
+		return "strict"
+	}
+}
+
+// ParseTrailingDataPolicy parses "strict" or "lenient" (case-insensitive).
+func ParseTrailingDataPolicy(name string) (TrailingDataPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "strict":
+		return TrailingDataStrict, nil
+	case "lenient":
+		return TrailingDataLenient, nil
+	default:
+		return TrailingDataStrict, fmt.Errorf("unknown trailing data policy %q", name)
+	}
+}
+
+// SetTrailingDataPolicy sets how RouteJSON treats bytes after the request
+// object. The default is TrailingDataStrict.
+func (r *Router) SetTrailingDataPolicy(policy TrailingDataPolicy) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.trailingDataPolicy = policy
+}
+
+// decodeRequest decodes the first JSON value in data into request, a
+// *Request or a batch, and applies the trailing data policy to whatever
+// follows it.
+func (r *Router) decodeRequest(data []byte, request interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(request); err != nil {
+		return err
+	}
+
+	r.mutex.RLock()
+	policy := r.trailingDataPolicy
+	r.mutex.RUnlock()
+	if policy == TrailingDataLenient {
+		return nil
+	}
+
+	// JSON whitespace is only space, tab, CR and LF
+	if rest := bytes.TrimLeft(data[decoder.InputOffset():], " \t\r\n"); len(rest) > 0 {
+		return errTrailingData
+	}
+	return nil
+}
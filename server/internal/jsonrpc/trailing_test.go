@@ -0,0 +1,77 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// TestRouteJSONTrailingData tests how RouteJSON treats bytes after the
+// request object under each trailing data policy.
+func TestRouteJSONTrailingData(t *testing.T) {
+	const request = `{"jsonrpc":"2.0","method":"ping","id":1}`
+
+	tests := []struct {
+		name      string
+		policy    TrailingDataPolicy
+		input     string
+		wantError bool
+	}{
+		{"strict trailing whitespace", TrailingDataStrict, request + " \r\n\t", false},
+		{"strict trailing garbage", TrailingDataStrict, request + "garbage", true},
+		{"strict second object", TrailingDataStrict, request + "\n" + request, true},
+		{"lenient trailing whitespace", TrailingDataLenient, request + "\n", false},
+		{"lenient trailing garbage", TrailingDataLenient, request + "\x00garbage", false},
+		{"lenient invalid object", TrailingDataLenient, `{"jsonrpc":`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouter()
+			router.SetTrailingDataPolicy(tt.policy)
+			handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+				return "pong", nil
+			}
+			if err := router.RegisterSimpleMethod("ping", handler, "Ping"); err != nil {
+				t.Fatalf("RegisterSimpleMethod failed: %v", err)
+			}
+
+			responseJSON, err := router.RouteJSON(context.Background(), []byte(tt.input))
+			if err != nil {
+				t.Fatalf("RouteJSON failed: %v", err)
+			}
+
+			var response Response
+			if err := json.Unmarshal(responseJSON, &response); err != nil {
+				t.Fatalf("Invalid response JSON: %v", err)
+			}
+
+			if tt.wantError {
+				if response.Error == nil || response.Error.Code != ParseError {
+					t.Errorf("Expected a parse error, got %s", responseJSON)
+				}
+				return
+			}
+			if response.Error != nil {
+				t.Errorf("Expected success, got error %v", response.Error)
+			}
+			if response.Result != "pong" {
+				t.Errorf("Expected result pong, got %v", response.Result)
+			}
+		})
+	}
+}
+
+func TestParseTrailingDataPolicy(t *testing.T) {
+	policy, err := ParseTrailingDataPolicy("Lenient")
+	if err != nil || policy != TrailingDataLenient {
+		t.Errorf("Expected lenient policy, got %v (%v)", policy, err)
+	}
+	if policy.String() != "lenient" {
+		t.Errorf("Expected String() lenient, got %q", policy.String())
+	}
+
+	if _, err := ParseTrailingDataPolicy("loose"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,55 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// TypedHandlerFunc handles a method whose params decode into P and whose
+// result is an R; see RegisterTyped.
+type TypedHandlerFunc[P any, R any] func(ctx context.Context, params P) (R, error)
+
+// RegisterTyped registers a method whose handler takes its params already
+// decoded into a P and returns an R, sparing it the json.Unmarshal and
+// interface{} plumbing of a HandlerFunc. When P is a struct, or a pointer to
+// one, it is used as the method's ParamsSchema, so params are validated with
+// the router's Validator before fn is called, like methods registered with
+// RegisterMethodWithValidation. Params that do not decode into a P are
+// answered with InvalidParams. Omitted params leave P at its zero value.
+func RegisterTyped[P any, R any](r *Router, methodName string, fn TypedHandlerFunc[P, R], description string) error {
+	info := &MethodInfo{Description: description}
+	if schema := structSchema(reflect.TypeOf((*P)(nil)).Elem()); schema != nil {
+		info.ParamsSchema = schema
+		info.ValidateParams = true
+	}
+
+	handler := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p P
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &p); err != nil {
+				return nil, NewErrorWithData(InvalidParams, ErrInvalidParams.Message, err.Error())
+			}
+		}
+
+		result, err := fn(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	return r.RegisterMethod(methodName, handler, info)
+}
+
+// structSchema returns the struct type t is or points to, or nil if it is
+// neither, as struct types are the ones the Validator can check.
+func structSchema(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
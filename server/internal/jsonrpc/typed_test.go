@@ -0,0 +1,106 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type greetParams struct {
+	Name  string `json:"name" validate:"required"`
+	Times int    `json:"times" validate:"min=0,max=3"`
+}
+
+type greetResult struct {
+	Greeting string `json:"greeting"`
+}
+
+// TestRegisterTyped tests that typed handlers receive decoded, validated
+// params and that malformed or invalid params never reach them.
+func TestRegisterTyped(t *testing.T) {
+	router := NewRouter()
+	calls := 0
+	err := RegisterTyped(router, "test.greet", func(ctx context.Context, p greetParams) (greetResult, error) {
+		calls++
+		greeting := "hello"
+		for i := 1; i < p.Times; i++ {
+			greeting += " hello"
+		}
+		return greetResult{Greeting: greeting + " " + p.Name}, nil
+	}, "Greet by name")
+	if err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+
+	info, err := router.GetMethodInfo("test.greet")
+	if err != nil || !info.ValidateParams || info.Description != "Greet by name" {
+		t.Errorf("Expected a validated method with its description, got %+v", info)
+	}
+
+	response := router.Route(context.Background(), &Request{
+		JSONRPCVersion: "2.0",
+		Method:         "test.greet",
+		Params:         []byte(`{"name":"Ada","times":2}`),
+		ID:             1,
+	})
+	if response.IsError() {
+		t.Fatalf("Expected success, got %v", response.Error)
+	}
+	if result, ok := response.Result.(greetResult); !ok || result.Greeting != "hello hello Ada" {
+		t.Errorf("Unexpected result: %#v", response.Result)
+	}
+
+	for _, params := range []string{`{"times":1}`, `{"name":"Ada","times":5}`, `{"name":42}`, `[1,2]`} {
+		response := router.Route(context.Background(), &Request{
+			JSONRPCVersion: "2.0",
+			Method:         "test.greet",
+			Params:         []byte(params),
+			ID:             2,
+		})
+		if !response.IsError() || response.Error.Code != InvalidParams {
+			t.Errorf("Expected InvalidParams for %s, got %+v", params, response)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected only the valid call to reach the handler, got %d calls", calls)
+	}
+}
+
+// TestRegisterTypedNonStruct tests typed handlers with non-struct params,
+// which are decoded but not validated, and handler errors.
+func TestRegisterTypedNonStruct(t *testing.T) {
+	router := NewRouter()
+	err := RegisterTyped(router, "test.sum", func(ctx context.Context, numbers []int) (int, error) {
+		if len(numbers) == 0 {
+			return 0, errors.New("nothing to sum")
+		}
+		total := 0
+		for _, n := range numbers {
+			total += n
+		}
+		return total, nil
+	}, "Sum numbers")
+	if err != nil {
+		t.Fatalf("Failed to register method: %v", err)
+	}
+
+	if info, _ := router.GetMethodInfo("test.sum"); info.ValidateParams {
+		t.Error("Expected non-struct params not to be validated")
+	}
+
+	response := router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.sum", Params: []byte(`[1,2,3]`), ID: 1})
+	if response.IsError() || response.Result != 6 {
+		t.Errorf("Expected 6, got %+v", response)
+	}
+
+	response = router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.sum", Params: []byte(`"one"`), ID: 2})
+	if !response.IsError() || response.Error.Code != InvalidParams {
+		t.Errorf("Expected InvalidParams for params of the wrong type, got %+v", response)
+	}
+
+	// Omitted params leave P at its zero value; the handler's error is an internal error
+	response = router.Route(context.Background(), &Request{JSONRPCVersion: "2.0", Method: "test.sum", ID: 3})
+	if !response.IsError() || response.Error.Code != InternalError {
+		t.Errorf("Expected InternalError from the handler, got %+v", response)
+	}
+}
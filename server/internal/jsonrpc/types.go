@@ -40,6 +40,16 @@ type Request struct {
 	// ID is an identifier established by the client.
 	// It can be a string, number, or null. If omitted, the request is a notification.
 	ID interface{} `json:"id,omitempty"`
+
+	// Meta carries optional, non-standard request flags; see RequestMeta.
+	Meta *RequestMeta `json:"_meta,omitempty"`
+}
+
+// RequestMeta holds the flags a client may send in a request's "_meta"
+// member to opt in to extra response information.
+type RequestMeta struct {
+	// Timing asks for the server's processing time in the response's _meta
+	Timing bool `json:"timing,omitempty"`
 }
 
 // IsNotification returns true if this request is a notification
@@ -73,6 +83,21 @@ type Response struct {
 	// ID is the same as the value of the id member in the Request Object.
 	// If there was an error in detecting the id in the Request object, it MUST be Null.
 	ID interface{} `json:"id"`
+
+	// Meta carries extra information the request opted in to, or a
+	// deprecation notice; it is omitted otherwise, leaving the standard
+	// members unchanged.
+	Meta *ResponseMeta `json:"_meta,omitempty"`
+}
+
+// ResponseMeta is the "_meta" member of a response.
+type ResponseMeta struct {
+	// DurationMs is how long the server took to route the request, in
+	// milliseconds with microsecond precision; nil unless timing was requested
+	DurationMs *float64 `json:"durationMs,omitempty"`
+
+	// Deprecation warns that the called method is deprecated; nil otherwise
+	Deprecation *DeprecationNotice `json:"deprecation,omitempty"`
 }
 
 // IsError returns true if this response contains an error.
@@ -142,6 +167,32 @@ const (
 	ServerErrorEnd = -32000
 )
 
+// Implementation-defined server error codes within the reserved -32099 to -32000 range.
+const (
+	// RequestTimeout indicates the request's deadline passed before the handler finished.
+	RequestTimeout = -32000
+
+	// ServerBusy indicates the server declined to run a request because a
+	// concurrency limit was reached.
+	ServerBusy = -32001
+
+	// RequestCancelled indicates the request was cancelled before the handler finished.
+	RequestCancelled = -32002
+
+	// Unauthorized indicates the caller is not allowed to invoke the method.
+	Unauthorized = -32003
+
+	// ResourceNotFound indicates the resource named in the params does not exist.
+	ResourceNotFound = -32004
+
+	// RateLimited indicates the caller exceeded a request rate limit.
+	RateLimited = -32005
+
+	// Maintenance indicates the server is in maintenance mode and refuses new
+	// connections.
+	Maintenance = -32006
+)
+
 // Standard error messages for predefined error codes.
 var (
 	// ErrParse represents a parse error (-32700).
@@ -173,6 +224,36 @@ var (
 		Code:    InternalError,
 		Message: "Internal error",
 	}
+
+	// ErrServerBusy represents a server busy error (-32001).
+	ErrServerBusy = &Error{
+		Code:    ServerBusy,
+		Message: "Server busy",
+	}
+
+	// ErrRequestTimeout represents a request timeout error (-32000).
+	ErrRequestTimeout = &Error{
+		Code:    RequestTimeout,
+		Message: "Request timed out",
+	}
+
+	// ErrRequestCancelled represents a request cancelled error (-32002).
+	ErrRequestCancelled = &Error{
+		Code:    RequestCancelled,
+		Message: "Request cancelled",
+	}
+
+	// ErrUnauthorized represents an unauthorized error (-32003).
+	ErrUnauthorized = &Error{
+		Code:    Unauthorized,
+		Message: "Unauthorized",
+	}
+
+	// ErrResourceNotFound represents a resource not found error (-32004).
+	ErrResourceNotFound = &Error{
+		Code:    ResourceNotFound,
+		Message: "Resource not found",
+	}
 )
 
 // NewError creates a new JSON-RPC error with the given code and message.
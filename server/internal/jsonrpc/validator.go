@@ -91,15 +91,27 @@ func (v *Validator) registerCustomValidators() {
 
 // validateSessionCode validates that a string follows the session code format:
 // "adjective-noun-number" where number is 1-99.
-// This validator is case-insensitive.
+// The format does not depend on case, so codes in any case pass. Whether codes
+// differing only in case identify the same session is decided by the session
+// package's NormalizeCode (see SESSION_CASE_SENSITIVE), not by this validator.
+// A namespace prefix, as in "prod:happy-panda-42", is accepted; whether it is
+// the configured one is likewise left to the session package.
 func (v *Validator) validateSessionCode(fl validator.FieldLevel) bool {
 	code := fl.Field().String()
 	if code == "" {
 		return false
 	}
 
-	// Convert to lowercase for case-insensitive validation
-	normalized := strings.ToLower(strings.TrimSpace(code))
+	// Only trim; case is irrelevant to the format and is left to session normalization
+	normalized := strings.TrimSpace(code)
+
+	// Strip a non-empty "prefix:" namespace (see SESSION_CODE_PREFIX)
+	if prefix, rest, found := strings.Cut(normalized, ":"); found {
+		if prefix == "" || strings.Contains(rest, ":") {
+			return false
+		}
+		normalized = rest
+	}
 
 	// Split by dashes
 	parts := strings.Split(normalized, "-")
@@ -104,6 +104,8 @@ func TestValidateSessionCode_Valid(t *testing.T) {
 		"quick-fox-99",
 		"LOUD-BEAR-1", // Test case insensitivity
 		"  space-wolf-33  ", // Test trimming
+		"prod:happy-panda-42", // Namespace prefix
+		"eu-west:blue-river-7", // Prefix containing a dash
 	}
 	
 	for _, code := range validCodes {
@@ -128,6 +130,9 @@ func TestValidateSessionCode_Invalid(t *testing.T) {
 		"happy--42", // Empty middle part
 		"-panda-42", // Empty first part
 		"happy-", // Incomplete
+		":happy-panda-42", // Empty prefix
+		"prod:dev:happy-panda-42", // Two prefixes
+		"prod:happy-panda", // Prefixed but missing number
 	}
 	
 	for _, code := range invalidCodes {
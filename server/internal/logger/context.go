@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// requestIDContextKey is the context key for the request ID.
+type requestIDContextKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID, which
+// ContextHandler adds to every record logged with that context.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or "" if none was set.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// ContextAttrFunc extracts a log attribute from a context, reporting false
+// when the context does not carry the value. Packages that own a context
+// value provide one, so this package need not import them.
+type ContextAttrFunc func(ctx context.Context) (slog.Attr, bool)
+
+// ContextHandler is a slog.Handler that adds known context values to each
+// record before passing it on: the request ID set with ContextWithRequestID as
+// "request_id", and whatever its ContextAttrFuncs extract, such as the
+// JSON-RPC caller's session code. This correlates any *Context logging call
+// without building a child logger.
+type ContextHandler struct {
+	slog.Handler
+	attrs []ContextAttrFunc
+}
+
+// NewContextHandler wraps handler so records pick up the request ID and the
+// attributes extracted by attrs from the context.
+func NewContextHandler(handler slog.Handler, attrs ...ContextAttrFunc) *ContextHandler {
+	return &ContextHandler{Handler: handler, attrs: attrs}
+}
+
+// Handle adds the context values found in ctx to the record and forwards it.
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if requestID := RequestIDFromContext(ctx); requestID != "" {
+			record.AddAttrs(slog.String("request_id", requestID))
+		}
+		for _, extract := range h.attrs {
+			if attr, ok := extract(ctx); ok {
+				record.AddAttrs(attr)
+			}
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a ContextHandler wrapping the handler with attrs added.
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs), attrs: h.attrs}
+}
+
+// WithGroup returns a ContextHandler wrapping the handler with the group opened.
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name), attrs: h.attrs}
+}
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/fle/server/internal/config"
+)
+
+// sessionCodeContextKey stands in for a context value owned by another package.
+type sessionCodeContextKey struct{}
+
+func sessionCodeAttr(ctx context.Context) (slog.Attr, bool) {
+	sessionCode, ok := ctx.Value(sessionCodeContextKey{}).(string)
+	return slog.String("session_code", sessionCode), ok
+}
+
+func TestContextHandlerAddsContextValues(t *testing.T) {
+	cfg := &config.Config{LogLevel: "info", Environment: "production", LogFormat: "json"}
+	var buf bytes.Buffer
+	logger, err := New(cfg, Options{Output: &buf, ContextAttrs: []ContextAttrFunc{sessionCodeAttr}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := ContextWithRequestID(context.Background(), "req-123")
+	ctx = context.WithValue(ctx, sessionCodeContextKey{}, "happy-cat-42")
+	logger.WithComponent("test").InfoContext(ctx, "handled request")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected JSON record, got %q", buf.String())
+	}
+	if record["request_id"] != "req-123" {
+		t.Errorf("expected request_id from context, got %v", record["request_id"])
+	}
+	if record["session_code"] != "happy-cat-42" {
+		t.Errorf("expected session_code from context, got %v", record["session_code"])
+	}
+	if record["component"] != "test" {
+		t.Errorf("expected child logger attributes to be kept, got %v", record["component"])
+	}
+}
+
+func TestContextHandlerWithoutContextValues(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil), sessionCodeAttr))
+
+	logger.InfoContext(context.Background(), "no correlation")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected JSON record, got %q", buf.String())
+	}
+	if _, ok := record["request_id"]; ok {
+		t.Errorf("expected no request_id, got %v", record)
+	}
+	if _, ok := record["session_code"]; ok {
+		t.Errorf("expected no session_code, got %v", record)
+	}
+}
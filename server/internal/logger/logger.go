@@ -12,6 +12,7 @@ import (
 	"log/slog"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fle/server/internal/config"
@@ -27,7 +28,8 @@ type Logger struct {
 // Options configures logger behavior.
 // It allows customization of output destination and format.
 type Options struct {
-	// Output is the destination for log messages. If nil, os.Stderr is used.
+	// Output is the destination for log messages. If nil, the configured log
+	// file is used when LogOutput is file, and os.Stderr otherwise.
 	Output io.Writer
 
 	// AddSource includes source code position in log records.
@@ -35,6 +37,10 @@ type Options struct {
 
 	// ReplaceAttr allows customization of log attributes before output.
 	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	// ContextAttrs extract further attributes from the context of *Context
+	// calls; see ContextHandler.
+	ContextAttrs []ContextAttrFunc
 }
 
 var (
@@ -51,7 +57,8 @@ var (
 )
 
 // New creates a new Logger instance based on the provided configuration.
-// The logger format (JSON or text) is determined by the environment setting.
+// The logger format (JSON or text) is set by LogFormat, or by the environment
+// (JSON in production) when LogFormat is auto.
 // Log level is configured based on the config.LogLevel setting.
 func New(cfg *config.Config, opts ...Options) (*Logger, error) {
 	if cfg == nil {
@@ -67,7 +74,10 @@ func New(cfg *config.Config, opts ...Options) (*Logger, error) {
 	// Set default output if not specified
 	output := options.Output
 	if output == nil {
-		output = os.Stderr
+		var err error
+		if output, err = NewOutput(cfg); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create handler options with configured level
@@ -79,8 +89,8 @@ func New(cfg *config.Config, opts ...Options) (*Logger, error) {
 
 	var handler slog.Handler
 
-	// Choose handler based on environment
-	if cfg.IsProduction() {
+	// Choose handler based on the configured format or environment
+	if cfg.UseJSONLogs(cfg.IsProduction()) {
 		// JSON format for production (structured logging for log aggregation systems)
 		handler = slog.NewJSONHandler(output, handlerOpts)
 	} else {
@@ -88,7 +98,9 @@ func New(cfg *config.Config, opts ...Options) (*Logger, error) {
 		handler = slog.NewTextHandler(output, handlerOpts)
 	}
 
-	slogLogger := slog.New(handler)
+	// Pull request IDs and the configured attributes from the context of
+	// *Context calls
+	slogLogger := slog.New(NewContextHandler(handler, options.ContextAttrs...))
 
 	logger := &Logger{
 		Logger: slogLogger,
@@ -98,6 +110,20 @@ func New(cfg *config.Config, opts ...Options) (*Logger, error) {
 	return logger, nil
 }
 
+// NewOutput returns the log destination selected by the configuration: a
+// RotatingFile when LogOutput is file, and os.Stderr otherwise.
+func NewOutput(cfg *config.Config) (io.Writer, error) {
+	if !cfg.LogToFile() {
+		return os.Stderr, nil
+	}
+
+	file, err := NewRotatingFile(cfg.LogFilePath, int64(cfg.LogMaxSizeMB)*1024*1024, cfg.LogMaxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file %s: %w", cfg.LogFilePath, err)
+	}
+	return file, nil
+}
+
 // Init initializes the global logger with the provided configuration.
 // This should be called once at application startup.
 // Subsequent calls are ignored (safe to call multiple times).
@@ -124,16 +150,28 @@ const (
 	RequestIDBytes = 8
 )
 
+var (
+	// randReader is the source of request ID randomness; tests replace it to
+	// exercise the fallback
+	randReader io.Reader = rand.Reader
+
+	// fallbackRequestIDs counts fallback request IDs, keeping them unique when
+	// several are generated in the same nanosecond
+	fallbackRequestIDs atomic.Uint64
+)
+
 // GenerateRequestID creates a unique request ID for tracing related operations.
 // The request ID is a cryptographically secure random 16-byte hex string.
 // This can be used to correlate log entries for a single request across components.
+// If crypto/rand fails, it falls back to an ID built from the current time
+// and a process-wide counter, which is unique within the process but guessable.
 func GenerateRequestID() string {
 	// Generate random data (16 hex characters)
 	bytes := make([]byte, RequestIDBytes)
-	_, err := rand.Read(bytes)
+	_, err := io.ReadFull(randReader, bytes)
 	if err != nil {
 		// Fall back to timestamp-based ID if crypto/rand fails
-		return fmt.Sprintf("req_%d", time.Now().UnixNano())
+		return fmt.Sprintf("req_%d_%d", time.Now().UnixNano(), fallbackRequestIDs.Add(1))
 	}
 	return hex.EncodeToString(bytes)
 }
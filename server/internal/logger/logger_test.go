@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fle/server/internal/config"
+)
+
+func TestNewLogFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		logFormat   string
+		wantJSON    bool
+	}{
+		{"auto development", "development", "auto", false},
+		{"auto production", "production", "auto", true},
+		{"json development", "development", "json", true},
+		{"text production", "production", "text", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{LogLevel: "info", Environment: tt.environment, LogFormat: tt.logFormat}
+			var buf bytes.Buffer
+			logger, err := New(cfg, Options{Output: &buf})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			logger.Info("hello", "key", "value")
+
+			var record map[string]interface{}
+			isJSON := json.Unmarshal(buf.Bytes(), &record) == nil
+			if isJSON != tt.wantJSON {
+				t.Fatalf("expected JSON output %v, got %q", tt.wantJSON, buf.String())
+			}
+			if isJSON && record["key"] != "value" {
+				t.Errorf("expected key=value in JSON record, got %v", record)
+			}
+			if !isJSON && !strings.Contains(buf.String(), "key=value") {
+				t.Errorf("expected key=value in text output, got %q", buf.String())
+			}
+		})
+	}
+}
+
+// failingReader simulates crypto/rand being unavailable.
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("entropy unavailable")
+}
+
+func TestGenerateRequestIDFallbackUnique(t *testing.T) {
+	randReader = failingReader{}
+	defer func() { randReader = rand.Reader }()
+
+	const workers, perWorker = 16, 500
+	ids := make(chan string, workers*perWorker)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				ids <- GenerateRequestID()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool, workers*perWorker)
+	for id := range ids {
+		if !strings.HasPrefix(id, "req_") {
+			t.Fatalf("expected a fallback request ID, got %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate request ID %q", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fle/server/internal/config"
+)
+
+func TestRotatingFileRotatesPastLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	file, err := NewRotatingFile(path, 100, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer file.Close()
+
+	line := []byte(strings.Repeat("x", 39) + "\n")
+	for i := 0; i < 8; i++ {
+		if _, err := file.Write(line); err != nil {
+			t.Fatalf("Write %d failed: %v", i, err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", name, err)
+		}
+		if info.Size() > 100 {
+			t.Errorf("%s is %d bytes, over the 100 byte limit", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 backups, found %s.3", path)
+	}
+}
+
+func TestRotatingFileWithoutBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	file, err := NewRotatingFile(path, 10, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer file.Close()
+
+	file.Write([]byte("first-line\n"))
+	file.Write([]byte("second\n"))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "second\n" {
+		t.Errorf("expected only the latest write after rotation, got %q", data)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Error("expected no backup when max backups is zero")
+	}
+}
+
+func TestRotatingFileRetriesFailedReopen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(dir, "server.log")
+	file, err := NewRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write([]byte("first-line\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Replace the log directory with a file so the rotation cannot reopen
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if err := os.WriteFile(dir, nil, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := file.Write([]byte("lost\n")); err == nil {
+		t.Fatal("expected the write to fail while the log file cannot be opened")
+	}
+
+	// Once the directory can be created again, logging resumes
+	if err := os.Remove(dir); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := file.Write([]byte("resumed\n")); err != nil {
+		t.Fatalf("Write after the problem cleared failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "resumed\n" {
+		t.Errorf("expected the resumed write in a fresh file, got %q", data)
+	}
+
+	file.Close()
+	if _, err := file.Write([]byte("closed\n")); err != os.ErrClosed {
+		t.Errorf("expected os.ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestNewOutputDefaultsToStderr(t *testing.T) {
+	got, err := NewOutput(&config.Config{LogOutput: config.DefaultLogOutput})
+	if err != nil {
+		t.Fatalf("NewOutput failed: %v", err)
+	}
+	if got != os.Stderr {
+		t.Errorf("expected logs on stderr by default, got %v", got)
+	}
+}
+
+func TestNewWritesToConfiguredLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "server.log")
+	cfg := &config.Config{
+		LogLevel:      "info",
+		Environment:   "development",
+		LogFormat:     "auto",
+		LogOutput:     "file",
+		LogFilePath:   path,
+		LogMaxSizeMB:  1,
+		LogMaxBackups: 1,
+	}
+
+	logger, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	logger.Info("written to file")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Contains(data, []byte("written to file")) {
+		t.Errorf("expected log record in file, got %q", data)
+	}
+}
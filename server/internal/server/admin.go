@@ -0,0 +1,262 @@
+// Package server provides HTTP handlers and middleware for the FLE application.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fle/server/internal/jsonrpc"
+	"github.com/fle/server/internal/session"
+	"github.com/fle/server/internal/websocket"
+)
+
+// adminRole is the role required to call admin.* JSON-RPC methods.
+const adminRole = "admin"
+
+// adminSessionKind is the kind of sessions created for admin connections;
+// only admins may restore them.
+const adminSessionKind = "admin"
+
+// sessionKindFor returns the kind of session to create for principal.
+func sessionKindFor(principal *jsonrpc.Principal) string {
+	if principal.HasRole(adminRole) {
+		return adminSessionKind
+	}
+	return session.DefaultSessionKind
+}
+
+// adminAuthorizer restricts admin.* methods to principals with the admin role.
+var adminAuthorizer = jsonrpc.RoleAuthorizer{"admin.": adminRole}
+
+// authenticate resolves the principal of a WebSocket upgrade request from
+// the bearer token in its Authorization header. Tokens are not accepted in
+// the query string, where proxies and access logs would record them. It
+// returns a nil principal for anonymous requests and ok=false when a token is
+// presented but not recognized.
+func (s *Server) authenticate(r *http.Request) (principal *jsonrpc.Principal, ok bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, true
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == "" {
+		return nil, true
+	}
+
+	adminToken := s.currentConfig().AdminToken
+	if adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+		return &jsonrpc.Principal{ID: "admin", Roles: []string{adminRole}}, true
+	}
+	return nil, false
+}
+
+// AdminGetSessionParams holds the parameters for the admin.getSession method.
+type AdminGetSessionParams struct {
+	// Code is the session code to look up
+	Code string `json:"code" validate:"required,sessioncode"`
+
+	// IncludeData requests the session's raw Data; only honored for admins
+	IncludeData bool `json:"includeData"`
+}
+
+// adminGetSessionParamsSchema is the validation schema for AdminGetSessionParams.
+var adminGetSessionParamsSchema = reflect.TypeOf(AdminGetSessionParams{})
+
+// handleAdminGetSession handles the "admin.getSession" JSON-RPC method.
+// It returns a session's metadata and connection status without refreshing
+// its expiry. Data is only included when requested by an admin.
+func (s *Server) handleAdminGetSession(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p AdminGetSessionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse admin.getSession params: %w", err)
+	}
+
+	sess, err := s.sessionManager.PeekSession(p.Code)
+	if errors.Is(err, session.ErrSessionNotFound) || errors.Is(err, session.ErrSessionExpired) ||
+		errors.Is(err, session.ErrInvalidSessionCode) {
+		return nil, jsonrpc.NewErrorWithData(jsonrpc.ResourceNotFound, jsonrpc.ErrResourceNotFound.Message, err.Error())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up session: %w", err)
+	}
+
+	result := map[string]interface{}{
+		"code":          sess.Code,
+		"kind":          sess.Kind,
+		"created_at":    sess.CreatedAt.UTC().Format(time.RFC3339),
+		"last_accessed": sess.LastAccessed.UTC().Format(time.RFC3339),
+		"connected":     s.hub.HasSession(sess.Code),
+	}
+	if !sess.DisconnectedAt.IsZero() {
+		result["disconnected_at"] = sess.DisconnectedAt.UTC().Format(time.RFC3339)
+	}
+
+	principal, _ := jsonrpc.PrincipalFromContext(ctx)
+	if p.IncludeData && principal.HasRole(adminRole) {
+		result["data"] = sess.Data
+	}
+
+	return result, nil
+}
+
+// AdminListSessionsParams holds the parameters for the admin.listSessions method.
+type AdminListSessionsParams struct {
+	jsonrpc.PageParams
+}
+
+// adminListSessionsParamsSchema is the validation schema for AdminListSessionsParams.
+var adminListSessionsParamsSchema = reflect.TypeOf(AdminListSessionsParams{})
+
+// AdminSessionSummary describes one session in an admin.listSessions page.
+type AdminSessionSummary struct {
+	Code         string `json:"code"`
+	Kind         string `json:"kind"`
+	CreatedAt    string `json:"created_at"`
+	LastAccessed string `json:"last_accessed"`
+	Connected    bool   `json:"connected"`
+}
+
+// handleAdminListSessions handles the "admin.listSessions" JSON-RPC method.
+// It returns a page of live sessions ordered by code, without refreshing
+// their expiry.
+func (s *Server) handleAdminListSessions(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p AdminListSessionsParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, fmt.Errorf("failed to parse admin.listSessions params: %w", err)
+		}
+	}
+
+	codes := s.sessionManager.ListSessions()
+	summaries := make([]AdminSessionSummary, 0, len(codes))
+	for _, code := range codes {
+		sess, err := s.sessionManager.PeekSession(code)
+		if err != nil {
+			// Expired or removed since listing
+			continue
+		}
+		summaries = append(summaries, AdminSessionSummary{
+			Code:         sess.Code,
+			Kind:         sess.Kind,
+			CreatedAt:    sess.CreatedAt.UTC().Format(time.RFC3339),
+			LastAccessed: sess.LastAccessed.UTC().Format(time.RFC3339),
+			Connected:    s.hub.HasSession(sess.Code),
+		})
+	}
+
+	return jsonrpc.Paginate(summaries, func(summary AdminSessionSummary) string {
+		return summary.Code
+	}, p.PageParams)
+}
+
+// DefaultDisconnectReason is the close reason sent by admin.disconnect when
+// the caller gives none.
+const DefaultDisconnectReason = "disconnected by administrator"
+
+// AdminDisconnectParams holds the parameters for the admin.disconnect method.
+type AdminDisconnectParams struct {
+	// Code is the session code whose connections are closed
+	Code string `json:"code" validate:"required,sessioncode"`
+
+	// Reason is sent to the client in the close frame
+	Reason string `json:"reason" validate:"max=120"`
+
+	// DeleteSession also deletes the session so it cannot be restored
+	DeleteSession bool `json:"deleteSession"`
+}
+
+// adminDisconnectParamsSchema is the validation schema for AdminDisconnectParams.
+var adminDisconnectParamsSchema = reflect.TypeOf(AdminDisconnectParams{})
+
+// handleAdminDisconnect handles the "admin.disconnect" JSON-RPC method.
+// It closes the session's live connections with a policy-violation close
+// frame and optionally deletes the session. The result reports whether a
+// live connection was found and whether the session was deleted.
+func (s *Server) handleAdminDisconnect(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p AdminDisconnectParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse admin.disconnect params: %w", err)
+	}
+
+	reason := p.Reason
+	if reason == "" {
+		reason = DefaultDisconnectReason
+	}
+
+	code := s.sessionManager.NormalizeCode(p.Code)
+	disconnected := s.hub.DisconnectSession(code, reason)
+
+	deleted := false
+	if p.DeleteSession {
+		var err error
+		if deleted, err = s.sessionManager.RemoveSession(code); err != nil {
+			return nil, fmt.Errorf("failed to delete session: %w", err)
+		}
+	}
+
+	s.logger.Info("Admin disconnected session",
+		"sessionCode", code,
+		"disconnected", disconnected,
+		"deleted", deleted)
+
+	return map[string]interface{}{
+		"disconnected": disconnected,
+		"deleted":      deleted,
+	}, nil
+}
+
+// AdminCleanupResult is the result of the admin.cleanup method.
+type AdminCleanupResult struct {
+	// Removed is the number of expired sessions the cleanup removed
+	Removed int `json:"removed"`
+
+	// Before and After are the session counts just before and after the
+	// cleanup; sessions created or deleted meanwhile show in the difference
+	Before int `json:"before"`
+	After  int `json:"after"`
+}
+
+// handleAdminCleanup handles the "admin.cleanup" JSON-RPC method. It removes
+// expired sessions right away instead of waiting for the next background
+// cleanup, and reports how many were removed.
+func (s *Server) handleAdminCleanup(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	before := s.sessionManager.GetSessionCount()
+	removed := s.sessionManager.Cleanup()
+	after := s.sessionManager.GetSessionCount()
+
+	s.logger.Info("Admin ran session cleanup",
+		"removed", removed,
+		"before", before,
+		"after", after)
+
+	return AdminCleanupResult{Removed: removed, Before: before, After: after}, nil
+}
+
+// AdminMetricsResult is the result of the admin.metrics method.
+type AdminMetricsResult struct {
+	// Router holds JSON-RPC call and error counts, overall and per method
+	Router jsonrpc.RouterMetrics `json:"router"`
+
+	// Hub holds the connection gauge, connection counters and message sizes
+	Hub websocket.HubMetrics `json:"hub"`
+
+	// Timestamp is when the snapshot was taken
+	Timestamp string `json:"timestamp"`
+}
+
+// handleAdminMetrics handles the "admin.metrics" JSON-RPC method.
+// It returns snapshots of the router and hub metrics.
+func (s *Server) handleAdminMetrics(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return AdminMetricsResult{
+		Router:    s.jsonrpcRouter.Metrics(),
+		Hub:       s.hub.Metrics(),
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}, nil
+}
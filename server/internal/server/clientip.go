@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// parseTrustedProxies parses comma-separated IP addresses and CIDR prefixes.
+// Entries that parse as neither are skipped; config validation rejects them.
+func parseTrustedProxies(list []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, entry := range list {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return prefixes
+}
+
+// clientIP returns the IP address of the client behind the request. When the
+// request comes from a trusted proxy, the X-Forwarded-For chain is walked
+// from the right, past any further trusted proxies, to the first address the
+// proxies did not add themselves; otherwise it is the host part of the remote
+// address.
+func (s *Server) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	trusted := *s.trustedProxies.Load()
+	if len(trusted) == 0 || !isTrustedProxy(trusted, host) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !isTrustedProxy(trusted, hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+// isTrustedProxy reports whether ip falls within one of the trusted prefixes.
+func isTrustedProxy(trusted []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
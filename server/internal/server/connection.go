@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fle/server/internal/websocket"
+)
+
+// ConnectionStatsResult is the result of the connection.stats method.
+type ConnectionStatsResult struct {
+	websocket.ConnectionInfo
+
+	// DurationMs is how long the connection has been open, in milliseconds
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// handleConnectionStats handles the "connection.stats" JSON-RPC method.
+// It reports the caller's own connection counters and never another
+// connection's.
+func (s *Server) handleConnectionStats(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	client, ok := websocket.ClientFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("connection.stats requires a WebSocket connection")
+	}
+
+	info := client.ConnectionInfo()
+	return ConnectionStatsResult{
+		ConnectionInfo: info,
+		DurationMs:     time.Since(info.ConnectedAt).Milliseconds(),
+	}, nil
+}
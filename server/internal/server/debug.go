@@ -0,0 +1,26 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ValidationTagsResult is the result of the debug.validationTags method.
+type ValidationTagsResult struct {
+	// Tags lists the validation tags the server's validator supports
+	Tags []string `json:"tags"`
+}
+
+// handleDebugValidationTags handles the "debug.validationTags" JSON-RPC
+// method, which is only registered in development. It lets client-side form
+// builders mirror the server's validation rules.
+func (s *Server) handleDebugValidationTags(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return ValidationTagsResult{Tags: s.jsonrpcRouter.Validator().GetSupportedTags()}, nil
+}
+
+// handleDebugErrorCodes handles the "debug.errorCodes" JSON-RPC method, which
+// is only registered in development. It returns the router's error code
+// catalog so client tooling can map codes to names.
+func (s *Server) handleDebugErrorCodes(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return s.jsonrpcRouter.ErrorCatalog(), nil
+}
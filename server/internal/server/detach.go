@@ -0,0 +1,46 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fle/server/internal/jsonrpc"
+)
+
+// sessionDetached is the hub's detach callback, run when a session's last
+// connection closes. Under the "detach" and "notify" disconnect policies it
+// records the disconnect on the session, unless the client has reconnected
+// meanwhile; "notify" also tells the clients sharing one of the session's
+// rooms with a session.disconnected notification. Clients without a shared
+// room are not told, so session codes do not leak to unrelated clients.
+func (s *Server) sessionDetached(code string, rooms []string) {
+	policy := strings.ToLower(s.currentConfig().SessionDisconnectPolicy)
+	if policy == "none" || s.hub.HasSession(code) {
+		return
+	}
+
+	disconnectedAt, err := s.sessionManager.MarkDisconnected(code)
+	if err != nil {
+		// The session may have expired or been deleted along with the connection
+		s.logger.Debug("Not marking session disconnected",
+			"sessionCode", code,
+			"error", err)
+		return
+	}
+	if policy != "notify" || len(rooms) == 0 {
+		return
+	}
+
+	notification, err := jsonrpc.NewNotification("session.disconnected", map[string]interface{}{
+		"session_code":    code,
+		"disconnected_at": disconnectedAt.UTC().Format(time.RFC3339),
+	})
+	if err == nil {
+		err = s.hub.NotifyRooms(rooms, notification)
+	}
+	if err != nil {
+		s.logger.Error("Failed to send session.disconnected notification",
+			"sessionCode", code,
+			"error", err)
+	}
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fle/server/internal/jsonrpc"
+)
+
+// ErrorResponse is the JSON body of HTTP error responses. Its error object
+// has the same shape as a JSON-RPC error, so clients can handle failures
+// from middleware and handlers the same way as JSON-RPC errors.
+type ErrorResponse struct {
+	Error *jsonrpc.Error `json:"error"`
+}
+
+// writeJSONError writes status with an ErrorResponse body carrying code and
+// message. Codes are JSON-RPC error codes, such as jsonrpc.InvalidRequest for
+// malformed requests or jsonrpc.RateLimited for throttled ones.
+func writeJSONError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: &jsonrpc.Error{Code: code, Message: message}})
+}
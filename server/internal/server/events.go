@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/fle/server/internal/websocket"
+)
+
+// EventParams holds the parameters for the events.subscribe and
+// events.unsubscribe methods.
+type EventParams struct {
+	// Events lists event types by notification method, e.g. "session.updated"
+	Events []string `json:"events" validate:"required,min=1,dive,required,max=64"`
+}
+
+// eventParamsSchema is the validation schema for EventParams.
+var eventParamsSchema = reflect.TypeOf(EventParams{})
+
+// handleEventsSubscribe handles the "events.subscribe" JSON-RPC method.
+// After its first subscription a connection only receives notifications
+// for the event types it is subscribed to.
+func (s *Server) handleEventsSubscribe(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	client, ok := websocket.ClientFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("events.subscribe requires a WebSocket connection")
+	}
+
+	var p EventParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse event params: %w", err)
+	}
+
+	subscriptions, err := s.hub.Subscribe(client, p.Events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	s.logger.Debug("Client subscribed to events",
+		"sessionCode", client.SessionCode(),
+		"events", p.Events)
+
+	return map[string]interface{}{
+		"events": subscriptions,
+	}, nil
+}
+
+// handleEventsUnsubscribe handles the "events.unsubscribe" JSON-RPC method.
+// It removes event types from the caller's subscriptions.
+func (s *Server) handleEventsUnsubscribe(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	client, ok := websocket.ClientFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("events.unsubscribe requires a WebSocket connection")
+	}
+
+	var p EventParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse event params: %w", err)
+	}
+
+	subscriptions := s.hub.Unsubscribe(client, p.Events)
+
+	s.logger.Debug("Client unsubscribed from events",
+		"sessionCode", client.SessionCode(),
+		"events", p.Events)
+
+	return map[string]interface{}{
+		"events": subscriptions,
+	}, nil
+}
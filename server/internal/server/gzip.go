@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fle/server/internal/websocket"
+)
+
+// gzipMiddleware gzips HTTP responses of at least GzipMinSize bytes for
+// clients that accept gzip. Responses are buffered until the threshold is
+// reached, so small ones go out unchanged. WebSocket upgrades are exempt, as
+// are responses the handler already encoded itself.
+func (s *Server) gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		minSize := s.currentConfig().GzipMinSize
+		if minSize <= 0 || websocket.IsUpgradeRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The response depends on Accept-Encoding whether or not it is gzipped
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize, statusCode: http.StatusOK}
+		defer gw.finish()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header value allows gzip,
+// either by name or through "*", with a non-zero quality.
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if quality, err := strconv.ParseFloat(q, 64); err == nil && quality == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter buffers a response until it reaches minSize bytes, then
+// switches to writing it gzipped. A response that ends or is flushed below
+// the threshold is written as is.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize     int
+	statusCode  int
+	wroteHeader bool
+	buffer      bytes.Buffer
+	gz          *gzip.Writer
+
+	// decided is set once the response has been committed, compressed or not
+	decided bool
+}
+
+// WriteHeader records the status code; it is sent once the encoding is known.
+func (gw *gzipResponseWriter) WriteHeader(code int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	gw.statusCode = code
+}
+
+// Write buffers p until the response is large enough to compress.
+func (gw *gzipResponseWriter) Write(p []byte) (int, error) {
+	gw.wroteHeader = true
+	if gw.decided {
+		if gw.gz != nil {
+			return gw.gz.Write(p)
+		}
+		return gw.ResponseWriter.Write(p)
+	}
+
+	gw.buffer.Write(p)
+	if gw.buffer.Len() < gw.minSize {
+		return len(p), nil
+	}
+	if err := gw.commit(true); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush implements http.Flusher. Flushing commits the response, so a
+// streamed response below the threshold so far is sent uncompressed.
+func (gw *gzipResponseWriter) Flush() {
+	if !gw.decided {
+		gw.commit(gw.buffer.Len() >= gw.minSize)
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	if flusher, ok := gw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// commit sends the headers and the buffered body, gzipped if compress is
+// set and the handler did not choose an encoding itself.
+func (gw *gzipResponseWriter) commit(compress bool) error {
+	gw.decided = true
+	header := gw.ResponseWriter.Header()
+	if compress && header.Get("Content-Encoding") == "" && bodyAllowed(gw.statusCode) {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		gw.gz = gzip.NewWriter(gw.ResponseWriter)
+	}
+	gw.ResponseWriter.WriteHeader(gw.statusCode)
+
+	var err error
+	if gw.gz != nil {
+		_, err = gw.gz.Write(gw.buffer.Bytes())
+	} else {
+		_, err = gw.ResponseWriter.Write(gw.buffer.Bytes())
+	}
+	gw.buffer.Reset()
+	return err
+}
+
+// finish completes the response once the handler has returned.
+func (gw *gzipResponseWriter) finish() {
+	if !gw.decided {
+		if !gw.wroteHeader {
+			// The handler wrote nothing; let the server send its defaults
+			return
+		}
+		gw.commit(false)
+	}
+	if gw.gz != nil {
+		gw.gz.Close()
+	}
+}
+
+// bodyAllowed reports whether a response with the given status may carry a
+// body, and so be compressed.
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -5,11 +5,18 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/fle/server/internal/jsonrpc"
+	"github.com/fle/server/internal/session"
 	"github.com/fle/server/internal/websocket"
 )
 
@@ -27,21 +34,42 @@ type HealthResponse struct {
 
 	// Environment indicates the current deployment environment
 	Environment string `json:"environment"`
+
+	// CleanupLastRun is when expired sessions were last cleaned up
+	CleanupLastRun time.Time `json:"cleanup_last_run"`
+
+	// CleanupPanics counts session cleanup runs that panicked and were recovered
+	CleanupPanics uint64 `json:"cleanup_panics"`
 }
 
 // handleHealth handles GET requests to the /health endpoint.
 // It returns a JSON response indicating the server's health status.
 // This endpoint is used for health checks by load balancers and monitoring systems.
+// It reports "unhealthy" with 503 when session cleanup has stalled.
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	cleanup := s.sessionManager.CleanupStatus()
 	response := HealthResponse{
-		Status:      "healthy",
-		Timestamp:   time.Now().UTC(),
-		Version:     "1.0.0", // TODO: This should come from build information
-		Environment: s.config.Environment,
+		Status:         "healthy",
+		Timestamp:      now.UTC(),
+		Version:        Version,
+		Environment:    s.currentConfig().Environment,
+		CleanupLastRun: cleanup.LastRun.UTC(),
+		CleanupPanics:  cleanup.Panics,
+	}
+
+	status := http.StatusOK
+	if cleanup.Stalled(now) {
+		response.Status = "unhealthy"
+		status = http.StatusServiceUnavailable
+		s.logger.Warn("Session cleanup has stalled",
+			"last_run", cleanup.LastRun,
+			"interval", cleanup.Interval,
+		)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		s.logger.Error("Failed to encode health response",
@@ -58,12 +86,74 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	)
 }
 
+// ReadinessResponse represents the structure of the readiness check response.
+type ReadinessResponse struct {
+	// Status is "ready", "maintenance", "overloaded", "cleanup_stalled" or "shutting_down"
+	Status string `json:"status"`
+
+	// Connections is the number of connected WebSocket clients
+	Connections int `json:"connections"`
+
+	// MaxConnections is the configured connection limit
+	MaxConnections int `json:"max_connections"`
+
+	// SaturatedClients is the number of clients with nearly full send buffers
+	SaturatedClients int `json:"saturated_clients"`
+}
+
+// handleReady handles GET requests to the /readyz endpoint.
+// It returns 503 while the server is shutting down or overloaded so load
+// balancers stop routing new connections to it, and 200 otherwise. A failing
+// session store is reported as "degraded" with a 200, since sessions are
+// still served from memory.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	stats := s.hub.LoadStats()
+	response := ReadinessResponse{
+		Status:           "ready",
+		Connections:      stats.Clients,
+		MaxConnections:   stats.MaxConnections,
+		SaturatedClients: stats.SaturatedClients,
+	}
+
+	status := http.StatusOK
+	switch {
+	case s.shuttingDown():
+		response.Status = "shutting_down"
+		status = http.StatusServiceUnavailable
+	case s.InMaintenance():
+		response.Status = "maintenance"
+		status = http.StatusServiceUnavailable
+	case s.readiness.update(stats):
+		response.Status = "overloaded"
+		status = http.StatusServiceUnavailable
+	case s.sessionManager.CleanupStatus().Stalled(time.Now()):
+		response.Status = "cleanup_stalled"
+		status = http.StatusServiceUnavailable
+	case s.sessionManager.StoreDegraded():
+		response.Status = "degraded"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		s.logger.Error("Failed to encode readiness response",
+			"error", err,
+			"remote_addr", r.RemoteAddr,
+		)
+	}
+}
+
 // WelcomeMessage represents the welcome message sent to newly connected WebSocket clients.
 type WelcomeMessage struct {
 	Type        string `json:"type"`
 	SessionCode string `json:"session_code"`
 	Message     string `json:"message"`
 	Timestamp   string `json:"timestamp"`
+
+	// ReconnectToken is sent once, when the session is created; clients
+	// present it as the reconnect_token query parameter to restore the session
+	ReconnectToken string `json:"reconnect_token,omitempty"`
 }
 
 // handleWebSocket handles WebSocket upgrade requests.
@@ -71,20 +161,120 @@ type WelcomeMessage struct {
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Check if this is a WebSocket upgrade request
 	if r.Header.Get("Upgrade") != "websocket" {
-		http.Error(w, "Expected WebSocket upgrade", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, jsonrpc.InvalidRequest, "Expected WebSocket upgrade")
 		return
 	}
 
-	// Try to get session code from query parameters or create a new session
-	sessionCode := r.URL.Query().Get("session")
+	// Bound the handshakes in flight from one client so it cannot tie up
+	// upgrades for everyone else
+	ip := s.clientIP(r)
+	release, ok := s.handshakes.acquire(ip, s.currentConfig().MaxHandshakesPerIP)
+	if !ok {
+		s.logger.Warn("Rejecting WebSocket upgrade, too many concurrent handshakes",
+			"client_ip", ip,
+			"limit", s.currentConfig().MaxHandshakesPerIP)
+		w.Header().Set("Retry-After", "1")
+		writeJSONError(w, http.StatusTooManyRequests, jsonrpc.RateLimited, "Too many concurrent handshakes")
+		return
+	}
+	defer release()
+
+	// Refuse new connections, and so new sessions, during maintenance
+	if s.shuttingDown() {
+		s.rejectShuttingDown(w, r)
+		return
+	}
+
+	if s.InMaintenance() {
+		s.logger.Info("Rejecting WebSocket upgrade during maintenance",
+			"remote_addr", r.RemoteAddr)
+		w.Header().Set("Retry-After", strconv.Itoa(s.currentConfig().ReconnectRetryAfter))
+		writeJSONError(w, http.StatusServiceUnavailable, jsonrpc.Maintenance, s.currentConfig().MaintenanceMessage)
+		return
+	}
+
+	// Reject disallowed origins before creating a session on their behalf
+	if !s.hub.CheckOrigin(r) {
+		s.logger.Warn("Rejecting WebSocket upgrade from disallowed origin",
+			"origin", r.Header.Get("Origin"),
+			"remote_addr", r.RemoteAddr)
+		writeJSONError(w, http.StatusForbidden, jsonrpc.Unauthorized, "Origin not allowed")
+		return
+	}
+
+	// Attach the authenticated principal, if any, for JSON-RPC authorization
+	principal, ok := s.authenticate(r)
+	if !ok {
+		s.logger.Warn("Rejecting WebSocket upgrade with unrecognized token",
+			"remote_addr", r.RemoteAddr)
+		writeJSONError(w, http.StatusUnauthorized, jsonrpc.Unauthorized, "Invalid token")
+		return
+	}
+	if principal != nil {
+		r = r.WithContext(jsonrpc.WithPrincipal(r.Context(), principal))
+	}
+
+	// A client opts in to sequence-numbered messages with lastSeq, zero on
+	// its first connection; on a reconnect it names the "seq" of the last
+	// message it received so only newer messages are replayed
+	if value := r.URL.Query().Get("lastSeq"); value != "" {
+		lastSeq, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, jsonrpc.InvalidRequest, "Invalid lastSeq")
+			return
+		}
+		r = r.WithContext(websocket.WithResumeSequence(r.Context(), lastSeq))
+	}
+
+	// Try to get session code from query parameters or create a new session,
+	// normalizing it so e.g. a Title-cased code restores the same session
+	sessionCode := s.sessionManager.NormalizeCode(r.URL.Query().Get("session"))
+
+	// Once a reconnect token was issued for the session, restoring it takes
+	// the token. A client that leaves it out, such as one written before
+	// tokens existed, is refused outright rather than quietly handed a new
+	// session; it should send the reconnect_token from its welcome message,
+	// or drop ?session= to start afresh
+	token := r.URL.Query().Get("reconnect_token")
+	if sessionCode != "" && token == "" && s.sessionManager.HasReconnectToken(sessionCode) {
+		s.logger.Warn("Refusing session restore without reconnect token",
+			"requested_session", sessionCode,
+			"remote_addr", r.RemoteAddr)
+		writeJSONError(w, http.StatusUnauthorized, jsonrpc.Unauthorized, "Reconnect token required")
+		return
+	}
+
+	// A presented reconnect token must be the session's current one, and
+	// RequireReconnectToken makes presenting it mandatory
+	if sessionCode != "" && (token != "" || s.currentConfig().RequireReconnectToken) &&
+		!s.sessionManager.VerifyReconnectToken(sessionCode, token) {
+		s.logger.Warn("Refusing session restore with invalid reconnect token",
+			"requested_session", sessionCode,
+			"remote_addr", r.RemoteAddr)
+		sessionCode = ""
+	}
 
 	if sessionCode != "" {
-		// Try to restore existing session
-		if existingSession, err := s.sessionManager.GetSession(sessionCode); err == nil {
-			sessionCode = existingSession.Code
+		// Try to restore existing session, checking its kind before the
+		// restore counts against its reconnect limit
+		if existingSession, err := s.sessionManager.PeekSession(sessionCode); err == nil &&
+			existingSession.Kind == adminSessionKind && !principal.HasRole(adminRole) {
+			s.logger.Warn("Refusing to restore admin session without admin token",
+				"requested_session", sessionCode,
+				"remote_addr", r.RemoteAddr)
+			sessionCode = ""
+		} else if restoredSession, err := s.sessionManager.RestoreSession(sessionCode); err == nil {
+			sessionCode = restoredSession.Code
 			s.logger.Debug("Restored existing session",
 				"sessionCode", sessionCode,
+				"reconnects", restoredSession.Reconnects,
+				"remote_addr", r.RemoteAddr)
+		} else if errors.Is(err, session.ErrReconnectLimitReached) {
+			s.logger.Warn("Session exceeded its reconnect limit, creating new session",
+				"requested_session", sessionCode,
+				"maxReconnects", s.currentConfig().MaxSessionReconnects,
 				"remote_addr", r.RemoteAddr)
+			sessionCode = ""
 		} else {
 			s.logger.Debug("Session not found or expired, creating new session",
 				"requested_session", sessionCode,
@@ -95,35 +285,90 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var reconnectToken string
 	if sessionCode == "" {
 		// Create a new session
-		newSession, err := s.sessionManager.CreateSession(context.Background(), nil)
+		options := session.DefaultSessionOptions()
+		options.Kind = sessionKindFor(principal)
+		if principal != nil {
+			options.Owner = principal.ID
+		}
+		newSession, err := s.sessionManager.CreateSession(context.Background(), options)
+		if errors.Is(err, session.ErrOwnerSessionLimitReached) {
+			s.logger.Warn("Rejecting WebSocket upgrade, principal session limit reached",
+				"principal", principal.ID,
+				"maxSessionsPerPrincipal", s.sessionManager.MaxSessionsPerOwner(),
+				"remote_addr", r.RemoteAddr)
+			writeJSONError(w, http.StatusTooManyRequests, jsonrpc.RateLimited, "Session limit reached for this principal")
+			return
+		}
+		if errors.Is(err, session.ErrSessionLimitReached) {
+			s.logger.Warn("Rejecting WebSocket upgrade, session limit reached",
+				"maxSessions", s.sessionManager.MaxSessions(),
+				"remote_addr", r.RemoteAddr)
+			w.Header().Set("Retry-After", strconv.Itoa(s.currentConfig().ReconnectRetryAfter))
+			writeJSONError(w, http.StatusServiceUnavailable, jsonrpc.ServerBusy, "Session limit reached")
+			return
+		}
 		if err != nil {
 			s.logger.Error("Failed to create session",
 				"error", err,
 				"remote_addr", r.RemoteAddr)
-			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+			writeJSONError(w, http.StatusInternalServerError, jsonrpc.InternalError, "Failed to create session")
+			return
+		}
+		// Shutdown may have begun while the session was being created, in
+		// which case nothing would ever connect to it
+		if s.shuttingDown() {
+			if _, err := s.sessionManager.RemoveSession(newSession.Code); err != nil {
+				s.logger.Warn("Failed to delete session created during shutdown",
+					"sessionCode", newSession.Code,
+					"error", err)
+			}
+			s.rejectShuttingDown(w, r)
 			return
 		}
 		sessionCode = newSession.Code
+		reconnectToken, err = s.sessionManager.IssueReconnectToken(sessionCode)
+		if err != nil {
+			s.logger.Error("Failed to issue reconnect token",
+				"sessionCode", sessionCode,
+				"error", err)
+		}
 		s.logger.Debug("Created new session",
 			"sessionCode", sessionCode,
 			"remote_addr", r.RemoteAddr)
 	}
 
 	// Upgrade HTTP connection to WebSocket
-	websocket.ServeWS(s.hub, w, r, sessionCode, s.logger, s.jsonrpcRouter)
+	client := websocket.ServeWS(s.hub, w, r, sessionCode, s.logger, s.jsonrpcRouter)
+	if client == nil {
+		return
+	}
+
+	// Hold the client until the welcome is queued, so a shutdown starting
+	// meanwhile drains it instead of closing the connection first
+	releaseWelcome := client.Hold()
 
 	// Send welcome message after connection is established
-	// Note: We need to wait a moment for the connection to be fully established
+	// Note: We need to wait a moment for the connection to be fully established,
+	// and give up if the client disconnects in the meantime
 	go func() {
-		time.Sleep(100 * time.Millisecond) // Brief delay to ensure connection is ready
+		defer releaseWelcome()
+		select {
+		case <-time.After(100 * time.Millisecond): // Brief delay to ensure connection is ready
+		case <-client.Context().Done():
+			s.logger.Debug("Client disconnected before welcome message",
+				"sessionCode", sessionCode)
+			return
+		}
 
 		welcomeMsg := WelcomeMessage{
-			Type:        "welcome",
-			SessionCode: sessionCode,
-			Message:     "WebSocket connection established successfully",
-			Timestamp:   time.Now().UTC().Format(time.RFC3339),
+			Type:           "welcome",
+			SessionCode:    sessionCode,
+			Message:        "WebSocket connection established successfully",
+			Timestamp:      time.Now().UTC().Format(time.RFC3339),
+			ReconnectToken: reconnectToken,
 		}
 
 		msgBytes, err := json.Marshal(welcomeMsg)
@@ -134,30 +379,58 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Send welcome message to the specific session
+		// Send welcome message to the specific session, unless the client
+		// disconnected while it was being built
+		if client.Context().Err() != nil {
+			return
+		}
 		s.hub.SendToSession(sessionCode, msgBytes)
 		s.logger.Debug("Welcome message sent",
 			"sessionCode", sessionCode)
 	}()
 
-	s.logger.Info("WebSocket connection established",
+	// Logged at debug level; the hub logs (sampled) connection events at info level
+	s.logger.Debug("WebSocket connection established",
 		"sessionCode", sessionCode,
 		"remote_addr", r.RemoteAddr,
 		"user_agent", r.Header.Get("User-Agent"))
 }
 
+// notifySessionUpdated sends a "session.updated" notification listing the
+// changed keys (not their values) to the client connected to the session.
+// Nothing is sent when no keys are given.
+func notifySessionUpdated(hub *websocket.Hub, logger *slog.Logger, code string, keys []string) {
+	if len(keys) == 0 || !hub.HasSession(code) {
+		return
+	}
+
+	notification, err := jsonrpc.NewNotification("session.updated", map[string]interface{}{
+		"session_code": code,
+		"keys":         keys,
+	})
+	if err == nil {
+		err = hub.SendNotification(code, notification)
+	}
+	if err != nil {
+		logger.Error("Failed to send session.updated notification",
+			"sessionCode", code,
+			"error", err)
+	}
+}
+
 // corsMiddleware adds CORS headers to responses for development environments.
 // This allows the frontend development server to communicate with the backend.
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", s.config.CORSOrigin)
+		w.Header().Set("Access-Control-Allow-Origin", s.currentConfig().CORSOrigin)
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		w.Header().Set("Access-Control-Max-Age", "86400")
 
-		// Handle preflight requests
-		if r.Method == http.MethodOptions {
+		// Handle preflight requests; those to the WebSocket endpoint are
+		// answered by its own handler
+		if r.Method == http.MethodOptions && r.URL.Path != "/ws" {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
@@ -192,7 +465,8 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 }
 
 // responseWriter wraps http.ResponseWriter to capture the status code.
-// It also implements http.Hijacker to support WebSocket upgrades.
+// It also implements http.Hijacker to support WebSocket upgrades and
+// http.Flusher for streamed responses.
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -204,6 +478,14 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Flush implements http.Flusher so streamed responses reach the client
+// as they are written.
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 // Hijack implements http.Hijacker for WebSocket upgrade support.
 func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if hijacker, ok := rw.ResponseWriter.(http.Hijacker); ok {
@@ -244,14 +526,130 @@ func (s *Server) handleEcho(ctx context.Context, params json.RawMessage) (interf
 }
 
 // handleGetSessionInfo handles the "getSessionInfo" JSON-RPC method.
+// activeSessions lists at most SessionInfoMaxCodes codes in sorted order;
+// activeSessionCount and truncated report when the list was cut short.
 func (s *Server) handleGetSessionInfo(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	s.logger.Debug("JSON-RPC getSessionInfo method called")
 	
+	codes := s.hub.GetSessionCodes()
+	total := len(codes)
+	sort.Strings(codes)
+	truncated := false
+	if limit := s.currentConfig().SessionInfoMaxCodes; limit > 0 && total > limit {
+		codes = codes[:limit]
+		truncated = true
+	}
+
 	// For now, return basic info about connected sessions
 	// In a real implementation, this would extract session info from context
 	return map[string]interface{}{
-		"totalSessions": s.hub.GetClientCount(),
-		"activeSessions": s.hub.GetSessionCodes(),
+		"totalSessions":      s.hub.GetClientCount(),
+		"activeSessions":     codes,
+		"activeSessionCount": total,
+		"truncated":          truncated,
+		"timestamp":          time.Now().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// handleServerStatus handles the "server.status" JSON-RPC method.
+// It reports the instance ID and current usage against the configured session
+// and connection caps; a max of 0 means unlimited.
+func (s *Server) handleServerStatus(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"instance_id": s.InstanceID(),
+		"sessions": map[string]interface{}{
+			"current": s.sessionManager.GetSessionCount(),
+			"max":     s.sessionManager.MaxSessions(),
+		},
+		"connections": map[string]interface{}{
+			"current": s.hub.GetClientCount(),
+			"max":     s.currentConfig().MaxConnections,
+		},
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 	}, nil
 }
+
+// RoomParams holds the parameters for the room.join and room.leave methods.
+type RoomParams struct {
+	// Room is the name of the room to join or leave
+	Room string `json:"room" validate:"required,min=1,max=64"`
+}
+
+// roomParamsSchema is the validation schema for RoomParams.
+var roomParamsSchema = reflect.TypeOf(RoomParams{})
+
+// handleRoomJoin handles the "room.join" JSON-RPC method.
+// It adds the caller's connection to the requested room.
+func (s *Server) handleRoomJoin(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	client, ok := websocket.ClientFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("room.join requires a WebSocket connection")
+	}
+
+	var p RoomParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse room params: %w", err)
+	}
+
+	if err := s.hub.JoinRoom(client, p.Room); err != nil {
+		return nil, fmt.Errorf("failed to join room: %w", err)
+	}
+
+	s.logger.Debug("Client joined room",
+		"sessionCode", client.SessionCode(),
+		"room", p.Room)
+
+	return map[string]interface{}{
+		"room":  p.Room,
+		"rooms": s.hub.ClientRooms(client),
+	}, nil
+}
+
+// handleRoomLeave handles the "room.leave" JSON-RPC method.
+// It removes the caller's connection from the requested room.
+func (s *Server) handleRoomLeave(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	client, ok := websocket.ClientFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("room.leave requires a WebSocket connection")
+	}
+
+	var p RoomParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse room params: %w", err)
+	}
+
+	left := s.hub.LeaveRoom(client, p.Room)
+
+	s.logger.Debug("Client left room",
+		"sessionCode", client.SessionCode(),
+		"room", p.Room,
+		"wasMember", left)
+
+	return map[string]interface{}{
+		"room":  p.Room,
+		"left":  left,
+		"rooms": s.hub.ClientRooms(client),
+	}, nil
+}
+
+// handleRoomList handles the "room.list" JSON-RPC method.
+// It returns the rooms the caller's connection currently belongs to.
+func (s *Server) handleRoomList(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	client, ok := websocket.ClientFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("room.list requires a WebSocket connection")
+	}
+
+	return map[string]interface{}{
+		"rooms": s.hub.ClientRooms(client),
+	}, nil
+}
+
+// rejectShuttingDown refuses a WebSocket upgrade because the server is
+// shutting down.
+func (s *Server) rejectShuttingDown(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Rejecting WebSocket upgrade during shutdown",
+		"remote_addr", r.RemoteAddr)
+	w.Header().Set("Retry-After", strconv.Itoa(s.currentConfig().ReconnectRetryAfter))
+	writeJSONError(w, http.StatusServiceUnavailable, jsonrpc.Maintenance, "Server is shutting down")
+}
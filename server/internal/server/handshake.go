@@ -0,0 +1,39 @@
+package server
+
+import "sync"
+
+// handshakeLimiter counts the WebSocket handshakes in flight per client IP.
+type handshakeLimiter struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+// newHandshakeLimiter creates an empty handshakeLimiter.
+func newHandshakeLimiter() *handshakeLimiter {
+	return &handshakeLimiter{inFlight: make(map[string]int)}
+}
+
+// acquire starts a handshake for ip unless limit handshakes from it are
+// already in flight; a limit of zero or less is unlimited. The limit is
+// passed on each call so reloaded limits apply immediately. When ok is true
+// the caller must call release once the handshake has finished.
+func (l *handshakeLimiter) acquire(ip string, limit int) (release func(), ok bool) {
+	if limit <= 0 {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[ip] >= limit {
+		return nil, false
+	}
+	l.inFlight[ip]++
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.inFlight[ip]--; l.inFlight[ip] <= 0 {
+			delete(l.inFlight, ip)
+		}
+	}, true
+}
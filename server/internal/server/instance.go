@@ -0,0 +1,35 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// InstanceIDHeader is the response header that carries the instance ID when
+// the InstanceIDHeader setting is enabled.
+const InstanceIDHeader = "X-Instance-ID"
+
+// NewInstanceID returns a random instance ID for servers configured without one.
+func NewInstanceID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// InstanceID returns the ID identifying this server instance, either the
+// configured INSTANCE_ID or the one generated when the server was created.
+func (s *Server) InstanceID() string {
+	return s.currentConfig().InstanceID
+}
+
+// instanceIDMiddleware echoes the instance ID in the X-Instance-ID response
+// header when the InstanceIDHeader setting is enabled.
+func (s *Server) instanceIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg := s.currentConfig(); cfg.InstanceIDHeader {
+			w.Header().Set(InstanceIDHeader, cfg.InstanceID)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
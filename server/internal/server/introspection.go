@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/fle/server/internal/jsonrpc"
+)
+
+// MethodsResponse is the body of GET /rpc/methods.
+type MethodsResponse struct {
+	Methods []jsonrpc.MethodDescription `json:"methods"`
+}
+
+// introspectionAllowed reports whether principal may list the registered
+// JSON-RPC methods under the configured MethodIntrospection mode.
+func (s *Server) introspectionAllowed(principal *jsonrpc.Principal) bool {
+	switch strings.ToLower(s.currentConfig().MethodIntrospection) {
+	case "public":
+		return true
+	case "admin":
+		return principal.HasRole(adminRole)
+	default:
+		return false
+	}
+}
+
+// handleMethods handles GET /rpc/methods, returning the router's method
+// introspection data as JSON. It is 404 when introspection is off and 403
+// for callers the configured mode does not allow.
+func (s *Server) handleMethods(w http.ResponseWriter, r *http.Request) {
+	if strings.EqualFold(s.currentConfig().MethodIntrospection, "off") {
+		writeJSONError(w, http.StatusNotFound, jsonrpc.ResourceNotFound, "Method introspection is disabled")
+		return
+	}
+
+	principal, ok := s.authenticate(r)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, jsonrpc.Unauthorized, "Invalid token")
+		return
+	}
+	if !s.introspectionAllowed(principal) {
+		writeJSONError(w, http.StatusForbidden, jsonrpc.Unauthorized, "Method introspection requires an admin token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := MethodsResponse{Methods: s.jsonrpcRouter.DescribeMethods()}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		s.logger.Error("Failed to encode methods response", "error", err)
+	}
+}
+
+// handleListMethods handles the "rpc.listMethods" JSON-RPC method, the socket
+// counterpart of GET /rpc/methods.
+func (s *Server) handleListMethods(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	principal, _ := jsonrpc.PrincipalFromContext(ctx)
+	if !s.introspectionAllowed(principal) {
+		return nil, jsonrpc.ErrUnauthorized
+	}
+
+	return MethodsResponse{Methods: s.jsonrpcRouter.DescribeMethods()}, nil
+}
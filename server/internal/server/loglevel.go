@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"github.com/fle/server/internal/jsonrpc"
+)
+
+// AdminSetLogLevelParams holds the parameters for the admin.setLogLevel method.
+type AdminSetLogLevelParams struct {
+	// Level is the new log level: debug, info, warn or error
+	Level string `json:"level" validate:"required"`
+}
+
+// adminSetLogLevelParamsSchema is the validation schema for AdminSetLogLevelParams.
+var adminSetLogLevelParamsSchema = reflect.TypeOf(AdminSetLogLevelParams{})
+
+// parseLogLevel parses a LOG_LEVEL style level name (case-insensitive).
+func parseLogLevel(name string) (slog.Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "info":
+		return slog.LevelInfo, true
+	case "warn":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return slog.LevelInfo, false
+}
+
+// logLevelName returns the LOG_LEVEL style name of level.
+func logLevelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// handleAdminGetLogLevel handles the "admin.getLogLevel" JSON-RPC method.
+// It returns the current level of the server's logger.
+func (s *Server) handleAdminGetLogLevel(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	if s.logLevel == nil {
+		return nil, fmt.Errorf("log level is not adjustable on this server")
+	}
+	return map[string]interface{}{
+		"level": logLevelName(s.logLevel.Level()),
+	}, nil
+}
+
+// handleAdminSetLogLevel handles the "admin.setLogLevel" JSON-RPC method.
+// It changes the level of the server's logger until the next change or
+// configuration reload, which applies LOG_LEVEL again.
+func (s *Server) handleAdminSetLogLevel(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p AdminSetLogLevelParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse admin.setLogLevel params: %w", err)
+	}
+
+	level, ok := parseLogLevel(p.Level)
+	if !ok {
+		return nil, jsonrpc.NewErrorWithData(jsonrpc.InvalidParams, jsonrpc.ErrInvalidParams.Message,
+			fmt.Sprintf("invalid log level %q, must be one of: debug, info, warn, error", p.Level))
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	if s.logLevel == nil {
+		return nil, fmt.Errorf("log level is not adjustable on this server")
+	}
+	previous := s.logLevel.Level()
+	s.logLevel.Set(level)
+
+	principal, _ := jsonrpc.PrincipalFromContext(ctx)
+	caller := ""
+	if principal != nil {
+		caller = principal.ID
+	}
+	s.logger.Info("Log level changed",
+		"level", logLevelName(level),
+		"previous", logLevelName(previous),
+		"principal", caller)
+
+	return map[string]interface{}{
+		"level":    logLevelName(level),
+		"previous": logLevelName(previous),
+	}, nil
+}
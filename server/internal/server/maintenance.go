@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// AdminSetMaintenanceModeParams holds the parameters for the
+// admin.setMaintenanceMode method.
+type AdminSetMaintenanceModeParams struct {
+	// Enabled turns maintenance mode on or off
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+// adminSetMaintenanceModeParamsSchema is the validation schema for AdminSetMaintenanceModeParams.
+var adminSetMaintenanceModeParamsSchema = reflect.TypeOf(AdminSetMaintenanceModeParams{})
+
+// InMaintenance reports whether the server is in maintenance mode, refusing
+// new WebSocket connections while existing ones keep working.
+func (s *Server) InMaintenance() bool {
+	return s.maintenance.Load()
+}
+
+// handleAdminSetMaintenanceMode handles the "admin.setMaintenanceMode"
+// JSON-RPC method. It turns maintenance mode on or off until the next change
+// or configuration reload, which applies MAINTENANCE_MODE again.
+func (s *Server) handleAdminSetMaintenanceMode(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p AdminSetMaintenanceModeParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse admin.setMaintenanceMode params: %w", err)
+	}
+
+	if previous := s.maintenance.Swap(*p.Enabled); previous != *p.Enabled {
+		s.logger.Warn("Maintenance mode changed", "enabled", *p.Enabled)
+	}
+	return map[string]interface{}{
+		"maintenance": *p.Enabled,
+	}, nil
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/fle/server/internal/jsonrpc"
+)
+
+// handleWebSocketPreflight answers OPTIONS /ws. Browsers never preflight
+// WebSocket upgrades, so by default the request is refused with 405. With
+// WebSocketPreflight set to "allow" it gets a 204 whose CORS headers, for an
+// allowed origin, permit only GET.
+func (s *Server) handleWebSocketPreflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", "GET")
+	if strings.ToLower(s.currentConfig().WebSocketPreflight) != "allow" {
+		writeJSONError(w, http.StatusMethodNotAllowed, jsonrpc.InvalidRequest, "WebSocket upgrades are not preflighted; use GET")
+		return
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" && s.hub.CheckOrigin(r) {
+		header := w.Header()
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Allow-Methods", "GET")
+		header.Set("Access-Control-Allow-Headers", "Authorization")
+		header.Set("Access-Control-Max-Age", "86400")
+		header.Add("Vary", "Origin")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/fle/server/internal/jsonrpc"
+	"github.com/fle/server/internal/session"
+)
+
+// PresenceCheckParams holds the parameters for the presence.check method.
+type PresenceCheckParams struct {
+	// Code is the session code to check
+	Code string `json:"code" validate:"required,sessioncode"`
+}
+
+// presenceCheckParamsSchema is the validation schema for PresenceCheckParams.
+var presenceCheckParamsSchema = reflect.TypeOf(PresenceCheckParams{})
+
+// PresenceResult is the result of the presence.check method. It deliberately
+// carries nothing beyond connection status: no session data or metadata.
+type PresenceResult struct {
+	// Connected reports whether the session has a live WebSocket connection
+	Connected bool `json:"connected"`
+
+	// LastSeen is when the session was last accessed, in RFC 3339 format
+	LastSeen string `json:"last_seen"`
+}
+
+// handlePresenceCheck handles the "presence.check" JSON-RPC method.
+// It reports whether another session is connected and when it was last
+// seen, without refreshing the session's expiry. Checks are throttled per
+// caller, so the method cannot be used to enumerate session codes.
+func (s *Server) handlePresenceCheck(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p PresenceCheckParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse presence.check params: %w", err)
+	}
+	if !s.allowSessionLookup(ctx) {
+		return nil, errSessionLookupThrottled
+	}
+
+	sess, err := s.sessionManager.PeekSession(p.Code)
+	if errors.Is(err, session.ErrSessionNotFound) || errors.Is(err, session.ErrSessionExpired) ||
+		errors.Is(err, session.ErrInvalidSessionCode) {
+		return nil, jsonrpc.NewErrorWithData(jsonrpc.ResourceNotFound, jsonrpc.ErrResourceNotFound.Message, err.Error())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up session: %w", err)
+	}
+
+	return PresenceResult{
+		Connected: s.hub.HasSession(sess.Code),
+		LastSeen:  sess.LastAccessed.UTC().Format(time.RFC3339),
+	}, nil
+}
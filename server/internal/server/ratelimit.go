@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fle/server/internal/jsonrpc"
+	"github.com/fle/server/internal/websocket"
+)
+
+// maxRateLimitBuckets bounds the number of client buckets kept before idle
+// ones are pruned.
+const maxRateLimitBuckets = 10000
+
+// sessionLookupRate and sessionLookupBurst throttle the methods that look up
+// other sessions by code, per connection, in lookups per second and at once,
+// so they cannot be used to enumerate live session codes.
+const (
+	sessionLookupRate  = 1
+	sessionLookupBurst = 10
+)
+
+// remoteIPContextKey is the context key for the client IP of a POST /rpc
+// request, which keys its session lookups in place of a connection.
+type remoteIPContextKey struct{}
+
+// rateLimiter is a token bucket rate limiter keyed by client.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+
+	// now returns the current time; tests replace it
+	now func() time.Time
+}
+
+// tokenBucket holds the tokens left for one client as of last.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates an empty rateLimiter.
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from key's bucket, which refills at rate tokens per
+// second up to burst, and reports whether one was available. The rate and
+// burst are passed on each call so reloaded limits apply immediately.
+func (l *rateLimiter) allow(key string, rate float64, burst int) bool {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitBuckets {
+			l.pruneLocked(now, rate, burst)
+		}
+		bucket = &tokenBucket{tokens: float64(burst), last: now}
+		l.buckets[key] = bucket
+	} else {
+		bucket.tokens = min(float64(burst), bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+		bucket.last = now
+	}
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// pruneLocked drops the buckets that have refilled completely, since a new
+// bucket starts full anyway. The caller must hold l.mu.
+func (l *rateLimiter) pruneLocked(now time.Time, rate float64, burst int) {
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*rate >= float64(burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// allowSessionLookup takes a token from the caller's session lookup bucket
+// and reports whether one was available. WebSocket callers are throttled per
+// connection and POST /rpc callers per client IP.
+func (s *Server) allowSessionLookup(ctx context.Context) bool {
+	var key string
+	if client, ok := websocket.ClientFromContext(ctx); ok {
+		key = fmt.Sprintf("conn:%p", client)
+	} else if ip, ok := ctx.Value(remoteIPContextKey{}).(string); ok {
+		key = "ip:" + ip
+	}
+	return s.lookupLimiter.allow(key, sessionLookupRate, sessionLookupBurst)
+}
+
+// errSessionLookupThrottled is returned by session lookups over the
+// caller's sessionLookupRate.
+var errSessionLookupThrottled = jsonrpc.NewError(jsonrpc.RateLimited, "Too many session lookups")
+
+// rateLimitRPC wraps next so that clients sending POST /rpc requests faster
+// than RPCRateLimit allows receive HTTP 429 with a JSON error body.
+func (s *Server) rateLimitRPC(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.currentConfig()
+		if cfg.RPCRateLimit > 0 && !s.rpcLimiter.allow(s.clientIP(r), float64(cfg.RPCRateLimit), cfg.RPCRateBurst) {
+			s.logger.Warn("Rate limiting RPC request",
+				"remote_addr", r.RemoteAddr,
+				"rate_limit", cfg.RPCRateLimit)
+			w.Header().Set("Retry-After", "1")
+			writeJSONError(w, http.StatusTooManyRequests, jsonrpc.RateLimited, "Too many requests")
+			return
+		}
+		next(w, r)
+	}
+}
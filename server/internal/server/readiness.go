@@ -0,0 +1,48 @@
+// Package server provides HTTP handlers and middleware for the FLE application.
+package server
+
+import (
+	"sync"
+
+	"github.com/fle/server/internal/websocket"
+)
+
+// Readiness thresholds. The server becomes overloaded when it reaches
+// MaxConnections or when at least half of its clients have saturated send
+// buffers, and only recovers once both signals drop below the lower
+// thresholds, so readiness does not flap around a single boundary.
+const (
+	overloadSaturationHigh  = 0.5
+	recoverSaturationLow    = 0.25
+	recoverConnectionsRatio = 0.9
+)
+
+// readiness tracks the overload state reported by /readyz.
+type readiness struct {
+	mu         sync.Mutex
+	overloaded bool
+}
+
+// update folds a load snapshot into the overload state and returns it.
+func (r *readiness) update(stats websocket.LoadStats) bool {
+	connections := 0.0
+	if stats.MaxConnections > 0 {
+		connections = float64(stats.Clients) / float64(stats.MaxConnections)
+	}
+	saturation := 0.0
+	if stats.Clients > 0 {
+		saturation = float64(stats.SaturatedClients) / float64(stats.Clients)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.overloaded {
+		if connections <= recoverConnectionsRatio && saturation <= recoverSaturationLow {
+			r.overloaded = false
+		}
+	} else if connections >= 1 || saturation >= overloadSaturationHigh {
+		r.overloaded = true
+	}
+	return r.overloaded
+}
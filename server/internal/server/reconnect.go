@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fle/server/internal/websocket"
+)
+
+// handleSessionRotateToken handles the "session.rotateToken" JSON-RPC method.
+// It issues a new reconnect token for the caller's session and returns it;
+// this is the only time the token is revealed. The previous token stops
+// working immediately.
+func (s *Server) handleSessionRotateToken(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	client, ok := websocket.ClientFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("session.rotateToken requires a WebSocket connection")
+	}
+
+	token, err := s.sessionManager.IssueReconnectToken(client.SessionCode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate reconnect token: %w", err)
+	}
+
+	s.logger.Info("Reconnect token rotated", "sessionCode", client.SessionCode())
+
+	return map[string]interface{}{
+		"reconnect_token": token,
+	}, nil
+}
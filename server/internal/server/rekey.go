@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fle/server/internal/jsonrpc"
+	"github.com/fle/server/internal/websocket"
+)
+
+// handleSessionRekey handles the "session.rekey" JSON-RPC method. It moves
+// the caller's session, with its data, to a freshly generated code, moves
+// the hub's connection mapping along, and returns the new code. The session
+// is also sent a session.rekeyed notification carrying the new code. The old
+// code stops resolving, so clients must reconnect with the new one. If the
+// connection cannot be moved, e.g. because the client disconnected meanwhile,
+// the session is moved back to its old code and an error is returned.
+func (s *Server) handleSessionRekey(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	client, ok := websocket.ClientFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("session.rekey requires a WebSocket connection")
+	}
+
+	oldCode := client.SessionCode()
+	rekeyed, err := s.sessionManager.RekeySession(ctx, oldCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rekey session: %w", err)
+	}
+	if !s.hub.RekeySession(oldCode, rekeyed.Code) {
+		if err := s.sessionManager.RevertRekey(rekeyed.Code, oldCode); err != nil {
+			s.logger.Error("Failed to move rekeyed session back to its old code",
+				"oldSessionCode", oldCode,
+				"sessionCode", rekeyed.Code,
+				"error", err)
+		}
+		return nil, fmt.Errorf("failed to rekey session: connection is no longer registered")
+	}
+
+	s.logger.Info("Session rekeyed",
+		"oldSessionCode", oldCode,
+		"sessionCode", rekeyed.Code)
+
+	notification, err := jsonrpc.NewNotification("session.rekeyed", map[string]interface{}{
+		"session_code": rekeyed.Code,
+	})
+	if err == nil {
+		err = s.hub.SendNotification(rekeyed.Code, notification)
+	}
+	if err != nil {
+		s.logger.Error("Failed to send session.rekeyed notification",
+			"sessionCode", rekeyed.Code,
+			"error", err)
+	}
+
+	return map[string]interface{}{
+		"session_code": rekeyed.Code,
+	}, nil
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/fle/server/internal/config"
+	"github.com/fle/server/internal/jsonrpc"
+	"github.com/fle/server/internal/websocket"
+)
+
+// currentConfig returns the configuration in effect, as last set by NewServer
+// or Reload. This method is thread-safe.
+func (s *Server) currentConfig() *config.Config {
+	return s.config.Load()
+}
+
+// SetLogLevelVar registers the level variable of the server's logger handler
+// so Reload can change the log level. Without it LOG_LEVEL is not reloadable.
+func (s *Server) SetLogLevelVar(level *slog.LevelVar) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.logLevel = level
+}
+
+// Reload applies the hot-reloadable settings of newCfg: log level and payload
+// logging, CORS and WebSocket origins, connection and request limits, the
+// admin token and auth requirement, and notification dedup. Other settings, such as the listen
+// address, only take effect on restart; changes to them are logged and
+// ignored. The new configuration is swapped in atomically, so each request
+// sees either the old or the new settings. It returns the names of the
+// ignored settings.
+func (s *Server) Reload(newCfg *config.Config) []string {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	// A configuration without an instance ID keeps the generated one
+	if newCfg.InstanceID == "" {
+		withID := *newCfg
+		withID.InstanceID = s.InstanceID()
+		newCfg = &withID
+	}
+
+	merged, ignored := mergeReloadable(s.currentConfig(), newCfg)
+	for _, name := range ignored {
+		s.logger.Warn("Ignoring configuration change that requires a restart", "setting", name)
+	}
+
+	s.config.Store(merged)
+	s.applyReloadableSettings(merged)
+
+	s.logger.Info("Configuration reloaded",
+		"log_level", merged.LogLevel,
+		"cors_origin", merged.CORSOrigin,
+		"max_connections", merged.MaxConnections,
+		"ignored", len(ignored))
+	return ignored
+}
+
+// applyReloadableSettings pushes the reloadable settings of cfg to the hub,
+// the JSON-RPC router and the log level.
+func (s *Server) applyReloadableSettings(cfg *config.Config) {
+	if s.logLevel != nil {
+		s.logLevel.Set(cfg.LogLevelSlog())
+	}
+	s.maintenance.Store(cfg.MaintenanceMode)
+	trustedProxies := parseTrustedProxies(cfg.TrustedProxyList())
+	s.trustedProxies.Store(&trustedProxies)
+
+	s.hub.SetMaxConnections(cfg.MaxConnections)
+	s.hub.SetReconnectHint(time.Duration(cfg.ReconnectRetryAfter) * time.Second)
+	s.hub.SetConnectionLogSampling(cfg.ConnectionLogSampleRate)
+	s.hub.SetWriteBatchLimit(cfg.WriteBatchLimit)
+	s.hub.SetSlowClientTolerance(cfg.SlowClientTolerance)
+	s.hub.SetRequestSequencing(cfg.RequestSequencing)
+	s.hub.SetTrimTrailingNewline(cfg.TrimTrailingNewline)
+	s.hub.SetNotificationDedupWindow(time.Duration(cfg.NotificationDedupWindow) * time.Millisecond)
+	if cfg.StrictOriginCheck() {
+		s.hub.SetOriginChecker(websocket.AllowOrigins(cfg.OriginAllowlist()))
+	} else {
+		s.hub.SetOriginChecker(nil)
+	}
+
+	s.jsonrpcRouter.SetAuthRequired(cfg.RequireAuth, cfg.AuthExemptMethodList())
+	s.jsonrpcRouter.SetMaxConcurrentHandlers(cfg.MaxConcurrentHandlers)
+	s.jsonrpcRouter.SetDefaultTimeout(time.Duration(cfg.HandlerTimeout) * time.Second)
+	s.jsonrpcRouter.SetMaxBatchSize(cfg.MaxBatchSize)
+	if policy, err := jsonrpc.ParseConcurrencyPolicy(cfg.ConcurrencyPolicy); err == nil {
+		s.jsonrpcRouter.SetConcurrencyPolicy(policy)
+	}
+
+	if cfg.LogPayloads {
+		s.jsonrpcRouter.SetPayloadLogger(s.logger)
+	} else {
+		s.jsonrpcRouter.SetPayloadLogger(nil)
+	}
+
+	if cfg.LogUnknownNotifications {
+		s.jsonrpcRouter.SetUnknownNotificationLogger(s.logger)
+	} else {
+		s.jsonrpcRouter.SetUnknownNotificationLogger(nil)
+	}
+}
+
+// mergeReloadable returns a copy of current with the reloadable settings
+// taken from next, along with the env names of the other settings that
+// differ between them and are therefore ignored.
+func mergeReloadable(current, next *config.Config) (*config.Config, []string) {
+	merged := *current
+	merged.LogLevel = next.LogLevel
+	merged.LogPayloads = next.LogPayloads
+	merged.LogUnknownNotifications = next.LogUnknownNotifications
+	merged.CORSOrigin = next.CORSOrigin
+	merged.AllowedOrigins = next.AllowedOrigins
+	merged.OriginCheck = next.OriginCheck
+	merged.WebSocketPreflight = next.WebSocketPreflight
+	merged.SecurityHeaders = next.SecurityHeaders
+	merged.FrameOptions = next.FrameOptions
+	merged.ContentSecurityPolicy = next.ContentSecurityPolicy
+	merged.HSTSMaxAge = next.HSTSMaxAge
+	merged.GzipMinSize = next.GzipMinSize
+	merged.AdminToken = next.AdminToken
+	merged.RequireAuth = next.RequireAuth
+	merged.AuthExemptMethods = next.AuthExemptMethods
+	merged.RequireReconnectToken = next.RequireReconnectToken
+	merged.MaxConnections = next.MaxConnections
+	merged.MaxHandshakesPerIP = next.MaxHandshakesPerIP
+	merged.TrustedProxies = next.TrustedProxies
+	merged.ReconnectRetryAfter = next.ReconnectRetryAfter
+	merged.ConnectionLogSampleRate = next.ConnectionLogSampleRate
+	merged.WriteBatchLimit = next.WriteBatchLimit
+	merged.SlowClientTolerance = next.SlowClientTolerance
+	merged.RequestSequencing = next.RequestSequencing
+	merged.TrimTrailingNewline = next.TrimTrailingNewline
+	merged.SessionInfoMaxCodes = next.SessionInfoMaxCodes
+	merged.SessionDisconnectPolicy = next.SessionDisconnectPolicy
+	merged.NotificationDedupWindow = next.NotificationDedupWindow
+	merged.RPCMaxBodyBytes = next.RPCMaxBodyBytes
+	merged.RPCRequestTimeout = next.RPCRequestTimeout
+	merged.RPCAcceptGzip = next.RPCAcceptGzip
+	merged.RPCContentTypeMode = next.RPCContentTypeMode
+	merged.RPCRateLimit = next.RPCRateLimit
+	merged.RPCRateBurst = next.RPCRateBurst
+	merged.InstanceIDHeader = next.InstanceIDHeader
+	merged.MaintenanceMode = next.MaintenanceMode
+	merged.MaintenanceMessage = next.MaintenanceMessage
+	merged.MaxConcurrentHandlers = next.MaxConcurrentHandlers
+	merged.HandlerTimeout = next.HandlerTimeout
+	merged.MaxBatchSize = next.MaxBatchSize
+	merged.ConcurrencyPolicy = next.ConcurrencyPolicy
+	merged.SessionDataAllowedKeys = next.SessionDataAllowedKeys
+	merged.SessionReservedKeyPrefix = next.SessionReservedKeyPrefix
+
+	var ignored []string
+	mergedValue := reflect.ValueOf(merged)
+	nextValue := reflect.ValueOf(*next)
+	for i := 0; i < mergedValue.NumField(); i++ {
+		if !reflect.DeepEqual(mergedValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			ignored = append(ignored, mergedValue.Type().Field(i).Tag.Get("env"))
+		}
+	}
+	return &merged, ignored
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fle/server/internal/jsonrpc"
+)
+
+// ndjsonContentType is the media type clients send in Accept to receive
+// partial results as newline-delimited JSON ahead of the final response.
+const ndjsonContentType = "application/x-ndjson"
+
+// rpcContentTypes are the request media types POST /rpc accepts when
+// RPCContentTypeMode is strict.
+var rpcContentTypes = map[string]bool{
+	"application/json":     true,
+	"application/json-rpc": true,
+}
+
+// errRPCBodyTooLarge reports a POST /rpc body exceeding RPCMaxBodyBytes.
+var errRPCBodyTooLarge = errors.New("request body too large")
+
+// handleRPC serves JSON-RPC requests over plain HTTP at POST /rpc.
+// Bodies may be sent with Content-Encoding: gzip when RPCAcceptGzip is set;
+// the size limit applies to the decompressed body to guard against zip bombs.
+// Clients accepting application/x-ndjson receive partial results streamed
+// by the handler, one JSON line each, followed by the final response line.
+// Routing is bounded by RPCRequestTimeout; see routeWithDeadline.
+// In strict RPCContentTypeMode, bodies must be sent as application/json or
+// application/json-rpc, so that browsers cannot submit them from HTML forms.
+func (s *Server) handleRPC(w http.ResponseWriter, r *http.Request) {
+	principal, ok := s.authenticate(r)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, jsonrpc.Unauthorized, "Invalid token")
+		return
+	}
+
+	cfg := s.currentConfig()
+	if contentType := r.Header.Get("Content-Type"); !isRPCContentType(contentType) {
+		if strings.EqualFold(cfg.RPCContentTypeMode, "strict") {
+			s.logger.Debug("Rejecting RPC request with unsupported content type",
+				"content_type", contentType,
+				"remote_addr", r.RemoteAddr)
+			writeJSONError(w, http.StatusUnsupportedMediaType, jsonrpc.InvalidRequest, "Unsupported Content-Type")
+			return
+		}
+		s.logger.Debug("Accepting RPC request with unexpected content type",
+			"content_type", contentType,
+			"remote_addr", r.RemoteAddr)
+	}
+
+	limit := int64(cfg.RPCMaxBodyBytes)
+	var body io.Reader = http.MaxBytesReader(w, r.Body, limit)
+
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip":
+		if !cfg.RPCAcceptGzip {
+			writeJSONError(w, http.StatusUnsupportedMediaType, jsonrpc.InvalidRequest, "Unsupported Content-Encoding")
+			return
+		}
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, jsonrpc.InvalidRequest, "Invalid gzip body")
+			return
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		s.logger.Debug("Rejecting RPC request with unsupported encoding",
+			"encoding", encoding,
+			"remote_addr", r.RemoteAddr)
+		writeJSONError(w, http.StatusUnsupportedMediaType, jsonrpc.InvalidRequest, "Unsupported Content-Encoding")
+		return
+	}
+
+	data, err := readLimited(body, limit)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.Is(err, errRPCBodyTooLarge) || errors.As(err, &maxBytesErr) {
+			s.logger.Warn("Rejecting oversized RPC request body",
+				"limit_bytes", limit,
+				"remote_addr", r.RemoteAddr)
+			writeJSONError(w, http.StatusRequestEntityTooLarge, jsonrpc.InvalidRequest, "Request body too large")
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, jsonrpc.InvalidRequest, "Invalid request body")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), remoteIPContextKey{}, s.clientIP(r))
+	if principal != nil {
+		ctx = jsonrpc.WithPrincipal(ctx, principal)
+	}
+	if cfg.RPCRequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.RPCRequestTimeout)*time.Second)
+		defer cancel()
+	}
+
+	if flusher, ok := w.(http.Flusher); ok && acceptsNDJSON(r) {
+		s.streamRPC(ctx, w, flusher, data)
+		return
+	}
+
+	response, err := s.routeWithDeadline(ctx, data)
+	if err != nil {
+		s.logger.Error("Failed to route RPC request", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, jsonrpc.InternalError, "Internal server error")
+		return
+	}
+
+	// Notifications produce no response
+	if response == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
+
+// routeWithDeadline routes data, giving up when ctx is done before routing
+// finishes. The handler's context is canceled with ctx, but a handler that
+// ignores it keeps running in the background; the caller gets a JSON-RPC
+// request timeout error (or cancellation error) right away instead.
+func (s *Server) routeWithDeadline(ctx context.Context, data []byte) ([]byte, error) {
+	type routed struct {
+		response []byte
+		err      error
+	}
+	done := make(chan routed, 1)
+	go func() {
+		response, err := s.jsonrpcRouter.RouteJSON(ctx, data)
+		done <- routed{response, err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.response, result.err
+	case <-ctx.Done():
+	}
+
+	// Notifications get no response, even when they time out
+	var envelope struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if json.Unmarshal(data, &envelope) == nil && envelope.ID == nil {
+		return nil, nil
+	}
+
+	rpcErr := jsonrpc.NewErrorWithData(jsonrpc.RequestCancelled, jsonrpc.ErrRequestCancelled.Message, ctx.Err().Error())
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		rpcErr = jsonrpc.NewErrorWithData(jsonrpc.RequestTimeout, jsonrpc.ErrRequestTimeout.Message, ctx.Err().Error())
+		s.logger.Warn("RPC request exceeded its deadline",
+			"timeout_seconds", s.currentConfig().RPCRequestTimeout)
+	}
+	return json.Marshal(jsonrpc.NewErrorResponse(rpcErr, requestID(data)))
+}
+
+// requestID returns the "id" member of a JSON-RPC request, or nil if it has
+// none or cannot be parsed; routing reports any parse error.
+func requestID(data []byte) interface{} {
+	var envelope struct {
+		ID interface{} `json:"id"`
+	}
+	json.Unmarshal(data, &envelope)
+	return envelope.ID
+}
+
+// readLimited reads r fully, failing with errRPCBodyTooLarge once more than
+// limit bytes have been read.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errRPCBodyTooLarge
+	}
+	return data, nil
+}
+
+// rpcPartial is the NDJSON line written for each partial result.
+type rpcPartial struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      interface{} `json:"id,omitempty"`
+	Partial interface{} `json:"partial"`
+}
+
+// streamRPC routes data with a PartialSender that writes each partial result
+// as an NDJSON line, flushing it immediately, and ends the chunked response
+// with the final JSON-RPC response line.
+func (s *Server) streamRPC(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, data []byte) {
+	// Echo the request ID on partial lines
+	id := requestID(data)
+
+	var (
+		mu   sync.Mutex
+		done bool
+	)
+	w.Header().Set("Content-Type", ndjsonContentType)
+	ctx = jsonrpc.WithPartialSender(ctx, func(partial interface{}) error {
+		line, err := json.Marshal(rpcPartial{JSONRPC: jsonrpc.Version, ID: id, Partial: partial})
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			return errors.New("response already completed")
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+
+	response, err := s.routeWithDeadline(ctx, data)
+
+	mu.Lock()
+	defer mu.Unlock()
+	done = true
+
+	if err != nil {
+		s.logger.Error("Failed to route RPC request", "error", err)
+		return
+	}
+	if response != nil {
+		w.Write(append(response, '\n'))
+	}
+	flusher.Flush()
+}
+
+// isRPCContentType reports whether contentType, a Content-Type header value,
+// names one of rpcContentTypes. Parameters such as charset are ignored.
+func isRPCContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && rpcContentTypes[mediaType]
+}
+
+// acceptsNDJSON reports whether the request's Accept header lists
+// application/x-ndjson.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == ndjsonContentType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fle/server/internal/websocket"
+)
+
+// securityHeadersMiddleware adds the configured security headers to HTTP
+// responses when the SecurityHeaders setting is enabled. WebSocket upgrades
+// are left alone, and Strict-Transport-Security is only sent over TLS, as
+// browsers ignore it on plain HTTP.
+func (s *Server) securityHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.currentConfig()
+		if !cfg.SecurityHeaders || websocket.IsUpgradeRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", strings.ToUpper(cfg.FrameOptions))
+		if cfg.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+		}
+		if r.TLS != nil && cfg.HSTSMaxAge > 0 {
+			header.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(cfg.HSTSMaxAge))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -5,9 +5,14 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fle/server/internal/config"
@@ -20,8 +25,14 @@ import (
 // It encapsulates all server-related functionality including middleware,
 // routing, and lifecycle management.
 type Server struct {
-	// config holds the server configuration
-	config *config.Config
+	// config holds the current server configuration; Reload swaps it
+	config atomic.Pointer[config.Config]
+
+	// reloadMu serializes Reload calls and guards logLevel
+	reloadMu sync.Mutex
+
+	// logLevel, if set, controls the level of the server's logger on Reload
+	logLevel *slog.LevelVar
 
 	// httpServer is the underlying HTTP server instance
 	httpServer *http.Server
@@ -40,6 +51,32 @@ type Server struct {
 
 	// jsonrpcRouter handles JSON-RPC method routing
 	jsonrpcRouter *jsonrpc.Router
+
+	// readiness tracks overload for the /readyz endpoint
+	readiness readiness
+
+	// maintenance refuses new WebSocket connections while set; it starts from
+	// MaintenanceMode and can be toggled with admin.setMaintenanceMode
+	maintenance atomic.Bool
+
+	// stopping is set when Stop begins, refusing new WebSocket connections
+	// while existing ones are drained
+	stopping atomic.Bool
+
+	// rpcLimiter throttles POST /rpc per client IP when RPCRateLimit is set
+	rpcLimiter *rateLimiter
+
+	// lookupLimiter throttles session.validateCode and presence.check per
+	// caller; see allowSessionLookup
+	lookupLimiter *rateLimiter
+
+	// handshakes bounds concurrent WebSocket handshakes per client IP when
+	// MaxHandshakesPerIP is set
+	handshakes *handshakeLimiter
+
+	// trustedProxies are the proxies whose X-Forwarded-For headers clientIP
+	// honors; it is replaced on reload
+	trustedProxies atomic.Pointer[[]netip.Prefix]
 }
 
 // NewServer creates and configures a new Server instance.
@@ -54,6 +91,13 @@ type Server struct {
 //   - *Server: Configured server instance
 //   - error: Error if server creation fails
 func NewServer(cfg *config.Config, logger *slog.Logger) (*Server, error) {
+	return NewServerWithStore(cfg, logger, nil)
+}
+
+// NewServerWithStore is NewServer with sessions persisted to store behind the
+// in-memory session cache. While the store fails, SessionStoreFallback keeps
+// the server serving from the cache and /readyz reports "degraded".
+func NewServerWithStore(cfg *config.Config, logger *slog.Logger, store session.Store) (*Server, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("config cannot be nil")
 	}
@@ -62,24 +106,103 @@ func NewServer(cfg *config.Config, logger *slog.Logger) (*Server, error) {
 		return nil, fmt.Errorf("logger cannot be nil")
 	}
 
+	// Generate an instance ID unless one is configured
+	if cfg.InstanceID == "" {
+		withID := *cfg
+		withID.InstanceID = NewInstanceID()
+		cfg = &withID
+	}
+
 	// Create session manager
-	sessionManager := session.NewManager(session.DefaultSessionOptions())
+	sessionOptions := session.DefaultSessionOptions()
+	sessionOptions.CaseSensitive = cfg.SessionCaseSensitive
+	sessionOptions.CodePrefix = cfg.SessionCodePrefix
+	sessionOptions.MaxSessions = cfg.MaxSessions
+	sessionOptions.MaxSessionsPerOwner = cfg.MaxSessionsPerPrincipal
+	sessionOptions.MaxDataKeys = cfg.MaxSessionDataKeys
+	sessionOptions.MaxTotalDataBytes = cfg.MaxSessionDataBytes
+	sessionOptions.MaxReconnects = cfg.MaxSessionReconnects
+	sessionOptions.CleanupInterval = time.Duration(cfg.SessionCleanupInterval) * time.Second
+	sessionOptions.CleanupJitter = time.Duration(cfg.SessionCleanupJitter) * time.Second
+	sessionOptions.CleanupBatchSize = cfg.SessionCleanupBatch
+	sessionOptions.Store = store
+	sessionOptions.StoreFallback = cfg.SessionStoreFallback
+	sessionOptions.StoreRetryInterval = time.Duration(cfg.SessionStoreRetry) * time.Second
+	ownerPolicy, err := session.ParseOwnerLimitPolicy(cfg.PrincipalSessionPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid principal session policy: %w", err)
+	}
+	sessionOptions.OwnerLimitPolicy = ownerPolicy
+	sessionManager := session.NewManager(sessionOptions)
 
-	// Create WebSocket hub
+	// Create WebSocket hub keyed by the same session code normalization as the manager
 	hub := websocket.NewHub(logger)
+	hub.SetCodeNormalizer(sessionManager.NormalizeCode)
+	pendingPolicy, err := websocket.ParseOverflowPolicy(cfg.PendingMessagePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pending message policy: %w", err)
+	}
+	hub.SetPendingLimit(cfg.PendingMessageLimit, pendingPolicy)
+	hub.SetPendingMaxAge(time.Duration(cfg.PendingMessageMaxAge) * time.Second)
+	hub.SetStrictOrdering(cfg.StrictSessionOrdering)
+	shutdownPolicy, err := websocket.ParseShutdownBroadcastPolicy(cfg.ShutdownBroadcastPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shutdown broadcast policy: %w", err)
+	}
+	hub.SetShutdownBroadcastPolicy(shutdownPolicy)
+	hub.SetBroadcastQueueSize(cfg.BroadcastQueueSize)
 
-	// Create JSON-RPC router
+	// Create JSON-RPC router; admin methods require the admin role
 	jsonrpcRouter := jsonrpc.NewRouter()
+	jsonrpcRouter.SetAuthorizer(adminAuthorizer)
+	jsonrpcRouter.SetDeprecationLogger(logger)
+	methodNames, err := jsonrpc.ParseMethodNameNormalization(cfg.MethodNameNormalization)
+	if err != nil {
+		return nil, fmt.Errorf("invalid method name normalization: %w", err)
+	}
+	jsonrpcRouter.SetMethodNameNormalization(methodNames)
+	trailingData, err := jsonrpc.ParseTrailingDataPolicy(cfg.JSONRPCTrailingData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON-RPC trailing data policy: %w", err)
+	}
+	jsonrpcRouter.SetTrailingDataPolicy(trailingData)
+	ackStyle, err := jsonrpc.ParseAckStyle(cfg.JSONRPCAckResponse)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON-RPC ack response: %w", err)
+	}
+	jsonrpcRouter.SetAckStyle(ackStyle)
 
 	// Create the server instance
 	server := &Server{
-		config:         cfg,
 		router:         http.NewServeMux(),
 		logger:         logger,
 		hub:            hub,
 		sessionManager: sessionManager,
 		jsonrpcRouter:  jsonrpcRouter,
+		rpcLimiter:     newRateLimiter(),
+		lookupLimiter:  newRateLimiter(),
+		handshakes:     newHandshakeLimiter(),
 	}
+	server.config.Store(cfg)
+
+	// Notify connected clients when the client-visible keys of their session data change
+	sessionManager.SetUpdateCallback(func(code string, keys []string) {
+		notifySessionUpdated(hub, logger, code, server.visibleSessionKeys(keys))
+	})
+
+	// Record on the session when its last connection closes
+	hub.SetDetachCallback(server.sessionDetached)
+
+	// Buffer messages only for existing sessions, and drop a session's buffer
+	// once it is deleted or expires
+	hub.SetSessionChecker(func(code string) bool {
+		_, err := sessionManager.PeekSession(code)
+		return err == nil
+	})
+	sessionManager.SetDeleteCallback(hub.ForgetSession)
+
+	// Apply the settings that Reload may change later
+	server.applyReloadableSettings(cfg)
 
 	// Set up routes
 	server.setupRoutes()
@@ -103,6 +226,7 @@ func NewServer(cfg *config.Config, logger *slog.Logger) (*Server, error) {
 		"address", cfg.Address(),
 		"environment", cfg.Environment,
 		"cors_origin", cfg.CORSOrigin,
+		"strict_origin_check", cfg.StrictOriginCheck(),
 	)
 
 	return server, nil
@@ -114,11 +238,21 @@ func (s *Server) setupRoutes() {
 	// Health check endpoint
 	s.router.HandleFunc("GET /health", s.handleHealth)
 
+	// Readiness endpoint; fails while shutting down or overloaded
+	s.router.HandleFunc("GET /readyz", s.handleReady)
+
 	// WebSocket endpoint
 	s.router.HandleFunc("GET /ws", s.handleWebSocket)
+	s.router.HandleFunc("OPTIONS /ws", s.handleWebSocketPreflight)
+
+	// JSON-RPC over plain HTTP, optionally gzip-encoded
+	s.router.HandleFunc("POST /rpc", s.rateLimitRPC(s.handleRPC))
+
+	// JSON-RPC method introspection, guarded by METHOD_INTROSPECTION
+	s.router.HandleFunc("GET /rpc/methods", s.handleMethods)
 
 	s.logger.Debug("Routes configured",
-		"routes", []string{"/health", "/ws"},
+		"routes", []string{"/health", "/readyz", "/ws", "/rpc", "/rpc/methods"},
 	)
 }
 
@@ -132,22 +266,103 @@ func (s *Server) setupJSONRPCMethods() {
 	
 	// Register get session info method
 	s.jsonrpcRouter.RegisterSimpleMethod("getSessionInfo", s.handleGetSessionInfo, "Get information about the current WebSocket session")
+
+	// Register server status method
+	s.jsonrpcRouter.RegisterSimpleMethod("server.status", s.handleServerStatus, "Report current and maximum session and connection counts")
+
+	// Register room membership methods
+	s.jsonrpcRouter.RegisterMethodWithValidation("room.join", s.handleRoomJoin, roomParamsSchema, nil, "Join a room by name")
+	s.jsonrpcRouter.RegisterMethodWithValidation("room.leave", s.handleRoomLeave, roomParamsSchema, nil, "Leave a room by name")
+	s.jsonrpcRouter.RegisterSimpleMethod("room.list", s.handleRoomList, "List the rooms the current connection belongs to")
+
+	// Register connection statistics for the caller
+	s.jsonrpcRouter.RegisterSimpleMethod("connection.stats", s.handleConnectionStats, "Report the caller's message counts, connection duration, last pong and send queue depth")
+
+	// Register method introspection, guarded by METHOD_INTROSPECTION
+	s.jsonrpcRouter.RegisterSimpleMethod("rpc.listMethods", s.handleListMethods, "List registered methods with their descriptions")
+
+	// Register reconnect token rotation
+	s.jsonrpcRouter.RegisterSimpleMethod("session.get", s.handleSessionGet, "Return the caller's session data, limited to the keys clients may see")
+	s.jsonrpcRouter.RegisterSimpleMethod("session.rotateToken", s.handleSessionRotateToken, "Replace the caller's reconnect token and return the new one")
+	s.jsonrpcRouter.RegisterSimpleMethod("session.rekey", s.handleSessionRekey, "Move the caller's session, with its data, to a new code and return it")
+	jsonrpc.RegisterTyped(s.jsonrpcRouter, "session.validateCode", s.handleSessionValidateCode, "Report whether a session code is well-formed and belongs to a live session")
+
+	// Register presence methods
+	s.jsonrpcRouter.RegisterMethodWithValidation("presence.check", s.handlePresenceCheck, presenceCheckParamsSchema, nil, "Check whether a session is connected and when it was last seen")
+
+	// Register event subscription methods
+	s.jsonrpcRouter.RegisterMethodWithValidation("events.subscribe", s.handleEventsSubscribe, eventParamsSchema, nil, "Receive only the listed notification event types")
+	s.jsonrpcRouter.RegisterMethodWithValidation("events.unsubscribe", s.handleEventsUnsubscribe, eventParamsSchema, nil, "Stop receiving the listed notification event types")
+
+	// Register admin methods (guarded by the admin authorizer)
+	s.jsonrpcRouter.RegisterMethodWithValidation("admin.getSession", s.handleAdminGetSession, adminGetSessionParamsSchema, nil, "Look up a session's metadata and connection status by code")
+	s.jsonrpcRouter.RegisterMethodWithValidation("admin.listSessions", s.handleAdminListSessions, adminListSessionsParamsSchema, nil, "List live sessions a page at a time (cursor, limit)")
+	s.jsonrpcRouter.RegisterMethodWithValidation("admin.disconnect", s.handleAdminDisconnect, adminDisconnectParamsSchema, nil, "Close a session's connections and optionally delete the session")
+	s.jsonrpcRouter.RegisterSimpleMethod("admin.getLogLevel", s.handleAdminGetLogLevel, "Report the server's current log level")
+	s.jsonrpcRouter.RegisterMethodWithValidation("admin.setLogLevel", s.handleAdminSetLogLevel, adminSetLogLevelParamsSchema, nil, "Change the server's log level (debug, info, warn or error) until the next reload")
+	s.jsonrpcRouter.RegisterMethodWithValidation("admin.setMaintenanceMode", s.handleAdminSetMaintenanceMode, adminSetMaintenanceModeParamsSchema, nil, "Turn maintenance mode, which refuses new WebSocket connections, on or off until the next reload")
+	s.jsonrpcRouter.RegisterSimpleMethod("admin.metrics", s.handleAdminMetrics, "Report router call counts and hub connection and message metrics")
+	s.jsonrpcRouter.RegisterSimpleMethod("admin.cleanup", s.handleAdminCleanup, "Remove expired sessions now and report how many were removed")
+
+	// Register debugging methods in development only
+	if s.currentConfig().IsDevelopment() {
+		s.jsonrpcRouter.RegisterSimpleMethod("debug.validationTags", s.handleDebugValidationTags, "List the validation tags usable in parameter schemas")
+		s.jsonrpcRouter.RegisterSimpleMethod("debug.errorCodes", s.handleDebugErrorCodes, "List the JSON-RPC error codes, their names and the reserved code ranges")
+	}
+
+	// Keep only the methods named in the ENABLED_METHODS manifest, if any
+	s.applyMethodManifest(s.currentConfig().EnabledMethodList())
 	
 	s.logger.Debug("JSON-RPC methods registered", 
 		"methodCount", s.jsonrpcRouter.MethodCount(),
 		"methods", s.jsonrpcRouter.GetMethods())
 }
 
+// applyMethodManifest unregisters every method not listed in enabled. An
+// empty manifest leaves all methods registered. Listed names that match no
+// registered method are logged, as they are most likely typos.
+func (s *Server) applyMethodManifest(enabled []string) {
+	if len(enabled) == 0 {
+		return
+	}
+
+	// Match by registration rather than by name, so listed names are
+	// normalized the same way calls are
+	keep := make(map[*jsonrpc.MethodInfo]bool, len(enabled))
+	for _, method := range enabled {
+		info, err := s.jsonrpcRouter.GetMethodInfo(method)
+		if err != nil {
+			s.logger.Warn("ENABLED_METHODS lists an unknown method", "method", method)
+			continue
+		}
+		keep[info] = true
+	}
+	for _, method := range s.jsonrpcRouter.GetMethods() {
+		if info, err := s.jsonrpcRouter.GetMethodInfo(method); err == nil && !keep[info] {
+			s.jsonrpcRouter.UnregisterMethod(method)
+		}
+	}
+}
+
 // setupMiddleware configures and chains all HTTP middleware.
-// This includes CORS, logging, and any other cross-cutting concerns.
+// This includes CORS, security headers, logging, and any other cross-cutting concerns.
 func (s *Server) setupMiddleware() http.Handler {
 	var handler http.Handler = s.router
 
+	// Compress large responses for clients that accept gzip
+	handler = s.gzipMiddleware(handler)
+
 	// Apply CORS middleware for development
-	if s.config.IsDevelopment() {
+	if s.currentConfig().IsDevelopment() {
 		handler = s.corsMiddleware(handler)
 	}
 
+	// Identify the instance in responses when enabled
+	handler = s.instanceIDMiddleware(handler)
+
+	// Add security headers to non-WebSocket responses when enabled
+	handler = s.securityHeadersMiddleware(handler)
+
 	// Apply logging middleware
 	handler = s.loggingMiddleware(handler)
 
@@ -155,50 +370,145 @@ func (s *Server) setupMiddleware() http.Handler {
 }
 
 // Start begins listening for HTTP requests on the configured address.
-// This method blocks until the server is stopped or encounters an error.
+// Once the listener is bound it logs the ready line (ReadyLogMessage) with
+// the bound address. This method blocks until the server is stopped or
+// encounters an error.
 //
 // Returns:
 //   - error: Error if server fails to start or encounters issues while running
 func (s *Server) Start() error {
 	s.logger.Info("Starting HTTP server",
-		"address", s.config.Address(),
-		"environment", s.config.Environment,
+		"address", s.currentConfig().Address(),
+		"environment", s.currentConfig().Environment,
 	)
 
-	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	// Bind before serving so the ready line is only logged once connections
+	// are actually accepted, and carries the bound address (e.g. for port 0)
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
 		return fmt.Errorf("server failed to start: %w", err)
 	}
 
+	cfg := s.currentConfig()
+	s.logger.Info(cfg.ReadyLogMessage,
+		"address", listener.Addr().String(),
+		"environment", cfg.Environment,
+		"version", Version,
+		"features", enabledFeatures(cfg),
+	)
+
+	if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("server failed: %w", err)
+	}
+
 	return nil
 }
 
-// Stop gracefully shuts down the HTTP server.
-// It waits for existing connections to close within the provided context timeout.
+// Stop gracefully shuts down the server in phases, so that work already
+// accepted is finished rather than cut off:
+//
+//  1. New WebSocket upgrades are refused.
+//  2. Connected clients are sent a "server.shutdown" notification and
+//     drained: their in-flight requests are answered and the messages queued
+//     for them, welcome messages included, are written.
+//  3. The HTTP server stops accepting requests and waits for in-flight
+//     POST /rpc handlers.
+//  4. The hub closes the remaining clients, then the session manager is closed.
+//
+// All phases share ctx's deadline. A phase that runs out of time is logged
+// and the later phases still run, so connections and sessions are always
+// released.
 //
 // Parameters:
 //   - ctx: Context with timeout for graceful shutdown
 //
 // Returns:
-//   - error: Error if shutdown fails
+//   - error: Error if a phase did not complete in time or shutdown fails
 func (s *Server) Stop(ctx context.Context) error {
 	s.logger.Info("Shutting down HTTP server")
+	var stopErr error
 
-	// Close session manager
-	if s.sessionManager != nil {
-		s.sessionManager.Close()
+	s.logger.Info("Shutdown: refusing new WebSocket connections")
+	s.stopping.Store(true)
+
+	if s.hub != nil {
+		s.logger.Info("Shutdown: draining WebSocket clients")
+		if err := s.hub.Drain(ctx, s.shutdownNotice()); err != nil {
+			s.logger.Warn("WebSocket clients not drained before the deadline", "error", err)
+			stopErr = fmt.Errorf("websocket drain failed: %w", err)
+		}
+	}
+
+	s.logger.Info("Shutdown: draining in-flight HTTP requests")
+	if err := s.httpServer.Shutdown(ctx); err != nil && stopErr == nil {
+		stopErr = fmt.Errorf("server shutdown failed: %w", err)
 	}
 
-	if err := s.httpServer.Shutdown(ctx); err != nil {
-		return fmt.Errorf("server shutdown failed: %w", err)
+	// Close WebSocket clients with a reconnect hint; hijacked connections
+	// are not tracked by http.Server.Shutdown
+	s.logger.Info("Shutdown: closing WebSocket hub and session manager")
+	if s.hub != nil {
+		s.hub.Shutdown()
+	}
+	if s.sessionManager != nil {
+		if err := s.sessionManager.Close(); err != nil {
+			s.logger.Warn("Session changes not written to the store", "error", err)
+		}
 	}
 
+	if stopErr != nil {
+		return stopErr
+	}
 	s.logger.Info("HTTP server stopped successfully")
 	return nil
 }
 
+// shuttingDown reports whether Stop has begun, in which case new WebSocket
+// connections are refused.
+func (s *Server) shuttingDown() bool {
+	return s.stopping.Load() || s.hub.ShuttingDown()
+}
+
+// shutdownNotice returns the "server.shutdown" notification sent to clients
+// when Stop begins, suggesting when to reconnect, or nil if it cannot be
+// built.
+func (s *Server) shutdownNotice() []byte {
+	notification, err := jsonrpc.NewNotification("server.shutdown", map[string]interface{}{
+		"retry_after": s.currentConfig().ReconnectRetryAfter,
+	})
+	if err != nil {
+		s.logger.Error("Failed to build server.shutdown notification", "error", err)
+		return nil
+	}
+	notice, err := json.Marshal(notification)
+	if err != nil {
+		s.logger.Error("Failed to marshal server.shutdown notification", "error", err)
+		return nil
+	}
+	return notice
+}
+
+// SessionManager returns the server's session manager, for embedding
+// applications and tests that need to manipulate sessions directly.
+func (s *Server) SessionManager() *session.Manager {
+	return s.sessionManager
+}
+
+// JSONRPCRouter returns the server's JSON-RPC router, for embedding
+// applications and tests that register their own methods.
+func (s *Server) JSONRPCRouter() *jsonrpc.Router {
+	return s.jsonrpcRouter
+}
+
+// Hub returns the server's WebSocket hub, for embedding applications and
+// tests that inspect connections.
+func (s *Server) Hub() *websocket.Hub {
+	return s.hub
+}
+
 // Address returns the complete server address.
 func (s *Server) Address() string {
-	return s.config.Address()
+	return s.currentConfig().Address()
 }
 
 // IsRunning returns true if the server is currently running.
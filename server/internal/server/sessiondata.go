@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fle/server/internal/websocket"
+)
+
+// SessionGetResult is the result of the session.get method.
+type SessionGetResult struct {
+	// Code is the caller's session code
+	Code string `json:"code"`
+
+	// Data holds the session data keys clients may see
+	Data map[string]interface{} `json:"data"`
+}
+
+// sessionKeyFilter returns a function reporting whether a session Data key
+// may be returned to clients: it must not start with the reserved prefix
+// and, when an allowlist is configured, must be on it.
+func (s *Server) sessionKeyFilter() func(key string) bool {
+	cfg := s.currentConfig()
+	reservedPrefix := cfg.SessionReservedKeyPrefix
+
+	var allowed map[string]bool
+	if keys := cfg.SessionDataAllowedKeyList(); len(keys) > 0 {
+		allowed = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			allowed[key] = true
+		}
+	}
+
+	return func(key string) bool {
+		if reservedPrefix != "" && strings.HasPrefix(key, reservedPrefix) {
+			return false
+		}
+		return allowed == nil || allowed[key]
+	}
+}
+
+// visibleSessionData returns a copy of data holding only the keys clients
+// may see. The session keeps its full data server-side.
+func (s *Server) visibleSessionData(data map[string]interface{}) map[string]interface{} {
+	visible := s.sessionKeyFilter()
+	projected := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if visible(key) {
+			projected[key] = value
+		}
+	}
+	return projected
+}
+
+// visibleSessionKeys filters keys down to those clients may see, so
+// session.updated notifications do not reveal internal key names.
+func (s *Server) visibleSessionKeys(keys []string) []string {
+	visible := s.sessionKeyFilter()
+	filtered := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if visible(key) {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered
+}
+
+// handleSessionGet handles the "session.get" JSON-RPC method.
+// It returns the caller's session data, projected to the keys clients may
+// see, without refreshing the session's expiry.
+func (s *Server) handleSessionGet(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	client, ok := websocket.ClientFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("session.get requires a WebSocket connection")
+	}
+
+	sess, err := s.sessionManager.PeekSession(client.SessionCode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up session: %w", err)
+	}
+
+	return SessionGetResult{
+		Code: sess.Code,
+		Data: s.visibleSessionData(sess.Data),
+	}, nil
+}
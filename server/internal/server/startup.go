@@ -0,0 +1,43 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/fle/server/internal/config"
+)
+
+// Version identifies the server build in health checks and the ready log
+// line. Release builds set it with
+// -ldflags "-X github.com/fle/server/internal/server.Version=<version>".
+var Version = "1.0.0"
+
+// enabledFeatures lists the optional behaviors switched on in cfg, for the
+// ready log line.
+func enabledFeatures(cfg *config.Config) []string {
+	features := []string{}
+	add := func(enabled bool, name string) {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+
+	add(cfg.StrictOriginCheck(), "strict_origin_check")
+	add(cfg.AdminToken != "", "admin_access")
+	add(cfg.RequireAuth, "require_auth")
+	add(cfg.RequireReconnectToken, "require_reconnect_token")
+	add(!strings.EqualFold(cfg.MethodIntrospection, "off"), "method_introspection")
+	add(cfg.StrictSessionOrdering, "strict_session_ordering")
+	add(cfg.PendingMessageLimit > 0, "pending_messages")
+	add(cfg.NotificationDedupWindow > 0, "notification_dedup")
+	add(cfg.RPCAcceptGzip, "rpc_gzip")
+	add(cfg.RPCRateLimit > 0, "rpc_rate_limit")
+	add(cfg.MaxConcurrentHandlers > 0, "max_concurrent_handlers")
+	add(cfg.HandlerTimeout > 0, "handler_timeout")
+	add(cfg.MaxBatchSize > 0, "max_batch_size")
+	add(cfg.LogPayloads, "log_payloads")
+	add(cfg.LogUnknownNotifications, "log_unknown_notifications")
+	add(cfg.SecurityHeaders, "security_headers")
+	add(cfg.MaintenanceMode, "maintenance_mode")
+	add(cfg.RequestSequencing, "request_sequencing")
+	return features
+}
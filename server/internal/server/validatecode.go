@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fle/server/internal/jsonrpc"
+	"github.com/fle/server/internal/session"
+)
+
+// ValidateCodeParams holds the parameters for the session.validateCode method.
+type ValidateCodeParams struct {
+	// Code is the session code to validate; it need not be well-formed
+	Code string `json:"code" validate:"required"`
+}
+
+// ValidateCodeResult is the result of the session.validateCode method. Like
+// PresenceResult it carries no session data or metadata.
+type ValidateCodeResult struct {
+	// WellFormed reports whether the code follows the session code format
+	WellFormed bool `json:"well_formed"`
+
+	// Exists reports whether a live, unexpired session has the code. It is
+	// only included for authenticated callers of well-formed codes
+	Exists *bool `json:"exists,omitempty"`
+}
+
+// handleSessionValidateCode handles the "session.validateCode" JSON-RPC
+// method. It lets clients check a typed code before connecting with it,
+// without creating a session or refreshing an existing one's expiry. Only
+// authenticated callers learn whether the code exists, and their lookups
+// are throttled, so the method cannot be used to enumerate session codes.
+func (s *Server) handleSessionValidateCode(ctx context.Context, p ValidateCodeParams) (ValidateCodeResult, error) {
+	if err := s.jsonrpcRouter.Validator().ValidateSessionCode(p.Code); err != nil {
+		return ValidateCodeResult{}, nil
+	}
+	if principal, _ := jsonrpc.PrincipalFromContext(ctx); principal == nil {
+		return ValidateCodeResult{WellFormed: s.sessionManager.IsValidFormat(p.Code)}, nil
+	}
+	if !s.allowSessionLookup(ctx) {
+		return ValidateCodeResult{}, errSessionLookupThrottled
+	}
+
+	exists := true
+	_, err := s.sessionManager.PeekSession(p.Code)
+	switch {
+	case err == nil:
+		return ValidateCodeResult{WellFormed: true, Exists: &exists}, nil
+	case errors.Is(err, session.ErrInvalidSessionCode):
+		// The manager's format can be stricter, e.g. with case-sensitive codes
+		return ValidateCodeResult{}, nil
+	case errors.Is(err, session.ErrSessionNotFound) || errors.Is(err, session.ErrSessionExpired):
+		exists = false
+		return ValidateCodeResult{WellFormed: true, Exists: &exists}, nil
+	default:
+		return ValidateCodeResult{}, fmt.Errorf("failed to look up session: %w", err)
+	}
+}
@@ -2,54 +2,114 @@
 package session
 
 import (
+	cryptorand "crypto/rand"
 	"fmt"
-	"math/rand"
+	"math/rand/v2"
 	"strings"
 	"sync"
-	"time"
-
-	"github.com/dustinkirkland/golang-petname"
 )
 
+// CodePrefixSeparator separates a session code's namespace prefix from the
+// code itself, as in "prod:happy-panda-42".
+const CodePrefixSeparator = ":"
+
 // Generator provides session code generation functionality.
 type Generator struct {
-	rng *rand.Rand
-	mu  sync.Mutex // Protects the random number generator for thread safety
+	rng           *rand.Rand
+	mu            sync.Mutex // Protects the random number generator for thread safety
+	caseSensitive bool       // Disables lowercasing during normalization and validation
+	prefix        string     // Namespaces generated codes; empty for unprefixed codes
 }
 
-// NewGenerator creates a new session code generator.
+// NewGenerator creates a new session code generator drawing its randomness
+// from a ChaCha8 generator seeded by crypto/rand.
 func NewGenerator() *Generator {
+	var seed [32]byte
+	cryptorand.Read(seed[:])
+	return NewGeneratorWithRand(rand.NewChaCha8(seed))
+}
+
+// NewGeneratorWithRand creates a session code generator that selects words
+// and numbers using src, e.g. a seeded source for reproducible tests or a
+// stronger generator. src is only used under the generator's lock, so it
+// need not be safe for concurrent use.
+func NewGeneratorWithRand(src rand.Source) *Generator {
 	return &Generator{
-		rng: rand.New(rand.NewSource(time.Now().UnixNano())),
+		rng: rand.New(src),
 	}
 }
 
+// SetCaseSensitive controls whether session codes are treated as case-sensitive.
+// When enabled, NormalizeCode and IsValidFormat only trim whitespace so that
+// "HAPPY-PANDA-42" and "happy-panda-42" identify different sessions.
+// This should be configured before the generator is used concurrently.
+func (g *Generator) SetCaseSensitive(caseSensitive bool) {
+	g.caseSensitive = caseSensitive
+}
+
+// IsCaseSensitive returns true if session codes are treated as case-sensitive.
+func (g *Generator) IsCaseSensitive() bool {
+	return g.caseSensitive
+}
+
+// SetPrefix namespaces session codes, e.g. per environment or tenant so that
+// several deployments can share a store: generated codes take the form
+// "prefix:adjective-noun-number", only codes with this prefix are valid, and
+// NormalizeCode adds it to unprefixed codes. An empty prefix, the default,
+// leaves codes unprefixed. This should be configured before the generator is
+// used concurrently.
+func (g *Generator) SetPrefix(prefix string) {
+	g.prefix = strings.TrimSpace(prefix)
+}
+
+// Prefix returns the namespace prefix of session codes, or "" if none.
+func (g *Generator) Prefix() string {
+	return g.prefix
+}
+
 // GenerateCode generates a human-friendly session code in the format "adjective-noun-number".
 // The number suffix is between 1-99.
 // Example: "happy-panda-42", "blue-river-7"
+// With a prefix set the code is namespaced, e.g. "prod:happy-panda-42".
 // This method is thread-safe.
 func (g *Generator) GenerateCode() string {
-	// Generate adjective-noun using golang-petname with 2 words
-	petName := petname.Generate(2, "-")
-
-	// Add number suffix (1-99) - protect access to random number generator
+	// Pick adjective, noun and number suffix (1-99) - protect access to random number generator
 	g.mu.Lock()
-	number := g.rng.Intn(99) + 1
+	adjective := codeAdjectives[g.rng.IntN(len(codeAdjectives))]
+	noun := codeNouns[g.rng.IntN(len(codeNouns))]
+	number := g.rng.IntN(99) + 1
 	g.mu.Unlock()
 
-	return fmt.Sprintf("%s-%d", petName, number)
+	code := fmt.Sprintf("%s-%s-%d", adjective, noun, number)
+	if g.prefix != "" {
+		code = g.prefix + CodePrefixSeparator + code
+	}
+	return code
 }
 
 // IsValidFormat validates that a session code follows the expected format.
-// It checks for the pattern: adjective-noun-number
-// The validation is case-insensitive.
+// It checks for the pattern: adjective-noun-number, preceded by the prefix
+// when one is set; codes with a different prefix are invalid.
+// The validation is case-insensitive unless the generator is case-sensitive.
 func (g *Generator) IsValidFormat(code string) bool {
 	if code == "" {
 		return false
 	}
 
-	// Convert to lowercase for case-insensitive validation
-	normalized := strings.ToLower(strings.TrimSpace(code))
+	// Normalize for validation (lowercased unless case-sensitive)
+	normalized := g.NormalizeCode(code)
+	if g.prefix != "" {
+		var ok bool
+		prefix := NormalizeCode(g.prefix, g.caseSensitive) + CodePrefixSeparator
+		if normalized, ok = strings.CutPrefix(normalized, prefix); !ok {
+			return false
+		}
+	}
+
+	// Any other prefix belongs to another namespace
+	if strings.Contains(normalized, CodePrefixSeparator) {
+		return false
+	}
 
 	// Split by dashes
 	parts := strings.Split(normalized, "-")
@@ -86,8 +146,25 @@ func (g *Generator) IsValidFormat(code string) bool {
 	return true
 }
 
-// NormalizeCode converts a session code to lowercase for consistent comparison.
-// This supports case-insensitive session code handling.
+// NormalizeCode trims surrounding whitespace from a session code and, unless the
+// generator is case-sensitive, converts it to lowercase for consistent comparison.
+// With a prefix set, a non-empty code without one is given it, so users may
+// type the short form of a code.
 func (g *Generator) NormalizeCode(code string) string {
-	return strings.ToLower(strings.TrimSpace(code))
+	code = NormalizeCode(code, g.caseSensitive)
+	if g.prefix != "" && code != "" && !strings.Contains(code, CodePrefixSeparator) {
+		code = NormalizeCode(g.prefix, g.caseSensitive) + CodePrefixSeparator + code
+	}
+	return code
+}
+
+// NormalizeCode applies the session code normalization rules outside of a Generator,
+// so that other components (such as the WebSocket hub) can key by the same form.
+// Codes are always trimmed and are lowercased unless caseSensitive is true.
+func NormalizeCode(code string, caseSensitive bool) string {
+	code = strings.TrimSpace(code)
+	if caseSensitive {
+		return code
+	}
+	return strings.ToLower(code)
 }
@@ -2,6 +2,7 @@ package session
 
 import (
 	"fmt"
+	"math/rand/v2"
 	"strconv"
 	"strings"
 	"sync"
@@ -34,6 +35,31 @@ func TestGenerateCode(t *testing.T) {
 	t.Logf("Generated code: %s", code)
 }
 
+func TestNewGeneratorWithRandDeterministic(t *testing.T) {
+	generator := NewGeneratorWithRand(rand.NewPCG(1, 2))
+	other := NewGeneratorWithRand(rand.NewPCG(1, 2))
+
+	// Mirror the generator's selection with an identically seeded source
+	expected := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 20; i++ {
+		want := fmt.Sprintf("%s-%s-%d",
+			codeAdjectives[expected.IntN(len(codeAdjectives))],
+			codeNouns[expected.IntN(len(codeNouns))],
+			expected.IntN(99)+1)
+
+		code := generator.GenerateCode()
+		if code != want {
+			t.Fatalf("code %d: expected %s, got %s", i, want, code)
+		}
+		if otherCode := other.GenerateCode(); otherCode != code {
+			t.Fatalf("code %d: identically seeded generators diverged: %s vs %s", i, code, otherCode)
+		}
+		if !generator.IsValidFormat(code) {
+			t.Fatalf("generated code %s should be valid", code)
+		}
+	}
+}
+
 func TestIsValidFormat(t *testing.T) {
 	generator := NewGenerator()
 
@@ -108,6 +134,26 @@ func TestGenerateUniquenessProbability(t *testing.T) {
 	t.Logf("Generated %d unique codes out of 100", len(codes))
 }
 
+func TestCodeWordLists(t *testing.T) {
+	// Shrinking the lists makes codes easier to guess
+	if keyspace := len(codeAdjectives) * len(codeNouns) * 99; keyspace < 20_000_000 {
+		t.Errorf("Expected a keyspace of at least 20 million codes, got %d", keyspace)
+	}
+
+	for _, list := range [][]string{codeAdjectives[:], codeNouns[:]} {
+		seen := make(map[string]bool)
+		for _, word := range list {
+			if seen[word] {
+				t.Errorf("Duplicate word %q", word)
+			}
+			seen[word] = true
+			if strings.Trim(word, "abcdefghijklmnopqrstuvwxyz") != "" || word == "" {
+				t.Errorf("Word %q should be lowercase ASCII letters", word)
+			}
+		}
+	}
+}
+
 func TestGenerateCodeConcurrency(t *testing.T) {
 	generator := NewGenerator()
 	const numGoroutines = 50
@@ -333,3 +379,72 @@ func TestGenerateCodeFormat(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizeCodeCaseSensitive(t *testing.T) {
+	generator := NewGenerator()
+	generator.SetCaseSensitive(true)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"HAPPY-PANDA-42", "HAPPY-PANDA-42"},
+		{"Happy-Panda-42", "Happy-Panda-42"},
+		{"  happy-panda-42  ", "happy-panda-42"},
+	}
+
+	for _, tt := range tests {
+		result := generator.NormalizeCode(tt.input)
+		if result != tt.expected {
+			t.Errorf("NormalizeCode(%s) = %s, expected %s", tt.input, result, tt.expected)
+		}
+	}
+
+	if !generator.IsValidFormat("HAPPY-PANDA-42") {
+		t.Error("Upper-case code should still be a valid format when case-sensitive")
+	}
+}
+
+func TestGeneratorPrefix(t *testing.T) {
+	generator := NewGenerator()
+	generator.SetPrefix("Prod")
+
+	code := generator.GenerateCode()
+	if !strings.HasPrefix(code, "Prod:") {
+		t.Fatalf("GenerateCode() = %q, expected the Prod: prefix", code)
+	}
+	if !generator.IsValidFormat(code) {
+		t.Errorf("Generated code %q should be valid", code)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"happy-panda-42", "prod:happy-panda-42"},
+		{" PROD:Happy-Panda-42 ", "prod:happy-panda-42"},
+		{"dev:happy-panda-42", "dev:happy-panda-42"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if result := generator.NormalizeCode(tt.input); result != tt.expected {
+			t.Errorf("NormalizeCode(%q) = %q, expected %q", tt.input, result, tt.expected)
+		}
+	}
+
+	// The short form is valid as it normalizes to the configured prefix;
+	// another namespace is not
+	if !generator.IsValidFormat("happy-panda-42") {
+		t.Error("Unprefixed code should be valid once normalized")
+	}
+	for _, code := range []string{"dev:happy-panda-42", "prod:dev:happy-panda-42", "prod:happy-panda"} {
+		if generator.IsValidFormat(code) {
+			t.Errorf("IsValidFormat(%q) should be false", code)
+		}
+	}
+
+	// Without a prefix, prefixed codes belong to another namespace
+	if NewGenerator().IsValidFormat("prod:happy-panda-42") {
+		t.Error("Prefixed code should be invalid for an unprefixed generator")
+	}
+}
@@ -0,0 +1,89 @@
+// Package session provides session management and code generation functionality.
+package session
+
+import (
+	"context"
+	"fmt"
+)
+
+// idempotencyReservation tracks the session creation owning an idempotency key.
+type idempotencyReservation struct {
+	// done is closed once the owning creation has finished
+	done chan struct{}
+
+	// code is the created session's code; empty while pending. It is written
+	// under the manager mutex before done is closed.
+	code string
+}
+
+// createSessionIdempotent creates a session for options.IdempotencyKey, or
+// returns the one already created for it. The key is reserved under the
+// manager lock before a code is generated, so concurrent calls with the same
+// key wait for a single creation instead of racing. A failed creation
+// releases the key and waiting callers retry.
+func (m *Manager) createSessionIdempotent(ctx context.Context, options *SessionOptions) (*Session, error) {
+	key := options.IdempotencyKey
+
+	for {
+		m.mutex.Lock()
+		reservation, exists := m.idempotencyKeys[key]
+		if !exists {
+			reservation = &idempotencyReservation{done: make(chan struct{})}
+			m.idempotencyKeys[key] = reservation
+			m.mutex.Unlock()
+			return m.fulfillReservation(ctx, options, key, reservation)
+		}
+		m.mutex.Unlock()
+
+		select {
+		case <-reservation.done:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("session creation cancelled: %w", ctx.Err())
+		}
+
+		if reservation.code == "" {
+			// The owning creation failed and released the key
+			continue
+		}
+
+		if session, err := m.GetSession(reservation.code); err == nil {
+			return session, nil
+		}
+
+		// The session is gone; release the stale key unless it was already replaced
+		m.mutex.Lock()
+		if m.idempotencyKeys[key] == reservation {
+			delete(m.idempotencyKeys, key)
+		}
+		m.mutex.Unlock()
+	}
+}
+
+// fulfillReservation creates the session for a key reserved by the caller.
+func (m *Manager) fulfillReservation(ctx context.Context, options *SessionOptions, key string, reservation *idempotencyReservation) (*Session, error) {
+	session, err := m.createSession(ctx, options)
+
+	m.mutex.Lock()
+	if err != nil {
+		delete(m.idempotencyKeys, key)
+	} else {
+		reservation.code = session.Code
+	}
+	m.mutex.Unlock()
+	close(reservation.done)
+
+	return session, err
+}
+
+// removeStaleIdempotencyKeysLocked drops completed reservations whose session
+// no longer exists. The caller must hold the write lock.
+func (m *Manager) removeStaleIdempotencyKeysLocked() {
+	for key, reservation := range m.idempotencyKeys {
+		if reservation.code == "" {
+			continue
+		}
+		if _, exists := m.sessions[reservation.code]; !exists {
+			delete(m.idempotencyKeys, key)
+		}
+	}
+}
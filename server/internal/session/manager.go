@@ -2,12 +2,28 @@
 package session
 
 import (
+	"container/list"
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	// DefaultCleanupInterval is how often expired sessions are removed when
+	// SessionOptions.CleanupInterval is not set.
+	DefaultCleanupInterval = 10 * time.Minute
+
+	// CleanupStallFactor is how many cleanup intervals may pass without a run
+	// before the cleanup loop is considered stalled.
+	CleanupStallFactor = 3
+)
+
 // Manager provides thread-safe session management with in-memory storage.
 type Manager struct {
 	// sessions stores active sessions with their codes as keys
@@ -22,14 +38,86 @@ type Manager struct {
 	// options contains session configuration
 	options *SessionOptions
 
+	// idempotencyKeys maps idempotency keys to the creation they reserved
+	idempotencyKeys map[string]*idempotencyReservation
+
+	// onUpdate is called with the changed keys after session data is updated
+	onUpdate func(code string, keys []string)
+
+	// onDelete is called with the code of each session deleted or expired
+	onDelete func(code string)
+
 	// cleanupInterval is how often expired sessions are cleaned up
 	cleanupInterval time.Duration
 
+	// cleanupJitter is the maximum random offset applied to each cleanup wait
+	cleanupJitter time.Duration
+
+	// randInt64N returns a random number in [0, n); tests replace it to make
+	// jittered cleanup delays deterministic
+	randInt64N func(n int64) int64
+
+	// clock returns the current time; see SetClock
+	clock atomic.Pointer[func() time.Time]
+
+	// cleanupMu serializes incremental cleanup runs over cleanupPending
+	cleanupMu sync.Mutex
+
+	// cleanupPending holds the codes the current incremental cleanup pass
+	// has yet to scan; see cleanupBatch
+	cleanupPending []string
+
 	// stopCleanup is used to signal the cleanup goroutine to stop
 	stopCleanup chan struct{}
 
 	// cleanupDone signals when the cleanup goroutine has stopped
 	cleanupDone chan struct{}
+
+	// lastCleanup is the Unix time in nanoseconds of the last cleanup run
+	lastCleanup atomic.Int64
+
+	// cleanupPanics counts cleanup runs that panicked and were recovered
+	cleanupPanics atomic.Uint64
+
+	// dataBytes is the approximate total size of all sessions' Data; see dataSize
+	dataBytes int
+
+	// recency lists session codes, most recently accessed first; see touchLocked
+	recency *list.List
+
+	// memoryEvictions counts sessions evicted to stay within MaxTotalDataBytes
+	memoryEvictions atomic.Uint64
+
+	// ownerSessions counts the live sessions of each owner; see trackOwnerLocked
+	ownerSessions map[string]int
+
+	// ownerEvictions counts sessions evicted to stay within MaxSessionsPerOwner
+	ownerEvictions atomic.Uint64
+
+	// store, if set, persists sessions behind the in-memory cache; see Store
+	store Store
+
+	// storeWrites maps codes with changes not yet written to the store to
+	// whether the session is to be saved (true) or deleted (false)
+	storeWrites map[string]bool
+
+	// storeMu serializes flushes of storeWrites
+	storeMu sync.Mutex
+
+	// storeRetryInterval is how often failed store writes are retried, and
+	// bounds each store call
+	storeRetryInterval time.Duration
+
+	// storeDegraded is set while the store is failing; see StoreDegraded
+	storeDegraded atomic.Bool
+
+	// storeDone signals when the store reconcile goroutine has stopped
+	storeDone chan struct{}
+
+	// evicted maps the codes of sessions evicted from memory, and so only
+	// held by the store, to their LastAccessed, so cleanup can delete them
+	// from the store once they expire
+	evicted map[string]time.Time
 }
 
 // NewManager creates a new session manager with the given options.
@@ -39,97 +127,198 @@ func NewManager(options *SessionOptions) *Manager {
 		options = DefaultSessionOptions()
 	}
 
+	generator := NewGenerator()
+	if options.RandSource != nil {
+		generator = NewGeneratorWithRand(options.RandSource)
+	}
+	generator.SetCaseSensitive(options.CaseSensitive)
+	generator.SetPrefix(options.CodePrefix)
+
+	cleanupInterval := options.CleanupInterval
+	if cleanupInterval <= 0 {
+		cleanupInterval = DefaultCleanupInterval
+	}
+	cleanupJitter := min(max(options.CleanupJitter, 0), cleanupInterval/2)
+
+	storeRetryInterval := options.StoreRetryInterval
+	if storeRetryInterval <= 0 {
+		storeRetryInterval = DefaultStoreRetryInterval
+	}
+
 	manager := &Manager{
 		sessions:        make(map[string]*Session),
-		generator:       NewGenerator(),
+		idempotencyKeys: make(map[string]*idempotencyReservation),
+		ownerSessions:   make(map[string]int),
+		recency:         list.New(),
+		generator:       generator,
 		options:         options,
-		cleanupInterval: 10 * time.Minute, // Clean up every 10 minutes
+		cleanupInterval: cleanupInterval,
+		cleanupJitter:   cleanupJitter,
+		randInt64N:      rand.Int64N,
 		stopCleanup:     make(chan struct{}),
 		cleanupDone:     make(chan struct{}),
+
+		store:              options.Store,
+		storeWrites:        make(map[string]bool),
+		evicted:            make(map[string]time.Time),
+		storeRetryInterval: storeRetryInterval,
+		storeDone:          make(chan struct{}),
 	}
+	manager.SetClock(nil)
+	manager.lastCleanup.Store(manager.now().UnixNano())
 
 	// Start background cleanup goroutine
 	go manager.cleanupExpiredSessions()
 
+	// Retry failed store writes in the background
+	if manager.store != nil {
+		go manager.reconcileStore()
+	}
+
 	return manager
 }
 
 // CreateSession creates a new session with a unique code.
 // It will retry code generation up to MaxRetries times if collisions occur.
 // Returns the created session or an error if unique code generation fails.
+// If options carries an IdempotencyKey, calls with the same key return the
+// same session for as long as it exists.
 func (m *Manager) CreateSession(ctx context.Context, options *SessionOptions) (*Session, error) {
 	if options == nil {
 		options = m.options
 	}
 
-	// Generate unique session code with collision detection
-	var code string
-	var collision bool
-
-	for attempt := 0; attempt <= options.MaxRetries; attempt++ {
-		code = m.generator.GenerateCode()
-
-		// Validate the generated code format
-		if !m.generator.IsValidFormat(code) {
-			continue // Try again with a new code
-		}
-
-		// Normalize the code for consistent storage
-		normalizedCode := m.generator.NormalizeCode(code)
+	if options.IdempotencyKey != "" {
+		return m.createSessionIdempotent(ctx, options)
+	}
 
-		// Check for collision
-		m.mutex.RLock()
-		_, collision = m.sessions[normalizedCode]
-		m.mutex.RUnlock()
+	return m.createSession(ctx, options)
+}
 
-		if !collision {
-			// No collision, we can use this code
-			code = normalizedCode
-			break
-		}
+// createSession creates a session with a fresh code.
+func (m *Manager) createSession(ctx context.Context, options *SessionOptions) (*Session, error) {
+	if m.atCapacity() {
+		return nil, ErrSessionLimitReached
+	}
 
-		// Check if context is cancelled
-		select {
-		case <-ctx.Done():
-			return nil, fmt.Errorf("session creation cancelled: %w", ctx.Err())
-		default:
-			// Continue with next attempt
-		}
+	// Generate unique session code with collision detection
+	code, err := m.GenerateUniqueCode(ctx, m.generator.GenerateCode, options.MaxRetries)
+	if err != nil {
+		return nil, err
 	}
 
-	// If we still have a collision after all retries, return an error
-	if collision {
-		return nil, ErrCodeGenerationFailed
+	kind := options.Kind
+	if kind == "" {
+		kind = DefaultSessionKind
 	}
 
 	// Create the session
-	now := time.Now()
+	now := m.now()
 	session := &Session{
 		Code:         code,
+		Kind:         kind,
+		Owner:        options.Owner,
 		CreatedAt:    now,
 		LastAccessed: now,
 		Data:         make(map[string]interface{}),
 	}
 
+	if m.exceedsDataKeys(len(options.InitialData)) {
+		return nil, ErrDataKeyLimitReached
+	}
+
 	// Copy initial data if provided
 	if options.InitialData != nil {
 		for k, v := range options.InitialData {
 			session.Data[k] = v
 		}
 	}
+	size := dataSize(session.Data)
+	if m.exceedsDataBytes(size) {
+		return nil, ErrDataTooLarge
+	}
 
-	// Store the session
+	// Store the session, re-checking the caps under the write lock
 	m.mutex.Lock()
+	if m.atCapacityLocked() {
+		m.mutex.Unlock()
+		return nil, ErrSessionLimitReached
+	}
+	if err := m.makeRoomForOwnerLocked(session.Owner); err != nil {
+		m.mutex.Unlock()
+		return nil, err
+	}
 	m.sessions[code] = session
+	m.trackOwnerLocked(session)
+	m.touchLocked(code, session)
+	m.resizeLocked(session, size)
+	m.evictForMemoryLocked(code)
+	m.queueSaveLocked(code)
 	m.mutex.Unlock()
 
+	if err := m.syncStore(); err != nil {
+		return nil, err
+	}
+
 	return session, nil
 }
 
+// GenerateUniqueCode calls generate until it returns a well-formed code that no
+// live session uses, making at most maxRetries+1 attempts, and returns the code
+// normalized for storage. Custom code strategies can use it to get the same
+// collision handling as CreateSession. It returns ErrCodeGenerationFailed if
+// every attempt produced a malformed or colliding code, or the context error
+// if ctx is cancelled between attempts.
+func (m *Manager) GenerateUniqueCode(ctx context.Context, generate func() string, maxRetries int) (string, error) {
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			// Check if context is cancelled before retrying
+			select {
+			case <-ctx.Done():
+				return "", fmt.Errorf("session creation cancelled: %w", ctx.Err())
+			default:
+			}
+		}
+
+		code := generate()
+
+		// Validate the generated code format
+		if !m.generator.IsValidFormat(code) {
+			continue // Try again with a new code
+		}
+
+		// Normalize the code for consistent storage and check for collision
+		normalizedCode := m.generator.NormalizeCode(code)
+		m.mutex.RLock()
+		_, collision := m.sessions[normalizedCode]
+		m.mutex.RUnlock()
+
+		if !collision {
+			return normalizedCode, nil
+		}
+	}
+
+	return "", ErrCodeGenerationFailed
+}
+
+// NormalizeCode returns code in the form the manager stores it: trimmed and,
+// unless the manager is case-sensitive, lowercased. Every lookup applies it,
+// so callers only need it to compare or key codes the same way the manager does.
+func (m *Manager) NormalizeCode(code string) string {
+	return m.generator.NormalizeCode(code)
+}
+
+// IsValidFormat reports whether code is well-formed for the manager, taking
+// its case sensitivity and code prefix into account, without looking it up.
+func (m *Manager) IsValidFormat(code string) bool {
+	return code != "" && m.generator.IsValidFormat(code)
+}
+
 // GetSession retrieves a session by its code.
 // Returns ErrSessionNotFound if the session doesn't exist.
 // Returns ErrSessionExpired if the session has expired.
-// Updates the LastAccessed timestamp if the session is found and valid.
+// Updates the LastAccessed timestamp if the session is found and valid; the
+// store sees the update once it is touchInterval newer than its copy.
 func (m *Manager) GetSession(code string) (*Session, error) {
 	if code == "" {
 		return nil, ErrInvalidSessionCode
@@ -142,6 +331,13 @@ func (m *Manager) GetSession(code string) (*Session, error) {
 
 	// Normalize the code
 	normalizedCode := m.generator.NormalizeCode(code)
+	if err := m.loadFromStore(normalizedCode); err != nil {
+		return nil, err
+	}
+
+	// Write the access, or the deletion, behind once unlocked. A failed
+	// write stays queued for the reconcile loop rather than failing the read.
+	defer m.syncStore()
 
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -154,78 +350,382 @@ func (m *Manager) GetSession(code string) (*Session, error) {
 	// Check if session has expired
 	if m.isExpired(session) {
 		// Remove expired session
-		delete(m.sessions, normalizedCode)
+		m.deleteLocked(normalizedCode)
 		return nil, ErrSessionExpired
 	}
 
 	// Update last accessed time
-	session.LastAccessed = time.Now()
+	session.LastAccessed = m.now()
+	m.touchLocked(normalizedCode, session)
+	m.queueTouchLocked(normalizedCode, session)
+
+	return session, nil
+}
+
+// RestoreSession retrieves a session for a reconnecting client and counts the
+// reconnect. When the count would exceed MaxReconnects the session is deleted
+// and ErrReconnectLimitReached returned. Otherwise it behaves like GetSession.
+func (m *Manager) RestoreSession(code string) (session *Session, err error) {
+	if code == "" || !m.generator.IsValidFormat(code) {
+		return nil, ErrInvalidSessionCode
+	}
+
+	normalizedCode := m.generator.NormalizeCode(code)
+	if err := m.loadFromStore(normalizedCode); err != nil {
+		return nil, err
+	}
+
+	// Write the reconnect count, or the deletion, behind once unlocked
+	defer func() {
+		if syncErr := m.syncStore(); syncErr != nil && err == nil {
+			session, err = nil, syncErr
+		}
+	}()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	session, exists := m.sessions[normalizedCode]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	if m.isExpired(session) {
+		m.deleteLocked(normalizedCode)
+		return nil, ErrSessionExpired
+	}
+
+	if m.options.MaxReconnects > 0 && session.Reconnects >= m.options.MaxReconnects {
+		m.deleteLocked(normalizedCode)
+		return nil, ErrReconnectLimitReached
+	}
 
+	session.Reconnects++
+	session.LastAccessed = m.now()
+	m.touchLocked(normalizedCode, session)
+	session.DisconnectedAt = time.Time{}
+	m.queueSaveLocked(normalizedCode)
 	return session, nil
 }
 
+// MarkDisconnected records that the session's last connection closed and
+// returns the recorded time. The session stays until it expires, so the
+// client can still reconnect; RestoreSession clears the mark.
+func (m *Manager) MarkDisconnected(code string) (at time.Time, err error) {
+	if code == "" || !m.generator.IsValidFormat(code) {
+		return time.Time{}, ErrInvalidSessionCode
+	}
+
+	normalizedCode := m.generator.NormalizeCode(code)
+	if err := m.loadFromStore(normalizedCode); err != nil {
+		return time.Time{}, err
+	}
+
+	// Write the mark behind once unlocked
+	defer func() {
+		if syncErr := m.syncStore(); syncErr != nil && err == nil {
+			at, err = time.Time{}, syncErr
+		}
+	}()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	session, exists := m.sessions[normalizedCode]
+	if !exists {
+		return time.Time{}, ErrSessionNotFound
+	}
+
+	session.DisconnectedAt = m.now()
+	m.queueSaveLocked(normalizedCode)
+	return session.DisconnectedAt, nil
+}
+
+// PeekSession returns a copy of a session without updating its LastAccessed
+// timestamp, for inspection by tooling. It returns the same errors as GetSession.
+func (m *Manager) PeekSession(code string) (Session, error) {
+	if code == "" || !m.generator.IsValidFormat(code) {
+		return Session{}, ErrInvalidSessionCode
+	}
+
+	normalizedCode := m.generator.NormalizeCode(code)
+	if err := m.loadFromStore(normalizedCode); err != nil {
+		return Session{}, err
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	session, exists := m.sessions[normalizedCode]
+	if !exists {
+		return Session{}, ErrSessionNotFound
+	}
+	if m.isExpired(session) {
+		return Session{}, ErrSessionExpired
+	}
+
+	return *copySession(session), nil
+}
+
 // DeleteSession removes a session by its code.
-// Returns true if the session was found and deleted, false otherwise.
+// Returns true if the session was found and deleted, false otherwise. A
+// store error is not reported; callers that need it use RemoveSession.
 func (m *Manager) DeleteSession(code string) bool {
+	deleted, _ := m.RemoveSession(code)
+	return deleted
+}
+
+// RemoveSession is DeleteSession that also reports store errors: it removes
+// the session from memory and the store, and the error is set when the store
+// could not be read or written; see syncStore.
+func (m *Manager) RemoveSession(code string) (deleted bool, err error) {
 	if code == "" {
-		return false
+		return false, nil
 	}
 
 	// Validate code format for consistency with GetSession
 	if !m.generator.IsValidFormat(code) {
-		return false
+		return false, nil
 	}
 
 	normalizedCode := m.generator.NormalizeCode(code)
+	if err := m.loadFromStore(normalizedCode); err != nil {
+		return false, err
+	}
+
+	// Write the deletion behind once unlocked
+	defer func() {
+		if syncErr := m.syncStore(); syncErr != nil && err == nil {
+			err = syncErr
+		}
+	}()
 
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	_, exists := m.sessions[normalizedCode]
 	if exists {
-		delete(m.sessions, normalizedCode)
+		m.deleteLocked(normalizedCode)
 	}
 
-	return exists
+	return exists, nil
 }
 
 // UpdateSessionData updates the data for a session.
 // Returns ErrSessionNotFound if the session doesn't exist.
 // Returns ErrSessionExpired if the session has expired.
+// Returns ErrDataKeyLimitReached, leaving the data unchanged, if the update
+// would add keys beyond MaxDataKeys, and ErrDataTooLarge if it would grow the
+// session's data past MaxTotalDataBytes on its own.
 func (m *Manager) UpdateSessionData(code string, data map[string]interface{}) error {
 	if code == "" {
 		return ErrInvalidSessionCode
 	}
 
 	normalizedCode := m.generator.NormalizeCode(code)
+	if err := m.loadFromStore(normalizedCode); err != nil {
+		return err
+	}
 
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
 
 	session, exists := m.sessions[normalizedCode]
 	if !exists {
+		m.mutex.Unlock()
 		return ErrSessionNotFound
 	}
 
 	// Check if session has expired
 	if m.isExpired(session) {
-		delete(m.sessions, normalizedCode)
+		m.deleteLocked(normalizedCode)
+		m.mutex.Unlock()
 		return ErrSessionExpired
 	}
 
+	// Reject the whole update if its new keys would exceed the key cap
+	added := 0
+	for k := range data {
+		if _, ok := session.Data[k]; !ok {
+			added++
+		}
+	}
+	if m.exceedsDataKeys(len(session.Data) + added) {
+		m.mutex.Unlock()
+		return ErrDataKeyLimitReached
+	}
+
 	// Update session data
 	if session.Data == nil {
 		session.Data = make(map[string]interface{})
 	}
 
+	// Apply the update, keeping the replaced values to undo it if the data
+	// grows too large
+	keys := make([]string, 0, len(data))
+	replaced := make(map[string]interface{}, len(data))
 	for k, v := range data {
+		if old, ok := session.Data[k]; ok {
+			replaced[k] = old
+		}
 		session.Data[k] = v
+		keys = append(keys, k)
+	}
+
+	// Reject data too large to fit under the ceiling before evicting anything
+	size := dataSize(session.Data)
+	if m.exceedsDataBytes(size) {
+		for _, k := range keys {
+			if old, ok := replaced[k]; ok {
+				session.Data[k] = old
+			} else {
+				delete(session.Data, k)
+			}
+		}
+		m.mutex.Unlock()
+		return ErrDataTooLarge
 	}
 
 	// Update last accessed time
-	session.LastAccessed = time.Now()
+	session.LastAccessed = m.now()
+	m.touchLocked(normalizedCode, session)
+	m.resizeLocked(session, size)
+	m.evictForMemoryLocked(normalizedCode)
+	m.queueSaveLocked(normalizedCode)
+	onUpdate := m.onUpdate
+	m.mutex.Unlock()
+
+	// Notify outside the lock so the callback may call back into the manager
+	if onUpdate != nil && len(keys) > 0 {
+		sort.Strings(keys)
+		onUpdate(normalizedCode, keys)
+	}
+
+	return m.syncStore()
+}
+
+// SetSessionValue sets a single data key for a session.
+// It behaves like UpdateSessionData with a one-entry map.
+func (m *Manager) SetSessionValue(code, key string, value interface{}) error {
+	return m.UpdateSessionData(code, map[string]interface{}{key: value})
+}
+
+// IncrementSessionValue atomically adds delta to the integer stored under key
+// and returns the new value. A missing key is initialized to delta. Existing
+// values must be integers (float64 values from decoded JSON are accepted when
+// they have no fractional part); otherwise ErrValueNotNumeric is returned.
+// The result is stored as an int64.
+func (m *Manager) IncrementSessionValue(code, key string, delta int64) (int64, error) {
+	if code == "" {
+		return 0, ErrInvalidSessionCode
+	}
+
+	normalizedCode := m.generator.NormalizeCode(code)
+	if err := m.loadFromStore(normalizedCode); err != nil {
+		return 0, err
+	}
+
+	m.mutex.Lock()
+
+	session, exists := m.sessions[normalizedCode]
+	if !exists {
+		m.mutex.Unlock()
+		return 0, ErrSessionNotFound
+	}
+
+	if m.isExpired(session) {
+		m.deleteLocked(normalizedCode)
+		m.mutex.Unlock()
+		return 0, ErrSessionExpired
+	}
+
+	var current int64
+	if existing, ok := session.Data[key]; ok {
+		n, ok := toInt64(existing)
+		if !ok {
+			m.mutex.Unlock()
+			return 0, ErrValueNotNumeric
+		}
+		current = n
+	} else if m.exceedsDataKeys(len(session.Data) + 1) {
+		m.mutex.Unlock()
+		return 0, ErrDataKeyLimitReached
+	}
+
+	if session.Data == nil {
+		session.Data = make(map[string]interface{})
+	}
+
+	value := current + delta
+	previous, existed := session.Data[key]
+	session.Data[key] = value
+	size := dataSize(session.Data)
+	if m.exceedsDataBytes(size) {
+		if existed {
+			session.Data[key] = previous
+		} else {
+			delete(session.Data, key)
+		}
+		m.mutex.Unlock()
+		return 0, ErrDataTooLarge
+	}
+	session.LastAccessed = m.now()
+	m.touchLocked(normalizedCode, session)
+	m.resizeLocked(session, size)
+	m.evictForMemoryLocked(normalizedCode)
+	m.queueSaveLocked(normalizedCode)
+	onUpdate := m.onUpdate
+	m.mutex.Unlock()
 
-	return nil
+	if onUpdate != nil {
+		onUpdate(normalizedCode, []string{key})
+	}
+
+	if err := m.syncStore(); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// toInt64 converts an integer session data value to int64.
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// SetUpdateCallback registers a function called with the session code and the
+// sorted changed keys after UpdateSessionData or SetSessionValue succeeds. It
+// lets the transport notify clients without the manager depending on it.
+// The callback runs synchronously on the updating goroutine.
+func (m *Manager) SetUpdateCallback(onUpdate func(code string, keys []string)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.onUpdate = onUpdate
+}
+
+// SetDeleteCallback registers a function called with the session code when a
+// session is deleted, expires, or is evicted for its owner, but not when it
+// is only evicted from memory. It lets the transport drop state it keeps for
+// the session. The callback runs synchronously with the manager's lock held,
+// so it must not call back into the manager.
+func (m *Manager) SetDeleteCallback(onDelete func(code string)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.onDelete = onDelete
 }
 
 // GetSessionCount returns the current number of active sessions.
@@ -249,50 +749,228 @@ func (m *Manager) ListSessions() []string {
 	return codes
 }
 
+// SessionsByKind returns the codes of all active sessions of the given kind.
+func (m *Manager) SessionsByKind(kind string) []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	codes := make([]string, 0)
+	for code, session := range m.sessions {
+		if session.Kind == kind {
+			codes = append(codes, code)
+		}
+	}
+
+	return codes
+}
+
 // Cleanup removes all expired sessions.
 // Returns the number of sessions that were removed.
 func (m *Manager) Cleanup() int {
+	defer m.syncStore()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.removeExpiredLocked()
+}
+
+// cleanupBatch is the background cleanup run. With CleanupBatchSize set it
+// scans at most that many sessions under the write lock, resuming where the
+// previous run stopped, so large session maps are cleaned up across several
+// runs without long lock holds. A pass starts by snapshotting the codes to
+// scan; sessions created during the pass are picked up by the next one.
+// Without CleanupBatchSize it runs Cleanup. It returns the number of
+// sessions removed.
+func (m *Manager) cleanupBatch() int {
+	batchSize := m.options.CleanupBatchSize
+	if batchSize <= 0 {
+		return m.Cleanup()
+	}
+
+	m.cleanupMu.Lock()
+	defer m.cleanupMu.Unlock()
+	defer m.syncStore()
+
+	if len(m.cleanupPending) == 0 {
+		m.mutex.RLock()
+		m.cleanupPending = make([]string, 0, len(m.sessions))
+		for code := range m.sessions {
+			m.cleanupPending = append(m.cleanupPending, code)
+		}
+		m.mutex.RUnlock()
+	}
+
+	batch := m.cleanupPending[:min(batchSize, len(m.cleanupPending))]
+	m.cleanupPending = m.cleanupPending[len(batch):]
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	removed := 0
+	for _, code := range batch {
+		if session, ok := m.sessions[code]; ok && m.isExpired(session) {
+			m.deleteLocked(code)
+			removed++
+		}
+	}
+
+	// Finish the pass like a full cleanup
+	if len(m.cleanupPending) == 0 {
+		m.cleanupPending = nil
+		removed += m.removeExpiredEvictedLocked()
+		m.removeStaleIdempotencyKeysLocked()
+	}
+
+	return removed
+}
+
+// CleanupStatus returns the liveness of the background cleanup loop.
+// This method is thread-safe.
+func (m *Manager) CleanupStatus() CleanupStatus {
+	return CleanupStatus{
+		LastRun:  time.Unix(0, m.lastCleanup.Load()),
+		Interval: m.cleanupInterval + m.cleanupJitter,
+		Panics:   m.cleanupPanics.Load(),
+	}
+}
+
+// SetClock replaces the function the manager reads the current time from,
+// which decides session expiry; nil restores time.Now. It lets tests expire
+// sessions without waiting, and is safe to call while the manager is in use.
+func (m *Manager) SetClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	m.clock.Store(&now)
+}
+
+// now returns the current time from the manager's clock.
+func (m *Manager) now() time.Time {
+	return (*m.clock.Load())()
+}
+
+// MaxSessions returns the configured session cap (zero means unlimited).
+func (m *Manager) MaxSessions() int {
+	if m.options.MaxSessions < 0 {
+		return 0
+	}
+	return m.options.MaxSessions
+}
+
+// exceedsDataKeys reports whether a session holding n data keys would be over
+// the manager's MaxDataKeys cap.
+func (m *Manager) exceedsDataKeys(n int) bool {
+	return m.options.MaxDataKeys > 0 && n > m.options.MaxDataKeys
+}
+
+// atCapacity reports whether the manager holds MaxSessions live sessions.
+func (m *Manager) atCapacity() bool {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
+	return m.atCapacityLocked()
+}
+
+// atCapacityLocked is atCapacity for callers holding the write lock. Expired
+// sessions still awaiting cleanup are removed first so they do not count
+// against the cap.
+func (m *Manager) atCapacityLocked() bool {
+	max := m.MaxSessions()
+	if max == 0 || len(m.sessions) < max {
+		return false
+	}
+	m.removeExpiredLocked()
+	return len(m.sessions) >= max
+}
 
+// removeExpiredLocked deletes expired sessions, including those evicted from
+// memory to the store, and returns how many were removed, along with
+// idempotency keys pointing at sessions that no longer exist. The caller must
+// hold the write lock.
+func (m *Manager) removeExpiredLocked() int {
 	removed := 0
 	for code, session := range m.sessions {
 		if m.isExpired(session) {
-			delete(m.sessions, code)
+			m.deleteLocked(code)
 			removed++
 		}
 	}
+	removed += m.removeExpiredEvictedLocked()
+
+	m.removeStaleIdempotencyKeysLocked()
 
 	return removed
 }
 
 // Close stops the background cleanup goroutine and cleans up resources.
 // This should be called when the session manager is no longer needed.
-func (m *Manager) Close() {
+// Changes still queued for the store are written before it returns; the
+// error reports any that could not be, which are then lost.
+func (m *Manager) Close() error {
 	close(m.stopCleanup)
 	<-m.cleanupDone
+	if m.store == nil {
+		return nil
+	}
+	<-m.storeDone
+	return m.flushStore()
 }
 
 // isExpired checks if a session has expired based on the session timeout.
 // This method assumes the caller holds the appropriate lock.
 func (m *Manager) isExpired(session *Session) bool {
-	return time.Since(session.LastAccessed) > m.options.SessionTimeout
+	return m.expiredSince(session.LastAccessed)
+}
+
+// expiredSince reports whether a session last accessed at lastAccessed has
+// expired.
+func (m *Manager) expiredSince(lastAccessed time.Time) bool {
+	return m.now().Sub(lastAccessed) > m.options.SessionTimeout
 }
 
 // cleanupExpiredSessions runs in a background goroutine to periodically
-// remove expired sessions from memory.
+// remove expired sessions from memory. A panicking cleanup run is recovered
+// and the loop restarted, so one bad run does not stop cleanup for good.
 func (m *Manager) cleanupExpiredSessions() {
 	defer close(m.cleanupDone)
 
-	ticker := time.NewTicker(m.cleanupInterval)
-	defer ticker.Stop()
+	timer := time.NewTimer(m.nextCleanupDelay())
+	defer timer.Stop()
+
+	for !m.runCleanupLoop(timer) {
+		m.cleanupPanics.Add(1)
+	}
+}
+
+// nextCleanupDelay returns how long to wait before the next cleanup run: the
+// cleanup interval offset by a random amount within the configured jitter.
+func (m *Manager) nextCleanupDelay() time.Duration {
+	if m.cleanupJitter <= 0 {
+		return m.cleanupInterval
+	}
+	offset := time.Duration(m.randInt64N(int64(2*m.cleanupJitter)+1)) - m.cleanupJitter
+	return m.cleanupInterval + offset
+}
+
+// runCleanupLoop runs cleanup each time the timer fires until Close is called,
+// recording each completed run. The timer is rearmed before each run so a
+// panicking run does not stop later ones. It returns true when stopped and
+// false if a run panicked.
+func (m *Manager) runCleanupLoop(timer *time.Timer) (stopped bool) {
+	defer func() {
+		if recover() != nil {
+			stopped = false
+		}
+	}()
 
 	for {
 		select {
-		case <-ticker.C:
-			m.Cleanup()
+		case <-timer.C:
+			timer.Reset(m.nextCleanupDelay())
+			m.cleanupBatch()
+			m.lastCleanup.Store(m.now().UnixNano())
 		case <-m.stopCleanup:
-			return
+			return true
 		}
 	}
 }
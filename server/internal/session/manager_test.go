@@ -1,10 +1,17 @@
 package session
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -307,6 +314,304 @@ func TestListSessions(t *testing.T) {
 	}
 }
 
+func TestSessionsByKind(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.Close()
+
+	ctx := context.Background()
+	kinds := map[string][]string{}
+	for _, kind := range []string{"game", "admin", "game", "", "game"} {
+		options := DefaultSessionOptions()
+		options.Kind = kind
+		session, err := manager.CreateSession(ctx, options)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if kind == "" {
+			kind = DefaultSessionKind
+		}
+		if session.Kind != kind {
+			t.Errorf("expected kind %q, got %q", kind, session.Kind)
+		}
+		kinds[kind] = append(kinds[kind], session.Code)
+	}
+
+	// Sessions created without options get the default kind
+	session, err := manager.CreateSession(ctx, nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	kinds[DefaultSessionKind] = append(kinds[DefaultSessionKind], session.Code)
+
+	total := 0
+	for kind, want := range kinds {
+		got := manager.SessionsByKind(kind)
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("SessionsByKind(%q) = %v, want %v", kind, got, want)
+		}
+		total += len(got)
+	}
+	if total != manager.GetSessionCount() {
+		t.Errorf("kinds should partition all %d sessions, got %d", manager.GetSessionCount(), total)
+	}
+
+	if codes := manager.SessionsByKind("unknown"); len(codes) != 0 {
+		t.Errorf("SessionsByKind for an unused kind should be empty, got %v", codes)
+	}
+}
+
+func TestRestoreSessionMaxReconnects(t *testing.T) {
+	options := DefaultSessionOptions()
+	options.MaxReconnects = 2
+	manager := NewManager(options)
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// Reconnecting up to the cap succeeds
+	for i := 1; i <= 2; i++ {
+		restored, err := manager.RestoreSession(session.Code)
+		if err != nil {
+			t.Fatalf("RestoreSession %d failed: %v", i, err)
+		}
+		if restored.Reconnects != i {
+			t.Errorf("expected %d reconnects, got %d", i, restored.Reconnects)
+		}
+	}
+
+	// One more invalidates the session
+	if _, err := manager.RestoreSession(session.Code); !errors.Is(err, ErrReconnectLimitReached) {
+		t.Fatalf("expected ErrReconnectLimitReached, got %v", err)
+	}
+	if _, err := manager.GetSession(session.Code); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("session should be deleted after exceeding the cap, got %v", err)
+	}
+
+	// Plain lookups do not count as reconnects
+	other, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := manager.GetSession(other.Code); err != nil {
+			t.Fatalf("GetSession failed: %v", err)
+		}
+	}
+	if _, err := manager.RestoreSession(other.Code); err != nil {
+		t.Errorf("RestoreSession after lookups failed: %v", err)
+	}
+}
+
+func TestMarkDisconnected(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	disconnectedAt, err := manager.MarkDisconnected(session.Code)
+	if err != nil {
+		t.Fatalf("MarkDisconnected failed: %v", err)
+	}
+	peeked, err := manager.PeekSession(session.Code)
+	if err != nil {
+		t.Fatalf("PeekSession failed: %v", err)
+	}
+	if peeked.DisconnectedAt.IsZero() || !peeked.DisconnectedAt.Equal(disconnectedAt) {
+		t.Errorf("expected DisconnectedAt %v, got %v", disconnectedAt, peeked.DisconnectedAt)
+	}
+
+	// Reconnecting clears the mark
+	restored, err := manager.RestoreSession(session.Code)
+	if err != nil {
+		t.Fatalf("RestoreSession failed: %v", err)
+	}
+	if !restored.DisconnectedAt.IsZero() {
+		t.Errorf("expected DisconnectedAt to be cleared, got %v", restored.DisconnectedAt)
+	}
+
+	if _, err := manager.MarkDisconnected("missing-code-99"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestRekeySession(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.Close()
+
+	ctx := context.Background()
+	session, err := manager.CreateSession(ctx, nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := manager.SetSessionValue(session.Code, "score", 7); err != nil {
+		t.Fatalf("SetSessionValue failed: %v", err)
+	}
+	oldCode := session.Code
+
+	rekeyed, err := manager.RekeySession(ctx, oldCode)
+	if err != nil {
+		t.Fatalf("RekeySession failed: %v", err)
+	}
+	if rekeyed.Code == oldCode {
+		t.Fatalf("expected a new code, got %s again", oldCode)
+	}
+
+	moved, err := manager.GetSession(rekeyed.Code)
+	if err != nil {
+		t.Fatalf("GetSession with the new code failed: %v", err)
+	}
+	if moved.Data["score"] != 7 {
+		t.Errorf("expected score 7 under the new code, got %v", moved.Data["score"])
+	}
+	if _, err := manager.GetSession(oldCode); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected the old code to stop resolving, got %v", err)
+	}
+	if count := manager.GetSessionCount(); count != 1 {
+		t.Errorf("expected 1 session, got %d", count)
+	}
+
+	if _, err := manager.RekeySession(ctx, oldCode); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound rekeying the old code, got %v", err)
+	}
+
+	// Reverting moves the session and its data back to the old code
+	if err := manager.RevertRekey(rekeyed.Code, oldCode); err != nil {
+		t.Fatalf("RevertRekey failed: %v", err)
+	}
+	reverted, err := manager.GetSession(oldCode)
+	if err != nil {
+		t.Fatalf("GetSession with the old code failed: %v", err)
+	}
+	if reverted.Data["score"] != 7 {
+		t.Errorf("expected score 7 under the old code, got %v", reverted.Data["score"])
+	}
+	if _, err := manager.GetSession(rekeyed.Code); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected the new code to stop resolving, got %v", err)
+	}
+
+	other, err := manager.CreateSession(ctx, nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := manager.RevertRekey(oldCode, other.Code); !errors.Is(err, ErrSessionCodeInUse) {
+		t.Errorf("expected ErrSessionCodeInUse reverting onto a live code, got %v", err)
+	}
+}
+
+func TestMaxTotalDataBytesEvictsLeastRecentlyAccessed(t *testing.T) {
+	options := DefaultSessionOptions()
+	options.MaxTotalDataBytes = 100
+	manager := NewManager(options)
+	defer manager.Close()
+
+	value := strings.Repeat("x", 20)
+	entrySize := dataSize(map[string]interface{}{"v": value})
+
+	var codes []string
+	for i := 0; i < 3; i++ {
+		session, err := manager.CreateSession(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if err := manager.SetSessionValue(session.Code, "v", value); err != nil {
+			t.Fatalf("SetSessionValue failed: %v", err)
+		}
+		codes = append(codes, session.Code)
+		time.Sleep(2 * time.Millisecond) // Distinct access times
+	}
+	if got := manager.DataBytes(); got != 3*entrySize {
+		t.Fatalf("expected %d data bytes, got %d", 3*entrySize, got)
+	}
+
+	// Touch the first session so the second becomes the least recently accessed
+	if _, err := manager.GetSession(codes[0]); err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+
+	// Growing the third session's data past the ceiling evicts the second
+	if err := manager.SetSessionValue(codes[2], "w", value); err != nil {
+		t.Fatalf("SetSessionValue failed: %v", err)
+	}
+	if _, err := manager.PeekSession(codes[1]); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("least recently accessed session should be evicted, got %v", err)
+	}
+	for _, code := range []string{codes[0], codes[2]} {
+		if _, err := manager.PeekSession(code); err != nil {
+			t.Errorf("session %s should survive eviction: %v", code, err)
+		}
+	}
+	if got := manager.MemoryEvictions(); got != 1 {
+		t.Errorf("expected 1 eviction, got %d", got)
+	}
+	if got := manager.DataBytes(); got > options.MaxTotalDataBytes {
+		t.Errorf("data bytes %d should be within the ceiling %d", got, options.MaxTotalDataBytes)
+	}
+
+	// Deleting a session releases its data
+	before := manager.DataBytes()
+	manager.DeleteSession(codes[0])
+	if got := manager.DataBytes(); got != before-entrySize {
+		t.Errorf("expected %d data bytes after delete, got %d", before-entrySize, got)
+	}
+}
+
+func TestMaxTotalDataBytesRejectsOversizedData(t *testing.T) {
+	options := DefaultSessionOptions()
+	options.MaxTotalDataBytes = 100
+	manager := NewManager(options)
+	defer manager.Close()
+
+	oversized := strings.Repeat("x", 200)
+	if _, err := manager.CreateSession(context.Background(), &SessionOptions{
+		MaxRetries:  options.MaxRetries,
+		InitialData: map[string]interface{}{"v": oversized},
+	}); !errors.Is(err, ErrDataTooLarge) {
+		t.Fatalf("expected ErrDataTooLarge creating an oversized session, got %v", err)
+	}
+
+	var codes []string
+	for i := 0; i < 2; i++ {
+		session, err := manager.CreateSession(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if err := manager.SetSessionValue(session.Code, "v", "small"); err != nil {
+			t.Fatalf("SetSessionValue failed: %v", err)
+		}
+		codes = append(codes, session.Code)
+	}
+	before := manager.DataBytes()
+
+	// The update alone exceeds the ceiling, so nothing is evicted for it
+	err := manager.UpdateSessionData(codes[1], map[string]interface{}{"v": oversized, "w": "new"})
+	if !errors.Is(err, ErrDataTooLarge) {
+		t.Fatalf("expected ErrDataTooLarge, got %v", err)
+	}
+	if got := manager.MemoryEvictions(); got != 0 {
+		t.Errorf("expected no evictions, got %d", got)
+	}
+	if got := manager.DataBytes(); got != before {
+		t.Errorf("expected %d data bytes, got %d", before, got)
+	}
+	for _, code := range codes {
+		session, err := manager.PeekSession(code)
+		if err != nil {
+			t.Fatalf("session %s should survive: %v", code, err)
+		}
+		if len(session.Data) != 1 || session.Data["v"] != "small" {
+			t.Errorf("session %s data should be unchanged, got %v", code, session.Data)
+		}
+	}
+}
+
 func TestCleanup(t *testing.T) {
 	// Create manager with very short timeout
 	options := &SessionOptions{
@@ -861,3 +1166,798 @@ func TestSessionDataIntegrity(t *testing.T) {
 		t.Errorf("user_id should not change: got %v, expected test123", updated.Data["user_id"])
 	}
 }
+
+func TestGetSessionCaseSensitive(t *testing.T) {
+	options := DefaultSessionOptions()
+	options.CaseSensitive = true
+	manager := NewManager(options)
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// Exact code (with surrounding whitespace trimmed) resolves
+	if _, err := manager.GetSession("  " + session.Code + " "); err != nil {
+		t.Errorf("GetSession should trim whitespace in case-sensitive mode: %v", err)
+	}
+
+	// A different case identifies a different (non-existent) session
+	if _, err := manager.GetSession(strings.ToUpper(session.Code)); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound for upper-cased code, got %v", err)
+	}
+
+	if manager.DeleteSession(strings.ToUpper(session.Code)) {
+		t.Error("DeleteSession should not match a differently-cased code")
+	}
+}
+
+func TestGetSessionCodePrefix(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.CodePrefix = "prod"
+	options.Store = store
+	manager := NewManager(options)
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if !strings.HasPrefix(session.Code, "prod:") {
+		t.Fatalf("Expected a prod: code, got %q", session.Code)
+	}
+
+	// The full code and its short form round-trip to the session
+	short := strings.TrimPrefix(session.Code, "prod:")
+	for _, code := range []string{session.Code, short} {
+		found, err := manager.GetSession(code)
+		if err != nil {
+			t.Fatalf("GetSession(%q) failed: %v", code, err)
+		}
+		if found.Code != session.Code {
+			t.Errorf("GetSession(%q) returned %q, expected %q", code, found.Code, session.Code)
+		}
+	}
+
+	// The same code in another namespace does not resolve
+	if _, err := manager.GetSession("dev:" + short); err != ErrInvalidSessionCode {
+		t.Errorf("Expected ErrInvalidSessionCode for another prefix, got %v", err)
+	}
+
+	// A manager for another namespace sharing the store resolves neither form
+	other := DefaultSessionOptions()
+	other.CodePrefix = "dev"
+	other.Store = store
+	otherManager := NewManager(other)
+	defer otherManager.Close()
+	if _, err := otherManager.GetSession(session.Code); err != ErrInvalidSessionCode {
+		t.Errorf("Expected ErrInvalidSessionCode from the dev manager, got %v", err)
+	}
+	if _, err := otherManager.GetSession(short); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound for the short form in dev, got %v", err)
+	}
+}
+
+func TestCreateSessionMaxSessions(t *testing.T) {
+	options := DefaultSessionOptions()
+	options.MaxSessions = 2
+	options.SessionTimeout = time.Hour
+	manager := NewManager(options)
+	defer manager.Close()
+	var offset atomic.Int64
+	manager.SetClock(func() time.Time { return time.Now().Add(time.Duration(offset.Load())) })
+
+	first, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if _, err := manager.CreateSession(context.Background(), nil); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	if _, err := manager.CreateSession(context.Background(), nil); err != ErrSessionLimitReached {
+		t.Errorf("Expected ErrSessionLimitReached at capacity, got %v", err)
+	}
+	if manager.GetSessionCount() != 2 {
+		t.Errorf("Expected 2 sessions, got %d", manager.GetSessionCount())
+	}
+
+	// Freeing a slot allows creation again
+	manager.DeleteSession(first.Code)
+	if _, err := manager.CreateSession(context.Background(), nil); err != nil {
+		t.Errorf("CreateSession should succeed after a session is deleted: %v", err)
+	}
+
+	// Expired sessions awaiting cleanup do not count against the cap
+	offset.Store(int64(2 * time.Hour))
+	if _, err := manager.CreateSession(context.Background(), nil); err != nil {
+		t.Errorf("CreateSession should reclaim expired sessions at capacity: %v", err)
+	}
+}
+
+func TestCreateSessionMaxSessionsPerOwnerReject(t *testing.T) {
+	options := DefaultSessionOptions()
+	options.MaxSessionsPerOwner = 2
+	options.SessionTimeout = time.Hour
+	manager := NewManager(options)
+	defer manager.Close()
+	var offset atomic.Int64
+	manager.SetClock(func() time.Time { return time.Now().Add(time.Duration(offset.Load())) })
+
+	ownedBy := func(owner string) *SessionOptions {
+		options := DefaultSessionOptions()
+		options.Owner = owner
+		return options
+	}
+
+	first, err := manager.CreateSession(context.Background(), ownedBy("alice"))
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if first.Owner != "alice" {
+		t.Errorf("Expected owner alice, got %q", first.Owner)
+	}
+	if _, err := manager.CreateSession(context.Background(), ownedBy("alice")); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	if _, err := manager.CreateSession(context.Background(), ownedBy("alice")); err != ErrOwnerSessionLimitReached {
+		t.Errorf("Expected ErrOwnerSessionLimitReached beyond the owner cap, got %v", err)
+	}
+	if count := manager.SessionCountByOwner("alice"); count != 2 {
+		t.Errorf("Expected 2 sessions for alice, got %d", count)
+	}
+
+	// Other owners and anonymous sessions are not affected
+	if _, err := manager.CreateSession(context.Background(), ownedBy("bob")); err != nil {
+		t.Errorf("CreateSession for another owner failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := manager.CreateSession(context.Background(), nil); err != nil {
+			t.Errorf("Anonymous CreateSession failed: %v", err)
+		}
+	}
+
+	// Deleting a session frees a slot
+	manager.DeleteSession(first.Code)
+	if count := manager.SessionCountByOwner("alice"); count != 1 {
+		t.Errorf("Expected 1 session for alice after delete, got %d", count)
+	}
+	if _, err := manager.CreateSession(context.Background(), ownedBy("alice")); err != nil {
+		t.Errorf("CreateSession should succeed after a session is deleted: %v", err)
+	}
+
+	// Expired sessions no longer count
+	offset.Store(int64(2 * time.Hour))
+	if _, err := manager.CreateSession(context.Background(), ownedBy("alice")); err != nil {
+		t.Errorf("CreateSession should reclaim expired sessions at the owner cap: %v", err)
+	}
+	if count := manager.SessionCountByOwner("alice"); count != 1 {
+		t.Errorf("Expected only the new session for alice, got %d", count)
+	}
+}
+
+func TestCreateSessionMaxSessionsPerOwnerEvictOldest(t *testing.T) {
+	options := DefaultSessionOptions()
+	options.MaxSessionsPerOwner = 2
+	options.OwnerLimitPolicy = OwnerLimitEvictOldest
+	manager := NewManager(options)
+	defer manager.Close()
+
+	clock := time.Now()
+	manager.SetClock(func() time.Time { return clock })
+
+	create := func() *Session {
+		t.Helper()
+		options := DefaultSessionOptions()
+		options.Owner = "alice"
+		session, err := manager.CreateSession(context.Background(), options)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		clock = clock.Add(time.Second)
+		return session
+	}
+
+	oldest := create()
+	second := create()
+
+	// Accessing the oldest session does not save it; age is by creation
+	if _, err := manager.GetSession(oldest.Code); err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+
+	third := create()
+	if _, err := manager.PeekSession(oldest.Code); err != ErrSessionNotFound {
+		t.Errorf("Expected the oldest session to be evicted, got %v", err)
+	}
+	for _, code := range []string{second.Code, third.Code} {
+		if _, err := manager.PeekSession(code); err != nil {
+			t.Errorf("Expected session %s to remain, got %v", code, err)
+		}
+	}
+	if count := manager.SessionCountByOwner("alice"); count != 2 {
+		t.Errorf("Expected 2 sessions for alice, got %d", count)
+	}
+	if evictions := manager.OwnerEvictions(); evictions != 1 {
+		t.Errorf("Expected 1 owner eviction, got %d", evictions)
+	}
+}
+
+func TestParseOwnerLimitPolicy(t *testing.T) {
+	for name, want := range map[string]OwnerLimitPolicy{
+		"reject":       OwnerLimitReject,
+		"Evict-Oldest": OwnerLimitEvictOldest,
+	} {
+		policy, err := ParseOwnerLimitPolicy(name)
+		if err != nil || policy != want {
+			t.Errorf("ParseOwnerLimitPolicy(%q) = %v, %v; want %v", name, policy, err, want)
+		}
+	}
+	if _, err := ParseOwnerLimitPolicy("evict-newest"); err == nil {
+		t.Error("Expected an unknown policy to fail parsing")
+	}
+}
+
+func TestCreateSessionIdempotencyKeyConcurrent(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.Close()
+
+	const callers = 50
+	codes := make([]string, callers)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			options := DefaultSessionOptions()
+			options.IdempotencyKey = "shared-key"
+			<-start
+			session, err := manager.CreateSession(context.Background(), options)
+			if err != nil {
+				t.Errorf("CreateSession failed: %v", err)
+				return
+			}
+			codes[i] = session.Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if count := manager.GetSessionCount(); count != 1 {
+		t.Fatalf("Expected exactly one session for a shared key, got %d", count)
+	}
+	for i, code := range codes {
+		if code != codes[0] {
+			t.Errorf("Caller %d got session %q, expected %q", i, code, codes[0])
+		}
+	}
+
+	// A different key creates a different session
+	options := DefaultSessionOptions()
+	options.IdempotencyKey = "other-key"
+	other, err := manager.CreateSession(context.Background(), options)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if other.Code == codes[0] {
+		t.Error("Different idempotency keys should create different sessions")
+	}
+
+	// Once the session is gone the key creates a new one
+	manager.DeleteSession(codes[0])
+	options.IdempotencyKey = "shared-key"
+	recreated, err := manager.CreateSession(context.Background(), options)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if recreated.Code == codes[0] {
+		t.Error("Expected a new session after the keyed session was deleted")
+	}
+}
+
+func TestCreateSessionIdempotencyKeyReleasedOnFailure(t *testing.T) {
+	options := DefaultSessionOptions()
+	options.MaxSessions = 1
+	manager := NewManager(options)
+	defer manager.Close()
+
+	first, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	keyed := DefaultSessionOptions()
+	keyed.IdempotencyKey = "retry-key"
+	if _, err := manager.CreateSession(context.Background(), keyed); err != ErrSessionLimitReached {
+		t.Fatalf("Expected ErrSessionLimitReached, got %v", err)
+	}
+
+	// The failed creation released the key, so a retry can succeed
+	manager.DeleteSession(first.Code)
+	if _, err := manager.CreateSession(context.Background(), keyed); err != nil {
+		t.Errorf("Retry with the same key should succeed after a failure: %v", err)
+	}
+}
+
+func TestUpdateSessionDataCallback(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.Close()
+
+	var gotCode string
+	var gotKeys []string
+	calls := 0
+	manager.SetUpdateCallback(func(code string, keys []string) {
+		calls++
+		gotCode = code
+		gotKeys = keys
+	})
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	if err := manager.UpdateSessionData(session.Code, map[string]interface{}{"score": 10, "level": 2}); err != nil {
+		t.Fatalf("UpdateSessionData failed: %v", err)
+	}
+	if calls != 1 || gotCode != session.Code || strings.Join(gotKeys, ",") != "level,score" {
+		t.Errorf("Expected one callback for %s with keys level,score; got %d calls, %s, %v", session.Code, calls, gotCode, gotKeys)
+	}
+
+	if err := manager.SetSessionValue(session.Code, "name", "alice"); err != nil {
+		t.Fatalf("SetSessionValue failed: %v", err)
+	}
+	if calls != 2 || strings.Join(gotKeys, ",") != "name" {
+		t.Errorf("Expected a second callback with key name; got %d calls, %v", calls, gotKeys)
+	}
+
+	// Failed updates do not notify
+	if err := manager.SetSessionValue("missing-session-1", "name", "bob"); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected no callback for a failed update, got %d calls", calls)
+	}
+}
+
+func TestDeleteCallback(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.Close()
+
+	var deleted []string
+	manager.SetDeleteCallback(func(code string) {
+		deleted = append(deleted, code)
+	})
+
+	first, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	second, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	if !manager.DeleteSession(first.Code) {
+		t.Fatal("DeleteSession should find the session")
+	}
+	if len(deleted) != 1 || deleted[0] != first.Code {
+		t.Fatalf("Expected a callback for the deleted session, got %v", deleted)
+	}
+
+	// Deleting a missing session does not notify
+	manager.DeleteSession(first.Code)
+	if len(deleted) != 1 {
+		t.Errorf("Expected no callback for a missing session, got %v", deleted)
+	}
+
+	// Expiry notifies too
+	manager.mutex.Lock()
+	manager.sessions[second.Code].LastAccessed = time.Now().Add(-2 * manager.options.SessionTimeout)
+	manager.mutex.Unlock()
+	if removed := manager.Cleanup(); removed != 1 {
+		t.Fatalf("Expected one expired session removed, got %d", removed)
+	}
+	if len(deleted) != 2 || deleted[1] != second.Code {
+		t.Errorf("Expected a callback for the expired session, got %v", deleted)
+	}
+}
+
+func TestCleanupStatusAdvancesAndRecoversFromPanic(t *testing.T) {
+	manager := NewManager(&SessionOptions{
+		MaxRetries:      10,
+		SessionTimeout:  time.Hour,
+		CleanupInterval: 5 * time.Millisecond,
+	})
+	defer manager.Close()
+
+	waitForRun := func(after time.Time) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for !manager.CleanupStatus().LastRun.After(after) {
+			if time.Now().After(deadline) {
+				t.Fatalf("cleanup did not run after %v", after)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	status := manager.CleanupStatus()
+	if status.Interval != 5*time.Millisecond {
+		t.Errorf("expected interval 5ms, got %v", status.Interval)
+	}
+	waitForRun(status.LastRun)
+
+	// A nil session makes the next cleanup run panic
+	manager.mutex.Lock()
+	manager.sessions["broken"] = nil
+	manager.mutex.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for manager.CleanupStatus().Panics == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("cleanup panic was not recorded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	manager.mutex.Lock()
+	delete(manager.sessions, "broken")
+	manager.mutex.Unlock()
+
+	// The restarted loop keeps running cleanup
+	waitForRun(time.Now())
+	if manager.CleanupStatus().Stalled(time.Now()) {
+		t.Error("cleanup should not be stalled after recovering")
+	}
+}
+
+func TestCleanupStatusStalled(t *testing.T) {
+	now := time.Now()
+	status := CleanupStatus{LastRun: now.Add(-time.Minute), Interval: 30 * time.Second}
+	if status.Stalled(now) {
+		t.Error("two intervals without a run should not be stalled")
+	}
+
+	status.LastRun = now.Add(-2 * time.Minute)
+	if !status.Stalled(now) {
+		t.Error("four intervals without a run should be stalled")
+	}
+}
+
+func TestNextCleanupDelayJitter(t *testing.T) {
+	manager := NewManager(&SessionOptions{
+		MaxRetries:      10,
+		SessionTimeout:  time.Hour,
+		CleanupInterval: 10 * time.Minute,
+		CleanupJitter:   time.Minute,
+	})
+	// Stop the cleanup loop so the random source can be swapped safely
+	manager.Close()
+
+	base, jitter := 10*time.Minute, time.Minute
+
+	// Pin the random source to its extremes
+	manager.randInt64N = func(n int64) int64 { return 0 }
+	if got := manager.nextCleanupDelay(); got != base-jitter {
+		t.Errorf("Expected minimum delay %v, got %v", base-jitter, got)
+	}
+	manager.randInt64N = func(n int64) int64 { return n - 1 }
+	if got := manager.nextCleanupDelay(); got != base+jitter {
+		t.Errorf("Expected maximum delay %v, got %v", base+jitter, got)
+	}
+
+	// Over many cycles the delays stay in range and actually vary
+	manager.randInt64N = rand.Int64N
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		delay := manager.nextCleanupDelay()
+		if delay < base-jitter || delay > base+jitter {
+			t.Fatalf("Delay %v outside %v±%v", delay, base, jitter)
+		}
+		seen[delay] = true
+	}
+	if len(seen) < 2 {
+		t.Error("Expected jittered delays to vary between cycles")
+	}
+
+	if status := manager.CleanupStatus(); status.Interval != base+jitter {
+		t.Errorf("Expected stall detection to allow for jitter, got interval %v", status.Interval)
+	}
+}
+
+func TestCleanupJitterCappedAtHalfInterval(t *testing.T) {
+	manager := NewManager(&SessionOptions{
+		MaxRetries:      10,
+		SessionTimeout:  time.Hour,
+		CleanupInterval: time.Minute,
+		CleanupJitter:   time.Hour,
+	})
+	manager.Close()
+
+	manager.randInt64N = func(n int64) int64 { return 0 }
+	if got := manager.nextCleanupDelay(); got != 30*time.Second {
+		t.Errorf("Expected jitter capped at half the interval, got delay %v", got)
+	}
+}
+
+func TestCleanupBatchIncremental(t *testing.T) {
+	manager := NewManager(&SessionOptions{
+		MaxRetries:       10,
+		SessionTimeout:   time.Hour,
+		CleanupBatchSize: 10,
+	})
+	// Stop the cleanup loop so the clock can be swapped safely
+	manager.Close()
+
+	now := time.Now()
+	manager.SetClock(func() time.Time { return now })
+
+	ctx := context.Background()
+	const total = 35
+	for i := 0; i < total; i++ {
+		if _, err := manager.CreateSession(ctx, nil); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+	}
+
+	// Expire every session, then run cleanup tick by tick
+	now = now.Add(2 * time.Hour)
+	remaining := total
+	for _, want := range []int{10, 10, 10, 5} {
+		removed := manager.cleanupBatch()
+		if removed != want {
+			t.Fatalf("Expected run to remove %d sessions, got %d", want, removed)
+		}
+		remaining -= removed
+		if count := manager.GetSessionCount(); count != remaining {
+			t.Fatalf("Expected %d sessions left, got %d", remaining, count)
+		}
+	}
+
+	if removed := manager.cleanupBatch(); removed != 0 {
+		t.Errorf("Expected nothing left to clean up, removed %d", removed)
+	}
+}
+
+// collidingGenerator returns taken for the first n calls and fresh afterwards.
+func collidingGenerator(taken, fresh string, n int) func() string {
+	calls := 0
+	return func() string {
+		calls++
+		if calls <= n {
+			return taken
+		}
+		return fresh
+	}
+}
+
+func TestGenerateUniqueCodeRetriesCollisions(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.Close()
+
+	existing, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	ctx := context.Background()
+	code, err := manager.GenerateUniqueCode(ctx, collidingGenerator(strings.ToUpper(existing.Code), "Brave-Otter-7", 3), 3)
+	if err != nil {
+		t.Fatalf("expected retry to succeed after 3 collisions, got: %v", err)
+	}
+	if code != "brave-otter-7" {
+		t.Errorf("expected normalized fresh code, got %q", code)
+	}
+
+	_, err = manager.GenerateUniqueCode(ctx, collidingGenerator(existing.Code, "brave-otter-7", 4), 3)
+	if err != ErrCodeGenerationFailed {
+		t.Errorf("expected ErrCodeGenerationFailed after exhausting retries, got: %v", err)
+	}
+
+	_, err = manager.GenerateUniqueCode(ctx, func() string { return "not a code" }, 2)
+	if err != ErrCodeGenerationFailed {
+		t.Errorf("expected malformed codes to fail generation, got: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = manager.GenerateUniqueCode(cancelled, collidingGenerator(existing.Code, "brave-otter-7", 1), 3)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation between attempts, got: %v", err)
+	}
+}
+
+func TestManagerNormalizeCodeAcrossLookups(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	variant := "  " + strings.ToUpper(session.Code) + " "
+
+	if got := manager.NormalizeCode(variant); got != session.Code {
+		t.Errorf("NormalizeCode(%q) = %q, want %q", variant, got, session.Code)
+	}
+
+	if err := manager.UpdateSessionData(variant, map[string]interface{}{"k": "v"}); err != nil {
+		t.Errorf("UpdateSessionData with case variant failed: %v", err)
+	}
+	if _, err := manager.PeekSession(variant); err != nil {
+		t.Errorf("PeekSession with case variant failed: %v", err)
+	}
+	if !manager.DeleteSession(variant) {
+		t.Error("DeleteSession with case variant should delete the session")
+	}
+
+	sensitive := NewManager(&SessionOptions{MaxRetries: 10, SessionTimeout: time.Hour, CaseSensitive: true})
+	defer sensitive.Close()
+	if got := sensitive.NormalizeCode(" Happy-Panda-42 "); got != "Happy-Panda-42" {
+		t.Errorf("case-sensitive NormalizeCode should only trim, got %q", got)
+	}
+}
+
+func TestUpdateSessionDataMaxDataKeys(t *testing.T) {
+	manager := NewManager(&SessionOptions{MaxRetries: 10, SessionTimeout: time.Hour, MaxDataKeys: 2})
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	if err := manager.UpdateSessionData(session.Code, map[string]interface{}{"a": 1, "b": 2}); err != nil {
+		t.Fatalf("Updating up to the cap should succeed: %v", err)
+	}
+
+	err = manager.UpdateSessionData(session.Code, map[string]interface{}{"a": 10, "c": 3})
+	if err != ErrDataKeyLimitReached {
+		t.Errorf("Expected ErrDataKeyLimitReached when adding a key beyond the cap, got %v", err)
+	}
+
+	peeked, _ := manager.PeekSession(session.Code)
+	if peeked.Data["a"] != 1 || len(peeked.Data) != 2 {
+		t.Errorf("Rejected update should leave data unchanged, got %v", peeked.Data)
+	}
+
+	if err := manager.UpdateSessionData(session.Code, map[string]interface{}{"a": 10, "b": 20}); err != nil {
+		t.Errorf("Updating existing keys at the cap should succeed: %v", err)
+	}
+
+	_, err = manager.CreateSession(context.Background(), &SessionOptions{
+		MaxRetries:  10,
+		InitialData: map[string]interface{}{"x": 1, "y": 2, "z": 3},
+	})
+	if err != ErrDataKeyLimitReached {
+		t.Errorf("Expected ErrDataKeyLimitReached for oversized initial data, got %v", err)
+	}
+}
+
+func TestIncrementSessionValue(t *testing.T) {
+	manager := NewManager(&SessionOptions{MaxRetries: 10, SessionTimeout: time.Hour})
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), &SessionOptions{
+		MaxRetries:  10,
+		InitialData: map[string]interface{}{"score": float64(10), "name": "panda", "half": 1.5},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	value, err := manager.IncrementSessionValue(session.Code, "turn", 3)
+	if err != nil || value != 3 {
+		t.Errorf("Missing key should be initialized to delta, got %d, %v", value, err)
+	}
+
+	value, err = manager.IncrementSessionValue(session.Code, "score", -4)
+	if err != nil || value != 6 {
+		t.Errorf("Expected score 6 after decrement, got %d, %v", value, err)
+	}
+
+	if _, err := manager.IncrementSessionValue(session.Code, "name", 1); err != ErrValueNotNumeric {
+		t.Errorf("Expected ErrValueNotNumeric for string value, got %v", err)
+	}
+	if _, err := manager.IncrementSessionValue(session.Code, "half", 1); err != ErrValueNotNumeric {
+		t.Errorf("Expected ErrValueNotNumeric for fractional value, got %v", err)
+	}
+
+	if _, err := manager.IncrementSessionValue("missing-code-99", "score", 1); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestIncrementSessionValueConcurrent(t *testing.T) {
+	manager := NewManager(&SessionOptions{MaxRetries: 10, SessionTimeout: time.Hour})
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	const goroutines = 50
+	const increments = 100
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(delta int64) {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				if _, err := manager.IncrementSessionValue(session.Code, "counter", delta); err != nil {
+					t.Errorf("IncrementSessionValue failed: %v", err)
+					return
+				}
+			}
+		}(int64(i + 1))
+	}
+	wg.Wait()
+
+	// Each goroutine i adds (i+1) increments times
+	want := int64(increments * goroutines * (goroutines + 1) / 2)
+	peeked, _ := manager.PeekSession(session.Code)
+	if peeked.Data["counter"] != want {
+		t.Errorf("Expected counter %d, got %v", want, peeked.Data["counter"])
+	}
+}
+
+func TestReconnectToken(t *testing.T) {
+	manager := NewManager(&SessionOptions{MaxRetries: 10, SessionTimeout: time.Hour})
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	if manager.VerifyReconnectToken(session.Code, "anything") {
+		t.Error("A session without a token should verify none")
+	}
+	if manager.HasReconnectToken(session.Code) {
+		t.Error("A session without a token should not report one")
+	}
+
+	first, err := manager.IssueReconnectToken(session.Code)
+	if err != nil {
+		t.Fatalf("IssueReconnectToken failed: %v", err)
+	}
+	if !manager.HasReconnectToken(session.Code) {
+		t.Error("A session should report its issued token")
+	}
+	if !manager.VerifyReconnectToken(session.Code, first) {
+		t.Error("The issued token should verify")
+	}
+
+	second, err := manager.IssueReconnectToken(session.Code)
+	if err != nil {
+		t.Fatalf("IssueReconnectToken failed: %v", err)
+	}
+	if manager.VerifyReconnectToken(session.Code, first) {
+		t.Error("The replaced token should no longer verify")
+	}
+	if !manager.VerifyReconnectToken(session.Code, second) {
+		t.Error("The new token should verify")
+	}
+
+	peeked, _ := manager.PeekSession(session.Code)
+	data, _ := json.Marshal(peeked)
+	if strings.Contains(string(data), second) {
+		t.Errorf("Only the token hash should be serialized: %s", data)
+	}
+	var stored Session
+	if err := json.Unmarshal(data, &stored); err != nil || !bytes.Equal(stored.ReconnectTokenHash, peeked.ReconnectTokenHash) {
+		t.Errorf("The token hash should round-trip through JSON, got %v, %v", stored.ReconnectTokenHash, err)
+	}
+
+	if _, err := manager.IssueReconnectToken("missing-session-99"); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package session
+
+import "encoding/json"
+
+// dataSize approximates the memory held by session data as the length of its
+// JSON encoding. Data that cannot be encoded counts as zero.
+func dataSize(data map[string]interface{}) int {
+	if len(data) == 0 {
+		return 0
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
+
+// exceedsDataBytes reports whether a single session holding size bytes of
+// data would be over the manager's MaxTotalDataBytes ceiling on its own, so
+// no amount of eviction could make room for it.
+func (m *Manager) exceedsDataBytes(size int) bool {
+	return m.options.MaxTotalDataBytes > 0 && size > m.options.MaxTotalDataBytes
+}
+
+// resizeLocked records size as the data size of session, updating the
+// manager's total, after its Data changed. The caller must hold the write
+// lock.
+func (m *Manager) resizeLocked(session *Session, size int) {
+	m.dataBytes += size - session.dataSize
+	session.dataSize = size
+}
+
+// touchLocked moves the session stored under code to the front of the
+// recency list, adding it if it is not listed yet. It is called whenever a
+// session is stored or its LastAccessed updated, so the back of the list is
+// always the least recently accessed session. The caller must hold the write
+// lock.
+func (m *Manager) touchLocked(code string, session *Session) {
+	if session.recency == nil {
+		session.recency = m.recency.PushFront(code)
+		return
+	}
+	session.recency.Value = code
+	m.recency.MoveToFront(session.recency)
+}
+
+// deleteLocked removes the session stored under code, from memory and from
+// the store, and runs the delete callback. The caller must hold the write
+// lock.
+func (m *Manager) deleteLocked(code string) {
+	if m.dropLocked(code) {
+		m.queueDeleteLocked(code)
+		if m.onDelete != nil {
+			m.onDelete(code)
+		}
+	}
+}
+
+// dropLocked removes the session stored under code from memory only,
+// dropping its data from the manager's total and its owner's count, and
+// reports whether it was there. A copy in the store is kept and can be loaded
+// again. The caller must hold the write lock.
+func (m *Manager) dropLocked(code string) bool {
+	session, ok := m.sessions[code]
+	if !ok {
+		return false
+	}
+	m.dataBytes -= session.dataSize
+	m.untrackOwnerLocked(session)
+	if session.recency != nil {
+		m.recency.Remove(session.recency)
+		session.recency = nil
+	}
+	delete(m.sessions, code)
+	return true
+}
+
+// evictForMemoryLocked drops least recently accessed sessions from memory,
+// never the one stored under keep, until the total data size is within
+// MaxTotalDataBytes. Eviction only frees the cache: sessions stay in the
+// store, if any, and are loaded again when next looked up. Sessions with
+// changes not yet written to the store are skipped, as dropping them would
+// lose the changes; an access the store has not seen yet is queued so the
+// session can be evicted once it is written. The caller must hold the write
+// lock.
+func (m *Manager) evictForMemoryLocked(keep string) {
+	limit := m.options.MaxTotalDataBytes
+	oldest := m.recency.Back()
+	for limit > 0 && m.dataBytes > limit && oldest != nil {
+		code := oldest.Value.(string)
+		newer := oldest.Prev()
+		if _, unwritten := m.storeWrites[code]; code != keep && !unwritten {
+			if session := m.sessions[code]; m.store != nil && session.LastAccessed.After(session.storedAccess) {
+				m.queueSaveLocked(code)
+			} else {
+				m.dropLocked(code)
+				m.memoryEvictions.Add(1)
+				if m.store != nil {
+					m.evicted[code] = session.LastAccessed
+				}
+			}
+		}
+		oldest = newer
+	}
+}
+
+// removeExpiredEvictedLocked deletes the sessions evicted from memory whose
+// stored copies have expired from the store, as nothing else would look at
+// them again, and returns how many were removed. The caller must hold the
+// write lock.
+func (m *Manager) removeExpiredEvictedLocked() int {
+	removed := 0
+	for code, lastAccessed := range m.evicted {
+		if m.expiredSince(lastAccessed) {
+			delete(m.evicted, code)
+			m.queueDeleteLocked(code)
+			if m.onDelete != nil {
+				m.onDelete(code)
+			}
+			removed++
+		}
+	}
+	return removed
+}
+
+// DataBytes returns the approximate total size, in bytes of JSON, of the data
+// held by all sessions.
+func (m *Manager) DataBytes() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.dataBytes
+}
+
+// MemoryEvictions returns how many sessions have been evicted to keep the
+// total data size within MaxTotalDataBytes.
+func (m *Manager) MemoryEvictions() uint64 {
+	return m.memoryEvictions.Load()
+}
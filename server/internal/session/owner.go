@@ -0,0 +1,124 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OwnerLimitPolicy determines what happens when an owner creating a session
+// already holds MaxSessionsPerOwner sessions.
+type OwnerLimitPolicy int
+
+const (
+	// OwnerLimitReject fails the creation with ErrOwnerSessionLimitReached.
+	OwnerLimitReject OwnerLimitPolicy = iota
+
+	// OwnerLimitEvictOldest deletes the owner's oldest session to make room
+	// for the new one.
+	OwnerLimitEvictOldest
+)
+
+// String returns the configuration name of the policy.
+func (p OwnerLimitPolicy) String() string {
+	switch p {
+	case OwnerLimitEvictOldest:
+		return "evict-oldest"
+	default:
+		return "reject"
+	}
+}
+
+// ParseOwnerLimitPolicy parses "reject" or "evict-oldest" (case-insensitive).
+func ParseOwnerLimitPolicy(name string) (OwnerLimitPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "reject":
+		return OwnerLimitReject, nil
+	case "evict-oldest":
+		return OwnerLimitEvictOldest, nil
+	default:
+		return OwnerLimitReject, fmt.Errorf("unknown owner limit policy %q", name)
+	}
+}
+
+// MaxSessionsPerOwner returns the configured per-owner session cap (zero
+// means unlimited).
+func (m *Manager) MaxSessionsPerOwner() int {
+	if m.options.MaxSessionsPerOwner < 0 {
+		return 0
+	}
+	return m.options.MaxSessionsPerOwner
+}
+
+// SessionCountByOwner returns the number of live sessions held by owner.
+// Sessions without an owner are not counted.
+func (m *Manager) SessionCountByOwner(owner string) int {
+	if owner == "" {
+		return 0
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.ownerSessions[owner]
+}
+
+// trackOwnerLocked counts a session just stored in the sessions map against
+// its owner. The caller must hold the write lock.
+func (m *Manager) trackOwnerLocked(session *Session) {
+	if session.Owner != "" {
+		m.ownerSessions[session.Owner]++
+	}
+}
+
+// untrackOwnerLocked stops counting a session removed from the sessions map.
+// The caller must hold the write lock.
+func (m *Manager) untrackOwnerLocked(session *Session) {
+	if session.Owner == "" {
+		return
+	}
+	if m.ownerSessions[session.Owner] <= 1 {
+		delete(m.ownerSessions, session.Owner)
+		return
+	}
+	m.ownerSessions[session.Owner]--
+}
+
+// makeRoomForOwnerLocked applies the owner limit policy before a session for
+// owner is stored: if owner already holds MaxSessionsPerOwner live sessions it
+// either returns ErrOwnerSessionLimitReached or deletes the owner's oldest
+// sessions until there is room. Expired sessions awaiting cleanup are removed
+// first so they do not count against the cap. The caller must hold the write
+// lock.
+func (m *Manager) makeRoomForOwnerLocked(owner string) error {
+	max := m.MaxSessionsPerOwner()
+	if owner == "" || max == 0 || m.ownerSessions[owner] < max {
+		return nil
+	}
+	m.removeExpiredLocked()
+	if m.ownerSessions[owner] < max {
+		return nil
+	}
+	if m.options.OwnerLimitPolicy != OwnerLimitEvictOldest {
+		return ErrOwnerSessionLimitReached
+	}
+
+	for m.ownerSessions[owner] >= max {
+		oldestCode := ""
+		var oldest *Session
+		for code, session := range m.sessions {
+			if session.Owner == owner && (oldest == nil || session.CreatedAt.Before(oldest.CreatedAt)) {
+				oldestCode, oldest = code, session
+			}
+		}
+		if oldest == nil {
+			break
+		}
+		m.deleteLocked(oldestCode)
+		m.ownerEvictions.Add(1)
+	}
+	return nil
+}
+
+// OwnerEvictions returns how many sessions have been evicted to keep owners
+// within MaxSessionsPerOwner.
+func (m *Manager) OwnerEvictions() uint64 {
+	return m.ownerEvictions.Load()
+}
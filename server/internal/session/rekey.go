@@ -0,0 +1,102 @@
+package session
+
+import (
+	"context"
+	"errors"
+)
+
+// RekeySession moves the session stored under code to a freshly generated
+// unique code, keeping its data and reconnect token, and returns a copy of
+// the moved session. The old code stops resolving immediately. Idempotency
+// keys that created the session follow it to the new code. If the move
+// cannot be written to the store the session stays under its old code.
+func (m *Manager) RekeySession(ctx context.Context, code string) (*Session, error) {
+	if code == "" || !m.generator.IsValidFormat(code) {
+		return nil, ErrInvalidSessionCode
+	}
+
+	oldCode := m.generator.NormalizeCode(code)
+	if err := m.loadFromStore(oldCode); err != nil {
+		return nil, err
+	}
+
+	newCode, err := m.GenerateUniqueCode(ctx, m.generator.GenerateCode, m.options.MaxRetries)
+	if err != nil {
+		return nil, err
+	}
+
+	// The code was unique when generated, but the lock is released before
+	// the move
+	rekeyed, err := m.moveSession(oldCode, newCode)
+	if errors.Is(err, ErrSessionCodeInUse) {
+		return nil, ErrCodeGenerationFailed
+	}
+	return rekeyed, err
+}
+
+// RevertRekey moves a session that RekeySession moved to newCode back to
+// oldCode, for when a later step of the rekey fails. It returns
+// ErrSessionCodeInUse if oldCode has been taken since.
+func (m *Manager) RevertRekey(newCode, oldCode string) error {
+	if !m.generator.IsValidFormat(newCode) || !m.generator.IsValidFormat(oldCode) {
+		return ErrInvalidSessionCode
+	}
+
+	_, err := m.moveSession(m.generator.NormalizeCode(newCode), m.generator.NormalizeCode(oldCode))
+	return err
+}
+
+// moveSession moves the session stored under the normalized code from to the
+// unused normalized code to and returns a copy of the moved session. When the
+// move cannot be written to the store it is undone in memory, queuing the
+// store writes that undo it, and the store error is returned.
+func (m *Manager) moveSession(from, to string) (*Session, error) {
+	m.mutex.Lock()
+	session, exists := m.sessions[from]
+	if !exists {
+		m.mutex.Unlock()
+		return nil, ErrSessionNotFound
+	}
+	if m.isExpired(session) {
+		m.deleteLocked(from)
+		m.mutex.Unlock()
+		m.syncStore()
+		return nil, ErrSessionExpired
+	}
+	if _, taken := m.sessions[to]; taken {
+		m.mutex.Unlock()
+		return nil, ErrSessionCodeInUse
+	}
+
+	m.moveLocked(from, to, session)
+	moved := copySession(session)
+	m.mutex.Unlock()
+
+	if err := m.syncStore(); err != nil {
+		m.mutex.Lock()
+		if _, taken := m.sessions[from]; !taken && m.sessions[to] == session {
+			m.moveLocked(to, from, session)
+		}
+		m.mutex.Unlock()
+		return nil, err
+	}
+	return moved, nil
+}
+
+// moveLocked moves session from code from to code to, along with the
+// idempotency keys that created it, and queues the store writes. The caller
+// must hold the write lock.
+func (m *Manager) moveLocked(from, to string, session *Session) {
+	delete(m.sessions, from)
+	session.Code = to
+	session.LastAccessed = m.now()
+	m.sessions[to] = session
+	m.touchLocked(to, session)
+	for _, reservation := range m.idempotencyKeys {
+		if reservation.code == from {
+			reservation.code = to
+		}
+	}
+	m.queueDeleteLocked(from)
+	m.queueSaveLocked(to)
+}
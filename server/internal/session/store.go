@@ -0,0 +1,245 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultStoreRetryInterval is how often writes that failed to reach the
+// Store are retried when SessionOptions.StoreRetryInterval is not set.
+const DefaultStoreRetryInterval = 5 * time.Second
+
+// Store persists sessions outside the Manager, e.g. in Redis, so they outlive
+// the process and can be shared between instances. The Manager keeps live
+// sessions in memory as a cache in front of the store: lookups of sessions it
+// does not hold read through to the store, and changes are written behind.
+type Store interface {
+	// Load returns the session stored under code, or ErrSessionNotFound.
+	Load(ctx context.Context, code string) (*Session, error)
+
+	// Save stores session under its code, replacing any previous version.
+	Save(ctx context.Context, session *Session) error
+
+	// Delete removes the session stored under code. Deleting a session that
+	// is not stored is not an error.
+	Delete(ctx context.Context, code string) error
+}
+
+// queueSaveLocked schedules the session stored under code to be written to
+// the store. The caller must hold the write lock.
+func (m *Manager) queueSaveLocked(code string) {
+	if m.store != nil {
+		m.storeWrites[code] = true
+		if session, ok := m.sessions[code]; ok {
+			session.storedAccess = session.LastAccessed
+		}
+	}
+}
+
+// touchInterval is how far a session's LastAccessed may run ahead of the
+// copy in the store before a plain access writes it again. Reads would
+// otherwise write through on every call; a tenth of SessionTimeout bounds
+// how much earlier the stored copy can look expired than the cached one.
+func (m *Manager) touchInterval() time.Duration {
+	return m.options.SessionTimeout / 10
+}
+
+// queueTouchLocked schedules the session stored under code to be written to
+// the store if its LastAccessed has run touchInterval ahead of the stored
+// copy. The caller must hold the write lock.
+func (m *Manager) queueTouchLocked(code string, session *Session) {
+	if session.LastAccessed.Sub(session.storedAccess) >= m.touchInterval() {
+		m.queueSaveLocked(code)
+	}
+}
+
+// queueDeleteLocked schedules code to be deleted from the store. The caller
+// must hold the write lock.
+func (m *Manager) queueDeleteLocked(code string) {
+	if m.store != nil {
+		m.storeWrites[code] = false
+	}
+}
+
+// syncStore writes the queued changes to the store. Failed writes stay
+// queued for the reconcile loop, and the manager reports itself degraded
+// until they succeed. The error is only returned when StoreFallback is off;
+// with it on, callers carry on serving from memory, and while the manager is
+// degraded their changes are only queued, leaving the store to the reconcile
+// loop rather than making every caller wait for it to time out.
+func (m *Manager) syncStore() error {
+	if m.store == nil {
+		return nil
+	}
+	if m.options.StoreFallback && m.storeDegraded.Load() {
+		return nil
+	}
+	if err := m.flushStore(); err != nil && !m.options.StoreFallback {
+		return err
+	}
+	return nil
+}
+
+// flushStore writes every queued change to the store, one at a time. It stops
+// at the first failure, requeuing the changes not yet written unless they
+// were superseded meanwhile, and returns the error.
+func (m *Manager) flushStore() error {
+	m.storeMu.Lock()
+	defer m.storeMu.Unlock()
+
+	m.mutex.Lock()
+	type storeWrite struct {
+		code    string
+		session *Session
+	}
+	writes := make([]storeWrite, 0, len(m.storeWrites))
+	for code, save := range m.storeWrites {
+		write := storeWrite{code: code}
+		if session, ok := m.sessions[code]; ok && save {
+			write.session = copySession(session)
+		}
+		writes = append(writes, write)
+	}
+	clear(m.storeWrites)
+	m.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.storeRetryInterval)
+	defer cancel()
+
+	for i, write := range writes {
+		var err error
+		if write.session != nil {
+			err = m.store.Save(ctx, write.session)
+		} else {
+			err = m.store.Delete(ctx, write.code)
+		}
+		if err == nil {
+			continue
+		}
+
+		m.mutex.Lock()
+		for _, unwritten := range writes[i:] {
+			if _, superseded := m.storeWrites[unwritten.code]; !superseded {
+				m.storeWrites[unwritten.code] = unwritten.session != nil
+			}
+		}
+		m.mutex.Unlock()
+
+		m.storeDegraded.Store(true)
+		return fmt.Errorf("failed to write session %s to store: %w", write.code, err)
+	}
+
+	if len(writes) > 0 {
+		m.storeDegraded.Store(false)
+	}
+	return nil
+}
+
+// loadFromStore reads the session stored under the normalized code into
+// memory if the manager does not already hold it, so the caller's lookup
+// finds it. A missing session is not an error: the lookup then reports it
+// as not found, as it does for an expired one, which is deleted from the
+// store instead of loaded. When the store fails the manager is marked degraded and,
+// under StoreFallback, the lookup is served from memory alone.
+func (m *Manager) loadFromStore(code string) error {
+	if m.store == nil {
+		return nil
+	}
+
+	m.mutex.RLock()
+	_, cached := m.sessions[code]
+	_, queued := m.storeWrites[code]
+	m.mutex.RUnlock()
+	if cached || queued {
+		// A queued delete must not resurrect the stored copy
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.storeRetryInterval)
+	defer cancel()
+
+	session, err := m.store.Load(ctx, code)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		m.storeDegraded.Store(true)
+		if m.options.StoreFallback {
+			return nil
+		}
+		return fmt.Errorf("failed to load session %s from store: %w", code, err)
+	}
+
+	// Pending writes keep the manager degraded until they are flushed
+	m.mutex.RLock()
+	m.storeDegraded.Store(len(m.storeWrites) > 0)
+	m.mutex.RUnlock()
+	if err != nil {
+		return nil
+	}
+
+	m.mutex.Lock()
+	_, exists := m.sessions[code]
+	switch {
+	case exists:
+	case m.isExpired(session):
+		delete(m.evicted, code)
+		m.queueDeleteLocked(code)
+		if m.onDelete != nil {
+			m.onDelete(code)
+		}
+	default:
+		delete(m.evicted, code)
+		session.storedAccess = session.LastAccessed
+		m.sessions[code] = session
+		m.trackOwnerLocked(session)
+		m.touchLocked(code, session)
+		m.resizeLocked(session, dataSize(session.Data))
+		m.evictForMemoryLocked(code)
+	}
+	m.mutex.Unlock()
+	return nil
+}
+
+// reconcileStore retries queued store writes every retry interval until
+// Close is called, bringing the store up to date once it recovers.
+func (m *Manager) reconcileStore() {
+	defer close(m.storeDone)
+
+	ticker := time.NewTicker(m.storeRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.mutex.RLock()
+			queued := len(m.storeWrites)
+			m.mutex.RUnlock()
+			if queued > 0 {
+				m.flushStore()
+			}
+		case <-m.stopCleanup:
+			return
+		}
+	}
+}
+
+// StoreDegraded reports whether the last read from or write to the Store
+// failed, meaning sessions are being served from memory and some changes may
+// not have reached the store yet. It is always false without a Store.
+func (m *Manager) StoreDegraded() bool {
+	return m.storeDegraded.Load()
+}
+
+// copySession returns a copy of session whose Data map is not shared. The
+// copy is detached from the manager's memory accounting, so it can be stored
+// again, as when loaded back from the store, and be counted afresh.
+func copySession(session *Session) *Session {
+	copied := *session
+	copied.dataSize = 0
+	copied.recency = nil
+	copied.Data = make(map[string]interface{}, len(session.Data))
+	for k, v := range session.Data {
+		copied.Data[k] = v
+	}
+	return &copied
+}
@@ -0,0 +1,540 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errStoreDown simulates a store backend outage.
+var errStoreDown = errors.New("store unavailable")
+
+// memoryStore is a Store backed by a map whose calls fail while down is set.
+type memoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	down     bool
+	saves    int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{sessions: make(map[string]*Session)}
+}
+
+func (s *memoryStore) Load(ctx context.Context, code string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, errStoreDown
+	}
+	session, ok := s.sessions[code]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return copySession(session), nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saves++
+	if s.down {
+		return errStoreDown
+	}
+	s.sessions[session.Code] = copySession(session)
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errStoreDown
+	}
+	delete(s.sessions, code)
+	return nil
+}
+
+func (s *memoryStore) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *memoryStore) saveCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves
+}
+
+func (s *memoryStore) get(code string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[code]
+	return session, ok
+}
+
+func TestStoreReadThrough(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+
+	first := NewManager(options)
+	defer first.Close()
+	session, err := first.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := first.SetSessionValue(session.Code, "score", 7); err != nil {
+		t.Fatalf("SetSessionValue failed: %v", err)
+	}
+
+	// A second manager sharing the store finds the session
+	second := NewManager(options)
+	defer second.Close()
+	loaded, err := second.GetSession(session.Code)
+	if err != nil {
+		t.Fatalf("GetSession through the store failed: %v", err)
+	}
+	if loaded.Data["score"] != 7 {
+		t.Errorf("expected score 7, got %v", loaded.Data["score"])
+	}
+
+	if !first.DeleteSession(session.Code) {
+		t.Fatal("DeleteSession should find the session")
+	}
+	if _, ok := store.get(session.Code); ok {
+		t.Error("deleted session should be removed from the store")
+	}
+}
+
+func TestStoreFallback(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+	options.StoreFallback = true
+	options.StoreRetryInterval = 10 * time.Millisecond
+	manager := NewManager(options)
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	store.setDown(true)
+
+	// Cached sessions are still served and updated
+	if err := manager.SetSessionValue(session.Code, "level", 2); err != nil {
+		t.Fatalf("SetSessionValue should succeed from the cache, got %v", err)
+	}
+	cached, err := manager.GetSession(session.Code)
+	if err != nil {
+		t.Fatalf("GetSession should succeed from the cache, got %v", err)
+	}
+	if cached.Data["level"] != 2 {
+		t.Errorf("expected level 2, got %v", cached.Data["level"])
+	}
+	if !manager.StoreDegraded() {
+		t.Error("manager should be degraded while the store is down")
+	}
+
+	// Once the store recovers the queued write reaches it
+	store.setDown(false)
+	deadline := time.Now().Add(2 * time.Second)
+	for manager.StoreDegraded() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if manager.StoreDegraded() {
+		t.Fatal("manager should recover once the store is back")
+	}
+	stored, ok := store.get(session.Code)
+	if !ok || stored.Data["level"] != 2 {
+		t.Errorf("expected the store to hold level 2, got %v", stored)
+	}
+}
+
+func TestStoreFallbackQueuesWhileDegraded(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+	options.StoreFallback = true
+	options.StoreRetryInterval = time.Hour // Keep the reconcile loop out of the way
+	manager := NewManager(options)
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	store.setDown(true)
+	if err := manager.SetSessionValue(session.Code, "level", 1); err != nil {
+		t.Fatalf("SetSessionValue should succeed from the cache, got %v", err)
+	}
+	if !manager.StoreDegraded() {
+		t.Fatal("manager should be degraded after a failed write")
+	}
+
+	// Once degraded, changes are queued without calling the store
+	calls := store.saveCalls()
+	for i := 2; i <= 5; i++ {
+		if err := manager.SetSessionValue(session.Code, "level", i); err != nil {
+			t.Fatalf("SetSessionValue should succeed from the cache, got %v", err)
+		}
+	}
+	if _, err := manager.MarkDisconnected(session.Code); err != nil {
+		t.Fatalf("MarkDisconnected should succeed from the cache, got %v", err)
+	}
+	if got := store.saveCalls(); got != calls {
+		t.Errorf("expected no store calls while degraded, got %d", got-calls)
+	}
+
+	// The reconcile loop's flush writes the latest state
+	store.setDown(false)
+	if err := manager.flushStore(); err != nil {
+		t.Fatalf("flushStore failed: %v", err)
+	}
+	stored, ok := store.get(session.Code)
+	if !ok || stored.Data["level"] != 5 || stored.DisconnectedAt.IsZero() {
+		t.Errorf("expected the store to hold the latest state, got %+v", stored)
+	}
+	if manager.StoreDegraded() {
+		t.Error("manager should recover once the queued writes are flushed")
+	}
+}
+
+func TestStoreWithoutFallback(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+	manager := NewManager(options)
+	defer manager.Close()
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	store.setDown(true)
+	if err := manager.SetSessionValue(session.Code, "level", 2); !errors.Is(err, errStoreDown) {
+		t.Errorf("expected the store error, got %v", err)
+	}
+	if _, err := manager.GetSession("missing-code-99"); !errors.Is(err, errStoreDown) {
+		t.Errorf("expected the store error on read-through, got %v", err)
+	}
+	if _, err := manager.RestoreSession(session.Code); !errors.Is(err, errStoreDown) {
+		t.Errorf("expected the store error from RestoreSession, got %v", err)
+	}
+	if _, err := manager.MarkDisconnected(session.Code); !errors.Is(err, errStoreDown) {
+		t.Errorf("expected the store error from MarkDisconnected, got %v", err)
+	}
+	if _, err := manager.RemoveSession(session.Code); !errors.Is(err, errStoreDown) {
+		t.Errorf("expected the store error from RemoveSession, got %v", err)
+	}
+	if !manager.StoreDegraded() {
+		t.Error("manager should be degraded while the store is down")
+	}
+}
+
+func TestStoreMutatorsReadThrough(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+
+	first := NewManager(options)
+	defer first.Close()
+	session, err := first.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// Each mutator on a manager that has not cached the session loads it
+	// from the store first
+	for name, mutate := range map[string]func(m *Manager) error{
+		"UpdateSessionData": func(m *Manager) error {
+			return m.UpdateSessionData(session.Code, map[string]interface{}{"level": 2})
+		},
+		"IncrementSessionValue": func(m *Manager) error {
+			_, err := m.IncrementSessionValue(session.Code, "score", 1)
+			return err
+		},
+		"MarkDisconnected": func(m *Manager) error {
+			_, err := m.MarkDisconnected(session.Code)
+			return err
+		},
+	} {
+		other := NewManager(options)
+		if err := mutate(other); err != nil {
+			t.Errorf("%s should find the stored session, got %v", name, err)
+		}
+		other.Close()
+	}
+
+	stored, ok := store.get(session.Code)
+	if !ok {
+		t.Fatal("session should still be stored")
+	}
+	if stored.Data["level"] != 2 || stored.Data["score"] != int64(1) || stored.DisconnectedAt.IsZero() {
+		t.Errorf("expected every change to reach the store, got %+v", stored)
+	}
+
+	other := NewManager(options)
+	defer other.Close()
+	if deleted, err := other.RemoveSession(session.Code); err != nil || !deleted {
+		t.Fatalf("RemoveSession should find the stored session, got %v, %v", deleted, err)
+	}
+	if _, ok := store.get(session.Code); ok {
+		t.Error("deleted session should be removed from the store")
+	}
+}
+
+func TestStoreMemoryEvictionKeepsStoredSession(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+	options.MaxTotalDataBytes = 100
+	manager := NewManager(options)
+	defer manager.Close()
+
+	value := strings.Repeat("x", 30)
+	var codes []string
+	for i := 0; i < 3; i++ {
+		session, err := manager.CreateSession(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if err := manager.SetSessionValue(session.Code, "v", value); err != nil {
+			t.Fatalf("SetSessionValue failed: %v", err)
+		}
+		codes = append(codes, session.Code)
+	}
+
+	// The third session's data pushed the first out of memory only
+	if got := manager.MemoryEvictions(); got != 1 {
+		t.Fatalf("expected 1 eviction, got %d", got)
+	}
+	if got := manager.GetSessionCount(); got != 2 {
+		t.Fatalf("expected 2 cached sessions, got %d", got)
+	}
+	if _, ok := store.get(codes[0]); !ok {
+		t.Fatal("evicted session should stay in the store")
+	}
+
+	// Looking it up loads it back
+	loaded, err := manager.GetSession(codes[0])
+	if err != nil {
+		t.Fatalf("GetSession of an evicted session failed: %v", err)
+	}
+	if loaded.Data["v"] != value {
+		t.Errorf("expected the stored data, got %v", loaded.Data)
+	}
+	for _, code := range codes {
+		if _, ok := store.get(code); !ok {
+			t.Errorf("session %s should still be stored", code)
+		}
+	}
+}
+
+func TestStoreWritesAccessBehind(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+	options.SessionTimeout = time.Hour
+	manager := NewManager(options)
+	defer manager.Close()
+	now := time.Now()
+	manager.SetClock(func() time.Time { return now })
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	saves := store.saveCalls()
+
+	// An access within the touch interval is not written through
+	now = now.Add(time.Minute)
+	if _, err := manager.GetSession(session.Code); err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if got := store.saveCalls(); got != saves {
+		t.Errorf("expected no save for a recent access, got %d saves", got-saves)
+	}
+
+	// One past it is, so the stored copy does not go stale
+	now = now.Add(10 * time.Minute)
+	if _, err := manager.GetSession(session.Code); err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	stored, ok := store.get(session.Code)
+	if !ok {
+		t.Fatal("session should be stored")
+	}
+	if !stored.LastAccessed.Equal(now) {
+		t.Errorf("expected stored LastAccessed %v, got %v", now, stored.LastAccessed)
+	}
+}
+
+func TestStoreDeletesExpiredEvictedSessions(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+	options.MaxTotalDataBytes = 100
+	options.SessionTimeout = time.Hour
+	manager := NewManager(options)
+	defer manager.Close()
+	now := time.Now()
+	manager.SetClock(func() time.Time { return now })
+
+	value := strings.Repeat("x", 30)
+	var codes []string
+	for i := 0; i < 3; i++ {
+		session, err := manager.CreateSession(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if err := manager.SetSessionValue(session.Code, "v", value); err != nil {
+			t.Fatalf("SetSessionValue failed: %v", err)
+		}
+		codes = append(codes, session.Code)
+	}
+	if got := manager.MemoryEvictions(); got != 1 {
+		t.Fatalf("expected 1 eviction, got %d", got)
+	}
+
+	// Cleanup deletes the evicted session from the store once it expires
+	now = now.Add(2 * time.Hour)
+	if removed := manager.Cleanup(); removed != 3 {
+		t.Errorf("expected 3 sessions removed, got %d", removed)
+	}
+	for _, code := range codes {
+		if _, ok := store.get(code); ok {
+			t.Errorf("expired session %s should be deleted from the store", code)
+		}
+	}
+}
+
+func TestStoreRejectsExpiredSessions(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+	options.SessionTimeout = time.Hour
+
+	first := NewManager(options)
+	defer first.Close()
+	session, err := first.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// Another manager finds the stored copy expired and deletes it
+	second := NewManager(options)
+	defer second.Close()
+	second.SetClock(func() time.Time { return time.Now().Add(2 * time.Hour) })
+	if _, err := second.GetSession(session.Code); err != ErrSessionNotFound {
+		t.Errorf("expected ErrSessionNotFound for an expired stored session, got %v", err)
+	}
+	if got := second.GetSessionCount(); got != 0 {
+		t.Errorf("expired session should not be cached, got %d sessions", got)
+	}
+	if _, ok := store.get(session.Code); ok {
+		t.Error("expired session should be deleted from the store")
+	}
+}
+
+func TestStoreReconnectToken(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+
+	first := NewManager(options)
+	defer first.Close()
+	session, err := first.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// A manager that only has the stored copy issues and verifies tokens
+	second := NewManager(options)
+	defer second.Close()
+	token, err := second.IssueReconnectToken(session.Code)
+	if err != nil {
+		t.Fatalf("IssueReconnectToken through the store failed: %v", err)
+	}
+
+	third := NewManager(options)
+	defer third.Close()
+	if !third.VerifyReconnectToken(session.Code, token) {
+		t.Error("token issued by another manager should verify through the store")
+	}
+	if first.VerifyReconnectToken(session.Code, "wrong") {
+		t.Error("wrong token should not verify")
+	}
+}
+
+func TestStoreCloseFlushesQueuedWrites(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+	options.StoreFallback = true
+	options.StoreRetryInterval = time.Hour // Keep the reconcile loop out of the way
+	manager := NewManager(options)
+
+	session, err := manager.CreateSession(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	store.setDown(true)
+	if err := manager.SetSessionValue(session.Code, "level", 3); err != nil {
+		t.Fatalf("SetSessionValue should succeed from the cache, got %v", err)
+	}
+
+	store.setDown(false)
+	if err := manager.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	stored, ok := store.get(session.Code)
+	if !ok || stored.Data["level"] != 3 {
+		t.Errorf("expected Close to write the queued change, got %+v", stored)
+	}
+}
+
+func TestStoreRekeyRollsBack(t *testing.T) {
+	store := newMemoryStore()
+	options := DefaultSessionOptions()
+	options.Store = store
+	options.StoreRetryInterval = time.Hour // Keep the reconcile loop out of the way
+	manager := NewManager(options)
+
+	ctx := context.Background()
+	session, err := manager.CreateSession(ctx, nil)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	store.setDown(true)
+	if _, err := manager.RekeySession(ctx, session.Code); !errors.Is(err, errStoreDown) {
+		t.Fatalf("expected the store error from RekeySession, got %v", err)
+	}
+	store.setDown(false)
+
+	if _, err := manager.GetSession(session.Code); err != nil {
+		t.Errorf("expected the session to stay under its old code, got %v", err)
+	}
+	if count := manager.GetSessionCount(); count != 1 {
+		t.Errorf("expected 1 session, got %d", count)
+	}
+
+	// The queued writes undo the move in the store too
+	if err := manager.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.sessions) != 1 || store.sessions[session.Code] == nil {
+		t.Errorf("expected only the old code in the store, got %v", store.sessions)
+	}
+}
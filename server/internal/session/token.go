@@ -0,0 +1,84 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+)
+
+// reconnectTokenBytes is the number of random bytes in a reconnect token.
+const reconnectTokenBytes = 32
+
+// IssueReconnectToken generates a new reconnect token for a session and
+// returns it. Only the token's SHA-256 hash is stored, replacing the hash of
+// any previous token, so earlier tokens stop verifying immediately and the
+// token cannot be recovered later. It returns the same errors as GetSession
+// but does not update LastAccessed.
+func (m *Manager) IssueReconnectToken(code string) (string, error) {
+	if code == "" || !m.generator.IsValidFormat(code) {
+		return "", ErrInvalidSessionCode
+	}
+
+	raw := make([]byte, reconnectTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate reconnect token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	hash := sha256.Sum256([]byte(token))
+
+	normalizedCode := m.generator.NormalizeCode(code)
+	if err := m.loadFromStore(normalizedCode); err != nil {
+		return "", err
+	}
+	defer m.syncStore()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	session, exists := m.sessions[normalizedCode]
+	if !exists {
+		return "", ErrSessionNotFound
+	}
+	if m.isExpired(session) {
+		m.deleteLocked(normalizedCode)
+		return "", ErrSessionExpired
+	}
+
+	session.ReconnectTokenHash = hash[:]
+	m.queueSaveLocked(normalizedCode)
+	return token, nil
+}
+
+// HasReconnectToken reports whether a reconnect token has been issued for the
+// live session stored under code. Such a session is only restored with its
+// token.
+func (m *Manager) HasReconnectToken(code string) bool {
+	session, err := m.PeekSession(code)
+	return err == nil && session.ReconnectTokenHash != nil
+}
+
+// VerifyReconnectToken reports whether token is the current reconnect token
+// of a live session. A session without a token verifies no token, as does
+// one the store could not be read for.
+func (m *Manager) VerifyReconnectToken(code, token string) bool {
+	if code == "" || token == "" || !m.generator.IsValidFormat(code) {
+		return false
+	}
+	hash := sha256.Sum256([]byte(token))
+
+	normalizedCode := m.generator.NormalizeCode(code)
+	if err := m.loadFromStore(normalizedCode); err != nil {
+		return false
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	session, exists := m.sessions[normalizedCode]
+	if !exists || m.isExpired(session) || session.ReconnectTokenHash == nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(hash[:], session.ReconnectTokenHash) == 1
+}
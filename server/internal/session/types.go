@@ -2,14 +2,27 @@
 package session
 
 import (
+	"container/list"
+	"math/rand/v2"
 	"time"
 )
 
+// DefaultSessionKind is the kind of sessions created without one.
+const DefaultSessionKind = "default"
+
 // Session represents an active session with its metadata.
 type Session struct {
 	// Code is the unique human-friendly session identifier (e.g., "happy-panda-42")
 	Code string `json:"code"`
 
+	// Kind distinguishes sessions serving different purposes (e.g. "game" or
+	// "admin"); it is set at creation and never changes
+	Kind string `json:"kind"`
+
+	// Owner identifies the principal that created the session; empty for
+	// anonymous sessions. It is set at creation and never changes
+	Owner string `json:"owner,omitempty"`
+
 	// CreatedAt is the timestamp when the session was created
 	CreatedAt time.Time `json:"created_at"`
 
@@ -18,6 +31,29 @@ type Session struct {
 
 	// Data is a generic map for storing session-specific data
 	Data map[string]interface{} `json:"data,omitempty"`
+
+	// Reconnects counts the successful RestoreSession calls for this session
+	Reconnects int `json:"reconnects"`
+
+	// DisconnectedAt is when the session's last connection closed, as recorded
+	// by MarkDisconnected; it is zero while a client is connected
+	DisconnectedAt time.Time `json:"disconnected_at,omitzero"`
+
+	// dataSize is the size of Data last counted in the manager's total
+	dataSize int
+
+	// recency is the session's element in the manager's recency list; see
+	// touchLocked
+	recency *list.Element
+
+	// storedAccess is the LastAccessed last queued for the store; see
+	// touchInterval
+	storedAccess time.Time
+
+	// ReconnectTokenHash is the SHA-256 hash of the session's current
+	// reconnect token, or nil if none was issued; it is persisted with the
+	// session so the token survives eviction and other instances verify it
+	ReconnectTokenHash []byte `json:"reconnect_token_hash,omitempty"`
 }
 
 // SessionError represents errors related to session operations.
@@ -60,6 +96,52 @@ var (
 		Message: "invalid session code format",
 	}
 
+	// ErrSessionLimitReached is returned when creating a session would exceed MaxSessions
+	ErrSessionLimitReached = &SessionError{
+		Code:    "SESSION_LIMIT_REACHED",
+		Message: "maximum number of sessions reached",
+	}
+
+	// ErrOwnerSessionLimitReached is returned when creating a session would give
+	// its owner more than MaxSessionsPerOwner sessions under OwnerLimitReject
+	ErrOwnerSessionLimitReached = &SessionError{
+		Code:    "OWNER_SESSION_LIMIT_REACHED",
+		Message: "maximum number of sessions for this owner reached",
+	}
+
+	// ErrDataKeyLimitReached is returned when session data would hold more than MaxDataKeys keys
+	ErrDataKeyLimitReached = &SessionError{
+		Code:    "DATA_KEY_LIMIT_REACHED",
+		Message: "maximum number of session data keys reached",
+	}
+
+	// ErrDataTooLarge is returned when a session's data alone would exceed
+	// MaxTotalDataBytes; the data is left unchanged and nothing is evicted
+	ErrDataTooLarge = &SessionError{
+		Code:    "DATA_TOO_LARGE",
+		Message: "session data exceeds the total data size limit",
+	}
+
+	// ErrValueNotNumeric is returned when incrementing a data key holding a non-integer value
+	ErrValueNotNumeric = &SessionError{
+		Code:    "VALUE_NOT_NUMERIC",
+		Message: "session data value is not an integer",
+	}
+
+	// ErrReconnectLimitReached is returned when restoring a session would exceed
+	// MaxReconnects; the session is deleted
+	ErrReconnectLimitReached = &SessionError{
+		Code:    "RECONNECT_LIMIT_REACHED",
+		Message: "maximum number of session reconnects reached",
+	}
+
+	// ErrSessionCodeInUse is returned when moving a session to a code another
+	// session holds
+	ErrSessionCodeInUse = &SessionError{
+		Code:    "SESSION_CODE_IN_USE",
+		Message: "session code is already in use",
+	}
+
 	// ErrCodeGenerationFailed is returned when session code generation fails after retries
 	ErrCodeGenerationFailed = &SessionError{
 		Code:    "CODE_GENERATION_FAILED",
@@ -77,6 +159,118 @@ type SessionOptions struct {
 
 	// InitialData is the initial data to store with the session
 	InitialData map[string]interface{}
+
+	// Kind is the kind of the created session; empty means DefaultSessionKind.
+	// Only honored per CreateSession call.
+	Kind string
+
+	// Owner identifies the principal creating the session, counted against
+	// MaxSessionsPerOwner; empty creates an anonymous session. Only honored
+	// per CreateSession call.
+	Owner string
+
+	// CaseSensitive disables lowercasing of session codes, so codes differing only
+	// in case identify different sessions. Only honored when creating a Manager.
+	CaseSensitive bool
+
+	// CodePrefix namespaces session codes, e.g. "prod" for codes like
+	// "prod:happy-panda-42"; empty leaves them unprefixed. Only honored when
+	// creating a Manager.
+	CodePrefix string
+
+	// RandSource, if set, is the randomness used to generate session codes in
+	// place of a crypto/rand-seeded ChaCha8 source. Only honored when creating
+	// a Manager.
+	RandSource rand.Source
+
+	// MaxSessions caps the number of live sessions held by a Manager; zero or
+	// less means unlimited. Only honored when creating a Manager.
+	MaxSessions int
+
+	// MaxSessionsPerOwner caps the number of live sessions each owner may
+	// hold; OwnerLimitPolicy decides whether creating one more fails or
+	// evicts the owner's oldest session. Anonymous sessions are not capped.
+	// Zero or less means unlimited. Only honored when creating a Manager.
+	MaxSessionsPerOwner int
+
+	// OwnerLimitPolicy decides what happens when an owner at
+	// MaxSessionsPerOwner creates a session. Only honored when creating a
+	// Manager.
+	OwnerLimitPolicy OwnerLimitPolicy
+
+	// MaxDataKeys caps the number of keys in each session's Data; updates adding
+	// keys beyond it are rejected, while existing keys can still be changed.
+	// Zero or less means unlimited. Only honored when creating a Manager.
+	MaxDataKeys int
+
+	// MaxTotalDataBytes caps the approximate combined size of all sessions'
+	// Data, measured as JSON. Creating or updating a session beyond it evicts
+	// the least recently accessed other sessions until the total fits again;
+	// data that would exceed it on its own is rejected with ErrDataTooLarge.
+	// Zero or less means unlimited. Only honored when creating a Manager.
+	MaxTotalDataBytes int
+
+	// MaxReconnects caps how many times a session can be restored with
+	// RestoreSession; the restore exceeding it deletes the session, forcing
+	// the client onto a fresh one. Zero or less means unlimited. Only honored
+	// when creating a Manager.
+	MaxReconnects int
+
+	// CleanupInterval is how often a Manager removes expired sessions; zero or
+	// less uses DefaultCleanupInterval. Only honored when creating a Manager.
+	CleanupInterval time.Duration
+
+	// CleanupJitter staggers cleanup runs: each wait is CleanupInterval plus a
+	// random offset in [-CleanupJitter, +CleanupJitter], so instances sharing a
+	// store do not clean up in lockstep. It is capped at half the interval.
+	// Zero disables jitter. Only honored when creating a Manager.
+	CleanupJitter time.Duration
+
+	// CleanupBatchSize caps how many sessions each background cleanup run
+	// scans while holding the lock; later runs resume where it stopped, so
+	// a full pass over many sessions takes several runs. Zero or less scans
+	// all sessions every run. Only honored when creating a Manager.
+	CleanupBatchSize int
+
+	// Store persists sessions behind the Manager's in-memory cache; nil keeps
+	// sessions in memory only. Only honored when creating a Manager.
+	Store Store
+
+	// StoreFallback keeps the Manager serving from memory while the Store is
+	// failing: store errors are not returned to callers, and failed writes are
+	// retried until the store recovers. Without it, writes and lookups return
+	// store errors, though failed writes are still retried. Only honored when
+	// creating a Manager.
+	StoreFallback bool
+
+	// StoreRetryInterval is how often failed Store writes are retried, and
+	// also bounds each store call; zero or less uses DefaultStoreRetryInterval.
+	// Only honored when creating a Manager.
+	StoreRetryInterval time.Duration
+
+	// IdempotencyKey makes CreateSession return the session previously created
+	// with the same key, if it still exists, instead of creating a new one.
+	// Only honored per CreateSession call.
+	IdempotencyKey string
+}
+
+// CleanupStatus describes the liveness of a Manager's background cleanup loop.
+type CleanupStatus struct {
+	// LastRun is when expired sessions were last cleaned up, or when the
+	// manager was created if no cleanup has run yet
+	LastRun time.Time
+
+	// Interval is the longest expected wait between cleanup runs, including jitter
+	Interval time.Duration
+
+	// Panics counts cleanup runs that panicked and were recovered
+	Panics uint64
+}
+
+// Stalled reports whether cleanup has not run within CleanupStallFactor
+// intervals of now, meaning expired sessions may be accumulating.
+func (s CleanupStatus) Stalled(now time.Time) bool {
+	return now.Sub(s.LastRun) > CleanupStallFactor*s.Interval
 }
 
 // DefaultSessionOptions returns the default session configuration.
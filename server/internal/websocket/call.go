@@ -0,0 +1,129 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/fle/server/internal/jsonrpc"
+)
+
+// ErrClientClosed is returned by Call when the client disconnects before
+// answering.
+var ErrClientClosed = errors.New("client connection closed")
+
+// callResponse is a client's answer to a request sent by Call.
+type callResponse struct {
+	result json.RawMessage
+	err    *jsonrpc.Error
+}
+
+// Call sends the client a JSON-RPC request for method and waits for its
+// response, returning the raw result. Requests are numbered per client, and
+// the client must answer with the same numeric id. A response carrying an
+// error is returned as a *jsonrpc.Error. Call gives up with the context's
+// error once ctx is done, or with ErrClientClosed if the client disconnects;
+// a response arriving after that is discarded. It may be called from a
+// handler serving the same client, as responses are picked up by the read
+// pump without waiting for the handler. This method is thread-safe.
+func (c *Client) Call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	id := c.nextCallID.Add(1)
+	request := jsonrpc.Request{JSONRPCVersion: jsonrpc.Version, Method: method, ID: id}
+	if params != nil {
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal params for %s: %w", method, err)
+		}
+		request.Params = encoded
+	}
+	message, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request for %s: %w", method, err)
+	}
+
+	responses := make(chan callResponse, 1)
+	c.callsMu.Lock()
+	if c.calls == nil {
+		c.calls = make(map[uint64]chan callResponse)
+	}
+	c.calls[id] = responses
+	c.callsMu.Unlock()
+	defer func() {
+		c.callsMu.Lock()
+		delete(c.calls, id)
+		c.callsMu.Unlock()
+	}()
+
+	if err := c.trySend(message); err != nil {
+		if errors.Is(err, errSendClosed) {
+			return nil, ErrClientClosed
+		}
+		return nil, fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+
+	select {
+	case response := <-responses:
+		if response.err != nil {
+			return nil, response.err
+		}
+		return response.result, nil
+	case <-ctx.Done():
+		// A handler's context is derived from the client's, so it is done
+		// too when the client goes away; report that as the reason
+		if c.ctx.Err() != nil {
+			return nil, ErrClientClosed
+		}
+		return nil, fmt.Errorf("call to %s abandoned: %w", method, ctx.Err())
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
+	}
+}
+
+// resolveCall hands message to the Call waiting for it if it is a response
+// (a result or error without a method) whose id matches a pending call, and
+// reports whether it was. Anything else is left to the JSON-RPC router.
+func (c *Client) resolveCall(message []byte) bool {
+	c.callsMu.Lock()
+	pending := len(c.calls) > 0
+	c.callsMu.Unlock()
+	if !pending {
+		return false
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(message, &fields) != nil {
+		return false
+	}
+	_, hasMethod := fields["method"]
+	result, hasResult := fields["result"]
+	rawError, hasError := fields["error"]
+	if hasMethod || (!hasResult && !hasError) {
+		return false
+	}
+	id, err := strconv.ParseUint(string(fields["id"]), 10, 64)
+	if err != nil {
+		return false
+	}
+
+	var response callResponse
+	if hasError && string(rawError) != "null" {
+		response.err = &jsonrpc.Error{}
+		if err := json.Unmarshal(rawError, response.err); err != nil {
+			return false
+		}
+	} else {
+		response.result = result
+	}
+
+	c.callsMu.Lock()
+	responses, ok := c.calls[id]
+	delete(c.calls, id)
+	c.callsMu.Unlock()
+	if !ok {
+		return false
+	}
+	responses <- response
+	return true
+}
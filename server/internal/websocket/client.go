@@ -3,9 +3,12 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
+	"unicode/utf8"
 
 	"github.com/fle/server/internal/jsonrpc"
 	"github.com/gorilla/websocket"
@@ -23,6 +26,13 @@ const (
 
 	// Maximum message size allowed from peer.
 	maxMessageSize = 512
+
+	// Maximum number of messages read ahead of the one being processed.
+	incomingQueueSize = 16
+
+	// DefaultWriteBatchLimit is the default maximum number of queued messages
+	// coalesced into a single WebSocket frame per write pump iteration.
+	DefaultWriteBatchLimit = 64
 )
 
 var (
@@ -33,32 +43,40 @@ var (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow connections from any origin during development
-		// In production, this should be more restrictive
-		return true
-	},
+}
+
+// IsUpgradeRequest reports whether r asks to upgrade to the WebSocket protocol.
+func IsUpgradeRequest(r *http.Request) bool {
+	return websocket.IsWebSocketUpgrade(r)
 }
 
 // ServeWS handles WebSocket requests from the peer and creates a new client
 // connection. It upgrades the HTTP connection to WebSocket and registers
-// the client with the hub.
-func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, sessionCode string, logger *slog.Logger, router *jsonrpc.Router) {
+// the client with the hub. The request's origin is checked with the hub's
+// OriginChecker. It returns the new client, or nil if the upgrade failed.
+func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, sessionCode string, logger *slog.Logger, router *jsonrpc.Router) *Client {
+	upgrader := upgrader
+	upgrader.CheckOrigin = hub.CheckOrigin
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		logger.Error("WebSocket upgrade failed", 
 			"error", err,
 			"sessionCode", sessionCode)
-		return
+		return nil
 	}
 
 	client := NewClient(hub, conn, sessionCode, logger, router)
+	// The caller authenticates the request and attaches the principal to its context
+	client.principal, _ = jsonrpc.PrincipalFromContext(r.Context())
+	client.resumeAfter, client.resuming = resumeSequenceFromContext(r.Context())
 	client.hub.RegisterClient(client)
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
 	go client.writePump()
 	go client.readPump()
+
+	return client
 }
 
 // readPump pumps messages from the WebSocket connection to the hub.
@@ -66,29 +84,55 @@ func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, sessionCode strin
 // The application runs readPump in a per-connection goroutine. The application
 // ensures that there is at most one reader on a connection by executing all
 // reads from this goroutine.
+//
+// Messages are handed to processMessages rather than processed here, so a
+// slow handler does not stop the connection's control frames from being
+// read: pings are still answered and pongs still extend the read deadline.
+// Messages are processed one at a time in the order they were read, so
+// responses go out in request order, as when they were processed inline.
+// Up to incomingQueueSize messages wait behind the one being processed;
+// a request arriving with the queue full is answered with a ServerBusy
+// error instead. Once the read loop stops, the requests still being
+// handled see their contexts canceled.
 func (c *Client) readPump() {
+	incoming := make(chan []byte, incomingQueueSize)
+	processed := make(chan struct{})
+	go c.processMessages(incoming, processed)
+
 	defer func() {
 		if r := recover(); r != nil {
 			c.logger.Error("panic in readPump",
-				"sessionCode", c.sessionCode,
+				"sessionCode", c.SessionCode(),
 				"panic", r)
 		}
+		// Messages already read are processed before the client goes away,
+		// but with the client's context canceled first: the peer is gone, so
+		// a handler waiting on it, such as in a Call, must not hold this up
+		c.cancel()
+		close(incoming)
+		<-processed
 		c.hub.UnregisterClient(c)
 		c.conn.Close()
 	}()
 
+	c.hub.mu.RLock()
+	c.requestSequencing = c.hub.requestSequencing
+	c.trimTrailingNewline = c.hub.trimTrailingNewline
+	c.hub.mu.RUnlock()
+
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.logger.Debug("pong received", "sessionCode", c.sessionCode)
+		c.logger.Debug("pong received", "sessionCode", c.SessionCode())
+		c.lastPong.Store(time.Now().UnixNano())
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
 	c.conn.SetPingHandler(func(appData string) error {
-		c.logger.Debug("ping received", "sessionCode", c.sessionCode)
-		c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-		if err := c.conn.WriteMessage(websocket.PongMessage, []byte(appData)); err != nil {
-			c.logger.Warn("failed to send pong", "sessionCode", c.sessionCode, "error", err)
+		c.logger.Debug("ping received", "sessionCode", c.SessionCode())
+		// WriteControl is safe to call concurrently with the write pump
+		if err := c.conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(writeWait)); err != nil {
+			c.logger.Warn("failed to send pong", "sessionCode", c.SessionCode(), "error", err)
 			return err
 		}
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -96,40 +140,119 @@ func (c *Client) readPump() {
 	})
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		messageType, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				c.logger.Warn("WebSocket connection error",
-					"sessionCode", c.sessionCode,
+					"sessionCode", c.SessionCode(),
 					"error", err)
 			} else {
 				c.logger.Debug("WebSocket connection closed",
-					"sessionCode", c.sessionCode,
+					"sessionCode", c.SessionCode(),
 					"error", err)
 			}
 			break
 		}
 
+		c.hub.receivedSizes.record(len(message))
+		c.messagesReceived.Add(1)
 		c.logger.Debug("message received",
-			"sessionCode", c.sessionCode,
+			"sessionCode", c.SessionCode(),
 			"messageLength", len(message))
 
-		// Process the message as JSON-RPC
-		c.processJSONRPCMessage(message)
+		// Text frames must be valid UTF-8 (RFC 6455 section 8.1); reject them as
+		// a protocol violation rather than letting them surface as a JSON parse error
+		if messageType == websocket.TextMessage && !utf8.Valid(message) {
+			c.logger.Warn("invalid UTF-8 in text message, closing connection",
+				"sessionCode", c.SessionCode(),
+				"messageLength", len(message))
+			c.closeWithCode(websocket.CloseInvalidFramePayloadData, "invalid UTF-8 in text message")
+			break
+		}
+
+		if messageType == websocket.TextMessage && c.trimTrailingNewline {
+			message = trimNewline(message)
+		}
+
+		// Responses to the server's own requests go straight to the waiting
+		// Call, as the handler making it may hold up the queue below
+		if c.resolveCall(message) {
+			continue
+		}
+
+		// Queue the message for processing as JSON-RPC. With the queue full
+		// the message is refused rather than waited for, so a client sending
+		// faster than its requests are handled cannot stall the read loop.
+		c.pendingWork.Add(1)
+		select {
+		case incoming <- message:
+		default:
+			c.pendingWork.Add(-1)
+			c.rejectBusy(message)
+		}
+	}
+}
+
+// rejectBusy answers a message that found the incoming queue full with a
+// ServerBusy error, or logs and drops it if there is no id to answer, as for
+// a notification or a batch.
+func (c *Client) rejectBusy(message []byte) {
+	var request map[string]json.RawMessage
+	json.Unmarshal(message, &request)
+	rawID, hasID := request["id"]
+	if !hasID {
+		c.logger.Warn("dropping message, incoming queue full",
+			"sessionCode", c.SessionCode(),
+			"messageLength", len(message))
+		return
+	}
+
+	var id interface{}
+	json.Unmarshal(rawID, &id)
+	c.logger.Warn("rejecting request, incoming queue full",
+		"sessionCode", c.SessionCode())
+	c.sendJSONRPCError(id, jsonrpc.ErrServerBusy, "too many requests queued on this connection")
+}
+
+// processMessages processes the messages read by readPump as JSON-RPC, one at
+// a time and in order, until incoming is closed, then closes done.
+func (c *Client) processMessages(incoming <-chan []byte, done chan<- struct{}) {
+	defer close(done)
+	for message := range incoming {
+		c.processMessageSafely(message)
+		c.pendingWork.Add(-1)
 	}
 }
 
+// processMessageSafely processes one message, recovering from panics so the
+// remaining messages are still processed.
+func (c *Client) processMessageSafely(message []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Error("panic processing message",
+				"sessionCode", c.SessionCode(),
+				"panic", r)
+		}
+	}()
+	c.processJSONRPCMessage(message)
+}
+
 // writePump pumps messages from the hub to the WebSocket connection.
 //
 // A goroutine running writePump is started for each connection. The
 // application ensures that there is at most one writer to a connection by
 // executing all writes from this goroutine.
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	c.hub.mu.RLock()
+	batchLimit, period := c.hub.writeBatchLimit, c.hub.pingPeriod
+	c.slowClientTolerance = c.hub.slowClientTolerance
+	c.hub.mu.RUnlock()
+
+	ticker := time.NewTicker(period)
 	defer func() {
 		if r := recover(); r != nil {
 			c.logger.Error("panic in writePump",
-				"sessionCode", c.sessionCode,
+				"sessionCode", c.SessionCode(),
 				"panic", r)
 		}
 		ticker.Stop()
@@ -143,87 +266,259 @@ func (c *Client) writePump() {
 			if !ok {
 				// The hub closed the channel.
 				c.logger.Debug("send channel closed, sending close message",
-					"sessionCode", c.sessionCode)
+					"sessionCode", c.SessionCode())
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 
+			if !c.tolerateBacklog() {
+				c.unwritten.Add(-1)
+				c.abortWrites("client too slow, disconnecting", errSendBacklogged, 1+len(c.send))
+				return
+			}
+
 			w, err := c.conn.NextWriter(websocket.TextMessage)
 			if err != nil {
-				c.logger.Error("failed to get next writer",
-					"sessionCode", c.sessionCode,
-					"error", err)
+				c.unwritten.Add(-1)
+				c.abortWrites("failed to get next writer", err, 1+len(c.send))
 				return
 			}
-			
-			w.Write(message)
 
-			// Add queued chat messages to the current websocket message.
+			if _, err = w.Write(message); err == nil {
+				c.hub.sentSizes.record(len(message))
+				c.messagesSent.Add(1)
+			}
+			batched := 1
+
+			// Add queued chat messages to the current websocket message, up to
+			// the batch limit so a flooded queue cannot hold off pings.
 			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write(newline)
-				w.Write(<-c.send)
+			if n > batchLimit-1 {
+				n = batchLimit - 1
+			}
+			for i := 0; i < n && err == nil; i++ {
+				if _, err = w.Write(newline); err != nil {
+					break
+				}
+				next := <-c.send
+				batched++
+				if _, err = w.Write(next); err == nil {
+					c.hub.sentSizes.record(len(next))
+					c.messagesSent.Add(1)
+				}
 			}
 
-			if err := w.Close(); err != nil {
-				c.logger.Error("failed to close writer",
-					"sessionCode", c.sessionCode,
-					"error", err)
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+			c.unwritten.Add(-int64(batched))
+			if err != nil {
+				// Nothing in the batch is known to have reached the peer.
+				c.abortWrites("failed to write message batch", err, batched+len(c.send))
 				return
 			}
 
 			c.logger.Debug("message sent",
-				"sessionCode", c.sessionCode,
+				"sessionCode", c.SessionCode(),
 				"messageLength", len(message),
 				"additionalMessages", n)
 
+			// Give a due ping priority over the next batch; select alone picks
+			// randomly between ready cases and could keep choosing the queue.
+			select {
+			case <-ticker.C:
+				if !c.writePing() {
+					return
+				}
+			default:
+			}
+
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				c.logger.Debug("ping failed, connection likely closed",
-					"sessionCode", c.sessionCode,
-					"error", err)
+			if !c.writePing() {
 				return
 			}
-			c.logger.Debug("ping sent", "sessionCode", c.sessionCode)
 		}
 	}
 }
 
-// Close gracefully closes the client connection by sending a close message
-// and cleaning up resources. This method is safe to call multiple times.
-func (c *Client) Close() error {
-	c.logger.Debug("closing client connection", "sessionCode", c.sessionCode)
-	
-	// Send close message to the client
+// writePing sends a ping frame to the peer. It reports whether writePump
+// should keep going: false when the ping failed, most likely because the
+// connection is closed.
+func (c *Client) writePing() bool {
 	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-	if err := c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
-		c.logger.Warn("failed to send close message",
-			"sessionCode", c.sessionCode,
+	if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+		c.logger.Debug("ping failed, connection likely closed",
+			"sessionCode", c.SessionCode(),
 			"error", err)
+		c.hub.UnregisterClient(c)
+		return false
+	}
+	c.logger.Debug("ping sent", "sessionCode", c.SessionCode())
+	return true
+}
+
+// tolerateBacklog reports whether writePump may write the next batch. It is
+// called before each batch is written: a client whose send queue is still at
+// least half full is falling behind, and it is kept connected for at most the
+// hub's slow client tolerance of such batches in a row. A write error is never
+// tolerated, because the connection cannot be written to after one.
+func (c *Client) tolerateBacklog() bool {
+	if c.slowClientTolerance == 0 || len(c.send) < cap(c.send)/2 {
+		c.backloggedBatches = 0
+		return true
 	}
+	if c.backloggedBatches >= c.slowClientTolerance {
+		return false
+	}
+	c.backloggedBatches++
+	c.hub.toleratedBacklogs.Add(1)
+	c.logger.Debug("send queue backlogged, keeping slow client connected",
+		"sessionCode", c.SessionCode(),
+		"queued", len(c.send),
+		"backloggedBatches", c.backloggedBatches,
+		"tolerance", c.slowClientTolerance)
+	return true
+}
 
-	// Close the underlying connection
-	return c.conn.Close()
+// abortWrites handles a write failure in writePump. The unsent messages are
+// counted as dropped and the client is unregistered so the hub stops routing
+// messages to a connection that can no longer be written to.
+func (c *Client) abortWrites(msg string, err error, unsent int) {
+	c.logger.Warn(msg,
+		"sessionCode", c.SessionCode(),
+		"droppedMessages", unsent,
+		"error", err)
+	c.hub.recordWriteFailure(unsent)
+	c.hub.UnregisterClient(c)
 }
 
-// Send sends a message to this specific client. This method is thread-safe
-// and non-blocking. If the client's send channel is full, the message is dropped.
-func (c *Client) Send(message []byte) {
+// Close gracefully closes the client connection by sending a close message,
+// closing the send channel and closing the underlying connection. Only the
+// first call does any work; later calls return its result. Failures caused
+// by the connection already being gone are expected and not reported.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		c.logger.Debug("closing client connection", "sessionCode", c.SessionCode())
+
+		// WriteControl is safe to call concurrently with the write pump
+		payload := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		if err := c.conn.WriteControl(websocket.CloseMessage, payload, time.Now().Add(writeWait)); err != nil && !isConnClosedError(err) {
+			c.logger.Warn("failed to send close message",
+				"sessionCode", c.SessionCode(),
+				"error", err)
+		}
+
+		c.closeSend()
+
+		if err := c.conn.Close(); err != nil && !isConnClosedError(err) {
+			c.closeErr = err
+		}
+	})
+	return c.closeErr
+}
+
+// isConnClosedError reports whether err only says that the connection was
+// already closed, by us or by the peer.
+func isConnClosedError(err error) bool {
+	return errors.Is(err, websocket.ErrCloseSent) || errors.Is(err, net.ErrClosed)
+}
+
+// closeWithCode sends a close frame with the given code and plain-text reason.
+func (c *Client) closeWithCode(code int, reason string) {
+	c.writeCloseFrame(code, websocket.FormatCloseMessage(code, reason))
+}
+
+// closeWithRetry sends a close frame with the given code whose reason carries a
+// structured reconnect hint (see FormatCloseWithRetry).
+func (c *Client) closeWithRetry(code int, reason string, retryAfter time.Duration) {
+	c.writeCloseFrame(code, FormatCloseWithRetry(code, reason, retryAfter))
+}
+
+// writeCloseFrame writes a pre-formatted close frame payload. It uses WriteControl
+// so it is safe to call concurrently with the write pump. Errors are logged, not
+// returned, since the connection is being torn down anyway.
+func (c *Client) writeCloseFrame(code int, payload []byte) {
+	if err := c.conn.WriteControl(websocket.CloseMessage, payload, time.Now().Add(writeWait)); err != nil {
+		c.logger.Debug("failed to send close message",
+			"sessionCode", c.SessionCode(),
+			"closeCode", code,
+			"error", err)
+	}
+}
+
+// errSendClosed is returned by trySend once the client's send channel has been closed.
+var errSendClosed = errors.New("send channel closed")
+
+// errSendFull is returned by trySend when the client's send channel is full.
+var errSendFull = errors.New("send channel full")
+
+// errSendBacklogged is reported when writePump disconnects a client whose
+// send queue stayed backlogged past the slow client tolerance.
+var errSendBacklogged = errors.New("send queue backlogged")
+
+// trySend queues a message for the write pump without blocking. It never sends on
+// a closed channel: the closed check and the send happen under sendMu, which
+// closeSend holds exclusively while closing.
+func (c *Client) trySend(message []byte) error {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+
+	if c.sendClosed {
+		return errSendClosed
+	}
+
+	c.unwritten.Add(1)
 	select {
 	case c.send <- message:
-		c.logger.Debug("message queued for client",
-			"sessionCode", c.sessionCode,
-			"messageLength", len(message))
+		return nil
 	default:
-		c.logger.Warn("client send channel full, message dropped",
-			"sessionCode", c.sessionCode,
-			"messageLength", len(message))
+		c.unwritten.Add(-1)
+		return errSendFull
+	}
+}
+
+// closeSend closes the send channel, signalling the write pump to send a close
+// frame and exit. It is safe to call multiple times.
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if !c.sendClosed {
+		c.sendClosed = true
+		close(c.send)
+		c.cancel()
+	}
+}
+
+// Context returns the client's lifecycle context. It is canceled when the
+// client is unregistered or closed, or its read pump stops, so work done on
+// the client's behalf, such as sending it a delayed message or handling its
+// requests, can stop once it is gone.
+func (c *Client) Context() context.Context {
+	return c.ctx
+}
+
+// Send sends a message to this specific client. This method is thread-safe
+// and non-blocking. If the client's send channel is full or already closed,
+// the message is dropped.
+func (c *Client) Send(message []byte) {
+	if err := c.trySend(message); err != nil {
+		c.logger.Warn("client message dropped",
+			"sessionCode", c.SessionCode(),
+			"messageLength", len(message),
+			"error", err)
+		return
 	}
+	c.logger.Debug("message queued for client",
+		"sessionCode", c.SessionCode(),
+		"messageLength", len(message))
 }
 
-// SessionCode returns the session code associated with this client.
+// SessionCode returns the session code associated with this client. It
+// changes when the session is rekeyed; see Hub.RekeySession.
 func (c *Client) SessionCode() string {
+	c.sessionCodeMu.RLock()
+	defer c.sessionCodeMu.RUnlock()
 	return c.sessionCode
 }
 
@@ -241,53 +536,75 @@ func (c *Client) IsConnected() bool {
 // It parses the message, routes it through the JSON-RPC router, and sends back the response.
 func (c *Client) processJSONRPCMessage(message []byte) {
 	c.logger.Debug("processing JSON-RPC message",
-		"sessionCode", c.sessionCode,
+		"sessionCode", c.SessionCode(),
 		"message", string(message))
 
-	// Create a context for the request
-	ctx := context.Background()
+	// Create a context for the request carrying this client for handlers
+	// and its session code for the router. It is derived from the client's
+	// context, so handlers see the request canceled when the client goes away.
+	ctx := withClient(c.ctx, c)
+	ctx = jsonrpc.WithSessionCode(ctx, c.SessionCode())
+	if c.principal != nil {
+		ctx = jsonrpc.WithPrincipal(ctx, c.principal)
+	}
 	
 	// Check if the router is available
 	if c.jsonrpcRouter == nil {
 		c.logger.Error("JSON-RPC router not available",
-			"sessionCode", c.sessionCode)
+			"sessionCode", c.SessionCode())
 		c.sendJSONRPCError(nil, jsonrpc.ErrInternal, "JSON-RPC router not available")
 		return
 	}
 
+	// Reject replayed or reordered requests when sequencing is enabled
+	var rejected []*jsonrpc.Response
+	if c.requestSequencing {
+		if message, rejected = c.checkRequestSequence(message); message == nil {
+			if len(rejected) > 0 {
+				c.sendRejected(rejected)
+			}
+			return
+		}
+	}
+
 	// Try to route the JSON message through the JSON-RPC router
 	responseBytes, err := c.jsonrpcRouter.RouteJSON(ctx, message)
 	if err != nil {
 		c.logger.Error("failed to route JSON-RPC message",
-			"sessionCode", c.sessionCode,
+			"sessionCode", c.SessionCode(),
 			"error", err,
 			"message", string(message))
 		c.sendJSONRPCError(nil, jsonrpc.ErrInternal, err.Error())
 		return
 	}
 
+	// Rejected requests of a batch are answered in its response
+	if len(rejected) > 0 {
+		responseBytes = appendBatchResponses(responseBytes, rejected)
+	}
+
 	// If responseBytes is nil, it was a notification (no response needed)
 	if responseBytes == nil {
 		c.logger.Debug("JSON-RPC notification processed successfully",
-			"sessionCode", c.sessionCode)
+			"sessionCode", c.SessionCode())
 		return
 	}
 
 	// Send the JSON-RPC response back to the client
 	c.logger.Debug("sending JSON-RPC response",
-		"sessionCode", c.sessionCode,
+		"sessionCode", c.SessionCode(),
 		"response", string(responseBytes))
 
-	select {
-	case c.send <- responseBytes:
-		c.logger.Debug("JSON-RPC response queued for sending",
-			"sessionCode", c.sessionCode,
-			"responseLength", len(responseBytes))
-	default:
-		c.logger.Warn("send channel full, dropping JSON-RPC response",
-			"sessionCode", c.sessionCode,
-			"responseLength", len(responseBytes))
+	if err := c.trySend(responseBytes); err != nil {
+		c.logger.Warn("dropping JSON-RPC response",
+			"sessionCode", c.SessionCode(),
+			"responseLength", len(responseBytes),
+			"error", err)
+		return
 	}
+	c.logger.Debug("JSON-RPC response queued for sending",
+		"sessionCode", c.SessionCode(),
+		"responseLength", len(responseBytes))
 }
 
 // sendJSONRPCError sends a JSON-RPC error response back to the client.
@@ -307,21 +624,21 @@ func (c *Client) sendJSONRPCError(id interface{}, rpcError *jsonrpc.Error, detai
 	responseBytes, marshalErr := json.Marshal(response)
 	if marshalErr != nil {
 		c.logger.Error("failed to marshal JSON-RPC error response",
-			"sessionCode", c.sessionCode,
+			"sessionCode", c.SessionCode(),
 			"error", marshalErr)
 		return
 	}
 
 	// Send error response
-	select {
-	case c.send <- responseBytes:
-		c.logger.Debug("JSON-RPC error response sent",
-			"sessionCode", c.sessionCode,
+	if sendErr := c.trySend(responseBytes); sendErr != nil {
+		c.logger.Warn("dropping JSON-RPC error response",
+			"sessionCode", c.SessionCode(),
 			"errorCode", err.Code,
-			"errorMessage", err.Message)
-	default:
-		c.logger.Warn("send channel full, dropping JSON-RPC error response",
-			"sessionCode", c.sessionCode,
-			"errorCode", err.Code)
+			"error", sendErr)
+		return
 	}
+	c.logger.Debug("JSON-RPC error response sent",
+		"sessionCode", c.SessionCode(),
+		"errorCode", err.Code,
+		"errorMessage", err.Message)
 }
\ No newline at end of file
@@ -1,16 +1,19 @@
 package websocket
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"sync"
 	"testing"
 	"time"
-	"unsafe"
 
 	"github.com/fle/server/internal/jsonrpc"
 	"github.com/gorilla/websocket"
@@ -18,19 +21,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// Interface to abstract the WebSocket connection for testing
-type webSocketConn interface {
-	WriteMessage(messageType int, data []byte) error
-	Close() error
-	SetWriteDeadline(t time.Time) error
-	SetReadDeadline(t time.Time) error
-	NextWriter(messageType int) (io.WriteCloser, error)
-	ReadMessage() (messageType int, p []byte, err error)
-	SetReadLimit(limit int64)
-	SetPongHandler(h func(appData string) error)
-}
-
-// mockWebSocketConn implements webSocketConn interface for testing
+// mockWebSocketConn implements Conn for testing, with injectable read and write errors
 type mockWebSocketConn struct {
 	*mockConn
 	readLimit       int64
@@ -47,6 +38,7 @@ type mockWebSocketConn struct {
 	writeError      error
 	mu              sync.RWMutex
 	closeReceived   bool
+	closeAttempts   int
 	closeCode       int
 	closeText       string
 }
@@ -105,6 +97,10 @@ func (m *mockWebSocketConn) ReadMessage() (int, []byte, error) {
 func (m *mockWebSocketConn) WriteMessage(messageType int, data []byte) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+
+	if messageType == websocket.CloseMessage {
+		m.closeAttempts++
+	}
 	
 	if m.writeError != nil {
 		return m.writeError
@@ -145,6 +141,19 @@ func (m *mockWebSocketConn) WriteMessage(messageType int, data []byte) error {
 	return nil
 }
 
+func (m *mockWebSocketConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	return m.WriteMessage(messageType, data)
+}
+
+func (m *mockWebSocketConn) NextWriter(messageType int) (io.WriteCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.writeError != nil {
+		return nil, m.writeError
+	}
+	return m.mockConn.NextWriter(messageType)
+}
+
 func (m *mockWebSocketConn) addReadMessage(msg []byte) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -192,23 +201,26 @@ func (m *mockWebSocketConn) isCloseReceived() bool {
 	return m.closeReceived
 }
 
+func (m *mockWebSocketConn) getCloseAttempts() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.closeAttempts
+}
+
 func (m *mockWebSocketConn) simulatePong() {
 	if m.pongHandler != nil {
 		m.pongHandler("test pong")
 	}
 }
 
-// Helper to create client with mock connection using unsafe conversion
+// Helper to create client with mock connection
 func createTestClientWithMock(sessionCode string) (*Client, *mockWebSocketConn, *Hub) {
 	logger := createTestLogger()
 	hub := NewHub(logger)
 	router := createTestRouter()
 	mockWSConn := newMockWebSocketConn()
-	
-	// Use unsafe pointer conversion to bypass type checking for testing
-	// This is not recommended in production code but acceptable for unit tests
-	wsConn := (*websocket.Conn)(unsafe.Pointer(mockWSConn.mockConn))
-	client := NewClient(hub, wsConn, sessionCode, logger, router)
+
+	client := NewClient(hub, mockWSConn, sessionCode, logger, router)
 	
 	return client, mockWSConn, hub
 }
@@ -220,12 +232,11 @@ func TestNewClient(t *testing.T) {
 	mockConn := newMockConn()
 	sessionCode := "test_session"
 
-	wsConn := (*websocket.Conn)(unsafe.Pointer(mockConn))
-	client := NewClient(hub, wsConn, sessionCode, logger, router)
+	client := NewClient(hub, mockConn, sessionCode, logger, router)
 
 	assert.NotNil(t, client)
 	assert.Equal(t, hub, client.hub)
-	assert.Equal(t, wsConn, client.conn)
+	assert.Equal(t, Conn(mockConn), client.conn)
 	assert.Equal(t, sessionCode, client.sessionCode)
 	assert.Equal(t, logger, client.logger)
 	assert.Equal(t, router, client.jsonrpcRouter)
@@ -270,8 +281,42 @@ func TestClientSendChannelFull(t *testing.T) {
 }
 
 func TestClientClose(t *testing.T) {
-	// Skip this test as it requires proper WebSocket connection initialization
-	t.Skip("Skipping close test due to unsafe pointer conversion limitations")
+	client, mockConn, _ := createTestClientWithMock("test_session")
+
+	require.NoError(t, client.Close())
+	assert.True(t, mockConn.isCloseReceived())
+	assert.Equal(t, websocket.CloseNormalClosure, mockConn.closeCode)
+	assert.True(t, mockConn.isClosed())
+}
+
+func TestClientCloseTwice(t *testing.T) {
+	client, mockConn, _ := createTestClientWithMock("test_session")
+
+	require.NotPanics(t, func() {
+		require.NoError(t, client.Close())
+		require.NoError(t, client.Close())
+	})
+	assert.Equal(t, 1, mockConn.getCloseAttempts(), "Close should send a single close frame")
+
+	_, ok := <-client.send
+	assert.False(t, ok, "Close should close the send channel")
+
+	// Hub cleanup closing the send channel again must not panic
+	assert.NotPanics(t, client.closeSend)
+	assert.ErrorIs(t, client.trySend([]byte("late")), errSendClosed)
+}
+
+func TestClientCloseConnectionGone(t *testing.T) {
+	var logs bytes.Buffer
+	mockConn := newMockWebSocketConn()
+	mockConn.setWriteError(websocket.ErrCloseSent)
+	client := NewClient(NewHub(createTestLogger()), mockConn,
+		"test_session", slog.New(slog.NewTextHandler(&logs, nil)), createTestRouter())
+
+	assert.NoError(t, client.Close(), "An already closed connection is not a Close failure")
+	assert.NoError(t, client.Close())
+	assert.Equal(t, 1, mockConn.getCloseAttempts())
+	assert.NotContains(t, logs.String(), "level=WARN", "Expected close errors should not be logged as warnings")
 }
 
 func TestClientProcessJSONRPCMessage(t *testing.T) {
@@ -694,4 +739,736 @@ func TestClientGracefulDisconnect(t *testing.T) {
 	// Verify client is unregistered
 	assert.Equal(t, 0, hub.GetClientCount())
 	assert.False(t, hub.HasSession("disconnect_test"))
-}
\ No newline at end of file
+}
+
+// Test that text frames with invalid UTF-8 close the connection with 1007
+// instead of being routed as JSON-RPC.
+func TestClientInvalidUTF8ClosesWithInvalidPayload(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+	router := createTestRouter()
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWS(hub, w, r, "utf8_test", logger, router)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// 0xff never appears in valid UTF-8
+	invalid := []byte("{\"jsonrpc\":\"2.0\",\"method\":\"test.echo\",\"params\":\"\xff\xfe\",\"id\":1}")
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, invalid))
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, message, err := conn.ReadMessage()
+	require.Error(t, err, "expected close, got message %s", message)
+
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseInvalidFramePayloadData, closeErr.Code)
+
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+// Test that a request processed after the hub closed the client's send channel
+// (e.g. a rejected or shut-down client) is dropped instead of panicking.
+func TestClientProcessJSONRPCAfterSendClosed(t *testing.T) {
+	client, _, _ := createTestClientWithMock("closed_session")
+
+	client.closeSend()
+	client.closeSend() // Safe to call twice
+
+	assert.NotPanics(t, func() {
+		client.processJSONRPCMessage([]byte(`{"jsonrpc":"2.0","method":"test.echo","params":"hello","id":1}`))
+		client.sendJSONRPCError(1, jsonrpc.ErrInternal, "late error")
+		client.Send([]byte("late message"))
+	})
+
+	_, ok := <-client.send
+	assert.False(t, ok, "send channel should stay closed and empty")
+}
+
+// Test that a request queued behind a full method concurrency gate does not block
+// the read pump forever: WebSocket requests carry no deadline, so the router's
+// queue timeout must turn it into a ServerBusy error response.
+func TestClientProcessJSONRPCConcurrencyGateTimesOut(t *testing.T) {
+	router := jsonrpc.NewRouter()
+	router.SetConcurrencyQueueTimeout(50 * time.Millisecond)
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	err := router.RegisterMethod("test.slow", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		started <- struct{}{}
+		<-release
+		return "done", nil
+	}, &jsonrpc.MethodInfo{MaxConcurrency: 1})
+	require.NoError(t, err)
+
+	busyClient, _, _ := createTestClientWithMock("busy_session")
+	busyClient.jsonrpcRouter = router
+	waitingClient, _, _ := createTestClientWithMock("waiting_session")
+	waitingClient.jsonrpcRouter = router
+
+	request := []byte(`{"jsonrpc":"2.0","method":"test.slow","id":1}`)
+	go busyClient.processJSONRPCMessage(request)
+	<-started
+
+	processed := make(chan struct{})
+	go func() {
+		waitingClient.processJSONRPCMessage(request)
+		close(processed)
+	}()
+
+	select {
+	case <-processed:
+	case <-time.After(time.Second):
+		t.Fatal("processJSONRPCMessage should not block once the queue timeout elapses")
+	}
+
+	var response jsonrpc.Response
+	require.NoError(t, json.Unmarshal(<-waitingClient.send, &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, jsonrpc.ServerBusy, response.Error.Code)
+}
+
+func TestClientWritePumpFailureUnregisters(t *testing.T) {
+	client, mockConn, hub := createTestClientWithMock("write_fail_session")
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.RegisterClient(client)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	// Queue a batch before the pump starts so the failure drops all of it
+	for i := 0; i < 3; i++ {
+		client.Send([]byte(fmt.Sprintf(`{"n":%d}`, i)))
+	}
+	mockConn.setWriteError(fmt.Errorf("broken pipe"))
+
+	done := make(chan struct{})
+	go func() {
+		client.writePump()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writePump did not stop after write failure")
+	}
+
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+	assert.False(t, hub.HasSession("write_fail_session"))
+	assert.Equal(t, uint64(1), hub.WriteFailures())
+	assert.Equal(t, uint64(3), hub.DroppedMessages())
+	assert.True(t, mockConn.isClosed())
+	assert.ErrorIs(t, client.trySend([]byte("late")), errSendClosed)
+}
+
+func TestClientRequestSequencing(t *testing.T) {
+	client, _, _ := createTestClientWithMock("sequence_session")
+	client.requestSequencing = true
+
+	call := func(request string) map[string]interface{} {
+		t.Helper()
+		client.processJSONRPCMessage([]byte(request))
+		select {
+		case response := <-client.send:
+			var decoded map[string]interface{}
+			require.NoError(t, json.Unmarshal(response, &decoded))
+			return decoded
+		case <-time.After(time.Second):
+			t.Fatal("no response")
+			return nil
+		}
+	}
+
+	// In-order sequences are accepted, with _seq stripped from params
+	response := call(`{"jsonrpc":"2.0","method":"test.echo","params":{"_seq":1,"v":"a"},"id":1}`)
+	require.Nil(t, response["error"])
+	assert.Equal(t, `{"v":"a"}`, response["result"])
+	response = call(`{"jsonrpc":"2.0","method":"test.echo","params":{"_seq":5},"id":2}`)
+	require.Nil(t, response["error"])
+
+	// Requests without _seq are not checked
+	response = call(`{"jsonrpc":"2.0","method":"test.echo","params":{"v":"b"},"id":3}`)
+	require.Nil(t, response["error"])
+
+	// Duplicated and regressed sequences are rejected
+	for id, seq := range map[int]int{4: 5, 5: 2} {
+		response = call(fmt.Sprintf(`{"jsonrpc":"2.0","method":"test.echo","params":{"_seq":%d},"id":%d}`, seq, id))
+		require.NotNil(t, response["error"], "sequence %d should be rejected", seq)
+		rpcErr := response["error"].(map[string]interface{})
+		assert.Equal(t, float64(jsonrpc.InvalidRequest), rpcErr["code"])
+		assert.Equal(t, float64(id), response["id"])
+	}
+
+	// Rejections do not advance the sequence
+	response = call(`{"jsonrpc":"2.0","method":"test.echo","params":{"_seq":6},"id":6}`)
+	require.Nil(t, response["error"])
+
+	// Each request of a batch is checked in order, and the rejected ones
+	// are answered in the batch response without being dispatched
+	client.processJSONRPCMessage([]byte(`[
+		{"jsonrpc":"2.0","method":"test.echo","params":{"_seq":7,"v":"c"},"id":7},
+		{"jsonrpc":"2.0","method":"test.echo","params":{"_seq":7},"id":8},
+		{"jsonrpc":"2.0","method":"test.echo","params":{"_seq":3},"id":9},
+		{"jsonrpc":"2.0","method":"test.echo","params":{"_seq":2}},
+		{"jsonrpc":"2.0","method":"test.echo","params":{"_seq":8},"id":10}
+	]`))
+	var batch []map[string]interface{}
+	select {
+	case message := <-client.send:
+		require.NoError(t, json.Unmarshal(message, &batch))
+	case <-time.After(time.Second):
+		t.Fatal("no batch response")
+	}
+	byID := make(map[float64]map[string]interface{})
+	for _, response := range batch {
+		byID[response["id"].(float64)] = response
+	}
+	require.Len(t, byID, 4, "the regressed notification gets no response")
+	assert.Equal(t, `{"v":"c"}`, byID[7]["result"])
+	assert.Nil(t, byID[10]["error"])
+	for _, id := range []float64{8, 9} {
+		require.NotNil(t, byID[id]["error"], "request %v should be rejected", id)
+		assert.Equal(t, float64(jsonrpc.InvalidRequest), byID[id]["error"].(map[string]interface{})["code"])
+	}
+
+	// A batch whose requests are all rejected is still answered as a batch
+	client.processJSONRPCMessage([]byte(`[{"jsonrpc":"2.0","method":"test.echo","params":{"_seq":1},"id":11}]`))
+	select {
+	case message := <-client.send:
+		require.NoError(t, json.Unmarshal(message, &batch))
+		require.Len(t, batch, 1)
+		assert.NotNil(t, batch[0]["error"])
+	case <-time.After(time.Second):
+		t.Fatal("no batch response")
+	}
+}
+
+// Test that control frames are still read while a handler is slow, and that
+// the slow request is still answered once the handler returns.
+func TestClientReadsControlFramesDuringSlowHandler(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+	router := createTestRouter()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	router.RegisterSimpleMethod("test.slow", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		close(started)
+		<-release
+		return "done", nil
+	}, "Blocks until released")
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWS(hub, w, r, "slow_handler_test", logger, router)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	pongs := make(chan string, 1)
+	conn.SetPongHandler(func(appData string) error {
+		pongs <- appData
+		return nil
+	})
+	messages := make(chan []byte, 1)
+	go func() {
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			messages <- message
+		}
+	}()
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"jsonrpc":"2.0","method":"test.slow","id":1}`)))
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("slow handler was not called")
+	}
+
+	// The server answers the ping while the handler is still running
+	require.NoError(t, conn.WriteControl(websocket.PingMessage, []byte("probe"), time.Now().Add(time.Second)))
+	select {
+	case appData := <-pongs:
+		assert.Equal(t, "probe", appData)
+	case <-time.After(time.Second):
+		t.Fatal("ping was not answered while the handler was running")
+	}
+
+	close(release)
+	select {
+	case message := <-messages:
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(message, &response))
+		assert.Equal(t, "done", response["result"])
+		assert.Equal(t, float64(1), response["id"])
+	case <-time.After(time.Second):
+		t.Fatal("slow request was not answered")
+	}
+}
+
+// Test that requests arriving while the incoming queue is full are refused
+// with a ServerBusy error, and that a disconnect cancels the running handler.
+func TestClientIncomingQueueFull(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+	router := createTestRouter()
+
+	started := make(chan struct{}, 1)
+	canceled := make(chan struct{})
+	router.RegisterSimpleMethod("test.wait", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		select {
+		case started <- struct{}{}:
+			<-ctx.Done()
+			close(canceled)
+		default:
+		}
+		return nil, ctx.Err()
+	}, "Blocks until the request is canceled")
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWS(hub, w, r, "queue_full_test", logger, router)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	request := func(id int) {
+		t.Helper()
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage,
+			[]byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"test.wait","id":%d}`, id))))
+	}
+
+	// One request blocks the handler, the next ones fill the queue
+	request(0)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("handler was not called")
+	}
+	for id := 1; id <= incomingQueueSize; id++ {
+		request(id)
+	}
+
+	request(99)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(message, &response))
+	assert.Equal(t, float64(99), response["id"])
+	require.NotNil(t, response["error"])
+	assert.Equal(t, float64(jsonrpc.ServerBusy), response["error"].(map[string]interface{})["code"])
+
+	conn.Close()
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("running handler was not canceled on disconnect")
+	}
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+// Test that Call sends the client a request and returns its answer, whether
+// called directly or from a handler serving the same client.
+func TestClientCall(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+	router := createTestRouter()
+	router.RegisterSimpleMethod("test.ask", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		client, _ := ClientFromContext(ctx)
+		answer, err := client.Call(ctx, "client.confirm", map[string]string{"question": "sure?"})
+		if err != nil {
+			return nil, err
+		}
+		return "client said " + string(answer), nil
+	}, "Ask the caller a question")
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	clients := make(chan *Client, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clients <- ServeWS(hub, w, r, "call_test", logger, router)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := <-clients
+
+	readRequest := func() jsonrpc.Request {
+		t.Helper()
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		_, message, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var request jsonrpc.Request
+		require.NoError(t, json.Unmarshal(message, &request))
+		return request
+	}
+	reply := func(response string) {
+		t.Helper()
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(response)))
+	}
+
+	type callResult struct {
+		result json.RawMessage
+		err    error
+	}
+	call := func(ctx context.Context, method string) <-chan callResult {
+		done := make(chan callResult, 1)
+		go func() {
+			result, err := client.Call(ctx, method, []int{1, 2})
+			done <- callResult{result, err}
+		}()
+		return done
+	}
+
+	// A result
+	done := call(context.Background(), "client.hello")
+	request := readRequest()
+	assert.Equal(t, "client.hello", request.Method)
+	assert.JSONEq(t, `[1,2]`, string(request.Params))
+	reply(fmt.Sprintf(`{"jsonrpc":"2.0","result":{"ok":true},"id":%v}`, request.ID))
+	outcome := <-done
+	require.NoError(t, outcome.err)
+	assert.JSONEq(t, `{"ok":true}`, string(outcome.result))
+
+	// An error
+	done = call(context.Background(), "client.fail")
+	request = readRequest()
+	reply(fmt.Sprintf(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":%v}`, request.ID))
+	outcome = <-done
+	var rpcErr *jsonrpc.Error
+	require.ErrorAs(t, outcome.err, &rpcErr)
+	assert.Equal(t, jsonrpc.MethodNotFound, rpcErr.Code)
+
+	// No answer before the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done = call(ctx, "client.ignore")
+	readRequest()
+	outcome = <-done
+	assert.ErrorIs(t, outcome.err, context.DeadlineExceeded)
+
+	// A handler calling back the client that called it
+	reply(`{"jsonrpc":"2.0","method":"test.ask","id":"outer"}`)
+	request = readRequest()
+	assert.Equal(t, "client.confirm", request.Method)
+	reply(fmt.Sprintf(`{"jsonrpc":"2.0","result":"yes","id":%v}`, request.ID))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(message, &response))
+	assert.Equal(t, "outer", response["id"])
+	assert.Equal(t, `client said "yes"`, response["result"])
+}
+
+// Test that a handler calling back its own client gives up when the client
+// disconnects, so the client is unregistered rather than left waiting.
+func TestClientCallFromHandlerClientDisconnects(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+	router := createTestRouter()
+	handlerErr := make(chan error, 1)
+	router.RegisterSimpleMethod("test.ask", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		client, _ := ClientFromContext(ctx)
+		_, err := client.Call(ctx, "client.confirm", nil)
+		handlerErr <- err
+		return nil, err
+	}, "Ask the caller a question")
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWS(hub, w, r, "call_disconnect_test", logger, router)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"jsonrpc":"2.0","method":"test.ask","id":1}`)))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var request jsonrpc.Request
+	require.NoError(t, json.Unmarshal(message, &request))
+	require.Equal(t, "client.confirm", request.Method)
+
+	// Disconnect without answering
+	conn.Close()
+
+	select {
+	case err := <-handlerErr:
+		assert.ErrorIs(t, err, ErrClientClosed)
+	case <-time.After(time.Second):
+		t.Fatal("Call did not give up after the client disconnected")
+	}
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+	assert.False(t, hub.HasSession("call_disconnect_test"))
+}
+
+func TestClientTrimTrailingNewline(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{"disabled", false},
+		{"enabled", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mockConn, hub := createTestClientWithMock("newline_session")
+			hub.SetTrimTrailingNewline(tt.enabled)
+
+			go hub.Run()
+			defer hub.Shutdown()
+
+			hub.RegisterClient(client)
+			require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+			// The router tolerates trailing whitespace, so the request parses
+			// either way; trimming just hands it over without the newline
+			mockConn.addReadMessage([]byte(`{"jsonrpc":"2.0","method":"test.echo","params":{"v":"a"},"id":1}` + "\r\n"))
+			client.readPump()
+			assert.Equal(t, tt.enabled, client.trimTrailingNewline)
+
+			select {
+			case response := <-client.send:
+				var decoded map[string]interface{}
+				require.NoError(t, json.Unmarshal(response, &decoded))
+				assert.Nil(t, decoded["error"])
+				assert.Equal(t, `{"v":"a"}`, decoded["result"])
+			case <-time.After(time.Second):
+				t.Fatal("no response")
+			}
+		})
+	}
+}
+
+func TestTrimNewline(t *testing.T) {
+	assert.Equal(t, "{}", string(trimNewline([]byte("{}\n"))))
+	assert.Equal(t, "{}", string(trimNewline([]byte("{}\r\n"))))
+	assert.Equal(t, "{}\n", string(trimNewline([]byte("{}\n\n"))), "only a single newline is trimmed")
+	assert.Equal(t, "{}\r", string(trimNewline([]byte("{}\r"))), "a bare carriage return is kept")
+}
+
+func TestClientContextCanceledOnUnregister(t *testing.T) {
+	client, _, hub := createTestClientWithMock("lifecycle_session")
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.RegisterClient(client)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+	assert.NoError(t, client.Context().Err(), "Registered client's context should be live")
+
+	hub.UnregisterClient(client)
+	select {
+	case <-client.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("client context was not canceled on unregister")
+	}
+}
+
+func TestClientWritePumpToleratesBacklog(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		tolerance  int
+		disconnect bool
+	}{
+		{name: "disabled", tolerance: 0},
+		{name: "within tolerance", tolerance: 2},
+		{name: "past tolerance", tolerance: 1, disconnect: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, mockConn, hub := createTestClientWithMock("slow_session")
+			hub.SetWriteBatchLimit(64)
+			hub.SetSlowClientTolerance(tc.tolerance)
+
+			go hub.Run()
+			defer hub.Shutdown()
+
+			hub.RegisterClient(client)
+			require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+			// Queue 200 messages before the write pump starts: the first two
+			// batches of 64 start with the queue at least half full, the third
+			// does not.
+			for i := 0; i < 200; i++ {
+				client.Send([]byte(fmt.Sprintf(`{"n":%d}`, i)))
+			}
+
+			done := make(chan struct{})
+			go func() {
+				client.writePump()
+				close(done)
+			}()
+
+			if tc.disconnect {
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+					t.Fatal("writePump did not stop after exceeding the slow client tolerance")
+				}
+				require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+				assert.Len(t, mockConn.getMessages(), 1, "Only the tolerated batch should be written")
+				assert.Equal(t, uint64(1), hub.ToleratedBacklogs())
+				assert.Equal(t, uint64(1), hub.WriteFailures())
+				assert.Equal(t, uint64(200-64), hub.DroppedMessages())
+				return
+			}
+
+			require.Eventually(t, func() bool { return len(mockConn.getMessages()) == 4 }, time.Second, 10*time.Millisecond)
+			assert.True(t, hub.HasSession("slow_session"))
+			assert.Equal(t, uint64(tc.tolerance), hub.ToleratedBacklogs())
+			assert.Equal(t, uint64(tc.tolerance), hub.Metrics().ToleratedBacklogs)
+			assert.Zero(t, hub.WriteFailures())
+		})
+	}
+}
+
+// shortDeadlineConn shortens the write deadlines set on a real connection so
+// a write to a peer that stops reading times out quickly.
+type shortDeadlineConn struct {
+	*websocket.Conn
+}
+
+func (c shortDeadlineConn) SetWriteDeadline(time.Time) error {
+	return c.Conn.SetWriteDeadline(time.Now().Add(50 * time.Millisecond))
+}
+
+func TestClientWritePumpClosesOnWriteTimeout(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+	// A tolerance must not keep a connection whose write timed out
+	hub.SetSlowClientTolerance(10)
+	go hub.Run()
+	defer hub.Shutdown()
+
+	upgrader := websocket.Upgrader{}
+	conns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		conns <- conn
+	}))
+	defer server.Close()
+
+	// The peer never reads, so the server's writes eventually block
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer peer.Close()
+
+	client := NewClient(hub, shortDeadlineConn{<-conns}, "stalled_session", logger, createTestRouter())
+	hub.RegisterClient(client)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		client.writePump()
+		close(done)
+	}()
+
+	payload := bytes.Repeat([]byte("x"), 256<<10)
+	for i := 0; i < 64; i++ {
+		client.Send(payload)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writePump did not stop after a write timeout")
+	}
+
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+	assert.False(t, hub.HasSession("stalled_session"))
+	assert.Equal(t, uint64(1), hub.WriteFailures(), "The first write timeout should disconnect")
+
+	// The server closed its end, so the peer sees the stream end once it
+	// drains what was written before the timeout.
+	peer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := peer.ReadMessage(); err != nil {
+			var netErr interface{ Timeout() bool }
+			if errors.As(err, &netErr) {
+				assert.False(t, netErr.Timeout(), "Peer connection should be closed, not left open")
+			}
+			break
+		}
+	}
+}
+
+func TestClientWritePumpBatchLimitKeepsPinging(t *testing.T) {
+	client, mockConn, hub := createTestClientWithMock("flood_session")
+	hub.SetWriteBatchLimit(4)
+	hub.pingPeriod = 20 * time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		client.writePump()
+		close(done)
+	}()
+
+	// Keep the send channel saturated for several ping periods
+	stop := make(chan struct{})
+	flooded := make(chan struct{})
+	go func() {
+		defer close(flooded)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				client.trySend([]byte(`{"flood":true}`))
+			}
+		}
+	}()
+
+	assert.Eventually(t, mockConn.isPingReceived, 10*hub.pingPeriod, time.Millisecond,
+		"ping was not sent while the send channel was flooded")
+
+	close(stop)
+	<-flooded
+	client.closeSend()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writePump did not stop after send channel closed")
+	}
+
+	for _, frame := range mockConn.getMessages() {
+		assert.LessOrEqual(t, bytes.Count(frame, newline)+1, 4, "frame exceeded write batch limit")
+	}
+}
@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultReconnectHint is the default backoff suggested to clients when the
+	// server closes a connection for a transient reason (shutdown or overload).
+	DefaultReconnectHint = 5 * time.Second
+
+	// maxCloseReasonBytes is the largest close reason allowed by RFC 6455
+	// (125 byte control frame payload minus the 2 byte status code).
+	maxCloseReasonBytes = 123
+)
+
+// CloseReason is the structured payload encoded into the reason of close frames
+// sent for transient conditions. Well-behaved clients can parse it to decide
+// how long to wait before reconnecting.
+type CloseReason struct {
+	// Reason is a short human-readable explanation of why the connection was closed
+	Reason string `json:"reason"`
+
+	// RetryAfter is the suggested number of seconds to wait before reconnecting
+	RetryAfter int `json:"retry_after"`
+}
+
+// FormatCloseWithRetry builds a close frame payload for the given close code whose
+// reason is a JSON-encoded CloseReason carrying a retry_after hint in seconds.
+// Sub-second hints are rounded up so that a non-zero hint never encodes as zero.
+// The human-readable reason is truncated if needed to respect the control frame limit.
+func FormatCloseWithRetry(code int, reason string, retryAfter time.Duration) []byte {
+	payload := CloseReason{
+		Reason:     reason,
+		RetryAfter: int(math.Ceil(retryAfter.Seconds())),
+	}
+
+	encoded, err := json.Marshal(payload)
+	for err == nil && len(encoded) > maxCloseReasonBytes && payload.Reason != "" {
+		overflow := len(encoded) - maxCloseReasonBytes
+		if overflow > len(payload.Reason) {
+			overflow = len(payload.Reason)
+		}
+		payload.Reason = payload.Reason[:len(payload.Reason)-overflow]
+		encoded, err = json.Marshal(payload)
+	}
+	if err != nil {
+		return websocket.FormatCloseMessage(code, "")
+	}
+
+	return websocket.FormatCloseMessage(code, string(encoded))
+}
+
+// ParseCloseReason decodes the structured reason of a close frame produced by
+// FormatCloseWithRetry. It returns false if the text is not a structured reason.
+func ParseCloseReason(text string) (CloseReason, bool) {
+	var reason CloseReason
+	if err := json.Unmarshal([]byte(text), &reason); err != nil {
+		return CloseReason{}, false
+	}
+	return reason, true
+}
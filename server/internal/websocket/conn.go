@@ -0,0 +1,27 @@
+package websocket
+
+import (
+	"io"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Conn is the subset of *websocket.Conn used by Client. Production code passes
+// the gorilla connection returned by the upgrader; tests can substitute a mock
+// to inject read and write failures.
+type Conn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	NextWriter(messageType int) (io.WriteCloser, error)
+	SetReadLimit(limit int64)
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
+	SetPingHandler(h func(appData string) error)
+	Close() error
+}
+
+// Ensure the gorilla connection satisfies Conn.
+var _ Conn = (*websocket.Conn)(nil)
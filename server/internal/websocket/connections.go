@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+)
+
+// ConnectionInfo is a snapshot of one registered client's connection.
+type ConnectionInfo struct {
+	// SessionCode identifies the client's session
+	SessionCode string `json:"session_code"`
+
+	// ConnectedAt is when the client was registered with the hub
+	ConnectedAt time.Time `json:"connected_at"`
+
+	// LastPong is when the client last answered a ping; zero if it has not yet
+	LastPong time.Time `json:"last_pong,omitzero"`
+
+	// MessagesReceived and MessagesSent count the messages read from and
+	// written to the client
+	MessagesReceived uint64 `json:"messages_received"`
+	MessagesSent     uint64 `json:"messages_sent"`
+
+	// QueuedMessages is the number of messages waiting in the send channel
+	QueuedMessages int `json:"queued_messages"`
+}
+
+// ListConnections returns a snapshot of every registered client, sorted by
+// session code. The per-client fields are updated by the read and write
+// pumps and read here atomically, so listing never blocks or races them.
+// This method is thread-safe.
+func (h *Hub) ListConnections() []ConnectionInfo {
+	h.mu.RLock()
+	connections := make([]ConnectionInfo, 0, len(h.clients))
+	for client := range h.clients {
+		connections = append(connections, client.ConnectionInfo())
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].SessionCode < connections[j].SessionCode
+	})
+	return connections
+}
+
+// ConnectionInfo snapshots the client's connection state. This method is
+// thread-safe.
+func (c *Client) ConnectionInfo() ConnectionInfo {
+	return ConnectionInfo{
+		SessionCode:      c.SessionCode(),
+		ConnectedAt:      unixNanoTime(c.connectedAt.Load()),
+		LastPong:         unixNanoTime(c.lastPong.Load()),
+		MessagesReceived: c.messagesReceived.Load(),
+		MessagesSent:     c.messagesSent.Load(),
+		// len is safe on a channel that is being used or has been closed
+		QueuedMessages: len(c.send),
+	}
+}
+
+// unixNanoTime converts Unix nanoseconds to a time, mapping zero to the zero
+// time.
+func unixNanoTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
@@ -0,0 +1,25 @@
+package websocket
+
+import "context"
+
+// contextKey is an unexported type for context keys defined in this package,
+// preventing collisions with keys defined in other packages.
+type contextKey int
+
+const (
+	// clientContextKey is the context key for the Client handling a request
+	clientContextKey contextKey = iota
+)
+
+// withClient returns a copy of ctx carrying the given client.
+func withClient(ctx context.Context, client *Client) context.Context {
+	return context.WithValue(ctx, clientContextKey, client)
+}
+
+// ClientFromContext returns the WebSocket client that received the JSON-RPC
+// request being handled, if any. JSON-RPC handlers can use it to act on the
+// caller's connection (e.g. joining rooms or reading its session code).
+func ClientFromContext(ctx context.Context) (*Client, bool) {
+	client, ok := ctx.Value(clientContextKey).(*Client)
+	return client, ok && client != nil
+}
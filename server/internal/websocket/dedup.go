@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fle/server/internal/jsonrpc"
+)
+
+// notificationKey identifies a notification by its method and params.
+type notificationKey [sha256.Size]byte
+
+// SetNotificationDedupWindow enables suppression of repeated notifications: a
+// notification sent with SendNotification is dropped if one with the same
+// method and params was sent to the same session within the window. Zero or a
+// negative window disables deduplication, which is the default.
+func (h *Hub) SetNotificationDedupWindow(window time.Duration) {
+	h.dedupMu.Lock()
+	defer h.dedupMu.Unlock()
+	if window < 0 {
+		window = 0
+	}
+	h.dedupWindow = window
+}
+
+// SuppressedNotifications returns the number of notifications dropped as
+// duplicates within the dedup window. This method is thread-safe.
+func (h *Hub) SuppressedNotifications() uint64 {
+	return h.suppressedNotifications.Load()
+}
+
+// SendNotification sends a JSON-RPC notification to a session like
+// SendToSession, unless the session's client has not subscribed to its event
+// type (see Subscribe) or an identical notification was sent to the session
+// within the dedup window. It returns an error if the notification cannot be
+// marshaled. This method is thread-safe.
+func (h *Hub) SendNotification(sessionCode string, notification *jsonrpc.Request) error {
+	message, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	if !h.sessionWantsEvent(sessionCode, notification.Method) {
+		return nil
+	}
+
+	if h.isDuplicateNotification(sessionCode, notification, time.Now()) {
+		h.suppressedNotifications.Add(1)
+		h.logger.Debug("duplicate notification suppressed",
+			"sessionCode", sessionCode,
+			"method", notification.Method)
+		return nil
+	}
+
+	h.SendToSession(sessionCode, message)
+	return nil
+}
+
+// isDuplicateNotification reports whether the notification repeats one sent to
+// the session within the dedup window, recording it as sent otherwise. Expired
+// entries for the session are pruned on each call; the history of a deleted
+// session is dropped by ForgetSession.
+func (h *Hub) isDuplicateNotification(sessionCode string, notification *jsonrpc.Request, now time.Time) bool {
+	h.dedupMu.Lock()
+	defer h.dedupMu.Unlock()
+
+	if h.dedupWindow == 0 {
+		return false
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(notification.Method))
+	hash.Write([]byte{0})
+	hash.Write(notification.Params)
+	var key notificationKey
+	copy(key[:], hash.Sum(nil))
+
+	sessionKey := h.sessionKey(sessionCode)
+	h.pruneNotificationsLocked(sessionKey, now)
+	recent := h.recentNotifications[sessionKey]
+
+	if _, ok := recent[key]; ok {
+		return true
+	}
+
+	if recent == nil {
+		recent = make(map[notificationKey]time.Time)
+		h.recentNotifications[sessionKey] = recent
+	}
+	recent[key] = now
+	return false
+}
+
+// pruneNotificationsLocked drops the entries of the session stored under
+// sessionKey that are older than the dedup window, and the session's history
+// altogether once it is empty. The caller must hold h.dedupMu.
+func (h *Hub) pruneNotificationsLocked(sessionKey string, now time.Time) {
+	recent, ok := h.recentNotifications[sessionKey]
+	if !ok {
+		return
+	}
+	for k, sentAt := range recent {
+		if now.Sub(sentAt) >= h.dedupWindow {
+			delete(recent, k)
+		}
+	}
+	if len(recent) == 0 {
+		delete(h.recentNotifications, sessionKey)
+	}
+}
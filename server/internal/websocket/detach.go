@@ -0,0 +1,27 @@
+package websocket
+
+// SetDetachCallback registers a function called with the session code when
+// the last connection for a session is unregistered or disconnected by
+// DisconnectSession, but not when a reconnect replaces the connection or the
+// hub shuts down. rooms are the sorted rooms the connection had joined. It
+// lets the session manager record the disconnect without the hub depending
+// on it. The callback runs on a goroutine of its own, so a slow callback,
+// such as one writing to a session store, never holds up the Run loop; by
+// the time it runs the client may already have reconnected.
+func (h *Hub) SetDetachCallback(onDetach func(sessionCode string, rooms []string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onDetach = onDetach
+}
+
+// detach starts the detach callback, if any, for a session that lost its
+// last connection.
+func (h *Hub) detach(sessionCode string, rooms []string) {
+	h.mu.RLock()
+	onDetach := h.onDetach
+	h.mu.RUnlock()
+
+	if onDetach != nil {
+		go onDetach(sessionCode, rooms)
+	}
+}
@@ -1,8 +1,14 @@
 package websocket
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/fle/server/internal/jsonrpc"
 	"github.com/gorilla/websocket"
@@ -18,8 +24,48 @@ type Hub struct {
 	// sessions maps session codes to their corresponding clients for targeted messaging
 	sessions map[string]*Client
 
-	// broadcast channel for broadcasting messages to all connected clients
-	broadcast chan []byte
+	// clientRooms maps each client to the rooms it has joined
+	clientRooms map[*Client]map[string]bool
+
+	// clientSubscriptions maps each client that opted into event filtering to
+	// the event types it subscribed to
+	clientSubscriptions map[*Client]map[string]bool
+
+	// broadcast queues messages for the Run loop to send to all connected clients
+	broadcast chan queuedBroadcast
+
+	// broadcastGate is held for reading while a broadcast is queued and for
+	// writing while Shutdown closes the queue, so no broadcast is queued after
+	// the queue has been drained
+	broadcastGate sync.RWMutex
+
+	// broadcastClosed is set under broadcastGate once the queue is closed
+	broadcastClosed bool
+
+	// closing is closed when Shutdown begins, releasing senders blocked on a
+	// full broadcast queue
+	closing chan struct{}
+
+	// broadcastMu is held while a broadcast is delivered, so Shutdown waits for
+	// an in-progress broadcast before closing clients
+	broadcastMu sync.Mutex
+
+	// broadcastsDrained is set under broadcastMu once Shutdown has drained the
+	// queue; a broadcast the Run loop dequeued before then is discarded
+	broadcastsDrained bool
+
+	// stopping is set once Shutdown begins; new broadcasts and registrations
+	// are then refused
+	stopping atomic.Bool
+
+	// shutdownBroadcastPolicy decides whether Shutdown flushes or discards queued broadcasts
+	shutdownBroadcastPolicy ShutdownBroadcastPolicy
+
+	// discardedBroadcasts counts broadcasts dropped because the hub was shutting down
+	discardedBroadcasts atomic.Uint64
+
+	// rejectedBroadcasts counts TryBroadcast calls refused because the queue was full
+	rejectedBroadcasts atomic.Uint64
 
 	// register channel for registering new clients
 	register chan *Client
@@ -32,6 +78,113 @@ type Hub struct {
 
 	// logger for structured logging
 	logger *slog.Logger
+
+	// maxConnections caps the number of registered clients (0 means unlimited)
+	maxConnections int
+
+	// reconnectHint is the backoff suggested to clients in transient close frames
+	reconnectHint time.Duration
+
+	// connectionLogSampling logs one in N connection lifecycle events (values <= 1 log all)
+	connectionLogSampling int
+
+	// totalConnections counts every client registration, whether or not it was logged
+	totalConnections atomic.Uint64
+
+	// totalDisconnections counts every client unregistration, whether or not it was logged
+	totalDisconnections atomic.Uint64
+
+	// writeFailures counts write pumps that stopped because a write to the connection failed
+	writeFailures atomic.Uint64
+
+	// droppedMessages counts queued messages discarded when a write pump failed
+	droppedMessages atomic.Uint64
+
+	// receivedSizes and sentSizes track the sizes of messages read from and
+	// written to clients; see Metrics
+	receivedSizes messageSizeCounter
+	sentSizes     messageSizeCounter
+
+	// pending buffers messages for sessions without a connected client, keyed like sessions
+	pending map[string]*pendingBuffer
+
+	// pendingLimit caps each pending buffer; zero disables buffering
+	pendingLimit int
+
+	// pendingPolicy decides which message is discarded when a pending buffer is full
+	pendingPolicy OverflowPolicy
+
+	// pendingDropped counts pending messages discarded by the overflow policy or on flush
+	pendingDropped atomic.Uint64
+
+	// pendingMaxAge discards buffered messages older than this; zero keeps them
+	// until they are flushed or displaced
+	pendingMaxAge time.Duration
+
+	// pendingExpired counts buffered messages discarded for exceeding pendingMaxAge
+	pendingExpired atomic.Uint64
+
+	// sessionExists, if set, reports whether a session exists; messages are
+	// only buffered for sessions it reports. See SetSessionChecker
+	sessionExists func(sessionCode string) bool
+
+	// now returns the current time; tests replace it to age pending messages
+	now func() time.Time
+
+	// strictOrdering serializes each session's outbound messages through orderLocks
+	// so buffered messages always precede live ones across a reconnect
+	strictOrdering bool
+
+	// orderLocks are striped by session key; see lockSessionOrder
+	orderLocks [orderLockStripes]sync.Mutex
+
+	// writeBatchLimit caps the messages a client's write pump coalesces into one frame
+	writeBatchLimit int
+
+	// slowClientTolerance is how many consecutive batches a client's write pump
+	// writes with a backlogged send queue before disconnecting; zero disables it
+	slowClientTolerance int
+
+	// toleratedBacklogs counts batches written despite a backlogged send queue
+	toleratedBacklogs atomic.Uint64
+
+	// requestSequencing enables validation of the "_seq" request param
+	requestSequencing bool
+
+	// trimTrailingNewline removes a trailing newline from inbound text messages
+	trimTrailingNewline bool
+
+	// pingPeriod is how often write pumps ping their peer
+	pingPeriod time.Duration
+
+	// dedupMu guards dedupWindow and recentNotifications
+	dedupMu sync.Mutex
+
+	// dedupWindow suppresses repeated notifications within this duration; zero disables it
+	dedupWindow time.Duration
+
+	// recentNotifications maps session keys to when each notification was last sent
+	recentNotifications map[string]map[notificationKey]time.Time
+
+	// suppressedNotifications counts notifications dropped as duplicates
+	suppressedNotifications atomic.Uint64
+
+	// checkOrigin decides which origins may open connections; nil accepts any
+	checkOrigin OriginChecker
+
+	// normalizeCode maps session codes to the form used as sessions map keys;
+	// nil means codes are used as-is
+	normalizeCode func(string) string
+
+	// onDetach, if set, is called with the session code and its rooms when
+	// the session's last connection goes away; see SetDetachCallback
+	onDetach func(sessionCode string, rooms []string)
+
+	// done is closed when the hub shuts down to stop the Run loop
+	done chan struct{}
+
+	// shutdownOnce ensures Shutdown only runs once
+	shutdownOnce sync.Once
 }
 
 // Client represents a single WebSocket connection with its associated session.
@@ -40,41 +193,230 @@ type Client struct {
 	hub *Hub
 
 	// conn is the websocket connection
-	conn *websocket.Conn
+	conn Conn
 
 	// send is a buffered channel of outbound messages
 	send chan []byte
 
-	// sessionCode is the unique session identifier for this client
-	sessionCode string
+	// sendMu guards sendClosed so that queueing a message never races closing send
+	sendMu sync.RWMutex
+
+	// sendClosed is set once the send channel has been closed
+	sendClosed bool
+
+	// ctx is the client's lifecycle context, canceled once send is closed or
+	// the read pump stops; request contexts are derived from it
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// closeOnce makes Close idempotent; closeErr is the result of the first call
+	closeOnce sync.Once
+	closeErr  error
+
+	// slowClientTolerance and backloggedBatches are only used by the write
+	// pump; see tolerateBacklog
+	slowClientTolerance int
+	backloggedBatches   int
+
+	// requestSequencing, lastRequestSeq and hasRequestSeq are only used by
+	// the read pump and the messages it processes; see checkRequestSequence
+	requestSequencing bool
+	lastRequestSeq    uint64
+	hasRequestSeq     bool
+
+	// trimTrailingNewline is only used by the read pump; see SetTrimTrailingNewline
+	trimTrailingNewline bool
+
+	// sessionCode is the unique session identifier for this client; read it
+	// through SessionCode, as rekeying the session changes it
+	sessionCode   string
+	sessionCodeMu sync.RWMutex
 
 	// logger for structured logging specific to this client
 	logger *slog.Logger
 
 	// jsonrpcRouter handles JSON-RPC method routing for this client
 	jsonrpcRouter *jsonrpc.Router
+
+	// principal is the authenticated caller, or nil for anonymous connections
+	principal *jsonrpc.Principal
+
+	// sequence is the number of the last message queued through SendToSession,
+	// continuing the numbering of the session's earlier connections
+	sequence atomic.Uint64
+
+	// resumeAfter is the sequence number of the last message the client
+	// received on an earlier connection, if resuming is set; buffered messages
+	// up to it are not replayed on registration. A resuming client receives
+	// its session messages in sequence envelopes
+	resumeAfter uint64
+	resuming    bool
+
+	// delivered holds the most recent messages queued through SendToSession,
+	// up to the pending limit, so that a reconnect can replay the ones the
+	// client never received
+	delivered   []pendingMessage
+	deliveredMu sync.Mutex
+
+	// nextCallID numbers the requests sent to the client by Call
+	nextCallID atomic.Uint64
+
+	// calls maps the ids of Call requests awaiting a response to the channel
+	// receiving it
+	calls   map[uint64]chan callResponse
+	callsMu sync.Mutex
+
+	// pendingWork counts messages read but not yet processed and work held
+	// through Hold; unwritten counts messages queued on send but not yet
+	// written. Drain waits for both to reach zero.
+	pendingWork atomic.Int64
+	unwritten   atomic.Int64
+
+	// connectedAt and lastPong (Unix nanoseconds, zero if unset) and the
+	// message counters are written by the hub and the pumps while
+	// ListConnections reads them, so they are atomic
+	connectedAt      atomic.Int64
+	lastPong         atomic.Int64
+	messagesReceived atomic.Uint64
+	messagesSent     atomic.Uint64
 }
 
 // NewHub creates a new Hub instance ready to manage WebSocket connections.
 // It initializes all channels and maps required for the hub pattern.
 func NewHub(logger *slog.Logger) *Hub {
 	return &Hub{
-		clients:    make(map[*Client]bool),
-		sessions:   make(map[string]*Client),
-		broadcast:  make(chan []byte),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		logger:     logger,
+		clients:             make(map[*Client]bool),
+		sessions:            make(map[string]*Client),
+		clientRooms:         make(map[*Client]map[string]bool),
+		clientSubscriptions: make(map[*Client]map[string]bool),
+		pending:             make(map[string]*pendingBuffer),
+		recentNotifications: make(map[string]map[notificationKey]time.Time),
+		broadcast:           make(chan queuedBroadcast, DefaultBroadcastQueueSize),
+		register:            make(chan *Client),
+		unregister:          make(chan *Client),
+		logger:              logger,
+		reconnectHint:       DefaultReconnectHint,
+		strictOrdering:      true,
+		writeBatchLimit:     DefaultWriteBatchLimit,
+		pingPeriod:          pingPeriod,
+		now:                 time.Now,
+		closing:             make(chan struct{}),
+		done:                make(chan struct{}),
+	}
+}
+
+// SetMaxConnections sets the maximum number of concurrently registered clients.
+// Clients registering beyond the limit are closed with a "try again later" close
+// frame carrying the reconnect hint. Zero or a negative value means unlimited.
+// This should be called before the hub starts accepting clients.
+func (h *Hub) SetMaxConnections(max int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxConnections = max
+}
+
+// SetWriteBatchLimit sets the maximum number of queued messages a client's write
+// pump coalesces into a single frame before checking for a due ping. Values below
+// one are treated as one, sending each message in its own frame.
+// This should be called before the hub starts accepting clients.
+func (h *Hub) SetWriteBatchLimit(limit int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if limit < 1 {
+		limit = 1
 	}
+	h.writeBatchLimit = limit
+}
+
+// SetCodeNormalizer sets the function used to normalize session codes before they
+// are used to look up clients. It should match the session manager's normalization
+// so that the hub and manager agree on which codes identify the same session.
+// This should be called before the hub starts accepting clients.
+func (h *Hub) SetCodeNormalizer(normalize func(string) string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.normalizeCode = normalize
+}
+
+// SetConnectionLogSampling limits connection lifecycle logging to one in every n
+// register/unregister events to reduce noise under high churn. Errors and rejected
+// connections are always logged, and connection counters stay accurate regardless.
+// Values of n <= 1 log every event.
+func (h *Hub) SetConnectionLogSampling(n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.connectionLogSampling = n
+}
+
+// TotalConnections returns the number of clients registered since the hub was created.
+// This method is thread-safe.
+func (h *Hub) TotalConnections() uint64 {
+	return h.totalConnections.Load()
+}
+
+// TotalDisconnections returns the number of clients unregistered since the hub was created.
+// This method is thread-safe.
+func (h *Hub) TotalDisconnections() uint64 {
+	return h.totalDisconnections.Load()
+}
+
+// WriteFailures returns the number of client write pumps that stopped on a write error.
+// This method is thread-safe.
+func (h *Hub) WriteFailures() uint64 {
+	return h.writeFailures.Load()
+}
+
+// DroppedMessages returns the number of queued messages discarded by failed write pumps.
+// This method is thread-safe.
+func (h *Hub) DroppedMessages() uint64 {
+	return h.droppedMessages.Load()
+}
+
+// recordWriteFailure accounts for a write pump that failed with the given
+// number of messages left unsent.
+func (h *Hub) recordWriteFailure(unsent int) {
+	h.writeFailures.Add(1)
+	h.droppedMessages.Add(uint64(unsent))
+}
+
+// SetSlowClientTolerance sets how many batches in a row a client's write pump
+// writes while its send queue is at least half full before it disconnects the
+// client, so a transiently slow client is not dropped while a persistently
+// slow one is. The queue depth is checked before each write; a batch started
+// with a shorter queue resets the count. Zero or a negative value, the
+// default, disables the check. A failed write, including a write timeout,
+// always disconnects. The setting applies to clients connecting after the
+// call.
+func (h *Hub) SetSlowClientTolerance(tolerance int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.slowClientTolerance = max(tolerance, 0)
+}
+
+// ToleratedBacklogs returns the number of batches that write pumps wrote
+// despite a backlogged send queue because of the slow client tolerance.
+func (h *Hub) ToleratedBacklogs() uint64 {
+	return h.toleratedBacklogs.Load()
+}
+
+// SetReconnectHint sets the backoff suggested to clients in the retry_after field
+// of close frames sent on shutdown or overload.
+func (h *Hub) SetReconnectHint(hint time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.reconnectHint = hint
 }
 
 // NewClient creates a new Client instance with the provided WebSocket connection
 // and session code. The client is not automatically registered with the hub.
-func NewClient(hub *Hub, conn *websocket.Conn, sessionCode string, logger *slog.Logger, jsonrpcRouter *jsonrpc.Router) *Client {
+func NewClient(hub *Hub, conn Conn, sessionCode string, logger *slog.Logger, jsonrpcRouter *jsonrpc.Router) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Client{
 		hub:           hub,
 		conn:          conn,
 		send:          make(chan []byte, 256), // Buffered channel to prevent blocking
+		ctx:           ctx,
+		cancel:        cancel,
 		sessionCode:   sessionCode,
 		logger:        logger,
 		jsonrpcRouter: jsonrpcRouter,
@@ -87,6 +429,19 @@ func NewClient(hub *Hub, conn *websocket.Conn, sessionCode string, logger *slog.
 func (h *Hub) Run() {
 	h.logger.Info("WebSocket hub started")
 
+	// Prune aged pending messages periodically so idle sessions that never
+	// reconnect do not hold them; a nil channel disables the case
+	var prune <-chan time.Time
+	if interval := h.pendingPruneInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		prune = ticker.C
+	}
+
+	// Shutdown closes the broadcast queue before it stops the loop; a nil
+	// channel then disables the case
+	queue := h.broadcast
+
 	for {
 		select {
 		case client := <-h.register:
@@ -95,58 +450,234 @@ func (h *Hub) Run() {
 		case client := <-h.unregister:
 			h.unregisterClient(client)
 
-		case message := <-h.broadcast:
-			h.broadcastMessage(message)
+		case broadcast, ok := <-queue:
+			if !ok {
+				queue = nil
+				continue
+			}
+			h.broadcastMessage(broadcast)
+
+		case <-prune:
+			h.PruneExpiredPending()
+
+		case <-h.done:
+			h.logger.Info("WebSocket hub stopped")
+			return
 		}
 	}
 }
 
+// Shutdown closes every connected client with a "going away" close frame that
+// carries the reconnect hint, and stops the hub's event loop.
+// It is safe to call multiple times.
+func (h *Hub) Shutdown() {
+	h.shutdownOnce.Do(func() {
+		// Refuse new broadcasts and registrations and close the queue once
+		// no sender is queuing, so later senders get an error instead of
+		// queuing a broadcast nobody drains. Then wait for a broadcast in
+		// progress to finish and flush or discard the queue before the
+		// clients are closed
+		h.stopping.Store(true)
+		close(h.closing)
+		h.broadcastGate.Lock()
+		h.broadcastClosed = true
+		close(h.broadcast)
+		h.broadcastGate.Unlock()
+
+		h.broadcastMu.Lock()
+		h.drainBroadcasts()
+		h.broadcastsDrained = true
+		h.broadcastMu.Unlock()
+
+		h.mu.Lock()
+		clients := make([]*Client, 0, len(h.clients))
+		for client := range h.clients {
+			clients = append(clients, client)
+			delete(h.clients, client)
+			delete(h.sessions, h.sessionKey(client.SessionCode()))
+			delete(h.clientRooms, client)
+			delete(h.clientSubscriptions, client)
+		}
+		hint := h.reconnectHint
+		h.mu.Unlock()
+
+		h.totalDisconnections.Add(uint64(len(clients)))
+		for _, client := range clients {
+			client.closeWithRetry(websocket.CloseGoingAway, "server shutting down", hint)
+			client.closeSend()
+		}
+
+		close(h.done)
+
+		h.logger.Info("WebSocket hub shut down",
+			"closedClients", len(clients))
+	})
+}
+
 // RegisterClient adds a new client to the hub. This method should be called
 // when a new WebSocket connection is established. It registers the client
 // both in the general clients map and in the sessions map for targeted messaging.
 func (h *Hub) RegisterClient(client *Client) {
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.done:
+		// The hub is gone, so nothing will unregister this client; tear it down here
+		client.closeWithRetry(websocket.CloseGoingAway, "server shutting down", h.getReconnectHint())
+		client.closeSend()
+		client.conn.Close()
+	}
 }
 
 // UnregisterClient removes a client from the hub. This method should be called
 // when a WebSocket connection is closed. It handles cleanup of both the clients
 // and sessions maps.
 func (h *Hub) UnregisterClient(client *Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
 }
 
 // SendToSession sends a message to a specific client identified by session code.
 // If the session is not found, the message is silently dropped. This method
-// is thread-safe and non-blocking.
+// is thread-safe and non-blocking. Each message takes the session's next
+// sequence number, which clients that opted in to resuming receive in an
+// envelope around the message; with strict ordering, messages reach the client in that order even when some were
+// buffered while it was reconnecting.
 func (h *Hub) SendToSession(sessionCode string, message []byte) {
+	key := h.sessionKey(sessionCode)
+
+	// Ask the session checker before taking the ordering lock: it may read
+	// through to the session store, and the Run loop's registerClient waits
+	// on the same lock
+	h.mu.RLock()
+	_, connected := h.sessions[key]
+	buffering := h.pendingLimit > 0
+	h.mu.RUnlock()
+	known := connected || !buffering || h.knownSession(sessionCode)
+
+	unlock := h.lockSessionOrder(key)
+
 	h.mu.RLock()
-	client, exists := h.sessions[sessionCode]
+	client, exists := h.sessions[key]
+	limit := h.pendingLimit
 	h.mu.RUnlock()
 
+	if !exists && limit > 0 && !known {
+		unlock()
+		h.logger.Debug("not buffering message for unknown session",
+			"sessionCode", sessionCode,
+			"messageLength", len(message))
+		return
+	}
+
+	if !exists && limit > 0 {
+		// Re-check under the write lock so a concurrent registration either
+		// receives the message live or flushes it from the buffer
+		h.mu.Lock()
+		client, exists = h.sessions[key]
+		if !exists {
+			seq := h.bufferPendingLocked(key, message)
+			h.mu.Unlock()
+			unlock()
+			h.logger.Debug("message buffered for disconnected session",
+				"sessionCode", sessionCode,
+				"sequence", seq,
+				"messageLength", len(message))
+			return
+		}
+		h.mu.Unlock()
+	}
+
 	if !exists {
+		unlock()
 		h.logger.Warn("attempted to send message to non-existent session",
 			"sessionCode", sessionCode)
 		return
 	}
 
-	select {
-	case client.send <- message:
+	// trySend never writes to a closed channel: the client may have been
+	// unregistered since it was looked up
+	seq := client.sequence.Add(1)
+	switch err := client.trySend(client.stamp(message, seq)); {
+	case err == nil:
+		client.retainDelivered(pendingMessage{seq: seq, data: message, queuedAt: h.now()}, limit)
+		unlock()
 		h.logger.Debug("message sent to session",
+			"sessionCode", sessionCode,
+			"sequence", seq,
+			"messageLength", len(message))
+	case errors.Is(err, errSendClosed):
+		unlock()
+		h.logger.Debug("dropping message for session closed during send",
 			"sessionCode", sessionCode,
 			"messageLength", len(message))
 	default:
+		unlock()
 		// Client's send channel is full, close and unregister the client
 		h.logger.Warn("client send channel full, unregistering",
 			"sessionCode", sessionCode)
-		close(client.send)
+		client.closeSend()
 		h.UnregisterClient(client)
 	}
 }
 
-// BroadcastMessage sends a message to all connected clients. This method
-// is thread-safe and non-blocking.
-func (h *Hub) BroadcastMessage(message []byte) {
-	h.broadcast <- message
+// BroadcastMessage queues a message for all connected clients. This method
+// is thread-safe; it only blocks while the broadcast queue is full. Once
+// Shutdown has begun the message is discarded and ErrHubShuttingDown is
+// returned.
+func (h *Hub) BroadcastMessage(message []byte) error {
+	return h.enqueueBroadcast(queuedBroadcast{message: message})
+}
+
+// enqueueBroadcast queues a broadcast for the Run loop, blocking while the
+// queue is full. It returns ErrHubShuttingDown, counting the broadcast as
+// discarded, once Shutdown has begun.
+func (h *Hub) enqueueBroadcast(broadcast queuedBroadcast) error {
+	h.broadcastGate.RLock()
+	defer h.broadcastGate.RUnlock()
+
+	if h.broadcastClosed {
+		h.discardedBroadcasts.Add(1)
+		return ErrHubShuttingDown
+	}
+
+	select {
+	case h.broadcast <- broadcast:
+		return nil
+	case <-h.closing:
+		h.discardedBroadcasts.Add(1)
+		return ErrHubShuttingDown
+	}
+}
+
+// TryBroadcast queues a message for all connected clients without blocking.
+// It returns false, counting the message as a rejected broadcast, when the
+// broadcast queue is full, and false once Shutdown has begun. This method is
+// thread-safe.
+func (h *Hub) TryBroadcast(message []byte) bool {
+	h.broadcastGate.RLock()
+	defer h.broadcastGate.RUnlock()
+
+	if h.broadcastClosed {
+		h.discardedBroadcasts.Add(1)
+		return false
+	}
+
+	select {
+	case h.broadcast <- queuedBroadcast{message: message}:
+		return true
+	default:
+		h.rejectedBroadcasts.Add(1)
+		return false
+	}
+}
+
+// SetBroadcastQueueSize sets how many broadcasts may wait for the Run loop
+// before BroadcastMessage blocks and TryBroadcast fails. Values below one are
+// treated as one. Must be called before the hub starts running.
+func (h *Hub) SetBroadcastQueueSize(size int) {
+	h.broadcast = make(chan queuedBroadcast, max(size, 1))
 }
 
 // GetClientCount returns the current number of connected clients.
@@ -157,6 +688,46 @@ func (h *Hub) GetClientCount() int {
 	return len(h.clients)
 }
 
+// LoadStats is a snapshot of hub load used for readiness decisions.
+type LoadStats struct {
+	// Clients is the number of registered clients
+	Clients int
+
+	// MaxConnections is the configured connection limit (0 means unlimited)
+	MaxConnections int
+
+	// SaturatedClients counts clients whose send buffer is at least three quarters full
+	SaturatedClients int
+}
+
+// LoadStats returns the current connection count and send buffer saturation.
+// This method is thread-safe.
+func (h *Hub) LoadStats() LoadStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := LoadStats{
+		Clients:        len(h.clients),
+		MaxConnections: h.maxConnections,
+	}
+	for client := range h.clients {
+		if len(client.send)*4 >= cap(client.send)*3 {
+			stats.SaturatedClients++
+		}
+	}
+	return stats
+}
+
+// ShuttingDown reports whether Shutdown has been called.
+func (h *Hub) ShuttingDown() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
 // GetSessionCodes returns a slice of all active session codes.
 // This method is thread-safe and returns a copy to prevent concurrent access issues.
 func (h *Hub) GetSessionCodes() []string {
@@ -175,73 +746,275 @@ func (h *Hub) GetSessionCodes() []string {
 func (h *Hub) HasSession(sessionCode string) bool {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	_, exists := h.sessions[sessionCode]
+	_, exists := h.sessions[h.sessionKey(sessionCode)]
 	return exists
 }
 
+// DisconnectSession closes every connection for the given session code with a
+// policy-violation close frame carrying reason. It returns true if at least
+// one live connection was found. The session itself is left untouched, so
+// the client may reconnect unless the caller also deletes it.
+func (h *Hub) DisconnectSession(sessionCode, reason string) bool {
+	key := h.sessionKey(sessionCode)
+
+	h.mu.Lock()
+	var clients []*Client
+	rooms := make(map[string]bool)
+	for client := range h.clients {
+		if h.sessionKey(client.SessionCode()) == key {
+			clients = append(clients, client)
+			for room := range h.clientRooms[client] {
+				rooms[room] = true
+			}
+			delete(h.clients, client)
+			delete(h.clientRooms, client)
+			delete(h.clientSubscriptions, client)
+		}
+	}
+	delete(h.sessions, key)
+	h.mu.Unlock()
+
+	h.totalDisconnections.Add(uint64(len(clients)))
+	for _, client := range clients {
+		client.closeWithCode(websocket.ClosePolicyViolation, reason)
+		client.closeSend()
+	}
+	if len(clients) > 0 {
+		h.detach(sessionCode, sortedKeys(rooms))
+	}
+
+	if len(clients) > 0 {
+		h.logger.Info("Disconnected session",
+			"sessionCode", sessionCode,
+			"reason", reason,
+			"closedClients", len(clients))
+	}
+	return len(clients) > 0
+}
+
+// JoinRoom adds a registered client to the named room. Joining a room the client
+// is already in is a no-op. Returns an error if the room name is empty or the
+// client is not registered with the hub.
+func (h *Hub) JoinRoom(client *Client, room string) error {
+	if room == "" {
+		return fmt.Errorf("room name cannot be empty")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.clients[client] {
+		return fmt.Errorf("client is not registered")
+	}
+
+	if h.clientRooms[client] == nil {
+		h.clientRooms[client] = make(map[string]bool)
+	}
+	h.clientRooms[client][room] = true
+
+	return nil
+}
+
+// LeaveRoom removes a client from the named room.
+// Returns true if the client was a member of the room.
+func (h *Hub) LeaveRoom(client *Client, room string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.clientRooms[client][room] {
+		return false
+	}
+
+	delete(h.clientRooms[client], room)
+	if len(h.clientRooms[client]) == 0 {
+		delete(h.clientRooms, client)
+	}
+	return true
+}
+
+// ClientRooms returns the sorted names of the rooms a client belongs to.
+// This method is thread-safe and returns a copy.
+func (h *Hub) ClientRooms(client *Client) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	rooms := make([]string, 0, len(h.clientRooms[client]))
+	for room := range h.clientRooms[client] {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// sessionKey returns the normalized form of a session code used as the sessions map key.
+// This method assumes the caller holds the appropriate lock.
+func (h *Hub) sessionKey(sessionCode string) string {
+	if h.normalizeCode == nil {
+		return sessionCode
+	}
+	return h.normalizeCode(sessionCode)
+}
+
+// getReconnectHint returns the configured reconnect hint. This method is thread-safe.
+func (h *Hub) getReconnectHint() time.Duration {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.reconnectHint
+}
+
 // registerClient is the internal implementation for registering a client.
 // It updates both the clients and sessions maps under write lock for thread safety.
 func (h *Hub) registerClient(client *Client) {
+	key := h.sessionKey(client.SessionCode())
+	unlock := h.lockSessionOrder(key)
+	defer unlock()
+
 	h.mu.Lock()
+	// Shutdown sets stopping before it takes its snapshot of the clients
+	// under mu, so a client registered here either is in that snapshot or
+	// is turned away
+	if h.stopping.Load() {
+		hint := h.reconnectHint
+		h.mu.Unlock()
+
+		h.logger.Debug("hub shutting down, rejecting client",
+			"sessionCode", client.SessionCode())
+
+		client.closeWithRetry(websocket.CloseGoingAway, "server shutting down", hint)
+		client.closeSend()
+		return
+	}
+	if h.maxConnections > 0 && len(h.clients) >= h.maxConnections {
+		clientCount := len(h.clients)
+		hint := h.reconnectHint
+		h.mu.Unlock()
+
+		h.logger.Warn("connection limit reached, rejecting client",
+			"sessionCode", client.SessionCode(),
+			"clientCount", clientCount,
+			"maxConnections", h.maxConnections)
+
+		client.closeWithRetry(websocket.CloseTryAgainLater, "server overloaded", hint)
+		client.closeSend()
+		return
+	}
 	h.clients[client] = true
-	h.sessions[client.sessionCode] = client
+	h.sessions[key] = client
+	client.connectedAt.Store(h.now().UnixNano())
+	h.flushPendingLocked(key, client)
+	clientCount := len(h.clients)
+	sampling := h.connectionLogSampling
 	h.mu.Unlock()
 
-	h.logger.Info("client registered",
-		"sessionCode", client.sessionCode,
-		"clientCount", len(h.clients))
+	total := h.totalConnections.Add(1)
+	if shouldSample(total, sampling) {
+		h.logger.Info("client registered",
+			"sessionCode", client.SessionCode(),
+			"clientCount", clientCount,
+			"totalConnections", total)
+	}
 }
 
 // unregisterClient is the internal implementation for unregistering a client.
 // It removes the client from both maps and closes the send channel if it's not already closed.
 func (h *Hub) unregisterClient(client *Client) {
+	detached := false
+	var rooms []string
+	unlock := h.lockSessionOrder(h.sessionKey(client.SessionCode()))
 	h.mu.Lock()
-	if _, ok := h.clients[client]; ok {
+	_, registered := h.clients[client]
+	if registered {
 		delete(h.clients, client)
-		delete(h.sessions, client.sessionCode)
-		
-		// Close the send channel if it's not already closed
-		select {
-		case <-client.send:
-			// Channel is already closed
-		default:
-			close(client.send)
+
+		// Only drop the session mapping if it still points at this client;
+		// a reconnect may already have registered a newer client for the code.
+		key := h.sessionKey(client.SessionCode())
+		if h.sessions[key] == client {
+			delete(h.sessions, key)
+			h.retainPendingLocked(key, client)
+			detached = true
+			rooms = sortedKeys(h.clientRooms[client])
 		}
+
+		delete(h.clientRooms, client)
+		delete(h.clientSubscriptions, client)
+
+		// Close the send channel if it's not already closed
+		client.closeSend()
 	}
 	clientCount := len(h.clients)
+	sampling := h.connectionLogSampling
 	h.mu.Unlock()
+	unlock()
+
+	if !registered {
+		return
+	}
+	if detached {
+		h.detach(client.SessionCode(), rooms)
+	}
 
-	h.logger.Info("client unregistered",
-		"sessionCode", client.sessionCode,
-		"clientCount", clientCount)
+	total := h.totalDisconnections.Add(1)
+	if shouldSample(total, sampling) {
+		h.logger.Info("client unregistered",
+			"sessionCode", client.SessionCode(),
+			"clientCount", clientCount,
+			"totalDisconnections", total)
+	}
+}
+
+// shouldSample reports whether the nth event should be logged when logging one
+// in every sampling events. The first event is always logged.
+func shouldSample(n uint64, sampling int) bool {
+	if sampling <= 1 {
+		return true
+	}
+	return (n-1)%uint64(sampling) == 0
 }
 
 // broadcastMessage is the internal implementation for broadcasting messages.
 // It sends the message to all connected clients. If a client's send channel is full,
 // the client is automatically unregistered to prevent blocking other clients.
-func (h *Hub) broadcastMessage(message []byte) {
+func (h *Hub) broadcastMessage(broadcast queuedBroadcast) {
+	h.broadcastMu.Lock()
+	defer h.broadcastMu.Unlock()
+
+	// Shutdown drained the queue while this broadcast waited for the lock
+	// and its clients are being closed, so account for it like the rest
+	if h.broadcastsDrained {
+		h.discardedBroadcasts.Add(1)
+		return
+	}
+	h.deliverBroadcast(broadcast)
+}
+
+// deliverBroadcast sends a broadcast to every registered client that wants
+// its event type. The caller must hold broadcastMu.
+func (h *Hub) deliverBroadcast(broadcast queuedBroadcast) {
 	h.mu.RLock()
 	clients := make([]*Client, 0, len(h.clients))
 	for client := range h.clients {
-		clients = append(clients, client)
+		if broadcast.eventType == "" || h.wantsEventLocked(client, broadcast.eventType) {
+			clients = append(clients, client)
+		}
 	}
 	h.mu.RUnlock()
 
+	message := broadcast.message
 	h.logger.Debug("broadcasting message",
 		"clientCount", len(clients),
 		"messageLength", len(message))
 
 	// Send to all clients without holding the lock
 	for _, client := range clients {
-		select {
-		case client.send <- message:
-			// Message sent successfully
-		default:
+		// trySend never sends on a channel closed by a concurrent unregister
+		if err := client.trySend(message); errors.Is(err, errSendFull) {
 			// Client's send channel is full, close and unregister the client
 			h.logger.Warn("client send channel full during broadcast, unregistering",
-				"sessionCode", client.sessionCode)
-			close(client.send)
-			h.UnregisterClient(client)
+				"sessionCode", client.SessionCode())
+			// Unregister directly: this runs on the Run loop or in Shutdown,
+			// so sending on the unregister channel could block forever
+			h.unregisterClient(client)
 		}
 	}
 }
\ No newline at end of file
@@ -7,14 +7,19 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
-	"unsafe"
 
 	"github.com/fle/server/internal/jsonrpc"
+	"github.com/fle/server/internal/session"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // mockConn implements a mock WebSocket connection for testing
@@ -50,11 +55,35 @@ func (m *mockConn) WriteMessage(messageType int, data []byte) error {
 func (m *mockConn) Close() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.closed = true
-	close(m.writeChan)
+	if !m.closed {
+		m.closed = true
+		close(m.writeChan)
+	}
+	return nil
+}
+
+func (m *mockConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return websocket.ErrCloseSent
+	}
+	if messageType == websocket.CloseMessage && len(data) >= 2 {
+		m.closeCode = int(data[0])<<8 | int(data[1])
+	}
 	return nil
 }
 
+func (m *mockConn) ReadMessage() (int, []byte, error) {
+	return 0, nil, websocket.ErrCloseSent
+}
+
+func (m *mockConn) SetReadLimit(limit int64) {}
+
+func (m *mockConn) SetPongHandler(h func(string) error) {}
+
+func (m *mockConn) SetPingHandler(h func(string) error) {}
+
 func (m *mockConn) SetWriteDeadline(t time.Time) error {
 	return nil
 }
@@ -113,17 +142,14 @@ func createTestRouter() *jsonrpc.Router {
 	return router
 }
 
-// Helper function to create a client with mock connection using unsafe conversion
+// Helper function to create a client with mock connection
 func createTestClient(sessionCode string) (*Client, *mockConn, *Hub) {
 	logger := createTestLogger()
 	hub := NewHub(logger)
 	router := createTestRouter()
 	mockConn := newMockConn()
-	
-	// Use unsafe pointer conversion to bypass type checking for testing
-	// This is not recommended in production code but acceptable for unit tests
-	wsConn := (*websocket.Conn)(unsafe.Pointer(mockConn))
-	client := NewClient(hub, wsConn, sessionCode, logger, router)
+
+	client := NewClient(hub, mockConn, sessionCode, logger, router)
 	
 	return client, mockConn, hub
 }
@@ -446,6 +472,203 @@ func TestHubSessionCodeRetrieval(t *testing.T) {
 	}
 }
 
+func TestHubStaleUnregisterKeepsReconnectedSession(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+
+	// Start the hub
+	go hub.Run()
+
+	oldClient, _, _ := createTestClient("reconnect_session")
+	oldClient.hub = hub
+	hub.RegisterClient(oldClient)
+
+	// The same session reconnects before the old connection is cleaned up
+	newClient, _, _ := createTestClient("reconnect_session")
+	newClient.hub = hub
+	hub.RegisterClient(newClient)
+
+	hub.UnregisterClient(oldClient)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	// The session must still route to the new client
+	assert.True(t, hub.HasSession("reconnect_session"))
+	hub.SendToSession("reconnect_session", []byte("still connected"))
+
+	select {
+	case msg := <-newClient.send:
+		assert.Equal(t, []byte("still connected"), msg)
+	case <-time.After(100 * time.Millisecond):
+		t.Error("message should be delivered to the reconnected client")
+	}
+}
+
+func TestHubDetachCallback(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+
+	type detachment struct {
+		code  string
+		rooms []string
+	}
+	detached := make(chan detachment, 4)
+	hub.SetDetachCallback(func(sessionCode string, rooms []string) {
+		detached <- detachment{sessionCode, rooms}
+	})
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	oldClient, _, _ := createTestClient("detach_session")
+	oldClient.hub = hub
+	hub.RegisterClient(oldClient)
+	newClient, _, _ := createTestClient("detach_session")
+	newClient.hub = hub
+	hub.RegisterClient(newClient)
+	require.Eventually(t, func() bool { return hub.HasSession("detach_session") }, time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return hub.JoinRoom(newClient, "lobby") == nil }, time.Second, 10*time.Millisecond)
+
+	// Dropping a replaced connection leaves the session attached
+	hub.UnregisterClient(oldClient)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Empty(t, detached)
+
+	// Dropping the last connection detaches the session with its rooms
+	hub.UnregisterClient(newClient)
+	select {
+	case d := <-detached:
+		assert.Equal(t, "detach_session", d.code)
+		assert.Equal(t, []string{"lobby"}, d.rooms)
+	case <-time.After(time.Second):
+		t.Fatal("detach callback was not called for the last connection")
+	}
+}
+
+func TestHubDetachCallbackDoesNotBlockRun(t *testing.T) {
+	hub := NewHub(createTestLogger())
+
+	release := make(chan struct{})
+	hub.SetDetachCallback(func(sessionCode string, rooms []string) {
+		<-release
+	})
+	defer close(release)
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	first, _, _ := createTestClient("slow_detach")
+	first.hub = hub
+	hub.RegisterClient(first)
+	hub.UnregisterClient(first)
+
+	// The Run loop keeps registering clients while the callback is stuck
+	second, _, _ := createTestClient("other_session")
+	second.hub = hub
+	hub.RegisterClient(second)
+	require.Eventually(t, func() bool { return hub.HasSession("other_session") }, time.Second, 10*time.Millisecond)
+}
+
+func TestHubNotifyRooms(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	go hub.Run()
+	defer hub.Shutdown()
+
+	member, _, _ := createTestClient("member_session")
+	member.hub = hub
+	outsider, _, _ := createTestClient("outsider_session")
+	outsider.hub = hub
+	hub.RegisterClient(member)
+	hub.RegisterClient(outsider)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 2 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, hub.JoinRoom(member, "lobby"))
+	require.NoError(t, hub.JoinRoom(outsider, "elsewhere"))
+
+	notification, err := jsonrpc.NewNotification("session.disconnected", map[string]string{"session_code": "gone"})
+	require.NoError(t, err)
+	require.NoError(t, hub.NotifyRooms([]string{"lobby"}, notification))
+
+	select {
+	case message := <-member.send:
+		assert.Contains(t, string(message), "session.disconnected")
+	case <-time.After(time.Second):
+		t.Fatal("room member was not notified")
+	}
+	assert.Empty(t, outsider.send, "clients outside the rooms must not be notified")
+}
+
+func TestHubRekeySession(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	client, _, _ := createTestClient("old_session")
+	client.hub = hub
+	hub.RegisterClient(client)
+	require.Eventually(t, func() bool { return hub.HasSession("old_session") }, time.Second, 10*time.Millisecond)
+
+	assert.True(t, hub.RekeySession("old_session", "new_session"))
+	assert.False(t, hub.HasSession("old_session"), "Old code should no longer map to the client")
+	assert.True(t, hub.HasSession("new_session"), "New code should map to the client")
+	assert.Equal(t, "new_session", client.SessionCode())
+
+	hub.SendToSession("new_session", []byte(`{"hello":"rekeyed"}`))
+	select {
+	case message := <-client.send:
+		assert.JSONEq(t, `{"hello":"rekeyed"}`, string(message))
+	case <-time.After(time.Second):
+		t.Fatal("message to the new code was not delivered")
+	}
+
+	assert.False(t, hub.RekeySession("missing_session", "other_session"))
+
+	// A code that already has a client is not taken over
+	other, _, _ := createTestClient("other_session")
+	other.hub = hub
+	hub.RegisterClient(other)
+	require.Eventually(t, func() bool { return hub.HasSession("other_session") }, time.Second, 10*time.Millisecond)
+	assert.False(t, hub.RekeySession("new_session", "other_session"))
+	assert.Equal(t, "new_session", client.SessionCode(), "a refused rekey should move nothing")
+	assert.Equal(t, "other_session", other.SessionCode())
+}
+
+func TestHubTryBroadcastWhenSaturated(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+	hub.SetBroadcastQueueSize(4)
+
+	// Without a running Run loop nothing drains the queue
+	results := make(chan []bool, 1)
+	go func() {
+		accepted := make([]bool, 0, 100)
+		for i := 0; i < 100; i++ {
+			accepted = append(accepted, hub.TryBroadcast([]byte(fmt.Sprintf("message %d", i))))
+		}
+		results <- accepted
+	}()
+
+	var accepted []bool
+	select {
+	case accepted = <-results:
+	case <-time.After(time.Second):
+		t.Fatal("TryBroadcast blocked on a saturated queue")
+	}
+
+	for i, ok := range accepted {
+		assert.Equal(t, i < 4, ok, "broadcast %d", i)
+	}
+	metrics := hub.Metrics()
+	assert.Equal(t, uint64(96), metrics.RejectedBroadcasts)
+	assert.Equal(t, 4, metrics.QueuedBroadcasts)
+
+	// Once the loop drains the queue broadcasts are accepted again
+	go hub.Run()
+	defer hub.Shutdown()
+	require.Eventually(t, func() bool { return hub.Metrics().QueuedBroadcasts == 0 }, time.Second, 10*time.Millisecond)
+	assert.True(t, hub.TryBroadcast([]byte("after drain")))
+}
+
 // Benchmark tests for performance evaluation
 func BenchmarkHubBroadcast(b *testing.B) {
 	logger := createTestLogger()
@@ -503,4 +726,1074 @@ func BenchmarkHubClientRegistration(b *testing.B) {
 		hub.RegisterClient(client)
 		hub.UnregisterClient(client)
 	}
-}
\ No newline at end of file
+}
+func TestHubOverloadCloseIncludesRetryAfter(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+	hub.SetMaxConnections(1)
+	hub.SetReconnectHint(7 * time.Second)
+	router := createTestRouter()
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	var sessionCounter int
+	var counterMu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counterMu.Lock()
+		sessionCounter++
+		code := fmt.Sprintf("overload_%d", sessionCounter)
+		counterMu.Unlock()
+		ServeWS(hub, w, r, code, logger, router)
+	}))
+	defer server.Close()
+
+	u := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// First connection fills the hub
+	conn1, _, err := websocket.DefaultDialer.Dial(u, nil)
+	require.NoError(t, err)
+	defer conn1.Close()
+
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	// Second connection is upgraded, then closed with a try-again-later frame
+	conn2, _, err := websocket.DefaultDialer.Dial(u, nil)
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	conn2.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn2.ReadMessage()
+	require.Error(t, err)
+
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseTryAgainLater, closeErr.Code)
+
+	reason, ok := ParseCloseReason(closeErr.Text)
+	require.True(t, ok, "close reason should be structured JSON, got %q", closeErr.Text)
+	assert.Equal(t, 7, reason.RetryAfter)
+	assert.Equal(t, "server overloaded", reason.Reason)
+
+	assert.Equal(t, 1, hub.GetClientCount())
+}
+
+func TestHubShutdownClosesClientsWithRetryAfter(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+	hub.SetReconnectHint(3 * time.Second)
+	router := createTestRouter()
+
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWS(hub, w, r, "shutdown_test", logger, router)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	hub.Shutdown()
+	hub.Shutdown() // Safe to call twice
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseGoingAway, closeErr.Code)
+
+	reason, ok := ParseCloseReason(closeErr.Text)
+	require.True(t, ok)
+	assert.Equal(t, 3, reason.RetryAfter)
+	assert.Equal(t, 0, hub.GetClientCount())
+}
+
+// Test that a registration the Run loop picks up after Shutdown has taken its
+// snapshot of the clients is turned away instead of left open.
+func TestHubRegisterAfterShutdownSnapshot(t *testing.T) {
+	client, mockConn, hub := createTestClient("late_session")
+
+	go hub.Run()
+	hub.Shutdown()
+
+	// As if Run had already received the client when Shutdown began
+	hub.registerClient(client)
+
+	assert.Equal(t, 0, hub.GetClientCount())
+	assert.False(t, hub.HasSession("late_session"))
+	assert.Equal(t, websocket.CloseGoingAway, mockConn.closeCode)
+	assert.Error(t, client.Context().Err(), "Rejected client's context should be canceled")
+}
+
+func TestFormatCloseWithRetryTruncatesLongReason(t *testing.T) {
+	message := FormatCloseWithRetry(websocket.CloseTryAgainLater, strings.Repeat("x", 200), 1500*time.Millisecond)
+
+	// Control frame payloads are limited to 125 bytes
+	assert.LessOrEqual(t, len(message), 125)
+
+	reason, ok := ParseCloseReason(string(message[2:]))
+	require.True(t, ok)
+	assert.Equal(t, 2, reason.RetryAfter, "sub-second remainders should round up")
+}
+
+func TestHubCodeNormalizer(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	hub.SetCodeNormalizer(func(code string) string { return session.NormalizeCode(code, false) })
+	go hub.Run()
+	defer hub.Shutdown()
+
+	client, _, _ := createTestClient("Happy-Panda-42")
+	client.hub = hub
+	hub.RegisterClient(client)
+
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	assert.True(t, hub.HasSession("HAPPY-PANDA-42"))
+	hub.SendToSession("happy-panda-42", []byte("hello"))
+
+	select {
+	case msg := <-client.send:
+		assert.Equal(t, []byte("hello"), msg)
+	case <-time.After(time.Second):
+		t.Fatal("message should be delivered using the normalized session code")
+	}
+
+	// Unregister before shutdown so Shutdown does not write to the mock connection
+	hub.UnregisterClient(client)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestHubCodeNormalizerCaseSensitive(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	hub.SetCodeNormalizer(func(code string) string { return session.NormalizeCode(code, true) })
+	go hub.Run()
+	defer hub.Shutdown()
+
+	upper, _, _ := createTestClient("HAPPY-PANDA-42")
+	lower, _, _ := createTestClient("happy-panda-42")
+	upper.hub = hub
+	lower.hub = hub
+	hub.RegisterClient(upper)
+	hub.RegisterClient(lower)
+
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	// Differently cased codes are distinct sessions; surrounding whitespace is still trimmed
+	assert.Len(t, hub.GetSessionCodes(), 2)
+	assert.False(t, hub.HasSession("Happy-Panda-42"))
+	hub.SendToSession(" HAPPY-PANDA-42 ", []byte("to upper"))
+	hub.SendToSession("happy-panda-42", []byte("to lower"))
+
+	for client, expected := range map[*Client]string{upper: "to upper", lower: "to lower"} {
+		select {
+		case msg := <-client.send:
+			assert.Equal(t, []byte(expected), msg)
+		case <-time.After(time.Second):
+			t.Fatalf("message %q should be delivered to %s", expected, client.sessionCode)
+		}
+	}
+
+	// Unregister before shutdown so Shutdown does not write to the mock connections
+	hub.UnregisterClient(upper)
+	hub.UnregisterClient(lower)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestHubRooms(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	go hub.Run()
+	defer hub.Shutdown()
+
+	client1, _, _ := createTestClient("room_client_1")
+	client2, _, _ := createTestClient("room_client_2")
+	client1.hub = hub
+	client2.hub = hub
+
+	// Unregistered clients cannot join rooms
+	assert.Error(t, hub.JoinRoom(client1, "lobby"))
+
+	hub.RegisterClient(client1)
+	hub.RegisterClient(client2)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, hub.JoinRoom(client1, "lobby"))
+	require.NoError(t, hub.JoinRoom(client1, "game"))
+	require.NoError(t, hub.JoinRoom(client2, "lobby"))
+	assert.Error(t, hub.JoinRoom(client1, ""))
+
+	assert.Equal(t, []string{"game", "lobby"}, hub.ClientRooms(client1))
+	assert.Equal(t, []string{"lobby"}, hub.ClientRooms(client2))
+
+	assert.True(t, hub.LeaveRoom(client1, "game"))
+	assert.False(t, hub.LeaveRoom(client1, "game"))
+	assert.Equal(t, []string{"lobby"}, hub.ClientRooms(client1))
+
+	// Unregistering removes the client from all rooms
+	hub.UnregisterClient(client1)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Empty(t, hub.ClientRooms(client1))
+	assert.Equal(t, []string{"lobby"}, hub.ClientRooms(client2))
+
+	// Unregister before shutdown so Shutdown does not write to the mock connection
+	hub.UnregisterClient(client2)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestHubConnectionLogSampling(t *testing.T) {
+	var logs bytes.Buffer
+	var logsMu sync.Mutex
+	logger := slog.New(slog.NewTextHandler(&lockedWriter{w: &logs, mu: &logsMu}, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	hub := NewHub(logger)
+	hub.SetConnectionLogSampling(5)
+	go hub.Run()
+	defer hub.Shutdown()
+
+	const numClients = 20
+	clients := make([]*Client, 0, numClients)
+	for i := 0; i < numClients; i++ {
+		client, _, _ := createTestClient(fmt.Sprintf("sampled_%d", i))
+		client.hub = hub
+		hub.RegisterClient(client)
+		clients = append(clients, client)
+	}
+
+	require.Eventually(t, func() bool { return hub.GetClientCount() == numClients }, time.Second, 10*time.Millisecond)
+
+	for _, client := range clients {
+		hub.UnregisterClient(client)
+	}
+
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+
+	logsMu.Lock()
+	registeredLogs := strings.Count(logs.String(), "client registered")
+	unregisteredLogs := strings.Count(logs.String(), "client unregistered")
+	logsMu.Unlock()
+
+	assert.Equal(t, numClients/5, registeredLogs, "only one in five connect events should be logged")
+	assert.Equal(t, numClients/5, unregisteredLogs, "only one in five disconnect events should be logged")
+	assert.Equal(t, uint64(numClients), hub.TotalConnections(), "counter should include every connection")
+	assert.Equal(t, uint64(numClients), hub.TotalDisconnections(), "counter should include every disconnection")
+}
+
+// lockedWriter serializes writes to an underlying writer for log capture in tests.
+type lockedWriter struct {
+	w  io.Writer
+	mu *sync.Mutex
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+func TestHubPendingBufferOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   OverflowPolicy
+		expected []string
+	}{
+		{"drop oldest", OverflowDropOldest, []string{"msg-3", "msg-4", "msg-5"}},
+		{"drop newest", OverflowDropNewest, []string{"msg-1", "msg-2", "msg-3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub(createTestLogger())
+			hub.SetPendingLimit(3, tt.policy)
+			go hub.Run()
+			defer hub.Shutdown()
+
+			// Nobody is polling or connected, so messages pile up in the buffer
+			for i := 1; i <= 5; i++ {
+				hub.SendToSession("idle_session", []byte(fmt.Sprintf("msg-%d", i)))
+			}
+			assert.Equal(t, 3, hub.PendingCount("idle_session"))
+			assert.Equal(t, uint64(2), hub.PendingDropped())
+
+			client, _, _ := createTestClient("idle_session")
+			client.hub = hub
+			hub.RegisterClient(client)
+			require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+			// Live messages follow the flushed buffer
+			hub.SendToSession("idle_session", []byte("live"))
+
+			var delivered []string
+			for len(delivered) < len(tt.expected)+1 {
+				select {
+				case msg := <-client.send:
+					delivered = append(delivered, string(msg))
+				case <-time.After(time.Second):
+					t.Fatalf("expected buffered messages, got %v", delivered)
+				}
+			}
+			assert.Equal(t, append(tt.expected, "live"), delivered)
+			assert.Equal(t, 0, hub.PendingCount("idle_session"))
+
+			hub.UnregisterClient(client)
+		})
+	}
+}
+
+func TestHubPendingBufferDisabledByDefault(t *testing.T) {
+	hub := NewHub(createTestLogger())
+
+	hub.SendToSession("idle_session", []byte("dropped"))
+	assert.Equal(t, 0, hub.PendingCount("idle_session"))
+	assert.Equal(t, uint64(0), hub.PendingDropped())
+}
+
+func TestHubPendingOnlyForKnownSessions(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	hub.SetPendingLimit(10, OverflowDropOldest)
+	hub.SetSessionChecker(func(sessionCode string) bool { return sessionCode == "known_session" })
+
+	hub.SendToSession("unknown_session", []byte("dropped"))
+	hub.SendToSession("known_session", []byte("buffered"))
+	assert.Equal(t, 0, hub.PendingCount("unknown_session"), "no buffer should be allocated for an unknown session")
+	assert.Equal(t, 1, hub.PendingCount("known_session"))
+
+	// Deleting the session frees its buffer
+	hub.ForgetSession("known_session")
+	assert.Equal(t, 0, hub.PendingCount("known_session"))
+	assert.Equal(t, uint64(0), hub.OutboundSequence("known_session"))
+}
+
+func TestHubSessionCheckerRunsWithoutOrderLock(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	hub.SetPendingLimit(10, OverflowDropOldest)
+	// A checker that needs the session's ordering lock, as registerClient
+	// does while the checker reads through to a slow store
+	hub.SetSessionChecker(func(sessionCode string) bool {
+		hub.lockSessionOrder(hub.sessionKey(sessionCode))()
+		return true
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		hub.SendToSession("known_session", []byte("buffered"))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SendToSession held the ordering lock while asking the session checker")
+	}
+	assert.Equal(t, 1, hub.PendingCount("known_session"))
+}
+
+func TestHubStrictOrderingAcrossReconnect(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		hub := NewHub(createTestLogger())
+		hub.SetPendingLimit(10, OverflowDropOldest)
+		go hub.Run()
+
+		first, _, _ := createTestClient("ordered_session")
+		first.hub = hub
+		hub.RegisterClient(first)
+		require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, time.Millisecond)
+
+		hub.UnregisterClient(first)
+		require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, time.Millisecond)
+
+		hub.SendToSession("ordered_session", []byte("1"))
+		hub.SendToSession("ordered_session", []byte("2"))
+		require.Equal(t, 2, hub.PendingCount("ordered_session"))
+
+		// The third message races the reconnect
+		second, _, _ := createTestClient("ordered_session")
+		second.hub = hub
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			hub.RegisterClient(second)
+		}()
+		go func() {
+			defer wg.Done()
+			hub.SendToSession("ordered_session", []byte("3"))
+		}()
+		wg.Wait()
+		require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, time.Millisecond)
+
+		var delivered []string
+		for len(delivered) < 3 {
+			select {
+			case msg := <-second.send:
+				delivered = append(delivered, string(msg))
+			case <-time.After(time.Second):
+				t.Fatalf("expected three messages, got %v", delivered)
+			}
+		}
+		assert.Equal(t, []string{"1", "2", "3"}, delivered)
+		assert.Equal(t, uint64(3), hub.OutboundSequence("ordered_session"))
+
+		hub.Shutdown()
+	}
+}
+
+func TestHubPendingMaxAge(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	hub.SetPendingLimit(10, OverflowDropOldest)
+	hub.SetPendingMaxAge(time.Minute)
+
+	now := time.Now()
+	hub.now = func() time.Time { return now }
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.SendToSession("idle_session", []byte("stale"))
+	now = now.Add(2 * time.Minute)
+	hub.SendToSession("idle_session", []byte("fresh"))
+
+	client, _, _ := createTestClient("idle_session")
+	client.hub = hub
+	hub.RegisterClient(client)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	select {
+	case msg := <-client.send:
+		assert.Equal(t, "fresh", string(msg))
+	case <-time.After(time.Second):
+		t.Fatal("expected the fresh buffered message")
+	}
+	assert.Empty(t, client.send, "stale message must not be replayed")
+	assert.Equal(t, uint64(1), hub.PendingExpired())
+	assert.Equal(t, uint64(2), hub.OutboundSequence("idle_session"))
+}
+
+func TestHubResumeSkipsAcknowledgedPending(t *testing.T) {
+	tests := []struct {
+		name     string
+		lastSeq  uint64
+		expected []string
+	}{
+		{"after last received", 3, []string{"msg-4", "msg-5"}},
+		{"nothing received", 0, []string{"msg-1", "msg-2", "msg-3", "msg-4", "msg-5"}},
+		{"from an earlier connection", 9, []string{"msg-1", "msg-2", "msg-3", "msg-4", "msg-5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub(createTestLogger())
+			hub.SetPendingLimit(10, OverflowDropOldest)
+			go hub.Run()
+			defer hub.Shutdown()
+
+			for i := 1; i <= 5; i++ {
+				hub.SendToSession("idle_session", []byte(fmt.Sprintf("msg-%d", i)))
+			}
+
+			client, _, _ := createTestClient("idle_session")
+			client.hub = hub
+			client.resumeAfter = tt.lastSeq
+			client.resuming = true
+			hub.RegisterClient(client)
+			require.Eventually(t, func() bool { return hub.GetClientCount() == 1 }, time.Second, 10*time.Millisecond)
+
+			var delivered []string
+			for len(delivered) < len(tt.expected) {
+				select {
+				case msg := <-client.send:
+					delivered = append(delivered, unwrapSequenced(t, msg))
+				case <-time.After(time.Second):
+					t.Fatalf("expected buffered messages, got %v", delivered)
+				}
+			}
+			assert.Equal(t, tt.expected, delivered)
+			assert.Empty(t, client.send, "acknowledged messages must not be replayed")
+			assert.Equal(t, uint64(5), hub.OutboundSequence("idle_session"))
+			assert.Equal(t, uint64(0), hub.PendingDropped())
+		})
+	}
+}
+
+func TestHubResumeReplaysUnacknowledged(t *testing.T) {
+	tests := []struct {
+		name     string
+		resuming bool
+		lastSeq  uint64
+		expected []string
+	}{
+		{"after last received", true, 3, []string{"msg-4", "msg-5"}},
+		{"sent but unacknowledged", true, 1, []string{"msg-2", "msg-3", "msg-4", "msg-5"}},
+		{"not resuming", false, 0, []string{"msg-4", "msg-5"}},
+		{"from a forgotten numbering", true, 9, []string{"msg-4", "msg-5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub(createTestLogger())
+			hub.SetPendingLimit(10, OverflowDropOldest)
+			go hub.Run()
+			defer hub.Shutdown()
+
+			// Messages 1-3 are sent to a connected client, 4 and 5 buffered after it left
+			first, _, _ := createTestClient("resumed_session")
+			first.hub = hub
+			hub.RegisterClient(first)
+			require.Eventually(t, func() bool { return hub.HasSession("resumed_session") }, time.Second, time.Millisecond)
+			for i := 1; i <= 3; i++ {
+				hub.SendToSession("resumed_session", []byte(fmt.Sprintf("msg-%d", i)))
+			}
+			hub.UnregisterClient(first)
+			require.Eventually(t, func() bool { return !hub.HasSession("resumed_session") }, time.Second, time.Millisecond)
+			for i := 4; i <= 5; i++ {
+				hub.SendToSession("resumed_session", []byte(fmt.Sprintf("msg-%d", i)))
+			}
+
+			second, _, _ := createTestClient("resumed_session")
+			second.hub = hub
+			second.resumeAfter = tt.lastSeq
+			second.resuming = tt.resuming
+			hub.RegisterClient(second)
+			require.Eventually(t, func() bool { return hub.HasSession("resumed_session") }, time.Second, time.Millisecond)
+
+			var delivered []string
+			for len(delivered) < len(tt.expected) {
+				select {
+				case msg := <-second.send:
+					if tt.resuming {
+						delivered = append(delivered, unwrapSequenced(t, msg))
+					} else {
+						delivered = append(delivered, string(msg))
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("expected replayed messages, got %v", delivered)
+				}
+			}
+			assert.Equal(t, tt.expected, delivered)
+			assert.Empty(t, second.send, "acknowledged messages must not be replayed")
+
+			// Numbering continues on the new connection
+			hub.SendToSession("resumed_session", []byte(`{"live":true}`))
+			select {
+			case msg := <-second.send:
+				if tt.resuming {
+					assert.JSONEq(t, `{"seq":6,"message":{"live":true}}`, string(msg))
+				} else {
+					assert.JSONEq(t, `{"live":true}`, string(msg))
+				}
+			case <-time.After(time.Second):
+				t.Fatal("expected the live message")
+			}
+		})
+	}
+}
+
+// unwrapSequenced returns the text message carried in a sequence envelope.
+func unwrapSequenced(t *testing.T, message []byte) string {
+	t.Helper()
+	var envelope sequencedMessage
+	require.NoError(t, json.Unmarshal(message, &envelope))
+	var text string
+	require.NoError(t, json.Unmarshal(envelope.Message, &text))
+	return text
+}
+
+func TestWithSequence(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{"object", `{"n":1}`, `{"seq":7,"message":{"n":1}}`},
+		{"keeps the message's own seq", `{"seq":"mine"}`, `{"seq":7,"message":{"seq":"mine"}}`},
+		{"json-rpc notification", `{"jsonrpc":"2.0","method":"session.updated"}`, `{"seq":7,"message":{"jsonrpc":"2.0","method":"session.updated"}}`},
+		{"array", `[1,2]`, `{"seq":7,"message":[1,2]}`},
+		{"not json", `msg-1`, `{"seq":7,"message":"msg-1"}`},
+		{"invalid object", `{"n":`, `{"seq":7,"message":"{\"n\":"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, string(withSequence([]byte(tt.message), 7)))
+		})
+	}
+}
+
+func TestHubPrunesExpiredPending(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	hub.SetPendingLimit(10, OverflowDropOldest)
+	hub.SetPendingMaxAge(time.Minute)
+
+	now := time.Now()
+	hub.now = func() time.Time { return now }
+
+	hub.SendToSession("idle_session", []byte("stale"))
+	hub.PruneExpiredPending()
+	assert.Equal(t, 1, hub.PendingCount("idle_session"))
+
+	now = now.Add(2 * time.Minute)
+	hub.PruneExpiredPending()
+	assert.Equal(t, 0, hub.PendingCount("idle_session"))
+	assert.Equal(t, uint64(1), hub.PendingExpired())
+}
+
+func TestHubPrunesIdlePendingWithoutMaxAge(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	hub.SetPendingLimit(10, OverflowDropOldest)
+	assert.Equal(t, DefaultPendingIdleAge/2, hub.pendingPruneInterval(), "buffers should be pruned without a max age")
+
+	now := time.Now()
+	hub.now = func() time.Time { return now }
+
+	// A client that unregisters leaves a buffer holding what it was sent
+	client, _, _ := createTestClient("idle_session")
+	client.hub = hub
+	hub.registerClient(client)
+	hub.SendToSession("idle_session", []byte("delivered"))
+	hub.unregisterClient(client)
+	hub.SendToSession("idle_session", []byte("buffered"))
+	require.Equal(t, 2, hub.PendingCount("idle_session"))
+
+	now = now.Add(DefaultPendingIdleAge / 2)
+	hub.PruneExpiredPending()
+	assert.Equal(t, 2, hub.PendingCount("idle_session"), "messages should not age out without a max age")
+
+	now = now.Add(DefaultPendingIdleAge)
+	hub.PruneExpiredPending()
+	assert.Equal(t, 0, hub.PendingCount("idle_session"))
+	assert.Equal(t, uint64(0), hub.OutboundSequence("idle_session"), "the idle buffer should be freed")
+	assert.Equal(t, uint64(1), hub.PendingExpired(), "only the undelivered message should count as expired")
+}
+
+// TestHubSendToSessionDuringUnregister stresses SendToSession against a
+// concurrent unregistration of the same session; sending on the closed send
+// channel would panic.
+func TestHubSendToSessionDuringUnregister(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	go hub.Run()
+	defer hub.Shutdown()
+
+	for i := 0; i < 50; i++ {
+		client, _, _ := createTestClient("racy_session")
+		client.hub = hub
+		hub.RegisterClient(client)
+		require.Eventually(t, func() bool { return hub.HasSession("racy_session") }, time.Second, time.Millisecond)
+
+		// Senders keep going until the unregistration has been processed
+		var wg sync.WaitGroup
+		for sender := 0; sender < 4; sender++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000 && hub.HasSession("racy_session"); j++ {
+					hub.SendToSession("racy_session", []byte("message"))
+					if j%32 == 0 {
+						// Keep the send buffer from filling up
+						for len(client.send) > 0 {
+							<-client.send
+						}
+					}
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Widen the window between the write pump closing the send
+			// channel and the hub processing the unregistration
+			client.closeSend()
+			time.Sleep(time.Millisecond)
+			hub.UnregisterClient(client)
+		}()
+		wg.Wait()
+
+		require.Eventually(t, func() bool { return !hub.HasSession("racy_session") }, time.Second, time.Millisecond)
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	policy, err := ParseOverflowPolicy("Drop-Newest")
+	require.NoError(t, err)
+	assert.Equal(t, OverflowDropNewest, policy)
+	assert.Equal(t, "drop-newest", policy.String())
+
+	policy, err = ParseOverflowPolicy("drop-oldest")
+	require.NoError(t, err)
+	assert.Equal(t, OverflowDropOldest, policy)
+
+	_, err = ParseOverflowPolicy("keep-all")
+	assert.Error(t, err)
+}
+
+func TestHubLoadStats(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	hub.SetMaxConnections(10)
+	go hub.Run()
+
+	idle, _, _ := createTestClient("idle_session")
+	idle.hub = hub
+	busy, _, _ := createTestClient("busy_session")
+	busy.hub = hub
+	hub.RegisterClient(idle)
+	hub.RegisterClient(busy)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	for i := 0; i < cap(busy.send)*3/4; i++ {
+		busy.send <- []byte("queued")
+	}
+
+	assert.Equal(t, LoadStats{Clients: 2, MaxConnections: 10, SaturatedClients: 1}, hub.LoadStats())
+	assert.False(t, hub.ShuttingDown())
+
+	hub.UnregisterClient(idle)
+	hub.UnregisterClient(busy)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 0 }, time.Second, 10*time.Millisecond)
+	hub.Shutdown()
+	assert.True(t, hub.ShuttingDown())
+}
+
+func TestAllowOrigins(t *testing.T) {
+	check := AllowOrigins([]string{"https://app.example.com/"})
+	request := func(origin string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+
+	assert.True(t, check(request("https://app.example.com")))
+	assert.True(t, check(request("HTTPS://App.Example.com")))
+	assert.False(t, check(request("https://evil.example.com")))
+	assert.True(t, check(request("")), "non-browser clients send no Origin")
+	assert.True(t, AllowOrigins([]string{"*"})(request("https://evil.example.com")))
+
+	hub := NewHub(createTestLogger())
+	assert.True(t, hub.CheckOrigin(request("https://evil.example.com")), "hub accepts any origin by default")
+	hub.SetOriginChecker(check)
+	assert.False(t, hub.CheckOrigin(request("https://evil.example.com")))
+}
+
+func TestHubNotificationDedupWindow(t *testing.T) {
+	client, _, hub := createTestClient("dedup_session")
+	hub.SetNotificationDedupWindow(100 * time.Millisecond)
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.RegisterClient(client)
+	require.Eventually(t, func() bool { return hub.HasSession("dedup_session") }, time.Second, 10*time.Millisecond)
+
+	notify := func(score int) {
+		notification, err := jsonrpc.NewNotification("game.state", map[string]int{"score": score})
+		require.NoError(t, err)
+		require.NoError(t, hub.SendNotification("dedup_session", notification))
+	}
+
+	for i := 0; i < 5; i++ {
+		notify(1)
+	}
+	assert.Len(t, client.send, 1, "identical notifications within the window should be delivered once")
+	assert.Equal(t, uint64(4), hub.SuppressedNotifications())
+
+	notify(2)
+	assert.Len(t, client.send, 2, "a different payload is not a duplicate")
+
+	time.Sleep(150 * time.Millisecond)
+	notify(1)
+	assert.Len(t, client.send, 3, "a repeat after the window should be delivered")
+
+	// A deleted session's history is dropped
+	hub.ForgetSession("dedup_session")
+	hub.dedupMu.Lock()
+	_, kept := hub.recentNotifications["dedup_session"]
+	hub.dedupMu.Unlock()
+	assert.False(t, kept, "ForgetSession should drop the session's dedup history")
+	notify(1)
+	assert.Len(t, client.send, 4, "a forgotten session's history no longer suppresses")
+}
+
+func TestHubNotificationDedupDisabledByDefault(t *testing.T) {
+	client, _, hub := createTestClient("no_dedup_session")
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.RegisterClient(client)
+	require.Eventually(t, func() bool { return hub.HasSession("no_dedup_session") }, time.Second, 10*time.Millisecond)
+
+	notification, err := jsonrpc.NewNotification("game.state", map[string]int{"score": 1})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, hub.SendNotification("no_dedup_session", notification))
+	}
+	assert.Len(t, client.send, 3)
+	assert.Zero(t, hub.SuppressedNotifications())
+}
+
+// TestHubEventSubscriptions tests that subscribed clients only receive the
+// notification event types they opted into, while others receive everything.
+func TestHubEventSubscriptions(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	go hub.Run()
+	defer hub.Shutdown()
+
+	subscriber, _, _ := createTestClient("subscriber_session")
+	everything, _, _ := createTestClient("everything_session")
+	subscriber.hub = hub
+	everything.hub = hub
+
+	// Unregistered clients cannot subscribe
+	_, err := hub.Subscribe(subscriber, []string{"presence"})
+	assert.Error(t, err)
+
+	hub.RegisterClient(subscriber)
+	hub.RegisterClient(everything)
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	subscriptions, err := hub.Subscribe(subscriber, []string{"session.updated", "presence"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"presence", "session.updated"}, subscriptions)
+	_, err = hub.Subscribe(subscriber, []string{""})
+	assert.Error(t, err)
+
+	notify := func(method string) {
+		notification, err := jsonrpc.NewNotification(method, map[string]string{"event": method})
+		require.NoError(t, err)
+		require.NoError(t, hub.SendNotification("subscriber_session", notification))
+		require.NoError(t, hub.BroadcastNotification(notification))
+	}
+
+	// Broadcasts go through the Run loop, so wait for the client receiving
+	// every one of them before checking the subscriber
+	notify("presence")
+	notify("game.state")
+	require.Eventually(t, func() bool { return len(everything.send) == 2 }, time.Second, 10*time.Millisecond,
+		"unsubscribed clients should get every broadcast")
+	assert.Len(t, subscriber.send, 2, "subscriber should get presence directly and by broadcast only")
+
+	assert.Equal(t, []string{"session.updated"}, hub.Unsubscribe(subscriber, []string{"presence"}))
+	notify("presence")
+	require.Eventually(t, func() bool { return len(everything.send) == 3 }, time.Second, 10*time.Millisecond)
+	assert.Len(t, subscriber.send, 2, "unsubscribed event types should no longer be delivered")
+	assert.Equal(t, []string{"session.updated"}, hub.Subscriptions(subscriber))
+}
+
+// TestHubShutdownBroadcastPolicy tests that broadcasts queued when Shutdown
+// runs are flushed to clients or discarded according to the policy, and
+// that broadcasts after shutdown are dropped without panicking.
+func TestHubShutdownBroadcastPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        ShutdownBroadcastPolicy
+		wantDelivered int
+		wantDiscarded uint64
+	}{
+		{"flush", ShutdownFlushBroadcasts, 3, 2},
+		{"discard", ShutdownDiscardBroadcasts, 0, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _, hub := createTestClient("shutdown_session")
+			hub.SetShutdownBroadcastPolicy(tt.policy)
+
+			// Register directly and leave the Run loop stopped so broadcasts stay queued
+			hub.registerClient(client)
+			for i := 0; i < 3; i++ {
+				hub.BroadcastMessage([]byte(fmt.Sprintf("broadcast %d", i)))
+			}
+
+			hub.Shutdown()
+			assert.ErrorIs(t, hub.BroadcastMessage([]byte("too late")), ErrHubShuttingDown)
+			notification, err := jsonrpc.NewNotification("too.late", nil)
+			require.NoError(t, err)
+			assert.Error(t, hub.BroadcastNotification(notification), "notifications should be refused after shutdown")
+
+			delivered := 0
+			for range client.send {
+				delivered++
+			}
+			assert.Equal(t, tt.wantDelivered, delivered)
+			assert.Equal(t, tt.wantDiscarded, hub.DiscardedBroadcasts())
+		})
+	}
+}
+
+// TestHubShutdownClosesBroadcastQueue tests that broadcasts racing Shutdown
+// are either accepted and flushed or refused with an error, never queued
+// after the drain and lost.
+func TestHubShutdownClosesBroadcastQueue(t *testing.T) {
+	client, _, hub := createTestClient("racing_session")
+
+	// Leave the Run loop stopped so accepted broadcasts wait for the drain,
+	// and senders beyond the queue size block until Shutdown releases them
+	hub.registerClient(client)
+
+	const senders, perSender = 4, 20
+	var accepted atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perSender; j++ {
+				if err := hub.BroadcastMessage([]byte("racing")); err == nil {
+					accepted.Add(1)
+				} else {
+					assert.ErrorIs(t, err, ErrHubShuttingDown)
+				}
+			}
+		}()
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	hub.Shutdown()
+	wg.Wait()
+
+	delivered := 0
+	for range client.send {
+		delivered++
+	}
+	assert.Equal(t, int(accepted.Load()), delivered, "every accepted broadcast should be flushed")
+	assert.Equal(t, uint64(senders*perSender-delivered), hub.DiscardedBroadcasts(),
+		"every refused broadcast should be counted")
+}
+
+// TestHubShutdownDuringBroadcasts tests that shutting down while broadcasts
+// are being sent does not panic on closed client channels.
+func TestHubShutdownDuringBroadcasts(t *testing.T) {
+	hub := NewHub(createTestLogger())
+	go hub.Run()
+
+	for i := 0; i < 5; i++ {
+		client, _, _ := createTestClient(fmt.Sprintf("busy_session_%d", i))
+		client.hub = hub
+		hub.RegisterClient(client)
+	}
+	require.Eventually(t, func() bool { return hub.GetClientCount() == 5 }, time.Second, 10*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				hub.BroadcastMessage([]byte("burst"))
+			}
+		}()
+	}
+
+	time.Sleep(time.Millisecond)
+	hub.Shutdown()
+	wg.Wait()
+}
+
+func TestParseShutdownBroadcastPolicy(t *testing.T) {
+	policy, err := ParseShutdownBroadcastPolicy("Discard")
+	require.NoError(t, err)
+	assert.Equal(t, ShutdownDiscardBroadcasts, policy)
+
+	_, err = ParseShutdownBroadcastPolicy("later")
+	assert.Error(t, err)
+}
+
+// TestListConnectionsWhilePumpsRun reads ListConnections repeatedly while a
+// client sends requests, receives responses and answers pings; run with
+// -race to check the snapshot does not race the pumps.
+func TestListConnectionsWhilePumpsRun(t *testing.T) {
+	logger := createTestLogger()
+	hub := NewHub(logger)
+	hub.pingPeriod = 5 * time.Millisecond
+	router := createTestRouter()
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWS(hub, w, r, "list_test", logger, router)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	const requests = 50
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < requests; i++ {
+			request := fmt.Sprintf(`{"jsonrpc":"2.0","method":"test.echo","params":"%d","id":%d}`, i, i)
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(request)); err != nil {
+				break
+			}
+			if _, _, err := conn.ReadMessage(); err != nil {
+				break
+			}
+		}
+		close(done)
+
+		// Keep reading, which answers the server's pings, until closed
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for listing := true; listing; {
+		select {
+		case <-done:
+			listing = false
+		default:
+			hub.ListConnections()
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		connections := hub.ListConnections()
+		return len(connections) == 1 &&
+			connections[0].MessagesReceived == requests &&
+			!connections[0].LastPong.IsZero()
+	}, time.Second, 5*time.Millisecond)
+
+	last := hub.ListConnections()[0]
+	assert.Equal(t, "list_test", last.SessionCode)
+	assert.False(t, last.ConnectedAt.IsZero(), "ConnectedAt should be set on registration")
+	assert.GreaterOrEqual(t, last.MessagesSent, uint64(1), "Responses should be counted as sent")
+}
+
+// TestHubDrain tests that Drain notifies clients and waits for held and
+// queued work, giving up when its context ends.
+func TestHubDrain(t *testing.T) {
+	client, mockConn, hub := createTestClient("drain_session")
+	hub.registerClient(client)
+	go client.writePump()
+	defer hub.Shutdown()
+
+	release := client.Hold()
+	drained := make(chan error, 1)
+	go func() { drained <- hub.Drain(context.Background(), []byte("shutting down")) }()
+
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain should wait for the hold, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	client.Send([]byte("last words"))
+	release()
+	release()
+
+	select {
+	case err := <-drained:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Drain should return once the client is idle")
+	}
+	assert.Equal(t, [][]byte{[]byte("shutting down"), []byte("last words")}, mockConn.getMessages())
+
+	// A client that stays busy is given up on at the deadline
+	client.Hold()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, hub.Drain(ctx, nil), context.DeadlineExceeded)
+}
@@ -0,0 +1,89 @@
+package websocket
+
+import "sync/atomic"
+
+// MessageSizeStats summarizes the sizes of messages in one direction.
+type MessageSizeStats struct {
+	// Count is the number of messages
+	Count uint64 `json:"count"`
+
+	// TotalBytes is the combined size of the messages
+	TotalBytes uint64 `json:"total_bytes"`
+
+	// MaxBytes is the size of the largest message
+	MaxBytes uint64 `json:"max_bytes"`
+}
+
+// HubMetrics is a snapshot of the hub's connection gauge and counters.
+type HubMetrics struct {
+	// Connections is the number of registered clients
+	Connections int `json:"connections"`
+
+	// QueuedBroadcasts is the number of broadcasts waiting for the Run loop
+	QueuedBroadcasts int `json:"queued_broadcasts"`
+
+	TotalConnections        uint64 `json:"total_connections"`
+	TotalDisconnections     uint64 `json:"total_disconnections"`
+	WriteFailures           uint64 `json:"write_failures"`
+	ToleratedBacklogs       uint64 `json:"tolerated_backlogs"`
+	DroppedMessages         uint64 `json:"dropped_messages"`
+	PendingDropped          uint64 `json:"pending_dropped"`
+	PendingExpired          uint64 `json:"pending_expired"`
+	DiscardedBroadcasts     uint64 `json:"discarded_broadcasts"`
+	RejectedBroadcasts      uint64 `json:"rejected_broadcasts"`
+	SuppressedNotifications uint64 `json:"suppressed_notifications"`
+
+	// Received covers messages read from clients, Sent messages written to them
+	Received MessageSizeStats `json:"received"`
+	Sent     MessageSizeStats `json:"sent"`
+}
+
+// messageSizeCounter accumulates MessageSizeStats without locking.
+type messageSizeCounter struct {
+	count atomic.Uint64
+	total atomic.Uint64
+	max   atomic.Uint64
+}
+
+// record counts one message of n bytes.
+func (c *messageSizeCounter) record(n int) {
+	size := uint64(n)
+	c.count.Add(1)
+	c.total.Add(size)
+	for {
+		old := c.max.Load()
+		if size <= old || c.max.CompareAndSwap(old, size) {
+			return
+		}
+	}
+}
+
+// snapshot returns the counts recorded so far.
+func (c *messageSizeCounter) snapshot() MessageSizeStats {
+	return MessageSizeStats{
+		Count:      c.count.Load(),
+		TotalBytes: c.total.Load(),
+		MaxBytes:   c.max.Load(),
+	}
+}
+
+// Metrics returns the current connection count along with the hub's
+// lifetime counters and message size statistics. This method is thread-safe.
+func (h *Hub) Metrics() HubMetrics {
+	return HubMetrics{
+		Connections:             h.GetClientCount(),
+		QueuedBroadcasts:        len(h.broadcast),
+		TotalConnections:        h.totalConnections.Load(),
+		TotalDisconnections:     h.totalDisconnections.Load(),
+		WriteFailures:           h.writeFailures.Load(),
+		ToleratedBacklogs:       h.toleratedBacklogs.Load(),
+		DroppedMessages:         h.droppedMessages.Load(),
+		PendingDropped:          h.pendingDropped.Load(),
+		PendingExpired:          h.pendingExpired.Load(),
+		DiscardedBroadcasts:     h.discardedBroadcasts.Load(),
+		RejectedBroadcasts:      h.rejectedBroadcasts.Load(),
+		SuppressedNotifications: h.SuppressedNotifications(),
+		Received:                h.receivedSizes.snapshot(),
+		Sent:                    h.sentSizes.snapshot(),
+	}
+}
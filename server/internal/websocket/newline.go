@@ -0,0 +1,22 @@
+package websocket
+
+import "bytes"
+
+// SetTrimTrailingNewline enables removing a single trailing newline ("\n" or
+// "\r\n") from inbound text messages before they are parsed. Some clients
+// echo back the newline the write pump once used to join messages. The
+// setting applies to clients connecting after the call.
+func (h *Hub) SetTrimTrailingNewline(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.trimTrailingNewline = enabled
+}
+
+// trimNewline returns message without a single trailing newline.
+func trimNewline(message []byte) []byte {
+	if trimmed, ok := bytes.CutSuffix(message, []byte("\n")); ok {
+		trimmed, _ = bytes.CutSuffix(trimmed, []byte("\r"))
+		return trimmed
+	}
+	return message
+}
@@ -0,0 +1,55 @@
+package websocket
+
+import "hash/fnv"
+
+// orderLockStripes is the number of locks session keys are hashed onto to
+// serialize their outbound messages.
+const orderLockStripes = 64
+
+// SetStrictOrdering enables or disables strict per-session FIFO delivery.
+// When enabled, which is the default, SendToSession and client registration
+// for the same session are serialized so that messages buffered while a
+// session was disconnected are always queued on the new connection before
+// any live message. Disabling it lets concurrent senders to one session
+// race a reconnect. Must be called before the hub starts running.
+func (h *Hub) SetStrictOrdering(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.strictOrdering = enabled
+}
+
+// OutboundSequence returns the sequence number of the last message sent or
+// buffered for a session through SendToSession, or zero if there is none.
+// With pending buffering enabled, numbering continues across the session's
+// connections; otherwise it restarts with each one. This method is
+// thread-safe.
+func (h *Hub) OutboundSequence(sessionCode string) uint64 {
+	key := h.sessionKey(sessionCode)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if client, ok := h.sessions[key]; ok {
+		return client.sequence.Load()
+	}
+	if buffer := h.pending[key]; buffer != nil {
+		return buffer.lastSeq
+	}
+	return 0
+}
+
+// lockSessionOrder acquires the ordering lock for a session key when strict
+// ordering is enabled and returns the function releasing it. It must be taken
+// before h.mu, and never held while blocking on the hub's channels.
+func (h *Hub) lockSessionOrder(key string) func() {
+	h.mu.RLock()
+	strict := h.strictOrdering
+	h.mu.RUnlock()
+	if !strict {
+		return func() {}
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	lock := &h.orderLocks[hash.Sum32()%orderLockStripes]
+	lock.Lock()
+	return lock.Unlock
+}
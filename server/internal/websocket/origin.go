@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"net/http"
+	"strings"
+)
+
+// OriginChecker decides whether a WebSocket upgrade request may proceed based
+// on its Origin header.
+type OriginChecker func(r *http.Request) bool
+
+// AllowAnyOrigin is an OriginChecker accepting every origin.
+func AllowAnyOrigin(r *http.Request) bool {
+	return true
+}
+
+// AllowOrigins returns an OriginChecker accepting only the given origins,
+// compared case-insensitively and ignoring a trailing slash. An entry of "*"
+// accepts any origin. Requests without an Origin header come from non-browser
+// clients, which are not subject to cross-origin restrictions, and are accepted.
+func AllowOrigins(origins []string) OriginChecker {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		if origin == "*" {
+			return AllowAnyOrigin
+		}
+		allowed[normalizeOrigin(origin)] = true
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || allowed[normalizeOrigin(origin)]
+	}
+}
+
+// normalizeOrigin lowercases an origin and strips a trailing slash.
+func normalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+}
+
+// SetOriginChecker sets the check applied to the Origin header of WebSocket
+// upgrade requests. The default accepts any origin. A nil checker restores
+// the default. This should be called before the hub starts accepting clients.
+func (h *Hub) SetOriginChecker(check OriginChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkOrigin = check
+}
+
+// CheckOrigin reports whether a WebSocket upgrade from the request's origin is
+// allowed. ServeWS applies it during the upgrade; callers may use it to reject
+// a request before doing any work on its behalf.
+// This method is thread-safe.
+func (h *Hub) CheckOrigin(r *http.Request) bool {
+	h.mu.RLock()
+	check := h.checkOrigin
+	h.mu.RUnlock()
+
+	if check == nil {
+		return true
+	}
+	return check(r)
+}
@@ -0,0 +1,335 @@
+package websocket
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// minPendingPruneInterval bounds how often the Run loop prunes aged pending
+// messages when the max age is very short.
+const minPendingPruneInterval = time.Second
+
+// DefaultPendingIdleAge is how long a pending buffer that receives no message
+// is kept when no pending max age is set, so that the buffers of sessions
+// that never reconnect are eventually freed.
+const DefaultPendingIdleAge = time.Hour
+
+// OverflowPolicy decides which message is discarded when a session's pending
+// message buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDropOldest discards the oldest buffered message to make room for the new one.
+	OverflowDropOldest OverflowPolicy = iota
+
+	// OverflowDropNewest keeps the buffer as is and discards the incoming message.
+	OverflowDropNewest
+)
+
+// String returns the configuration name of the policy.
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropNewest:
+		return "drop-newest"
+	default:
+		return "drop-oldest"
+	}
+}
+
+// ParseOverflowPolicy parses "drop-oldest" or "drop-newest" (case-insensitive).
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "drop-oldest":
+		return OverflowDropOldest, nil
+	case "drop-newest":
+		return OverflowDropNewest, nil
+	default:
+		return OverflowDropOldest, fmt.Errorf("unknown overflow policy %q", name)
+	}
+}
+
+// SetPendingLimit enables buffering of messages sent to sessions that have no
+// connected client, keeping at most limit messages per session and applying
+// policy when the buffer is full. Buffered messages are delivered, in order,
+// when a client registers for the session. The last limit messages delivered
+// to a connection are kept too, so that a client resuming from the last
+// sequence it received gets those it missed. A limit of zero or less disables
+// buffering, which is the default. Must be called before the hub starts running.
+func (h *Hub) SetPendingLimit(limit int, policy OverflowPolicy) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if limit < 0 {
+		limit = 0
+	}
+	h.pendingLimit = limit
+	h.pendingPolicy = policy
+}
+
+// SetPendingMaxAge discards buffered messages once they are older than maxAge:
+// aged messages are dropped before a reconnecting client receives the buffer
+// and pruned periodically by the Run loop. Zero or a negative value, the
+// default, keeps messages until they are delivered or displaced, or until
+// their buffer has received nothing for DefaultPendingIdleAge.
+// Must be called before the hub starts running.
+func (h *Hub) SetPendingMaxAge(maxAge time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	h.pendingMaxAge = maxAge
+}
+
+// SetSessionChecker registers a function reporting whether a session exists.
+// Messages sent to a session without a connected client are then only
+// buffered if it reports the session, so messages to unknown or expired codes
+// do not allocate buffers. Without a checker every session is buffered for.
+// The function is called without the hub's lock held.
+func (h *Hub) SetSessionChecker(exists func(sessionCode string) bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sessionExists = exists
+}
+
+// ForgetSession discards the pending message buffer and the notification
+// dedup history of a session that no longer exists, so nothing is replayed or
+// suppressed should its code be reused. It is meant as the session manager's
+// delete callback. This method is thread-safe.
+func (h *Hub) ForgetSession(sessionCode string) {
+	key := h.sessionKey(sessionCode)
+	h.mu.Lock()
+	delete(h.pending, key)
+	h.mu.Unlock()
+
+	h.dedupMu.Lock()
+	delete(h.recentNotifications, key)
+	h.dedupMu.Unlock()
+}
+
+// knownSession reports whether messages may be buffered for a session: true
+// without a session checker, and otherwise whatever the checker reports.
+func (h *Hub) knownSession(sessionCode string) bool {
+	h.mu.RLock()
+	exists := h.sessionExists
+	h.mu.RUnlock()
+	return exists == nil || exists(sessionCode)
+}
+
+// PendingExpired returns the number of buffered messages discarded because
+// they exceeded the pending max age. This method is thread-safe.
+func (h *Hub) PendingExpired() uint64 {
+	return h.pendingExpired.Load()
+}
+
+// PruneExpiredPending discards buffered messages older than the pending max
+// age, removing buffers left empty that have not been touched for as long,
+// which also forgets the session's sequence numbering. Without a max age,
+// whole buffers untouched for DefaultPendingIdleAge are removed instead. The
+// Run loop calls it periodically. This method is thread-safe.
+func (h *Hub) PruneExpiredPending() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	idleAge := h.pendingMaxAge
+	if idleAge <= 0 {
+		idleAge = DefaultPendingIdleAge
+	}
+	cutoff := now.Add(-idleAge)
+	for key, buffer := range h.pending {
+		h.expirePendingLocked(key, buffer, now)
+		if !buffer.touchedAt.Before(cutoff) {
+			continue
+		}
+		if len(buffer.messages) > 0 && h.pendingMaxAge > 0 {
+			continue
+		}
+		for _, message := range buffer.messages {
+			// Messages kept from the previous connection were delivered once
+			if message.seq > buffer.delivered {
+				h.pendingExpired.Add(1)
+			}
+		}
+		delete(h.pending, key)
+	}
+}
+
+// PendingCount returns the number of messages buffered for a session,
+// including those kept from its last connection for a resume.
+// This method is thread-safe.
+func (h *Hub) PendingCount(sessionCode string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if buffer := h.pending[h.sessionKey(sessionCode)]; buffer != nil {
+		return len(buffer.messages)
+	}
+	return 0
+}
+
+// PendingDropped returns the number of pending messages discarded because a
+// session's buffer was full or could not be delivered on registration.
+// This method is thread-safe.
+func (h *Hub) PendingDropped() uint64 {
+	return h.pendingDropped.Load()
+}
+
+// pendingMessage is a buffered message, as it was sent, with its session
+// sequence number.
+type pendingMessage struct {
+	seq      uint64
+	data     []byte
+	queuedAt time.Time
+}
+
+// pendingBuffer holds the messages buffered for one disconnected session.
+type pendingBuffer struct {
+	messages []pendingMessage
+
+	// lastSeq is the sequence number given to the most recently buffered
+	// message, including any the overflow policy discarded
+	lastSeq uint64
+
+	// delivered is the sequence number of the last message queued on the
+	// session's previous connection; messages up to it are only kept for
+	// a client resuming from an earlier sequence
+	delivered uint64
+
+	// touchedAt is when the buffer was created or last received a message
+	touchedAt time.Time
+}
+
+// bufferPendingLocked stores a message for a session without a connected client,
+// applying the overflow policy, and returns the message's sequence number.
+// Messages kept from the previous connection are discarded first to make
+// room. The caller must hold h.mu for writing.
+func (h *Hub) bufferPendingLocked(key string, message []byte) uint64 {
+	now := h.now()
+	buffer := h.pending[key]
+	if buffer == nil {
+		buffer = &pendingBuffer{}
+		h.pending[key] = buffer
+	}
+	buffer.lastSeq++
+	buffer.touchedAt = now
+
+	h.expirePendingLocked(key, buffer, now)
+	if len(buffer.messages) >= h.pendingLimit {
+		if buffer.messages[0].seq > buffer.delivered {
+			h.pendingDropped.Add(1)
+			if h.pendingPolicy == OverflowDropNewest {
+				return buffer.lastSeq
+			}
+		}
+		buffer.messages = buffer.messages[1:]
+	}
+	buffer.messages = append(buffer.messages, pendingMessage{
+		seq:      buffer.lastSeq,
+		data:     message,
+		queuedAt: now,
+	})
+	return buffer.lastSeq
+}
+
+// retainPendingLocked starts the pending buffer of a session whose client
+// is unregistering with the messages last delivered to it, so that numbering
+// continues and a resuming client can be sent those it missed. The caller
+// must hold h.mu for writing.
+func (h *Hub) retainPendingLocked(key string, client *Client) {
+	delivered := client.takeDelivered()
+	if h.pendingLimit <= 0 {
+		return
+	}
+	if _, exists := h.pending[key]; exists {
+		return
+	}
+
+	seq := client.sequence.Load()
+	h.pending[key] = &pendingBuffer{
+		messages:  delivered,
+		lastSeq:   seq,
+		delivered: seq,
+		touchedAt: h.now(),
+	}
+}
+
+// flushPendingLocked queues a session's buffered messages on a newly registered
+// client, which continues their sequence numbering. Messages the client has
+// already received are skipped: those up to its resume sequence, or, when
+// it is not resuming, those queued on an earlier connection. Running under
+// h.mu keeps them ahead of any live message sent to the client afterwards.
+// The caller must hold h.mu for writing.
+func (h *Hub) flushPendingLocked(key string, client *Client) {
+	buffer, ok := h.pending[key]
+	if !ok {
+		return
+	}
+	delete(h.pending, key)
+	client.sequence.Store(buffer.lastSeq)
+	h.expirePendingLocked(key, buffer, h.now())
+
+	messages := skipAcknowledged(buffer, client.resumeAfter, client.resuming)
+	if skipped := len(buffer.messages) - len(messages); skipped > 0 {
+		h.logger.Debug("skipping pending messages the client already received",
+			"sessionCode", client.SessionCode(),
+			"skippedMessages", skipped,
+			"lastSequence", client.resumeAfter)
+	}
+
+	// Replayed messages stay unacknowledged until the client resumes past them
+	for i, message := range messages {
+		if err := client.trySend(client.stamp(message.data, message.seq)); err != nil {
+			dropped := len(messages) - i
+			h.pendingDropped.Add(uint64(dropped))
+			h.logger.Warn("dropping pending messages on registration",
+				"sessionCode", client.SessionCode(),
+				"droppedMessages", dropped,
+				"firstDroppedSequence", message.seq,
+				"error", err)
+			return
+		}
+		client.retainDelivered(message, h.pendingLimit)
+	}
+}
+
+// expirePendingLocked drops the messages at the front of buffer that are older
+// than the pending max age. Messages are buffered in order, so the aged ones
+// always form a prefix. The caller must hold h.mu for writing.
+func (h *Hub) expirePendingLocked(key string, buffer *pendingBuffer, now time.Time) {
+	if h.pendingMaxAge <= 0 {
+		return
+	}
+
+	cutoff := now.Add(-h.pendingMaxAge)
+	expired := 0
+	for expired < len(buffer.messages) && buffer.messages[expired].queuedAt.Before(cutoff) {
+		expired++
+	}
+	if expired == 0 {
+		return
+	}
+
+	buffer.messages = buffer.messages[expired:]
+	h.pendingExpired.Add(uint64(expired))
+	h.logger.Debug("discarded aged pending messages",
+		"sessionCode", key,
+		"expiredMessages", expired,
+		"maxAge", h.pendingMaxAge)
+}
+
+// pendingPruneInterval returns how often the Run loop prunes aged pending
+// messages: half the max age, or of DefaultPendingIdleAge without one, but
+// not more often than minPendingPruneInterval. It returns zero when
+// buffering is disabled.
+func (h *Hub) pendingPruneInterval() time.Duration {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.pendingLimit <= 0 {
+		return 0
+	}
+	maxAge := h.pendingMaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultPendingIdleAge
+	}
+	return max(maxAge/2, minPendingPruneInterval)
+}
@@ -0,0 +1,55 @@
+package websocket
+
+// RekeySession moves the connections of the session oldCode to newCode,
+// along with any messages buffered for it and its notification dedup
+// history. Messages sent to newCode then reach the session's client and
+// messages sent to oldCode no longer do, and its clients report newCode from
+// SessionCode. It returns false, moving nothing, if the session has no
+// connected client or newCode already has one.
+func (h *Hub) RekeySession(oldCode, newCode string) bool {
+	oldKey, newKey := h.sessionKey(oldCode), h.sessionKey(newCode)
+	if oldKey == newKey {
+		return h.HasSession(oldCode)
+	}
+
+	// Hold off sends to the old code so none is queued mid-move
+	unlock := h.lockSessionOrder(oldKey)
+	defer unlock()
+
+	h.mu.Lock()
+	client, connected := h.sessions[oldKey]
+	if _, taken := h.sessions[newKey]; !connected || taken {
+		h.mu.Unlock()
+		return false
+	}
+	delete(h.sessions, oldKey)
+	h.sessions[newKey] = client
+	for c := range h.clients {
+		if h.sessionKey(c.SessionCode()) == oldKey {
+			c.setSessionCode(newCode)
+		}
+	}
+	if buffer, ok := h.pending[oldKey]; ok {
+		delete(h.pending, oldKey)
+		if _, exists := h.pending[newKey]; !exists {
+			h.pending[newKey] = buffer
+		}
+	}
+	h.mu.Unlock()
+
+	h.dedupMu.Lock()
+	if recent, ok := h.recentNotifications[oldKey]; ok {
+		delete(h.recentNotifications, oldKey)
+		h.recentNotifications[newKey] = recent
+	}
+	h.dedupMu.Unlock()
+
+	return true
+}
+
+// setSessionCode changes the client's session code.
+func (c *Client) setSessionCode(code string) {
+	c.sessionCodeMu.Lock()
+	defer c.sessionCodeMu.Unlock()
+	c.sessionCode = code
+}
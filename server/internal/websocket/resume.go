@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// resumeKey is the context key of the resume sequence.
+type resumeKey struct{}
+
+// WithResumeSequence returns a copy of ctx carrying the sequence number of
+// the last message a reconnecting client received, taken from the "seq" of
+// the envelopes SendToSession delivered to it, or zero on a first
+// connection. ServeWS reads it from the upgrade request's context so that
+// only the messages after it are replayed to the new connection. A client
+// connected this way opts in to resuming: its session messages are wrapped
+// in {"seq":N,"message":...} envelopes. Other clients receive them as sent.
+func WithResumeSequence(ctx context.Context, lastSeq uint64) context.Context {
+	return context.WithValue(ctx, resumeKey{}, lastSeq)
+}
+
+// resumeSequenceFromContext returns the resume sequence attached with
+// WithResumeSequence and whether one was attached.
+func resumeSequenceFromContext(ctx context.Context) (uint64, bool) {
+	lastSeq, ok := ctx.Value(resumeKey{}).(uint64)
+	return lastSeq, ok
+}
+
+// sequencedMessage is the envelope in which SendToSession delivers messages
+// to a client that connected with a resume sequence. Wrapping rather than
+// adding a member leaves the original message, such as a JSON-RPC
+// notification, untouched.
+type sequencedMessage struct {
+	Seq     uint64          `json:"seq"`
+	Message json.RawMessage `json:"message"`
+}
+
+// withSequence wraps message in a sequencedMessage numbered seq. A message
+// that is not valid JSON is carried as a JSON string.
+func withSequence(message []byte, seq uint64) []byte {
+	raw := json.RawMessage(message)
+	if !json.Valid(message) {
+		raw, _ = json.Marshal(string(message))
+	}
+
+	stamped, err := json.Marshal(sequencedMessage{Seq: seq, Message: raw})
+	if err != nil {
+		return message
+	}
+	return stamped
+}
+
+// stamp returns message as it is sent to the client: wrapped in its sequence
+// envelope if the client opted in to resuming, and unchanged otherwise.
+func (c *Client) stamp(message []byte, seq uint64) []byte {
+	if !c.resuming {
+		return message
+	}
+	return withSequence(message, seq)
+}
+
+// skipAcknowledged returns the buffered messages to replay to a registering
+// client. A resuming client is sent those after the last one it received.
+// Otherwise, or when lastSeq is beyond the buffer's numbering because the
+// hub has since forgotten the session, it is sent only the messages no
+// earlier connection was given.
+func skipAcknowledged(buffer *pendingBuffer, lastSeq uint64, resuming bool) []pendingMessage {
+	acknowledged := buffer.delivered
+	if resuming && lastSeq <= buffer.lastSeq {
+		acknowledged = lastSeq
+	}
+
+	messages := make([]pendingMessage, 0, len(buffer.messages))
+	for _, message := range buffer.messages {
+		if message.seq > acknowledged {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// retainDelivered keeps a message delivered to the client for replay should
+// it reconnect without having received it, holding at most limit messages.
+func (c *Client) retainDelivered(message pendingMessage, limit int) {
+	if limit <= 0 {
+		return
+	}
+	c.deliveredMu.Lock()
+	defer c.deliveredMu.Unlock()
+	if len(c.delivered) >= limit {
+		c.delivered = c.delivered[1:]
+	}
+	c.delivered = append(c.delivered, message)
+}
+
+// takeDelivered returns the messages retained by retainDelivered and forgets them.
+func (c *Client) takeDelivered() []pendingMessage {
+	c.deliveredMu.Lock()
+	defer c.deliveredMu.Unlock()
+	delivered := c.delivered
+	c.delivered = nil
+	return delivered
+}
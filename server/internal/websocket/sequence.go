@@ -0,0 +1,168 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fle/server/internal/jsonrpc"
+)
+
+// sequenceParam is the reserved params member in which clients may send a
+// per-connection request sequence number.
+const sequenceParam = "_seq"
+
+// SetRequestSequencing enables validation of the reserved "_seq" params
+// member: when a request carries it, its value must be a non-negative
+// integer greater than the last one the connection sent, or the request is
+// rejected with an Invalid Request error (notifications are dropped). This
+// catches replayed or reordered requests. The requests of a batch are
+// checked one by one in order, and rejected ones are answered in the batch
+// response. Requests without "_seq" are not checked, and "_seq" is removed
+// from params before routing. The setting applies to clients connecting
+// after the call.
+func (h *Hub) SetRequestSequencing(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.requestSequencing = enabled
+}
+
+// checkRequestSequence validates the "_seq" members of a message's params
+// against the last sequence number seen on this connection, checking the
+// requests of a batch one by one in order. It returns the message to route,
+// without "_seq" and without the rejected requests, or nil if nothing is
+// left to route, along with the error responses for rejected batch
+// requests, which belong in the batch's response. A rejected single request
+// is answered, or dropped if it is a notification, before returning nil.
+// Requests that are not objects with object params are passed on unchanged
+// for the router to handle. It must only be called from readPump.
+func (c *Client) checkRequestSequence(message []byte) ([]byte, []*jsonrpc.Response) {
+	var batch []json.RawMessage
+	if json.Unmarshal(message, &batch) != nil {
+		checked, response := c.checkSequencedRequest(message)
+		if response != nil {
+			c.sendRejected(response)
+		}
+		return checked, nil
+	}
+
+	var rejected []*jsonrpc.Response
+	kept := batch[:0]
+	for _, element := range batch {
+		checked, response := c.checkSequencedRequest(element)
+		if checked != nil {
+			kept = append(kept, checked)
+		} else if response != nil {
+			rejected = append(rejected, response)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, rejected
+	}
+	message, err := json.Marshal(kept)
+	if err != nil {
+		return nil, rejected
+	}
+	return message, rejected
+}
+
+// checkSequencedRequest validates the "_seq" member of a single request. It
+// returns the request without "_seq", or nil and the error response if the
+// request was rejected; the response is nil for notifications, which are
+// dropped.
+func (c *Client) checkSequencedRequest(message []byte) ([]byte, *jsonrpc.Response) {
+	var request map[string]json.RawMessage
+	if json.Unmarshal(message, &request) != nil {
+		return message, nil
+	}
+	var params map[string]json.RawMessage
+	if json.Unmarshal(request["params"], &params) != nil {
+		return message, nil
+	}
+	raw, ok := params[sequenceParam]
+	if !ok {
+		return message, nil
+	}
+
+	var seq uint64
+	if err := json.Unmarshal(raw, &seq); err != nil {
+		return nil, c.rejectRequestSequence(request, fmt.Sprintf("%s must be a non-negative integer", sequenceParam))
+	}
+	if c.hasRequestSeq && seq <= c.lastRequestSeq {
+		return nil, c.rejectRequestSequence(request, fmt.Sprintf("%s %d does not follow %d", sequenceParam, seq, c.lastRequestSeq))
+	}
+	c.lastRequestSeq, c.hasRequestSeq = seq, true
+
+	delete(params, sequenceParam)
+	stripped, err := json.Marshal(params)
+	if err != nil {
+		return message, nil
+	}
+	request["params"] = stripped
+	if stripped, err = json.Marshal(request); err != nil {
+		return message, nil
+	}
+	return stripped, nil
+}
+
+// rejectRequestSequence logs a request whose sequence number was refused and
+// returns its error response, or nil if it is a notification.
+func (c *Client) rejectRequestSequence(request map[string]json.RawMessage, detail string) *jsonrpc.Response {
+	rawID, hasID := request["id"]
+	if !hasID {
+		c.logger.Warn("dropping notification with invalid sequence",
+			"sessionCode", c.SessionCode(),
+			"detail", detail)
+		return nil
+	}
+
+	var id interface{}
+	json.Unmarshal(rawID, &id)
+	c.logger.Warn("rejecting request with invalid sequence",
+		"sessionCode", c.SessionCode(),
+		"detail", detail)
+	rpcErr := jsonrpc.NewErrorWithData(jsonrpc.ErrInvalidRequest.Code, jsonrpc.ErrInvalidRequest.Message, detail)
+	return jsonrpc.NewErrorResponse(rpcErr, id)
+}
+
+// sendRejected sends the error response of a rejected request, or a batch of
+// them, to the client.
+func (c *Client) sendRejected(responses interface{}) {
+	encoded, err := json.Marshal(responses)
+	if err != nil {
+		c.logger.Error("failed to marshal JSON-RPC error response",
+			"sessionCode", c.SessionCode(),
+			"error", err)
+		return
+	}
+	if err := c.trySend(encoded); err != nil {
+		c.logger.Warn("dropping JSON-RPC error response",
+			"sessionCode", c.SessionCode(),
+			"error", err)
+	}
+}
+
+// appendBatchResponses adds responses to the JSON array the router returned
+// for a batch, or makes an array of them if the router returned nothing. A
+// response that is not an array, i.e. an error for the batch as a whole, is
+// returned unchanged.
+func appendBatchResponses(routed []byte, responses []*jsonrpc.Response) []byte {
+	var batch []json.RawMessage
+	if routed != nil && json.Unmarshal(routed, &batch) != nil {
+		return routed
+	}
+	for _, response := range responses {
+		encoded, err := json.Marshal(response)
+		if err != nil {
+			continue
+		}
+		batch = append(batch, encoded)
+	}
+	if len(batch) == 0 {
+		return routed
+	}
+	combined, err := json.Marshal(batch)
+	if err != nil {
+		return routed
+	}
+	return combined
+}
@@ -0,0 +1,164 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBroadcastQueueSize is how many broadcasts may wait for the Run loop
+// unless changed with SetBroadcastQueueSize.
+const DefaultBroadcastQueueSize = 64
+
+// ErrHubShuttingDown is returned by BroadcastMessage and BroadcastNotification
+// once Shutdown has begun.
+var ErrHubShuttingDown = errors.New("hub is shutting down")
+
+// drainPollInterval is how often Drain checks whether clients are idle.
+const drainPollInterval = 10 * time.Millisecond
+
+// queuedBroadcast is a message waiting in the broadcast queue. A notification
+// carries its event type so only clients that want it receive it; an empty
+// event type reaches every client.
+type queuedBroadcast struct {
+	message   []byte
+	eventType string
+}
+
+// ShutdownBroadcastPolicy decides what Shutdown does with broadcasts still
+// queued for the Run loop.
+type ShutdownBroadcastPolicy int
+
+const (
+	// ShutdownFlushBroadcasts delivers queued broadcasts to connected clients
+	// before they are closed.
+	ShutdownFlushBroadcasts ShutdownBroadcastPolicy = iota
+
+	// ShutdownDiscardBroadcasts drops queued broadcasts.
+	ShutdownDiscardBroadcasts
+)
+
+// String returns the configuration name of the policy.
+func (p ShutdownBroadcastPolicy) String() string {
+	switch p {
+	case ShutdownDiscardBroadcasts:
+		return "discard"
+	default:
+		return "flush"
+	}
+}
+
+// ParseShutdownBroadcastPolicy parses "flush" or "discard" (case-insensitive).
+func ParseShutdownBroadcastPolicy(name string) (ShutdownBroadcastPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "flush":
+		return ShutdownFlushBroadcasts, nil
+	case "discard":
+		return ShutdownDiscardBroadcasts, nil
+	default:
+		return ShutdownFlushBroadcasts, fmt.Errorf("unknown shutdown broadcast policy %q", name)
+	}
+}
+
+// SetShutdownBroadcastPolicy sets what Shutdown does with queued broadcasts.
+// The default is ShutdownFlushBroadcasts.
+func (h *Hub) SetShutdownBroadcastPolicy(policy ShutdownBroadcastPolicy) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shutdownBroadcastPolicy = policy
+}
+
+// DiscardedBroadcasts returns the number of broadcasts dropped because the
+// hub was shutting down. This method is thread-safe.
+func (h *Hub) DiscardedBroadcasts() uint64 {
+	return h.discardedBroadcasts.Load()
+}
+
+// drainBroadcasts empties the closed broadcast queue during shutdown,
+// delivering or discarding each message per the shutdown broadcast policy.
+// The caller must hold broadcastMu.
+func (h *Hub) drainBroadcasts() {
+	h.mu.RLock()
+	policy := h.shutdownBroadcastPolicy
+	h.mu.RUnlock()
+
+	flushed, discarded := 0, 0
+	for broadcast := range h.broadcast {
+		if policy == ShutdownFlushBroadcasts {
+			h.deliverBroadcast(broadcast)
+			flushed++
+		} else {
+			h.discardedBroadcasts.Add(1)
+			discarded++
+		}
+	}
+
+	if flushed > 0 || discarded > 0 {
+		h.logger.Info("queued broadcasts handled on shutdown",
+			"policy", policy.String(),
+			"flushed", flushed,
+			"discarded", discarded)
+	}
+}
+
+// Hold marks work in progress on the client's behalf, such as a message being
+// prepared for it, so that Drain waits for it. The returned function ends the
+// hold; calling it more than once has no further effect.
+func (c *Client) Hold() (release func()) {
+	c.pendingWork.Add(1)
+	return sync.OnceFunc(func() { c.pendingWork.Add(-1) })
+}
+
+// idle reports whether the client has no messages waiting to be processed or
+// written and no holds.
+func (c *Client) idle() bool {
+	return c.pendingWork.Load() == 0 && c.unwritten.Load() == 0
+}
+
+// Drain prepares the hub for Shutdown without cutting off work in flight. It
+// sends notice, if not nil, to every connected client, then waits until each
+// is idle: the requests it sent have been processed, the messages queued for
+// it have been written, and nothing holds it (see Client.Hold). Clients that
+// disconnect meanwhile are not waited for. Drain returns ctx's error if ctx is
+// done first. Clients are still served while draining, so new connections
+// should be refused before calling it.
+func (h *Hub) Drain(ctx context.Context, notice []byte) error {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	if notice != nil {
+		for _, client := range clients {
+			client.Send(notice)
+		}
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		busy := 0
+		for _, client := range clients {
+			if client.ctx.Err() == nil && !client.idle() {
+				busy++
+			}
+		}
+		if busy == 0 {
+			h.logger.Info("WebSocket clients drained", "clients", len(clients))
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			h.logger.Warn("WebSocket clients still busy after drain deadline",
+				"busyClients", busy)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
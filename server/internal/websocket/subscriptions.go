@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/fle/server/internal/jsonrpc"
+)
+
+// Subscribe opts a client into the given event types, identified by
+// notification method (e.g. "session.updated"). A client that has never
+// subscribed receives every notification; after its first Subscribe call it
+// only receives the event types it is subscribed to. It returns the client's
+// sorted subscriptions, or an error if an event type is empty or the client
+// is not registered.
+func (h *Hub) Subscribe(client *Client, eventTypes []string) ([]string, error) {
+	for _, eventType := range eventTypes {
+		if eventType == "" {
+			return nil, fmt.Errorf("event type cannot be empty")
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.clients[client] {
+		return nil, fmt.Errorf("client is not registered")
+	}
+
+	subscriptions := h.clientSubscriptions[client]
+	if subscriptions == nil {
+		subscriptions = make(map[string]bool)
+		h.clientSubscriptions[client] = subscriptions
+	}
+	for _, eventType := range eventTypes {
+		subscriptions[eventType] = true
+	}
+	return sortedKeys(subscriptions), nil
+}
+
+// Unsubscribe removes event types from a client's subscriptions and returns
+// the remaining ones, sorted. A client stays opted in even with no
+// subscriptions left, so it then receives no subscribable notifications.
+func (h *Hub) Unsubscribe(client *Client, eventTypes []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subscriptions := h.clientSubscriptions[client]
+	for _, eventType := range eventTypes {
+		delete(subscriptions, eventType)
+	}
+	return sortedKeys(subscriptions)
+}
+
+// Subscriptions returns the sorted event types a client is subscribed to.
+// This method is thread-safe and returns a copy.
+func (h *Hub) Subscriptions(client *Client) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return sortedKeys(h.clientSubscriptions[client])
+}
+
+// BroadcastNotification queues a JSON-RPC notification, like BroadcastMessage,
+// for every connected client that wants its event type. It returns an error
+// if the notification cannot be marshaled, or ErrHubShuttingDown once
+// Shutdown has begun. This method is thread-safe.
+func (h *Hub) BroadcastNotification(notification *jsonrpc.Request) error {
+	message, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	return h.enqueueBroadcast(queuedBroadcast{message: message, eventType: notification.Method})
+}
+
+// NotifyRooms sends a JSON-RPC notification with SendNotification to every
+// session with a connection in any of the given rooms, so only peers sharing
+// a room are told and each recipient's subscriptions and dedup window apply.
+// It returns an error if the notification cannot be marshaled.
+func (h *Hub) NotifyRooms(rooms []string, notification *jsonrpc.Request) error {
+	if len(rooms) == 0 {
+		return nil
+	}
+
+	h.mu.RLock()
+	var recipients []string
+	for client, joined := range h.clientRooms {
+		for _, room := range rooms {
+			if joined[room] {
+				recipients = append(recipients, client.SessionCode())
+				break
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, sessionCode := range recipients {
+		if err := h.SendNotification(sessionCode, notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionWantsEvent reports whether the client connected for a session wants
+// the event type. Sessions without a connected client are treated as wanting
+// it, so buffered delivery is unaffected.
+func (h *Hub) sessionWantsEvent(sessionCode, eventType string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	client, exists := h.sessions[h.sessionKey(sessionCode)]
+	return !exists || h.wantsEventLocked(client, eventType)
+}
+
+// wantsEventLocked reports whether a client receives the event type: clients
+// that never subscribed receive everything. The caller must hold h.mu.
+func (h *Hub) wantsEventLocked(client *Client, eventType string) bool {
+	subscriptions, optedIn := h.clientSubscriptions[client]
+	return !optedIn || subscriptions[eventType]
+}
+
+// sortedKeys returns the keys of set in sorted order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}